@@ -0,0 +1,222 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manipulate
+
+import (
+	"sync"
+	"time"
+
+	"go.aporeto.io/elemental"
+)
+
+// CircuitBreakerSettings holds the settings of a circuit breaker.
+type CircuitBreakerSettings struct {
+
+	// ErrorRatio is the ratio of failed operations, between 0 and 1,
+	// over which the circuit opens.
+	ErrorRatio float64
+
+	// MinOperations is the minimum number of operations in
+	// the current window before the ErrorRatio is evaluated.
+	MinOperations int
+
+	// Window is the duration over which the operations
+	// are counted while the circuit is closed.
+	Window time.Duration
+
+	// OpenDuration is the duration during which the circuit stays
+	// open before a probe operation is let through.
+	OpenDuration time.Duration
+}
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+// A circuitBreakerManipulator stops forwarding operations
+// to a manipulator that keeps failing.
+type circuitBreakerManipulator struct {
+	transactionForwarder
+
+	manipulator Manipulator
+	settings    CircuitBreakerSettings
+	now         func() time.Time
+
+	state       circuitState
+	windowStart time.Time
+	operations  int
+	failures    int
+	openedAt    time.Time
+	lock        sync.Mutex
+}
+
+// NewCircuitBreakerManipulator returns a TransactionalManipulator that wraps
+// the given manipulator and opens the circuit when the ratio of operations
+// failing with an error for which IsConnectionError returns true goes over the
+// ErrorRatio of the given settings. While the circuit is open, operations
+// immediately fail with an ErrCannotExecuteQuery. Once the OpenDuration has
+// elapsed, a single operation is let through to probe the manipulator: if it
+// succeeds the circuit closes, otherwise it opens again.
+//
+// Transactions are not guarded by the circuit: Commit and Abort always
+// reach the wrapped manipulator when it is a TransactionalManipulator,
+// and their failures are not counted.
+func NewCircuitBreakerManipulator(manipulator Manipulator, settings CircuitBreakerSettings) TransactionalManipulator {
+
+	if manipulator == nil {
+		panic("manipulator must not be nil")
+	}
+
+	if settings.ErrorRatio <= 0 || settings.ErrorRatio > 1 {
+		panic("error ratio must be greater than 0 and lower or equal to 1")
+	}
+
+	if settings.Window <= 0 {
+		panic("window must be greater than 0")
+	}
+
+	if settings.OpenDuration <= 0 {
+		panic("open duration must be greater than 0")
+	}
+
+	return &circuitBreakerManipulator{
+		transactionForwarder: transactionForwarder{m: manipulator},
+		manipulator:          manipulator,
+		settings:             settings,
+		now:                  time.Now,
+	}
+}
+
+func (m *circuitBreakerManipulator) RetrieveMany(mctx Context, dest elemental.Identifiables) error {
+	return m.do(func() error { return m.manipulator.RetrieveMany(mctx, dest) })
+}
+
+func (m *circuitBreakerManipulator) Retrieve(mctx Context, object elemental.Identifiable) error {
+	return m.do(func() error { return m.manipulator.Retrieve(mctx, object) })
+}
+
+func (m *circuitBreakerManipulator) Create(mctx Context, object elemental.Identifiable) error {
+	return m.do(func() error { return m.manipulator.Create(mctx, object) })
+}
+
+func (m *circuitBreakerManipulator) Update(mctx Context, object elemental.Identifiable) error {
+	return m.do(func() error { return m.manipulator.Update(mctx, object) })
+}
+
+func (m *circuitBreakerManipulator) Delete(mctx Context, object elemental.Identifiable) error {
+	return m.do(func() error { return m.manipulator.Delete(mctx, object) })
+}
+
+func (m *circuitBreakerManipulator) DeleteMany(mctx Context, identity elemental.Identity) error {
+	return m.do(func() error { return m.manipulator.DeleteMany(mctx, identity) })
+}
+
+func (m *circuitBreakerManipulator) Count(mctx Context, identity elemental.Identity) (int, error) {
+
+	var n int
+	err := m.do(func() (err error) {
+		n, err = m.manipulator.Count(mctx, identity)
+		return err
+	})
+
+	return n, err
+}
+
+func (m *circuitBreakerManipulator) do(operation func() error) error {
+
+	probe, ok := m.allow()
+	if !ok {
+		return NewErrCannotExecuteQuery("circuit open")
+	}
+
+	err := operation()
+
+	m.record(probe, IsConnectionError(err))
+
+	return err
+}
+
+// allow returns true if an operation can be forwarded,
+// and if this operation is the probe of a half open circuit.
+func (m *circuitBreakerManipulator) allow() (probe bool, ok bool) {
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	switch m.state {
+
+	case circuitOpen:
+
+		if m.now().Sub(m.openedAt) < m.settings.OpenDuration {
+			return false, false
+		}
+
+		m.state = circuitHalfOpen
+		return true, true
+
+	case circuitHalfOpen:
+		return false, false
+	}
+
+	return false, true
+}
+
+// record records the outcome of an operation and
+// updates the state of the circuit accordingly.
+func (m *circuitBreakerManipulator) record(probe bool, failed bool) {
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	now := m.now()
+
+	if probe {
+
+		if failed {
+			m.state = circuitOpen
+			m.openedAt = now
+			return
+		}
+
+		m.state = circuitClosed
+		m.resetWindow(now)
+		return
+	}
+
+	if m.state != circuitClosed {
+		return
+	}
+
+	if now.Sub(m.windowStart) >= m.settings.Window {
+		m.resetWindow(now)
+	}
+
+	m.operations++
+	if failed {
+		m.failures++
+	}
+
+	if m.operations >= m.settings.MinOperations && float64(m.failures)/float64(m.operations) >= m.settings.ErrorRatio {
+		m.state = circuitOpen
+		m.openedAt = now
+	}
+}
+
+func (m *circuitBreakerManipulator) resetWindow(now time.Time) {
+	m.windowStart = now
+	m.operations = 0
+	m.failures = 0
+}
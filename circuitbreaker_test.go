@@ -0,0 +1,116 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manipulate
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	testmodel "go.aporeto.io/elemental/test/model"
+)
+
+func TestNewCircuitBreakerManipulator(t *testing.T) {
+
+	settings := CircuitBreakerSettings{
+		ErrorRatio:    0.5,
+		MinOperations: 2,
+		Window:        time.Minute,
+		OpenDuration:  10 * time.Second,
+	}
+
+	Convey("Given I call NewCircuitBreakerManipulator with a nil manipulator", t, func() {
+		So(func() { NewCircuitBreakerManipulator(nil, settings) }, ShouldPanicWith, "manipulator must not be nil")
+	})
+
+	Convey("Given I call NewCircuitBreakerManipulator with invalid settings", t, func() {
+		r := &recordingManipulator{}
+		So(func() { NewCircuitBreakerManipulator(r, CircuitBreakerSettings{}) }, ShouldPanicWith, "error ratio must be greater than 0 and lower or equal to 1")
+		So(func() { NewCircuitBreakerManipulator(r, CircuitBreakerSettings{ErrorRatio: 1}) }, ShouldPanicWith, "window must be greater than 0")
+		So(func() { NewCircuitBreakerManipulator(r, CircuitBreakerSettings{ErrorRatio: 1, Window: 1}) }, ShouldPanicWith, "open duration must be greater than 0")
+	})
+
+	Convey("Given I have a circuit breaker manipulator", t, func() {
+
+		now := time.Now()
+		r := &recordingManipulator{}
+		m := NewCircuitBreakerManipulator(r, settings)
+		m.(*circuitBreakerManipulator).now = func() time.Time { return now }
+
+		Convey("When the operations fail with non connection errors", func() {
+
+			r.err = NewErrObjectNotFound("nope")
+			_ = m.Retrieve(nil, testmodel.NewList())
+			_ = m.Retrieve(nil, testmodel.NewList())
+			err := m.Retrieve(nil, testmodel.NewList())
+
+			Convey("Then the circuit should stay closed", func() {
+				So(IsObjectNotFoundError(err), ShouldBeTrue)
+				So(len(r.calls), ShouldEqual, 3)
+			})
+		})
+
+		Convey("When too many operations fail with connection errors", func() {
+
+			r.err = NewErrCannotCommunicate("down")
+			_ = m.Retrieve(nil, testmodel.NewList())
+			_ = m.Retrieve(nil, testmodel.NewList())
+			err := m.Retrieve(nil, testmodel.NewList())
+
+			Convey("Then the circuit should be open", func() {
+				So(IsCannotExecuteQueryError(err), ShouldBeTrue)
+				So(err.Error(), ShouldEqual, "Unable to execute query: circuit open")
+				So(len(r.calls), ShouldEqual, 2)
+			})
+
+			Convey("When the open duration has elapsed and the probe succeeds", func() {
+
+				r.err = nil
+				now = now.Add(11 * time.Second)
+				err1 := m.Retrieve(nil, testmodel.NewList())
+				err2 := m.Retrieve(nil, testmodel.NewList())
+
+				Convey("Then the circuit should be closed", func() {
+					So(err1, ShouldBeNil)
+					So(err2, ShouldBeNil)
+					So(len(r.calls), ShouldEqual, 4)
+				})
+			})
+
+			Convey("When the open duration has elapsed and the probe fails", func() {
+
+				now = now.Add(11 * time.Second)
+				err1 := m.Retrieve(nil, testmodel.NewList())
+				err2 := m.Retrieve(nil, testmodel.NewList())
+
+				Convey("Then the circuit should be open again", func() {
+					So(IsCannotCommunicateError(err1), ShouldBeTrue)
+					So(err2.Error(), ShouldEqual, "Unable to execute query: circuit open")
+					So(len(r.calls), ShouldEqual, 3)
+				})
+			})
+		})
+
+		Convey("When failures are spread over multiple windows", func() {
+
+			r.err = NewErrCannotCommunicate("down")
+			_ = m.Retrieve(nil, testmodel.NewList())
+			now = now.Add(2 * time.Minute)
+			err := m.Retrieve(nil, testmodel.NewList())
+
+			Convey("Then the circuit should stay closed", func() {
+				So(IsCannotCommunicateError(err), ShouldBeTrue)
+				So(len(r.calls), ShouldEqual, 2)
+			})
+		})
+	})
+}
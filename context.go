@@ -16,6 +16,7 @@ import (
 	"fmt"
 	"net/url"
 
+	opentracing "github.com/opentracing/opentracing-go"
 	"go.aporeto.io/elemental"
 )
 
@@ -24,6 +25,11 @@ import (
 type ReadConsistency string
 
 // Various values for Consistency
+//
+// ReadConsistencyStrong always reads from the primary. Setting it on a
+// single read with ContextOptionReadConsistency overrides the default of
+// the manipulator, so the read observes the writes previously acknowledged
+// by the primary, even if the manipulator reads from secondaries otherwise.
 const (
 	ReadConsistencyDefault   ReadConsistency = "default"
 	ReadConsistencyNearest   ReadConsistency = "nearest"
@@ -49,6 +55,12 @@ const (
 // to now the intended ID before actually creating the object.
 type FinalizerFunc func(o elemental.Identifiable) error
 
+// A PostReadHookFunc is the type of a function that is called with every object
+// returned by Retrieve and RetrieveMany, once it has been decoded. It can modify the
+// object, and returns false to remove it from the results. In that case, Retrieve
+// returns an ErrObjectNotFound. If it returns an error, the operation fails with it.
+type PostReadHookFunc func(o elemental.Identifiable) (bool, error)
+
 // A RetryFunc is a function that can be called during an
 // auto retry.
 // The current manipulate.Context is given, a Stringer interface containing,
@@ -74,8 +86,12 @@ type Context interface {
 	SetCount(count int)
 	Filter() *elemental.Filter
 	Finalizer() FinalizerFunc
+	UpdateFinalizer() FinalizerFunc
+	DeleteFinalizer() FinalizerFunc
+	PostReadHook() PostReadHookFunc
 	Version() int
 	TransactionID() TransactionID
+	TransactionLabel() string
 	Page() int
 	PageSize() int
 	After() string
@@ -90,6 +106,7 @@ type Context interface {
 	Parent() elemental.Identifiable
 	ExternalTrackingID() string
 	ExternalTrackingType() string
+	SpanContext() opentracing.SpanContext
 	Order() []string
 	Context() context.Context
 	Derive(...ContextOption) Context
@@ -99,6 +116,9 @@ type Context interface {
 	Messages() []string
 	SetMessages([]string)
 	ClientIP() string
+	Actor() string
+	NotFoundAsEmpty() bool
+	PreserveZeroValues() bool
 	RetryFunc() RetryFunc
 	RetryRatio() int64
 
@@ -106,10 +126,12 @@ type Context interface {
 }
 
 type mcontext struct {
+	actor                string
 	clientIP             string
 	countTotal           int
 	createFinalizer      FinalizerFunc
 	ctx                  context.Context
+	deleteFinalizer      FinalizerFunc
 	externalTrackingID   string
 	externalTrackingType string
 	fields               []string
@@ -124,14 +146,20 @@ type mcontext struct {
 	after                string
 	limit                int
 	next                 string
+	notFoundAsEmpty      bool
 	parameters           url.Values
 	parent               elemental.Identifiable
 	password             string
+	postReadHook         PostReadHookFunc
+	preserveZeroValues   bool
 	readConsistency      ReadConsistency
 	recursive            bool
 	retryFunc            RetryFunc
 	retryRatio           int64
+	spanContext          opentracing.SpanContext
 	transactionID        TransactionID
+	transactionLabel     string
+	updateFinalizer      FinalizerFunc
 	username             string
 	version              int
 	writeConsistency     WriteConsistency
@@ -182,9 +210,11 @@ func (c *mcontext) Derive(options ...ContextOption) Context {
 	}
 
 	copy := &mcontext{
+		actor:                c.actor,
 		clientIP:             c.clientIP,
 		createFinalizer:      c.createFinalizer,
 		ctx:                  c.ctx,
+		deleteFinalizer:      c.deleteFinalizer,
 		externalTrackingID:   c.externalTrackingID,
 		externalTrackingType: c.externalTrackingType,
 		fields:               append([]string{}, c.fields...),
@@ -196,14 +226,20 @@ func (c *mcontext) Derive(options ...ContextOption) Context {
 		pageSize:             c.pageSize,
 		after:                c.after,
 		limit:                c.limit,
+		notFoundAsEmpty:      c.notFoundAsEmpty,
 		parameters:           paramsCopy,
 		parent:               c.parent,
 		password:             c.password,
+		postReadHook:         c.postReadHook,
+		preserveZeroValues:   c.preserveZeroValues,
 		readConsistency:      c.readConsistency,
 		recursive:            c.recursive,
 		retryFunc:            c.retryFunc,
 		retryRatio:           c.retryRatio,
+		spanContext:          c.spanContext,
 		transactionID:        c.transactionID,
+		transactionLabel:     c.transactionLabel,
+		updateFinalizer:      c.updateFinalizer,
 		username:             c.username,
 		version:              c.version,
 		writeConsistency:     c.writeConsistency,
@@ -229,12 +265,24 @@ func (c *mcontext) Filter() *elemental.Filter { return c.filter }
 // Finalizer returns the finalizer.
 func (c *mcontext) Finalizer() FinalizerFunc { return c.createFinalizer }
 
+// UpdateFinalizer returns the update finalizer.
+func (c *mcontext) UpdateFinalizer() FinalizerFunc { return c.updateFinalizer }
+
+// DeleteFinalizer returns the delete finalizer.
+func (c *mcontext) DeleteFinalizer() FinalizerFunc { return c.deleteFinalizer }
+
+// PostReadHook returns the post read hook.
+func (c *mcontext) PostReadHook() PostReadHookFunc { return c.postReadHook }
+
 // Version returns the version.
 func (c *mcontext) Version() int { return c.version }
 
 // TransactionID returns the transactionID.
 func (c *mcontext) TransactionID() TransactionID { return c.transactionID }
 
+// TransactionLabel returns the human readable label of the transaction.
+func (c *mcontext) TransactionLabel() string { return c.transactionLabel }
+
 // Page returns the page number.
 func (c *mcontext) Page() int { return c.page }
 
@@ -274,6 +322,9 @@ func (c *mcontext) ExternalTrackingID() string { return c.externalTrackingID }
 // ExternalTrackingType returns the ExternalTrackingType.
 func (c *mcontext) ExternalTrackingType() string { return c.externalTrackingType }
 
+// SpanContext returns the parent opentracing SpanContext.
+func (c *mcontext) SpanContext() opentracing.SpanContext { return c.spanContext }
+
 // Order returns the Order.
 func (c *mcontext) Order() []string { return c.order }
 
@@ -309,6 +360,17 @@ func (c *mcontext) Credentials() (string, string) { return c.username, c.passwor
 // Headers returns the optional headers.
 func (c *mcontext) ClientIP() string { return c.clientIP }
 
+// Actor returns the identity of the caller performing the operation.
+func (c *mcontext) Actor() string { return c.actor }
+
+// NotFoundAsEmpty returns true if a Retrieve operation not finding
+// the object should succeed instead of returning an ErrObjectNotFound.
+func (c *mcontext) NotFoundAsEmpty() bool { return c.notFoundAsEmpty }
+
+// PreserveZeroValues returns true if the default values of the attributes
+// should not be backported on the zero values of the retrieved objects.
+func (c *mcontext) PreserveZeroValues() bool { return c.preserveZeroValues }
+
 // RetryRatio returns the context retry ratio.
 func (c *mcontext) RetryRatio() int64 { return c.retryRatio }
 
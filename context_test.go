@@ -16,6 +16,7 @@ import (
 	"net/url"
 	"testing"
 
+	opentracing "github.com/opentracing/opentracing-go"
 	. "github.com/smartystreets/goconvey/convey"
 	"go.aporeto.io/elemental"
 	testmodel "go.aporeto.io/elemental/test/model"
@@ -132,13 +133,18 @@ func TestContext_Derive(t *testing.T) {
 			filter:               elemental.NewFilterComposer().WithKey("k").Equals("v").Done(),
 			parameters:           url.Values{"a": []string{"b"}},
 			transactionID:        NewTransactionID(),
+			transactionLabel:     "label",
 			namespace:            "/",
 			recursive:            true,
 			overrideProtection:   true,
 			createFinalizer:      nil,
+			updateFinalizer:      nil,
+			deleteFinalizer:      nil,
+			postReadHook:         nil,
 			version:              4,
 			externalTrackingID:   "externalTrackingID",
 			externalTrackingType: "externalTrackingType",
+			spanContext:          opentracing.NoopTracer{}.StartSpan("test").Context(),
 			order:                []string{"a", "b"},
 			fields:               []string{"a", "b"},
 			ctx:                  context.Background(),
@@ -146,6 +152,9 @@ func TestContext_Derive(t *testing.T) {
 			writeConsistency:     WriteConsistencyStrong,
 			readConsistency:      ReadConsistencyMonotonic,
 			clientIP:             "1.1.1.1",
+			actor:                "bob",
+			notFoundAsEmpty:      true,
+			preserveZeroValues:   true,
 			retryRatio:           12,
 			opaque:               map[string]interface{}{"a": "b"},
 		}
@@ -170,12 +179,19 @@ func TestContext_Derive(t *testing.T) {
 				So(copy.Messages(), ShouldBeNil)
 
 				So(copy.ClientIP(), ShouldEqual, mctx.clientIP)
+				So(copy.Actor(), ShouldEqual, mctx.actor)
+				So(copy.NotFoundAsEmpty(), ShouldEqual, mctx.notFoundAsEmpty)
+				So(copy.PreserveZeroValues(), ShouldEqual, mctx.preserveZeroValues)
 				So(copy.ExternalTrackingID(), ShouldEqual, mctx.externalTrackingID)
 				So(copy.ExternalTrackingType(), ShouldEqual, mctx.externalTrackingType)
+				So(copy.SpanContext(), ShouldResemble, mctx.spanContext)
 				So(copy.Fields(), ShouldResemble, mctx.fields)
 				So(copy.Fields(), ShouldNotEqual, mctx.fields)
 				So(copy.Filter().String(), ShouldEqual, `k == "v"`)
 				So(copy.Finalizer(), ShouldEqual, mctx.createFinalizer)
+				So(copy.UpdateFinalizer(), ShouldEqual, mctx.updateFinalizer)
+				So(copy.DeleteFinalizer(), ShouldEqual, mctx.deleteFinalizer)
+				So(copy.PostReadHook(), ShouldEqual, mctx.postReadHook)
 				So(copy.Namespace(), ShouldEqual, mctx.namespace)
 				So(copy.Order(), ShouldResemble, mctx.order)
 				So(copy.Order(), ShouldNotEqual, mctx.order)
@@ -192,6 +208,7 @@ func TestContext_Derive(t *testing.T) {
 				So(copy.RetryFunc(), ShouldEqual, rfunc)
 				So(copy.String(), ShouldEqual, mctx.String())
 				So(copy.TransactionID(), ShouldEqual, mctx.transactionID)
+				So(copy.TransactionLabel(), ShouldEqual, mctx.transactionLabel)
 				So(copy.username, ShouldEqual, mctx.username)
 				So(copy.Version(), ShouldEqual, mctx.version)
 				So(copy.WriteConsistency(), ShouldEqual, mctx.writeConsistency)
@@ -220,11 +237,18 @@ func TestContext_Derive(t *testing.T) {
 				So(copy.Messages(), ShouldBeNil)
 
 				So(copy.ClientIP(), ShouldEqual, mctx.clientIP)
+				So(copy.Actor(), ShouldEqual, mctx.actor)
+				So(copy.NotFoundAsEmpty(), ShouldEqual, mctx.notFoundAsEmpty)
+				So(copy.PreserveZeroValues(), ShouldEqual, mctx.preserveZeroValues)
 				So(copy.ExternalTrackingID(), ShouldEqual, mctx.externalTrackingID)
 				So(copy.ExternalTrackingType(), ShouldEqual, mctx.externalTrackingType)
+				So(copy.SpanContext(), ShouldResemble, mctx.spanContext)
 				So(copy.Fields(), ShouldResemble, mctx.fields)
 				So(copy.Fields(), ShouldNotEqual, mctx.fields)
 				So(copy.Finalizer(), ShouldEqual, mctx.createFinalizer)
+				So(copy.UpdateFinalizer(), ShouldEqual, mctx.updateFinalizer)
+				So(copy.DeleteFinalizer(), ShouldEqual, mctx.deleteFinalizer)
+				So(copy.PostReadHook(), ShouldEqual, mctx.postReadHook)
 				So(copy.Namespace(), ShouldEqual, mctx.namespace)
 				So(copy.Order(), ShouldResemble, mctx.order)
 				So(copy.Order(), ShouldNotEqual, mctx.order)
@@ -237,6 +261,7 @@ func TestContext_Derive(t *testing.T) {
 				So(copy.Recursive(), ShouldEqual, mctx.recursive)
 				So(copy.RetryFunc(), ShouldEqual, rfunc)
 				So(copy.TransactionID(), ShouldEqual, mctx.transactionID)
+				So(copy.TransactionLabel(), ShouldEqual, mctx.transactionLabel)
 				So(copy.username, ShouldEqual, mctx.username)
 				So(copy.Version(), ShouldEqual, mctx.version)
 				So(copy.WriteConsistency(), ShouldEqual, mctx.writeConsistency)
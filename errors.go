@@ -11,6 +11,11 @@
 
 package manipulate
 
+import (
+	"sort"
+	"strings"
+)
+
 // ErrCannotUnmarshal represents unmarshaling error.
 type ErrCannotUnmarshal struct{ message string }
 
@@ -235,18 +240,130 @@ func IsTooManyRequestsError(err error) bool {
 	return ok
 }
 
+// ErrTooManyResults represents the error returned when a retrieve
+// operation returns more objects than the configured maximum.
+type ErrTooManyResults struct{ message string }
+
+// NewErrTooManyResults returns a new ErrTooManyResults.
+func NewErrTooManyResults(message string) ErrTooManyResults {
+	return ErrTooManyResults{message: message}
+}
+
+func (e ErrTooManyResults) Error() string { return "Too many results: " + e.message }
+
+// IsTooManyResultsError returns true if the given error is am ErrTooManyResults.
+func IsTooManyResultsError(err error) bool {
+	_, ok := err.(ErrTooManyResults)
+	return ok
+}
+
+// ErrIterationDeadlineExceeded represents the error returned when an
+// iteration is stopped because its overall deadline has been exceeded.
+type ErrIterationDeadlineExceeded struct{ message string }
+
+// NewErrIterationDeadlineExceeded returns a new ErrIterationDeadlineExceeded.
+func NewErrIterationDeadlineExceeded(message string) ErrIterationDeadlineExceeded {
+	return ErrIterationDeadlineExceeded{message: message}
+}
+
+func (e ErrIterationDeadlineExceeded) Error() string {
+	return "Iteration deadline exceeded: " + e.message
+}
+
+// IsIterationDeadlineExceededError returns true if the given error is am ErrIterationDeadlineExceeded.
+func IsIterationDeadlineExceededError(err error) bool {
+	_, ok := err.(ErrIterationDeadlineExceeded)
+	return ok
+}
+
 // ErrTLS represents the error returned when there is a TLS error.
-type ErrTLS struct{ message string }
+type ErrTLS struct {
+	message string
+	err     error
+}
 
 // NewErrTLS returns a new ErrTLS.
 func NewErrTLS(message string) ErrTLS {
 	return ErrTLS{message: message}
 }
 
+// NewErrTLSWithCause returns a new ErrTLS caused by the given error,
+// like a certificate verification error, that can be retrieved
+// using errors.Unwrap, errors.Is or errors.As.
+func NewErrTLSWithCause(message string, err error) ErrTLS {
+	return ErrTLS{message: message, err: err}
+}
+
 func (e ErrTLS) Error() string { return "TLS error: " + e.message }
 
+// Unwrap returns the error that caused the ErrTLS, if any.
+func (e ErrTLS) Unwrap() error { return e.err }
+
 // IsTLSError returns true if the given error is am ErrTLS.
 func IsTLSError(err error) bool {
 	_, ok := err.(ErrTLS)
 	return ok
 }
+
+// ErrValidation represents a failure to validate an object before writing it.
+type ErrValidation struct {
+	message string
+	details []error
+}
+
+// NewErrValidation returns a new ErrValidation with the given detailed errors.
+func NewErrValidation(message string, details ...error) ErrValidation {
+	return ErrValidation{message: message, details: details}
+}
+
+func (e ErrValidation) Error() string { return "Validation error: " + e.message }
+
+// Details returns the detailed validation errors, usually one per invalid attribute.
+func (e ErrValidation) Details() []error { return e.details }
+
+// IsValidationError returns true if the given error is am ErrValidation.
+func IsValidationError(err error) bool {
+	_, ok := err.(ErrValidation)
+	return ok
+}
+
+// ErrPartialRetrieve represents the failure to retrieve some of
+// the objects of a multi objects retrieve operation.
+type ErrPartialRetrieve struct{ errors map[string]error }
+
+// NewErrPartialRetrieve returns a new ErrPartialRetrieve holding the given
+// errors keyed by a reference to the failed objects, like "list/xyz".
+func NewErrPartialRetrieve(errors map[string]error) ErrPartialRetrieve {
+	return ErrPartialRetrieve{errors: errors}
+}
+
+func (e ErrPartialRetrieve) Error() string {
+
+	ids := make([]string, 0, len(e.errors))
+	for id := range e.errors {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	msgs := make([]string, len(ids))
+	for i, id := range ids {
+		msgs[i] = id + ": " + e.errors[id].Error()
+	}
+
+	return "Partial retrieve: " + strings.Join(msgs, ", ")
+}
+
+// Errors returns the errors keyed by a reference to the failed objects.
+func (e ErrPartialRetrieve) Errors() map[string]error { return e.errors }
+
+// IsPartialRetrieveError returns true if the given error is am ErrPartialRetrieve.
+func IsPartialRetrieveError(err error) bool {
+	_, ok := err.(ErrPartialRetrieve)
+	return ok
+}
+
+// IsConnectionError returns true if the given error is caused by
+// a failure to communicate with the backend.
+func IsConnectionError(err error) bool {
+	return IsCannotCommunicateError(err)
+}
@@ -12,6 +12,8 @@
 package manipulate
 
 import (
+	"errors"
+	"fmt"
 	"testing"
 
 	. "github.com/smartystreets/goconvey/convey"
@@ -135,10 +137,80 @@ func TestThing_Function(t *testing.T) {
 		IsTooManyRequestsError,
 	)
 
+	genericErrorTest(
+		t,
+		"Too many results: ",
+		func(text string) error { return NewErrTooManyResults(text) },
+		IsTooManyResultsError,
+	)
+
+	genericErrorTest(
+		t,
+		"Iteration deadline exceeded: ",
+		func(text string) error { return NewErrIterationDeadlineExceeded(text) },
+		IsIterationDeadlineExceededError,
+	)
+
 	genericErrorTest(
 		t,
 		"TLS error: ",
 		func(text string) error { return NewErrTLS(text) },
 		IsTLSError,
 	)
+
+	genericErrorTest(
+		t,
+		"Validation error: ",
+		func(text string) error { return NewErrValidation(text) },
+		IsValidationError,
+	)
+}
+
+func TestErrValidation_Details(t *testing.T) {
+
+	Convey("Given I have a validation error with details", t, func() {
+
+		d1 := fmt.Errorf("invalid name")
+		d2 := fmt.Errorf("invalid age")
+		err := NewErrValidation("invalid object", d1, d2)
+
+		Convey("Then Details should return the details", func() {
+			So(err.Details(), ShouldResemble, []error{d1, d2})
+		})
+	})
+}
+
+func TestErrTLS_Unwrap(t *testing.T) {
+
+	Convey("Given I have a TLS error with a cause", t, func() {
+
+		cause := fmt.Errorf("bad certificate")
+		err := NewErrTLSWithCause("handshake failed", cause)
+
+		Convey("Then it should unwrap to the cause", func() {
+			So(err.Error(), ShouldEqual, "TLS error: handshake failed")
+			So(errors.Unwrap(err), ShouldEqual, cause)
+			So(errors.Is(err, cause), ShouldBeTrue)
+			So(IsTLSError(err), ShouldBeTrue)
+		})
+	})
+
+	Convey("Given I have a TLS error without a cause", t, func() {
+		So(errors.Unwrap(NewErrTLS("boom")), ShouldBeNil)
+	})
+}
+
+func TestIsConnectionError(t *testing.T) {
+
+	Convey("Given I have a communication error", t, func() {
+		So(IsConnectionError(NewErrCannotCommunicate("boom")), ShouldBeTrue)
+	})
+
+	Convey("Given I have another error", t, func() {
+		So(IsConnectionError(NewErrCannotExecuteQuery("boom")), ShouldBeFalse)
+	})
+
+	Convey("Given I have a nil error", t, func() {
+		So(IsConnectionError(nil), ShouldBeFalse)
+	})
 }
@@ -0,0 +1,39 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package manipulate
+
+import (
+	"context"
+
+	"go.aporeto.io/elemental"
+)
+
+// Exists returns true if at least one object of the given identity
+// matches the filter of the given Context. If the given manipulator
+// is an ExistenceChecker, it is used to avoid counting all the matching
+// objects. Otherwise, Exists falls back on Count.
+func Exists(manipulator Manipulator, mctx Context, identity elemental.Identity) (bool, error) {
+
+	if mctx == nil {
+		mctx = NewContext(context.Background())
+	}
+
+	if c, ok := manipulator.(ExistenceChecker); ok {
+		return c.Exists(mctx, identity)
+	}
+
+	n, err := manipulator.Count(mctx, identity)
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
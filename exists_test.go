@@ -0,0 +1,77 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package manipulate
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.aporeto.io/elemental"
+	testmodel "go.aporeto.io/elemental/test/model"
+)
+
+type existenceManipulator struct {
+	recordingManipulator
+}
+
+func (m *existenceManipulator) Exists(mctx Context, identity elemental.Identity) (bool, error) {
+	m.calls = append(m.calls, "exists")
+	return m.err == nil, m.err
+}
+
+func TestExists(t *testing.T) {
+
+	Convey("Given I have a manipulator that is not an ExistenceChecker", t, func() {
+
+		m := &recordingManipulator{}
+
+		Convey("When I call Exists", func() {
+
+			ok, err := Exists(m, NewContext(context.Background()), testmodel.ListIdentity)
+
+			Convey("Then it should fall back on Count", func() {
+				So(err, ShouldBeNil)
+				So(ok, ShouldBeFalse)
+				So(m.calls, ShouldResemble, []string{"count"})
+			})
+		})
+
+		Convey("When I call Exists and Count fails", func() {
+
+			m.err = fmt.Errorf("boom")
+			ok, err := Exists(m, nil, testmodel.ListIdentity)
+
+			Convey("Then err should be returned", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldEqual, "boom")
+				So(ok, ShouldBeFalse)
+			})
+		})
+	})
+
+	Convey("Given I have a manipulator that is an ExistenceChecker", t, func() {
+
+		m := &existenceManipulator{}
+
+		Convey("When I call Exists", func() {
+
+			ok, err := Exists(m, nil, testmodel.ListIdentity)
+
+			Convey("Then it should use Exists", func() {
+				So(err, ShouldBeNil)
+				So(ok, ShouldBeTrue)
+				So(m.calls, ShouldResemble, []string{"exists"})
+			})
+		})
+	})
+}
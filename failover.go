@@ -0,0 +1,133 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manipulate
+
+import (
+	"sync/atomic"
+
+	"go.aporeto.io/elemental"
+)
+
+// A failoverManipulator sends operations to a list of
+// manipulators, falling through to the next one when
+// the current one cannot be reached during a read.
+type failoverManipulator struct {
+	manipulators []Manipulator
+	roundRobin   bool
+	next         uint32
+}
+
+// NewFailoverManipulator returns a Manipulator that sends all operations
+// to the given primary. If the primary returns an error for which
+// IsConnectionError returns true during a read (RetrieveMany, Retrieve
+// or Count), the read is attempted against the given secondaries, in order,
+// until one of them succeeds or returns an error that is not a connection
+// error. The last error is returned if none of the manipulators can be
+// reached.
+//
+// Writes are never attempted again, as they may have been applied before
+// the connection failed, and the backend may have modified the given
+// object while trying to write it.
+//
+// The returned manipulator is not transactional, as a transaction
+// cannot be carried from one backend to another.
+func NewFailoverManipulator(primary Manipulator, secondaries ...Manipulator) Manipulator {
+
+	if primary == nil {
+		panic("primary must not be nil")
+	}
+
+	return &failoverManipulator{
+		manipulators: append([]Manipulator{primary}, secondaries...),
+	}
+}
+
+// NewRoundRobinManipulator works like NewFailoverManipulator, but every
+// operation starts with the next manipulator of the list in order to
+// spread the load across all of them.
+func NewRoundRobinManipulator(manipulators ...Manipulator) Manipulator {
+
+	if len(manipulators) == 0 {
+		panic("you must pass at least one manipulator")
+	}
+
+	return &failoverManipulator{
+		manipulators: manipulators,
+		roundRobin:   true,
+	}
+}
+
+func (m *failoverManipulator) RetrieveMany(mctx Context, dest elemental.Identifiables) error {
+	return m.read(func(manipulator Manipulator) error { return manipulator.RetrieveMany(mctx, dest) })
+}
+
+func (m *failoverManipulator) Retrieve(mctx Context, object elemental.Identifiable) error {
+	return m.read(func(manipulator Manipulator) error { return manipulator.Retrieve(mctx, object) })
+}
+
+func (m *failoverManipulator) Create(mctx Context, object elemental.Identifiable) error {
+	return m.write(func(manipulator Manipulator) error { return manipulator.Create(mctx, object) })
+}
+
+func (m *failoverManipulator) Update(mctx Context, object elemental.Identifiable) error {
+	return m.write(func(manipulator Manipulator) error { return manipulator.Update(mctx, object) })
+}
+
+func (m *failoverManipulator) Delete(mctx Context, object elemental.Identifiable) error {
+	return m.write(func(manipulator Manipulator) error { return manipulator.Delete(mctx, object) })
+}
+
+func (m *failoverManipulator) DeleteMany(mctx Context, identity elemental.Identity) error {
+	return m.write(func(manipulator Manipulator) error { return manipulator.DeleteMany(mctx, identity) })
+}
+
+func (m *failoverManipulator) Count(mctx Context, identity elemental.Identity) (int, error) {
+
+	var n int
+	err := m.read(func(manipulator Manipulator) (err error) {
+		n, err = manipulator.Count(mctx, identity)
+		return err
+	})
+
+	return n, err
+}
+
+// read runs the given read operation, falling through
+// to the next manipulator on connection errors.
+func (m *failoverManipulator) read(operation func(Manipulator) error) error {
+
+	start := m.start()
+
+	var err error
+	for i := 0; i < len(m.manipulators); i++ {
+		if err = operation(m.manipulators[(start+i)%len(m.manipulators)]); err == nil || !IsConnectionError(err) {
+			return err
+		}
+	}
+
+	return err
+}
+
+// write runs the given write operation once.
+func (m *failoverManipulator) write(operation func(Manipulator) error) error {
+	return operation(m.manipulators[m.start()])
+}
+
+// start returns the index of the manipulator to send the next operation to.
+func (m *failoverManipulator) start() int {
+
+	if !m.roundRobin {
+		return 0
+	}
+
+	return int((atomic.AddUint32(&m.next, 1) - 1) % uint32(len(m.manipulators)))
+}
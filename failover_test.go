@@ -0,0 +1,147 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manipulate
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	testmodel "go.aporeto.io/elemental/test/model"
+)
+
+func TestNewFailoverManipulator(t *testing.T) {
+
+	Convey("Given I call NewFailoverManipulator with a nil primary", t, func() {
+		So(func() { NewFailoverManipulator(nil) }, ShouldPanicWith, "primary must not be nil")
+	})
+
+	Convey("Given I have a failover manipulator with a working primary", t, func() {
+
+		p := &recordingManipulator{}
+		s := &recordingManipulator{}
+		m := NewFailoverManipulator(p, s)
+
+		Convey("When I call Create", func() {
+
+			err := m.Create(nil, testmodel.NewList())
+
+			Convey("Then only the primary should have been called", func() {
+				So(err, ShouldBeNil)
+				So(p.calls, ShouldResemble, []string{"create"})
+				So(s.calls, ShouldBeNil)
+			})
+		})
+	})
+
+	Convey("Given I have a failover manipulator with an unreachable primary", t, func() {
+
+		p := &recordingManipulator{err: NewErrCannotCommunicate("down")}
+		s := &recordingManipulator{}
+		m := NewFailoverManipulator(p, s)
+
+		Convey("When I call all the methods", func() {
+
+			_ = m.RetrieveMany(nil, testmodel.ListsList{})
+			_ = m.Retrieve(nil, testmodel.NewList())
+			_ = m.Create(nil, testmodel.NewList())
+			_ = m.Update(nil, testmodel.NewList())
+			_ = m.Delete(nil, testmodel.NewList())
+			_ = m.DeleteMany(nil, testmodel.ListIdentity)
+			_, err := m.Count(nil, testmodel.ListIdentity)
+
+			Convey("Then only the reads should have been sent to the secondary", func() {
+				So(err, ShouldBeNil)
+				So(p.calls, ShouldResemble, []string{"retrievemany", "retrieve", "create", "update", "delete", "deletemany", "count"})
+				So(s.calls, ShouldResemble, []string{"retrievemany", "retrieve", "count"})
+			})
+		})
+
+		Convey("When I call Create", func() {
+
+			err := m.Create(nil, testmodel.NewList())
+
+			Convey("Then the connection error should be returned", func() {
+				So(err, ShouldResemble, NewErrCannotCommunicate("down"))
+			})
+		})
+	})
+
+	Convey("Given I have a failover manipulator with a primary returning a non connection error", t, func() {
+
+		p := &recordingManipulator{err: NewErrObjectNotFound("nope")}
+		s := &recordingManipulator{}
+		m := NewFailoverManipulator(p, s)
+
+		Convey("When I call Retrieve", func() {
+
+			err := m.Retrieve(nil, testmodel.NewList())
+
+			Convey("Then the error should be returned without failing over", func() {
+				So(err, ShouldHaveSameTypeAs, ErrObjectNotFound{})
+				So(s.calls, ShouldBeNil)
+			})
+		})
+	})
+
+	Convey("Given I have a failover manipulator where nothing is reachable", t, func() {
+
+		p := &recordingManipulator{err: NewErrCannotCommunicate("down 1")}
+		s := &recordingManipulator{err: NewErrCannotCommunicate("down 2")}
+		m := NewFailoverManipulator(p, s)
+
+		Convey("When I call Retrieve", func() {
+
+			err := m.Retrieve(nil, testmodel.NewList())
+
+			Convey("Then the last error should be returned", func() {
+				So(err, ShouldResemble, NewErrCannotCommunicate("down 2"))
+			})
+		})
+	})
+}
+
+func TestNewRoundRobinManipulator(t *testing.T) {
+
+	Convey("Given I call NewRoundRobinManipulator with no manipulator", t, func() {
+		So(func() { NewRoundRobinManipulator() }, ShouldPanicWith, "you must pass at least one manipulator")
+	})
+
+	Convey("Given I have a round robin manipulator", t, func() {
+
+		m1 := &recordingManipulator{}
+		m2 := &recordingManipulator{}
+		m := NewRoundRobinManipulator(m1, m2)
+
+		Convey("When I call Retrieve three times", func() {
+
+			_ = m.Retrieve(nil, testmodel.NewList())
+			_ = m.Retrieve(nil, testmodel.NewList())
+			_ = m.Retrieve(nil, testmodel.NewList())
+
+			Convey("Then the calls should have been spread", func() {
+				So(len(m1.calls), ShouldEqual, 2)
+				So(len(m2.calls), ShouldEqual, 1)
+			})
+		})
+
+		Convey("When the next manipulator is unreachable", func() {
+
+			m1.err = NewErrCannotCommunicate("down")
+			_ = m.Retrieve(nil, testmodel.NewList())
+
+			Convey("Then it should fail over to the other one", func() {
+				So(m1.calls, ShouldResemble, []string{"retrieve"})
+				So(m2.calls, ShouldResemble, []string{"retrieve"})
+			})
+		})
+	})
+}
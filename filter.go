@@ -52,3 +52,275 @@ func NewFilterParser(input string) *FilterParser {
 	fmt.Println("DEPRECATED: manipulate.NewFilterParser is deprecated and aliased to elemental.NewFilterParser")
 	return elemental.NewFilterParser(input)
 }
+
+// RangeBounds defines which bounds of a range are included.
+type RangeBounds int
+
+// Various values of RangeBounds.
+const (
+	// RangeBoundsInclusive includes both bounds: [from, to].
+	RangeBoundsInclusive RangeBounds = iota
+
+	// RangeBoundsExclusive excludes both bounds: (from, to).
+	RangeBoundsExclusive
+
+	// RangeBoundsLowerInclusive only includes the lower bound: [from, to).
+	RangeBoundsLowerInclusive
+
+	// RangeBoundsUpperInclusive only includes the upper bound: (from, to].
+	RangeBoundsUpperInclusive
+)
+
+// NewBetweenFilter returns a filter matching the objects where
+// the value of the given key is between from and to, with the given
+// bounds. For instance, an inclusive range on createTime will
+// compile to {createtime: {$gte: from}} and {createtime: {$lte: to}}
+// which can be resolved with a single index range scan.
+//
+// The returned filter can be combined with other filters using
+// the And or Or methods of a FilterKeyComposer.
+func NewBetweenFilter(key string, from interface{}, to interface{}, bounds RangeBounds) *Filter {
+
+	k := elemental.NewFilterComposer().WithKey(key)
+
+	var c elemental.FilterKeyComposer
+	switch bounds {
+	case RangeBoundsInclusive, RangeBoundsLowerInclusive:
+		c = k.GreaterOrEqualThan(from)
+	default:
+		c = k.GreaterThan(from)
+	}
+
+	switch bounds {
+	case RangeBoundsInclusive, RangeBoundsUpperInclusive:
+		c = c.WithKey(key).LesserOrEqualThan(to)
+	default:
+		c = c.WithKey(key).LesserThan(to)
+	}
+
+	return c.Done()
+}
+
+// An ElementMatch is a filter value matching the elements of
+// an array of sub objects. It is used as the single value of a Contains
+// comparison and must be created using NewElementMatchFilter.
+type ElementMatch struct {
+	Filter *Filter
+}
+
+// String returns the string representation of the ElementMatch.
+func (e ElementMatch) String() string {
+	return fmt.Sprintf("elemMatch(%s)", e.Filter)
+}
+
+// NewElementMatchFilter returns a filter matching the objects where at
+// least one element of the array stored in the given key matches all the
+// conditions of the given sub filter. The keys of the sub filter are
+// the attributes of the elements. For instance, matching the tags
+// having both name=x and value=y will compile to
+// {tags: {$elemMatch: {name: x, value: y}}}.
+//
+// The returned filter can be combined with other filters using
+// the And or Or methods of a FilterKeyComposer.
+func NewElementMatchFilter(key string, sub *Filter) *Filter {
+
+	if sub == nil {
+		panic("sub filter must not be nil")
+	}
+
+	return elemental.NewFilterComposer().WithKey(key).Contains(ElementMatch{Filter: sub}).Done()
+}
+
+// AndFilters returns a filter matching the objects matching all the
+// given filters. The nil filters are ignored. If there is no filter
+// left, it returns nil, and if there is only one, it returns it as is.
+func AndFilters(filters ...*Filter) *Filter {
+
+	fs := nonNilFilters(filters)
+
+	switch len(fs) {
+	case 0:
+		return nil
+	case 1:
+		return fs[0]
+	default:
+		return elemental.NewFilterComposer().And(fs...).Done()
+	}
+}
+
+// OrFilters returns a filter matching the objects matching at least one
+// of the given filters. The nil filters are ignored. If there is no filter
+// left, it returns nil, and if there is only one, it returns it as is.
+func OrFilters(filters ...*Filter) *Filter {
+
+	fs := nonNilFilters(filters)
+
+	switch len(fs) {
+	case 0:
+		return nil
+	case 1:
+		return fs[0]
+	default:
+		return elemental.NewFilterComposer().Or(fs...).Done()
+	}
+}
+
+func nonNilFilters(filters []*Filter) []*Filter {
+
+	out := make([]*Filter, 0, len(filters))
+	for _, f := range filters {
+		if f != nil {
+			out = append(out, f)
+		}
+	}
+
+	return out
+}
+
+// A FilterComparison is a single comparison of a filter,
+// given to a FilterWalkFunc so it can be inspected or rewritten.
+type FilterComparison struct {
+	Key        string
+	Comparator elemental.FilterComparator
+	Values     []interface{}
+}
+
+// A FilterWalkFunc is the type of the function called by WalkFilter for every
+// comparison of a filter. It can modify the given comparison, and the returned
+// filter will use the modified version. If it returns an error, the walk is
+// interrupted and the error is returned.
+type FilterWalkFunc func(c *FilterComparison) error
+
+// WalkFilter calls the given function with every comparison of the given filter,
+// including the ones of its And and Or sub filters, and returns a new filter made
+// of the comparisons as modified by the function. The given filter is never
+// modified. The sub filters of ElementMatch values are not walked, as their keys
+// are the attributes of the elements of an array.
+//
+// It can be used to inspect a filter, for instance to reject some comparators,
+// or to rewrite it, for instance to rename some keys.
+// If the given filter is nil, it returns nil.
+func WalkFilter(f *Filter, fn FilterWalkFunc) (*Filter, error) {
+
+	if f == nil {
+		return nil, nil
+	}
+
+	out := elemental.NewFilterComposer()
+
+	for i, operator := range f.Operators() {
+
+		switch operator {
+
+		case elemental.AndOperator:
+
+			c := &FilterComparison{
+				Key:        f.Keys()[i],
+				Comparator: f.Comparators()[i],
+				Values:     append([]interface{}{}, f.Values()[i]...),
+			}
+
+			if err := fn(c); err != nil {
+				return nil, err
+			}
+
+			var err error
+			if out, err = composeComparison(out.WithKey(c.Key), c); err != nil {
+				return nil, err
+			}
+
+		case elemental.AndFilterOperator:
+
+			subs, err := walkFilters(f.AndFilters()[i], fn)
+			if err != nil {
+				return nil, err
+			}
+			out = out.And(subs...)
+
+		case elemental.OrFilterOperator:
+
+			subs, err := walkFilters(f.OrFilters()[i], fn)
+			if err != nil {
+				return nil, err
+			}
+			out = out.Or(subs...)
+
+		default:
+			return nil, fmt.Errorf("unsupported filter operator '%v'", operator)
+		}
+	}
+
+	return out.Done(), nil
+}
+
+func walkFilters(filters []*Filter, fn FilterWalkFunc) ([]*Filter, error) {
+
+	out := make([]*Filter, len(filters))
+
+	for i, sub := range filters {
+
+		f, err := WalkFilter(sub, fn)
+		if err != nil {
+			return nil, err
+		}
+
+		out[i] = f
+	}
+
+	return out, nil
+}
+
+func composeComparison(k elemental.FilterValueComposer, c *FilterComparison) (elemental.FilterKeyComposer, error) {
+
+	switch c.Comparator {
+
+	case elemental.ExistsComparator:
+		return k.Exists(), nil
+
+	case elemental.NotExistsComparator:
+		return k.NotExists(), nil
+
+	case elemental.InComparator:
+		return k.In(c.Values...), nil
+
+	case elemental.NotInComparator:
+		return k.NotIn(c.Values...), nil
+
+	case elemental.ContainComparator:
+		return k.Contains(c.Values...), nil
+
+	case elemental.NotContainComparator:
+		return k.NotContains(c.Values...), nil
+
+	case elemental.MatchComparator:
+		return k.Matches(c.Values...), nil
+	}
+
+	if len(c.Values) != 1 {
+		return nil, fmt.Errorf("comparator '%v' of key '%s' requires a single value", c.Comparator, c.Key)
+	}
+
+	switch c.Comparator {
+
+	case elemental.EqualComparator:
+		return k.Equals(c.Values[0]), nil
+
+	case elemental.NotEqualComparator:
+		return k.NotEquals(c.Values[0]), nil
+
+	case elemental.GreaterComparator:
+		return k.GreaterThan(c.Values[0]), nil
+
+	case elemental.GreaterOrEqualComparator:
+		return k.GreaterOrEqualThan(c.Values[0]), nil
+
+	case elemental.LesserComparator:
+		return k.LesserThan(c.Values[0]), nil
+
+	case elemental.LesserOrEqualComparator:
+		return k.LesserOrEqualThan(c.Values[0]), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported filter comparator '%v'", c.Comparator)
+	}
+}
@@ -12,6 +12,7 @@
 package manipulate
 
 import (
+	"fmt"
 	"testing"
 
 	. "github.com/smartystreets/goconvey/convey"
@@ -41,3 +42,218 @@ func TestNewFilter(t *testing.T) {
 		So(f, ShouldHaveSameTypeAs, elemental.NewFilterParser("a == a"))
 	})
 }
+
+func TestNewBetweenFilter(t *testing.T) {
+
+	Convey("Given I call NewBetweenFilter with inclusive bounds", t, func() {
+
+		f := NewBetweenFilter("a", 1, 2, RangeBoundsInclusive)
+
+		Convey("Then the filter should be correct", func() {
+			So(f.Keys(), ShouldResemble, elemental.FilterKeys{"a", "a"})
+			So(f.Comparators(), ShouldResemble, elemental.FilterComparators{elemental.GreaterOrEqualComparator, elemental.LesserOrEqualComparator})
+			So(f.Values(), ShouldResemble, elemental.FilterValues{{1}, {2}})
+		})
+	})
+
+	Convey("Given I call NewBetweenFilter with exclusive bounds", t, func() {
+
+		f := NewBetweenFilter("a", 1, 2, RangeBoundsExclusive)
+
+		Convey("Then the filter should be correct", func() {
+			So(f.Comparators(), ShouldResemble, elemental.FilterComparators{elemental.GreaterComparator, elemental.LesserComparator})
+		})
+	})
+
+	Convey("Given I call NewBetweenFilter with lower inclusive bound", t, func() {
+
+		f := NewBetweenFilter("a", 1, 2, RangeBoundsLowerInclusive)
+
+		Convey("Then the filter should be correct", func() {
+			So(f.Comparators(), ShouldResemble, elemental.FilterComparators{elemental.GreaterOrEqualComparator, elemental.LesserComparator})
+		})
+	})
+
+	Convey("Given I call NewBetweenFilter with upper inclusive bound", t, func() {
+
+		f := NewBetweenFilter("a", 1, 2, RangeBoundsUpperInclusive)
+
+		Convey("Then the filter should be correct", func() {
+			So(f.Comparators(), ShouldResemble, elemental.FilterComparators{elemental.GreaterComparator, elemental.LesserOrEqualComparator})
+		})
+	})
+}
+
+func TestNewElementMatchFilter(t *testing.T) {
+
+	Convey("Given I call NewElementMatchFilter", t, func() {
+
+		sub := elemental.NewFilterComposer().WithKey("name").Equals("x").Done()
+		f := NewElementMatchFilter("tags", sub)
+
+		Convey("Then the filter should be correct", func() {
+			So(f.Keys(), ShouldResemble, elemental.FilterKeys{"tags"})
+			So(f.Comparators(), ShouldResemble, elemental.FilterComparators{elemental.ContainComparator})
+			So(f.Values(), ShouldResemble, elemental.FilterValues{{ElementMatch{Filter: sub}}})
+		})
+
+		Convey("Then the string representation should contain the sub filter", func() {
+			So(ElementMatch{Filter: sub}.String(), ShouldEqual, "elemMatch("+sub.String()+")")
+		})
+	})
+
+	Convey("Given I call NewElementMatchFilter with a nil sub filter", t, func() {
+
+		Convey("Then it should panic", func() {
+			So(func() { NewElementMatchFilter("tags", nil) }, ShouldPanicWith, "sub filter must not be nil")
+		})
+	})
+}
+
+func TestAndFilters(t *testing.T) {
+
+	f1 := elemental.NewFilterComposer().WithKey("a").Equals(1).Done()
+	f2 := elemental.NewFilterComposer().WithKey("b").Equals(2).Done()
+
+	Convey("Given I call AndFilters without filters", t, func() {
+		So(AndFilters(), ShouldBeNil)
+		So(AndFilters(nil, nil), ShouldBeNil)
+	})
+
+	Convey("Given I call AndFilters with a single filter", t, func() {
+		So(AndFilters(nil, f1), ShouldEqual, f1)
+	})
+
+	Convey("Given I call AndFilters with multiple filters", t, func() {
+		So(AndFilters(f1, nil, f2), ShouldResemble, elemental.NewFilterComposer().And(f1, f2).Done())
+	})
+}
+
+func TestOrFilters(t *testing.T) {
+
+	f1 := elemental.NewFilterComposer().WithKey("a").Equals(1).Done()
+	f2 := elemental.NewFilterComposer().WithKey("b").Equals(2).Done()
+
+	Convey("Given I call OrFilters without filters", t, func() {
+		So(OrFilters(), ShouldBeNil)
+		So(OrFilters(nil, nil), ShouldBeNil)
+	})
+
+	Convey("Given I call OrFilters with a single filter", t, func() {
+		So(OrFilters(f1, nil), ShouldEqual, f1)
+	})
+
+	Convey("Given I call OrFilters with multiple filters", t, func() {
+		So(OrFilters(f1, nil, f2), ShouldResemble, elemental.NewFilterComposer().Or(f1, f2).Done())
+	})
+}
+
+func TestWalkFilter(t *testing.T) {
+
+	Convey("Given I call WalkFilter on a nil filter", t, func() {
+
+		f, err := WalkFilter(nil, func(*FilterComparison) error { return nil })
+
+		Convey("Then it should return nil", func() {
+			So(err, ShouldBeNil)
+			So(f, ShouldBeNil)
+		})
+	})
+
+	Convey("Given I have a filter with sub filters", t, func() {
+
+		f := elemental.NewFilterComposer().
+			WithKey("name").Equals("x").
+			WithKey("tags").Contains("a", "b").
+			And(
+				elemental.NewFilterComposer().WithKey("name").NotEquals("y").Done(),
+				elemental.NewFilterComposer().WithKey("date").Exists().Done(),
+			).
+			Or(
+				elemental.NewFilterComposer().WithKey("size").GreaterThan(1).Done(),
+				elemental.NewFilterComposer().WithKey("name").Matches("^z").Done(),
+			).
+			Done()
+
+		Convey("When I walk it without modification", func() {
+
+			var keys []string
+			out, err := WalkFilter(f, func(c *FilterComparison) error {
+				keys = append(keys, c.Key)
+				return nil
+			})
+
+			Convey("Then all the comparisons should have been visited", func() {
+				So(err, ShouldBeNil)
+				So(keys, ShouldResemble, []string{"name", "tags", "name", "date", "size", "name"})
+			})
+
+			Convey("Then the returned filter should be a copy", func() {
+				So(out, ShouldResemble, f)
+				So(out, ShouldNotPointTo, f)
+			})
+		})
+
+		Convey("When I walk it renaming a key", func() {
+
+			out, err := WalkFilter(f, func(c *FilterComparison) error {
+				if c.Key == "name" {
+					c.Key = "fullname"
+				}
+				return nil
+			})
+
+			Convey("Then the returned filter should be correct", func() {
+				So(err, ShouldBeNil)
+				So(out, ShouldResemble, elemental.NewFilterComposer().
+					WithKey("fullname").Equals("x").
+					WithKey("tags").Contains("a", "b").
+					And(
+						elemental.NewFilterComposer().WithKey("fullname").NotEquals("y").Done(),
+						elemental.NewFilterComposer().WithKey("date").Exists().Done(),
+					).
+					Or(
+						elemental.NewFilterComposer().WithKey("size").GreaterThan(1).Done(),
+						elemental.NewFilterComposer().WithKey("fullname").Matches("^z").Done(),
+					).
+					Done(),
+				)
+			})
+
+			Convey("Then the given filter should be untouched", func() {
+				So(f.Keys()[0], ShouldEqual, "name")
+				So(f.AndFilters()[2][0].Keys()[0], ShouldEqual, "name")
+			})
+		})
+
+		Convey("When I walk it rejecting a comparator", func() {
+
+			out, err := WalkFilter(f, func(c *FilterComparison) error {
+				if c.Comparator == elemental.MatchComparator {
+					return fmt.Errorf("matches is not allowed")
+				}
+				return nil
+			})
+
+			Convey("Then err should be returned", func() {
+				So(out, ShouldBeNil)
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldEqual, "matches is not allowed")
+			})
+		})
+
+		Convey("When I walk it setting multiple values to a single value comparator", func() {
+
+			_, err := WalkFilter(f, func(c *FilterComparison) error {
+				if c.Comparator == elemental.EqualComparator {
+					c.Values = []interface{}{1, 2}
+				}
+				return nil
+			})
+
+			Convey("Then err should not be nil", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
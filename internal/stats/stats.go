@@ -0,0 +1,116 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package stats
+
+import (
+	"sync/atomic"
+
+	"go.aporeto.io/elemental"
+	"go.aporeto.io/manipulate"
+)
+
+// operations are the operations for which the Counters keep
+// a counter. Other operations are ignored.
+var operations = [...]elemental.Operation{
+	elemental.OperationCreate,
+	elemental.OperationRetrieve,
+	elemental.OperationRetrieveMany,
+	elemental.OperationUpdate,
+	elemental.OperationDelete,
+	elemental.OperationInfo,
+	elemental.OperationPatch,
+}
+
+// Counters holds the counters of a manipulator. It is safe for
+// concurrent use, and counting does not allocate. A nil Counters
+// counts nothing.
+type Counters struct {
+	operations  [len(operations)]int64
+	errors      int64
+	retries     int64
+	cacheHits   int64
+	cacheMisses int64
+}
+
+// New returns new Counters. They must be allocated with New
+// to be correctly aligned for the atomic operations.
+func New() *Counters {
+	return &Counters{}
+}
+
+// Operation counts an operation of the given type returning the given error.
+func (c *Counters) Operation(op elemental.Operation, err error) {
+
+	if c == nil {
+		return
+	}
+
+	for i, o := range operations {
+		if o == op {
+			atomic.AddInt64(&c.operations[i], 1)
+			break
+		}
+	}
+
+	if err != nil {
+		atomic.AddInt64(&c.errors, 1)
+	}
+}
+
+// Retry counts a retry.
+func (c *Counters) Retry() {
+
+	if c == nil {
+		return
+	}
+
+	atomic.AddInt64(&c.retries, 1)
+}
+
+// Cache counts a read served from a cache if hit is true,
+// or forwarded to a backend otherwise.
+func (c *Counters) Cache(hit bool) {
+
+	if c == nil {
+		return
+	}
+
+	if hit {
+		atomic.AddInt64(&c.cacheHits, 1)
+	} else {
+		atomic.AddInt64(&c.cacheMisses, 1)
+	}
+}
+
+// Snapshot returns the current values of the counters.
+func (c *Counters) Snapshot() manipulate.Stats {
+
+	s := manipulate.Stats{
+		Operations: make(map[elemental.Operation]int64, len(operations)),
+	}
+
+	if c == nil {
+		return s
+	}
+
+	for i, o := range operations {
+		if n := atomic.LoadInt64(&c.operations[i]); n > 0 {
+			s.Operations[o] = n
+		}
+	}
+
+	s.Errors = atomic.LoadInt64(&c.errors)
+	s.Retries = atomic.LoadInt64(&c.retries)
+	s.CacheHits = atomic.LoadInt64(&c.cacheHits)
+	s.CacheMisses = atomic.LoadInt64(&c.cacheMisses)
+
+	return s
+}
@@ -0,0 +1,69 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package stats
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.aporeto.io/elemental"
+	"go.aporeto.io/manipulate"
+)
+
+func TestCounters(t *testing.T) {
+
+	Convey("Given I have some counters", t, func() {
+
+		c := New()
+
+		Convey("When I count some operations", func() {
+
+			c.Operation(elemental.OperationCreate, nil)
+			c.Operation(elemental.OperationCreate, errors.New("boom"))
+			c.Operation(elemental.OperationRetrieveMany, nil)
+			c.Operation(elemental.Operation("nope"), errors.New("boom"))
+			c.Retry()
+			c.Cache(true)
+			c.Cache(true)
+			c.Cache(false)
+
+			Convey("Then the snapshot should be correct", func() {
+				So(c.Snapshot(), ShouldResemble, manipulate.Stats{
+					Operations: map[elemental.Operation]int64{
+						elemental.OperationCreate:       2,
+						elemental.OperationRetrieveMany: 1,
+					},
+					Errors:      2,
+					Retries:     1,
+					CacheHits:   2,
+					CacheMisses: 1,
+				})
+			})
+		})
+	})
+
+	Convey("Given I have nil counters", t, func() {
+
+		var c *Counters
+
+		Convey("When I count some operations", func() {
+
+			c.Operation(elemental.OperationCreate, nil)
+			c.Retry()
+			c.Cache(true)
+
+			Convey("Then the snapshot should be empty", func() {
+				So(c.Snapshot(), ShouldResemble, manipulate.Stats{Operations: map[elemental.Operation]int64{}})
+			})
+		})
+	})
+}
@@ -26,7 +26,12 @@ func StartTrace(mctx manipulate.Context, name string) opentracing.Span {
 		return sp
 	}
 
-	sp, _ := opentracing.StartSpanFromContext(mctx.Context(), name)
+	var sp opentracing.Span
+	if opentracing.SpanFromContext(mctx.Context()) == nil && mctx.SpanContext() != nil {
+		sp = opentracing.StartSpan(name, opentracing.ChildOf(mctx.SpanContext()))
+	} else {
+		sp, _ = opentracing.StartSpanFromContext(mctx.Context(), name)
+	}
 
 	sp.SetTag("manipulate.context.api_version", mctx.Version())
 	sp.SetTag("manipulate.context.page", mctx.Page())
@@ -40,6 +45,14 @@ func StartTrace(mctx manipulate.Context, name string) opentracing.Span {
 		sp.SetTag("manipulate.context.namespace", "manipulator-default")
 	}
 
+	if tid := mctx.TransactionID(); tid != "" {
+		sp.SetTag("manipulate.context.transaction_id", string(tid))
+	}
+
+	if label := mctx.TransactionLabel(); label != "" {
+		sp.SetTag("manipulate.context.transaction_label", label)
+	}
+
 	if len(mctx.Parameters()) >= 0 {
 		sp.SetTag("manipulate.context.parameters", mctx.Parameters())
 	}
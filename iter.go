@@ -14,12 +14,33 @@ package manipulate
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"go.aporeto.io/elemental"
 )
 
 const iterDefaultBlockSize = 1000
 
+type iterConfig struct {
+	deadline time.Time
+}
+
+// An IterOption configures the iteration functions.
+type IterOption func(*iterConfig)
+
+// IterOptionDeadline sets an overall deadline for the whole iteration.
+// It is checked before retrieving each block: once it is exceeded,
+// the iteration stops cleanly and returns an ErrIterationDeadlineExceeded.
+// The blocks already passed to the iterator function are not affected,
+// and Iter returns the objects it retrieved along with the error.
+// Unlike a deadline on the given context.Context, it never interrupts
+// the retrieval of a block.
+func IterOptionDeadline(deadline time.Time) IterOption {
+	return func(c *iterConfig) {
+		c.deadline = deadline
+	}
+}
+
 // IterFunc calls RetrieveMany on the given Manipulator, and will retrieve the data by block
 // of the given blockSize.
 //
@@ -39,7 +60,9 @@ const iterDefaultBlockSize = 1000
 // hold the data block. It is reset at every iteration. Do not rely on it to be filled
 // once IterFunc is complete.
 //
-// Finally, if the given blockSize is <= 0, then it will use the default that is 1000.
+// If the given blockSize is <= 0, then it will use the default that is 1000.
+//
+// Finally, the iteration can be configured using the given IterOptions.
 func IterFunc(
 	ctx context.Context,
 	manipulator Manipulator,
@@ -47,8 +70,9 @@ func IterFunc(
 	mctx Context,
 	iteratorFunc func(block elemental.Identifiables) error,
 	blockSize int,
+	options ...IterOption,
 ) error {
-	return doIterFunc(ctx, manipulator, identifiablesTemplate, mctx, iteratorFunc, blockSize, false)
+	return doIterFunc(ctx, manipulator, identifiablesTemplate, mctx, iteratorFunc, blockSize, false, options...)
 }
 
 // IterUntilFunc works as IterFunc but pagination will not increase.
@@ -63,8 +87,9 @@ func IterUntilFunc(
 	mctx Context,
 	iteratorFunc func(block elemental.Identifiables) error,
 	blockSize int,
+	options ...IterOption,
 ) error {
-	return doIterFunc(ctx, manipulator, identifiablesTemplate, mctx, iteratorFunc, blockSize, true)
+	return doIterFunc(ctx, manipulator, identifiablesTemplate, mctx, iteratorFunc, blockSize, true, options...)
 }
 
 // Iter is a helper function for IterFunc.
@@ -75,6 +100,9 @@ func IterUntilFunc(
 //
 // Always pass an empty elemental.Identifiables to this function
 //
+// If the iteration is stopped because of IterOptionDeadline, the objects
+// retrieved so far are returned along with the ErrIterationDeadlineExceeded.
+//
 // For more information, please check IterFunc documentation.
 //
 // Example:
@@ -86,6 +114,7 @@ func Iter(
 	mctx Context,
 	identifiablesTemplate elemental.Identifiables,
 	blockSize int,
+	options ...IterOption,
 ) (elemental.Identifiables, error) {
 
 	if err := IterFunc(
@@ -98,7 +127,11 @@ func Iter(
 			return nil
 		},
 		blockSize,
+		options...,
 	); err != nil {
+		if IsIterationDeadlineExceededError(err) {
+			return identifiablesTemplate, err
+		}
 		return nil, err
 	}
 
@@ -113,6 +146,7 @@ func doIterFunc(
 	iteratorFunc func(block elemental.Identifiables) error,
 	blockSize int,
 	disablePageIncrease bool,
+	options ...IterOption,
 ) error {
 
 	if manipulator == nil {
@@ -135,12 +169,21 @@ func doIterFunc(
 		blockSize = iterDefaultBlockSize
 	}
 
+	cfg := iterConfig{}
+	for _, o := range options {
+		o(&cfg)
+	}
+
 	var iter int
 	var after string
 
 	for {
 		iter++
 
+		if !cfg.deadline.IsZero() && time.Now().After(cfg.deadline) {
+			return NewErrIterationDeadlineExceeded(fmt.Sprintf("stopped before iteration %d", iter))
+		}
+
 		objects := identifiablesTemplate.Copy()
 
 		smctx := mctx.Derive(ContextOptionAfter(after, blockSize))
@@ -16,6 +16,7 @@ import (
 	"fmt"
 	"strconv"
 	"testing"
+	"time"
 
 	. "github.com/smartystreets/goconvey/convey"
 	"go.aporeto.io/elemental"
@@ -428,6 +429,89 @@ func TestIter(t *testing.T) {
 	})
 }
 
+func TestIter_Deadline(t *testing.T) {
+
+	Convey("Given I have a manipulator and some objects in the db", t, func() {
+
+		m := &testManipulator{
+			data: makeData(45),
+		}
+
+		Convey("When I call IterFunc with a deadline exceeded during the second block", func() {
+
+			var called int
+			deadline := time.Now().Add(50 * time.Millisecond)
+
+			err := IterFunc(
+				context.Background(),
+				m,
+				testmodel.ListsList{},
+				nil,
+				func(block elemental.Identifiables) error {
+					called++
+					if called == 2 {
+						time.Sleep(time.Until(deadline) + time.Millisecond)
+					}
+					return nil
+				},
+				10,
+				IterOptionDeadline(deadline),
+			)
+
+			Convey("Then err should be correct", func() {
+				So(err, ShouldNotBeNil)
+				So(IsIterationDeadlineExceededError(err), ShouldBeTrue)
+				So(err.Error(), ShouldEqual, "Iteration deadline exceeded: stopped before iteration 3")
+			})
+
+			Convey("Then the iteration should have stopped", func() {
+				So(called, ShouldEqual, 2)
+			})
+		})
+
+		Convey("When I call Iter with an exceeded deadline", func() {
+
+			dest, err := Iter(
+				context.Background(),
+				m,
+				nil,
+				testmodel.ListsList{},
+				10,
+				IterOptionDeadline(time.Now().Add(-time.Second)),
+			)
+
+			Convey("Then err should be correct", func() {
+				So(IsIterationDeadlineExceededError(err), ShouldBeTrue)
+			})
+
+			Convey("Then dest should be returned", func() {
+				So(dest, ShouldNotBeNil)
+				So(len(dest.List()), ShouldEqual, 0)
+			})
+		})
+
+		Convey("When I call Iter with a deadline that is not exceeded", func() {
+
+			dest, err := Iter(
+				context.Background(),
+				m,
+				nil,
+				testmodel.ListsList{},
+				10,
+				IterOptionDeadline(time.Now().Add(time.Minute)),
+			)
+
+			Convey("Then err should be nil", func() {
+				So(err, ShouldBeNil)
+			})
+
+			Convey("Then dest should be correct", func() {
+				So(dest, ShouldResemble, m.data)
+			})
+		})
+	})
+}
+
 func TestIterUntilFunc(t *testing.T) {
 
 	Convey("Given I have a manipulator and some objects in the db", t, func() {
@@ -0,0 +1,141 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package maniphttp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	"go.aporeto.io/manipulate"
+)
+
+// An ETagEntry is a response cached in an ETagStore.
+type ETagEntry struct {
+	ETag   string
+	Header http.Header
+	Body   []byte
+}
+
+// An ETagStore stores the last response of GET requests
+// along with its ETag.
+type ETagStore interface {
+	Get(key string) (ETagEntry, bool)
+	Set(key string, entry ETagEntry)
+}
+
+type memoryETagStore struct {
+	entries map[string]ETagEntry
+	lock    sync.RWMutex
+}
+
+// NewETagStore returns an ETagStore keeping the responses in memory.
+// Entries are never evicted, so it should only be used to cache a
+// bounded set of resources. Implement your own ETagStore otherwise.
+func NewETagStore() ETagStore {
+	return &memoryETagStore{
+		entries: map[string]ETagEntry{},
+	}
+}
+
+func (s *memoryETagStore) Get(key string) (ETagEntry, bool) {
+
+	s.lock.RLock()
+	entry, ok := s.entries[key]
+	s.lock.RUnlock()
+
+	return entry, ok
+}
+
+func (s *memoryETagStore) Set(key string, entry ETagEntry) {
+
+	s.lock.Lock()
+	s.entries[key] = entry
+	s.lock.Unlock()
+}
+
+// makeETagKey returns the key identifying the representation
+// returned for the given request.
+func makeETagKey(req *http.Request) string {
+
+	return strings.Join(
+		[]string{
+			req.URL.String(),
+			req.Header.Get("X-Namespace"),
+			req.Header.Get("Accept"),
+			req.Header.Get("Accept-Language"),
+			strings.Join(req.Header["X-Fields"], ","),
+		},
+		"\n",
+	)
+}
+
+// setIfNoneMatch sets the If-None-Match header of the given
+// request if the store has a cached response for it.
+func setIfNoneMatch(store ETagStore, key string, req *http.Request) {
+
+	if entry, ok := store.Get(key); ok {
+		req.Header.Set("If-None-Match", entry.ETag)
+	}
+}
+
+// applyETagStore replaces a 304 response by the cached one, and caches
+// the successful responses having an ETag.
+func applyETagStore(store ETagStore, key string, response *http.Response) error {
+
+	switch {
+
+	case response.StatusCode == http.StatusNotModified:
+
+		entry, ok := store.Get(key)
+		if !ok {
+			return nil
+		}
+
+		_, _ = io.Copy(ioutil.Discard, response.Body)
+		_ = response.Body.Close() // nolint
+
+		// The 304 response may omit headers like the
+		// content type, so we backport the cached ones.
+		for k, v := range entry.Header {
+			if _, ok := response.Header[k]; !ok {
+				response.Header[k] = v
+			}
+		}
+
+		response.StatusCode = http.StatusOK
+		response.ContentLength = int64(len(entry.Body))
+		response.Body = ioutil.NopCloser(bytes.NewReader(entry.Body))
+
+	case response.StatusCode == http.StatusOK && response.Header.Get("ETag") != "":
+
+		data, err := ioutil.ReadAll(response.Body)
+		_ = response.Body.Close() // nolint
+		if err != nil {
+			return manipulate.NewErrCannotUnmarshal(fmt.Sprintf("unable to read data: %s", err.Error()))
+		}
+
+		store.Set(key, ETagEntry{
+			ETag:   response.Header.Get("ETag"),
+			Header: response.Header.Clone(),
+			Body:   data,
+		})
+
+		response.Body = ioutil.NopCloser(bytes.NewReader(data))
+	}
+
+	return nil
+}
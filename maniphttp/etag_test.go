@@ -0,0 +1,149 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package maniphttp
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.aporeto.io/manipulate"
+	"go.aporeto.io/manipulate/internal/tracing"
+)
+
+func TestETag_applyETagStore(t *testing.T) {
+
+	Convey("Given I have an etag store", t, func() {
+
+		store := NewETagStore()
+
+		Convey("When I apply it on a response with an ETag", func() {
+
+			r := &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Etag": []string{"v1"}, "Content-Type": []string{"application/json"}},
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte("hello"))),
+			}
+
+			err := applyETagStore(store, "key", r)
+
+			Convey("Then the response should be cached", func() {
+				So(err, ShouldBeNil)
+
+				entry, ok := store.Get("key")
+				So(ok, ShouldBeTrue)
+				So(entry.ETag, ShouldEqual, "v1")
+				So(string(entry.Body), ShouldEqual, "hello")
+			})
+
+			Convey("Then the body should still be readable", func() {
+				data, _ := ioutil.ReadAll(r.Body)
+				So(string(data), ShouldEqual, "hello")
+			})
+
+			Convey("When I apply it on a not modified response", func() {
+
+				r := &http.Response{
+					StatusCode: http.StatusNotModified,
+					Header:     http.Header{"Etag": []string{"v1"}},
+					Body:       ioutil.NopCloser(bytes.NewReader(nil)),
+				}
+
+				err := applyETagStore(store, "key", r)
+
+				Convey("Then the cached response should be served", func() {
+					So(err, ShouldBeNil)
+					So(r.StatusCode, ShouldEqual, http.StatusOK)
+					So(r.ContentLength, ShouldEqual, 5)
+					So(r.Header.Get("Content-Type"), ShouldEqual, "application/json")
+
+					data, _ := ioutil.ReadAll(r.Body)
+					So(string(data), ShouldEqual, "hello")
+				})
+			})
+		})
+
+		Convey("When I apply it on a response without ETag", func() {
+
+			r := &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{},
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte("hello"))),
+			}
+
+			err := applyETagStore(store, "key", r)
+
+			Convey("Then the response should not be cached", func() {
+				So(err, ShouldBeNil)
+
+				_, ok := store.Get("key")
+				So(ok, ShouldBeFalse)
+			})
+		})
+	})
+}
+
+func TestETag_send(t *testing.T) {
+
+	sp := tracing.StartTrace(nil, "test")
+	defer sp.Finish()
+
+	Convey("Given I have a server supporting ETags", t, func() {
+
+		var calls, notModified int
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			if r.Header.Get("If-None-Match") == "v1" {
+				notModified++
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", "v1")
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"ID":"1"}`))
+		}))
+		defer ts.Close()
+
+		m, _ := New(context.Background(), ts.URL, OptionETagStore(NewETagStore()))
+		hm := m.(*httpManipulator)
+
+		Convey("When I send the same request twice", func() {
+
+			r1, err1 := hm.send(manipulate.NewContext(context.Background()), http.MethodGet, ts.URL+"/lists/1", nil, nil, sp)
+			r2, err2 := hm.send(manipulate.NewContext(context.Background()), http.MethodGet, ts.URL+"/lists/1", nil, nil, sp)
+
+			Convey("Then the second response should be served from the cache", func() {
+				So(err1, ShouldBeNil)
+				So(err2, ShouldBeNil)
+				So(calls, ShouldEqual, 2)
+				So(notModified, ShouldEqual, 1)
+				So(r1.StatusCode, ShouldEqual, http.StatusOK)
+				So(r2.StatusCode, ShouldEqual, http.StatusOK)
+			})
+		})
+
+		Convey("When I send the same request with another namespace", func() {
+
+			_, _ = hm.send(manipulate.NewContext(context.Background(), manipulate.ContextOptionNamespace("/a")), http.MethodGet, ts.URL+"/lists/1", nil, nil, sp)
+			_, err := hm.send(manipulate.NewContext(context.Background(), manipulate.ContextOptionNamespace("/b")), http.MethodGet, ts.URL+"/lists/1", nil, nil, sp)
+
+			Convey("Then the cache should not be used", func() {
+				So(err, ShouldBeNil)
+				So(notModified, ShouldEqual, 0)
+			})
+		})
+	})
+}
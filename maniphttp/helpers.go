@@ -74,10 +74,12 @@ func ExtractTLSConfig(manipulator manipulate.Manipulator) *tls.Config {
 	}
 
 	return &tls.Config{
-		RootCAs:            m.tlsConfig.RootCAs,
-		Certificates:       m.tlsConfig.Certificates,
-		InsecureSkipVerify: m.tlsConfig.InsecureSkipVerify,
-		ClientSessionCache: m.tlsConfig.ClientSessionCache,
+		RootCAs:               m.tlsConfig.RootCAs,
+		Certificates:          m.tlsConfig.Certificates,
+		GetClientCertificate:  m.tlsConfig.GetClientCertificate,
+		VerifyPeerCertificate: m.tlsConfig.VerifyPeerCertificate,
+		InsecureSkipVerify:    m.tlsConfig.InsecureSkipVerify,
+		ClientSessionCache:    m.tlsConfig.ClientSessionCache,
 	} // #nosec
 }
 
@@ -39,12 +39,14 @@ import (
 	"go.aporeto.io/manipulate/internal/backoff"
 	"go.aporeto.io/manipulate/internal/idempotency"
 	"go.aporeto.io/manipulate/internal/snip"
+	"go.aporeto.io/manipulate/internal/stats"
 	"go.aporeto.io/manipulate/internal/tracing"
 )
 
 const (
 	defaultGlobalContextTimeout = 2 * time.Minute
 	minContextTimeout           = 20 * time.Second
+	defaultMaxResponseBytes     = 1 << 30
 )
 
 func init() {
@@ -61,12 +63,14 @@ type httpManipulator struct {
 	renewNotifiersLock   sync.RWMutex
 	disableAutoRetry     bool
 	disableCompression   bool
+	disableHTTP2         bool
 	defaultRetryFunc     manipulate.RetryFunc
 	atomicRenewTokenFunc func(context.Context) error
 	failureSimulations   map[float64]error
 	tokenCookieKey       string
 	backoffCurve         []time.Duration
 	strongBackoffCurve   []time.Duration
+	stats                *stats.Counters
 
 	// optionnable
 	ctx            context.Context
@@ -77,8 +81,25 @@ type httpManipulator struct {
 	transport      *http.Transport
 	encoding       elemental.EncodingType
 	tcpUserTimeout time.Duration
+	idGenerator    manipulate.IdentifierGenerator
+	requestSigner  RequestSigner
+	etagStore      ETagStore
+	maxResults     int
+	userAgent      string
+
+	maxResponseBytes int64
+
+	clientCertificateSelector ClientCertificateSelector
+	peerCertificateVerifiers  []peerCertificateVerifier
+	certificateFiles          *certificateFiles
+	rootCAs                   *x509.CertPool
 }
 
+var (
+	_ manipulate.Manipulator   = (*httpManipulator)(nil)
+	_ manipulate.StatsProvider = (*httpManipulator)(nil)
+)
+
 // New returns a maniphttp.Manipulator configured according to the given suite of Option.
 func New(ctx context.Context, url string, options ...Option) (manipulate.Manipulator, error) {
 
@@ -98,6 +119,9 @@ func New(ctx context.Context, url string, options ...Option) (manipulate.Manipul
 		encoding:           elemental.EncodingTypeJSON,
 		backoffCurve:       defaultBackoffCurve,
 		strongBackoffCurve: strongBackoffCurve,
+		stats:              stats.New(),
+		maxResponseBytes:   defaultMaxResponseBytes,
+		userAgent:          defaultUserAgent,
 	}
 
 	// Apply the options.
@@ -113,6 +137,11 @@ func New(ctx context.Context, url string, options ...Option) (manipulate.Manipul
 
 			m.transport, m.url = getDefaultHTTPTransport(url, m.disableCompression, m.tcpUserTimeout)
 
+			if m.disableHTTP2 {
+				m.transport.ForceAttemptHTTP2 = false
+				m.transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+			}
+
 			if m.tlsConfig == nil {
 				m.tlsConfig = getDefaultTLSConfig()
 			}
@@ -120,6 +149,39 @@ func New(ctx context.Context, url string, options ...Option) (manipulate.Manipul
 			m.transport.TLSClientConfig = m.tlsConfig
 		}
 
+		if m.rootCAs != nil {
+			m.transport.TLSClientConfig = withRootCAs(m.transport.TLSClientConfig, m.rootCAs)
+			m.tlsConfig = m.transport.TLSClientConfig
+		}
+
+		if m.certificateFiles != nil {
+
+			if m.clientCertificateSelector != nil {
+				return nil, fmt.Errorf("cannot use a client certificate selector with client certificate files")
+			}
+
+			reloader, err := newCertificateReloader(m.certificateFiles.certPath, m.certificateFiles.keyPath, m.transport.CloseIdleConnections)
+			if err != nil {
+				return nil, fmt.Errorf("unable to load client certificate: %s", err)
+			}
+
+			go reloader.run(m.ctx, m.certificateFiles.interval)
+
+			m.clientCertificateSelector = func(context.Context, *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+				return reloader.certificate(), nil
+			}
+		}
+
+		if m.clientCertificateSelector != nil {
+			m.transport.TLSClientConfig = withClientCertificateSelector(m.transport.TLSClientConfig, m.clientCertificateSelector)
+			m.tlsConfig = m.transport.TLSClientConfig
+		}
+
+		if len(m.peerCertificateVerifiers) > 0 {
+			m.transport.TLSClientConfig = withPeerCertificateVerifiers(m.transport.TLSClientConfig, m.peerCertificateVerifiers...)
+			m.tlsConfig = m.transport.TLSClientConfig
+		}
+
 		m.client.Transport = m.transport
 	}
 
@@ -161,7 +223,9 @@ func New(ctx context.Context, url string, options ...Option) (manipulate.Manipul
 	return m, nil
 }
 
-func (s *httpManipulator) RetrieveMany(mctx manipulate.Context, dest elemental.Identifiables) error {
+func (s *httpManipulator) RetrieveMany(mctx manipulate.Context, dest elemental.Identifiables) (err error) {
+
+	defer func() { s.stats.Operation(elemental.OperationRetrieveMany, err) }()
 
 	if dest == nil {
 		return manipulate.NewErrCannotBuildQuery("nil dest")
@@ -195,16 +259,20 @@ func (s *httpManipulator) RetrieveMany(mctx manipulate.Context, dest elemental.I
 	}
 
 	// backport all default values that are empty.
-	for _, o := range dest.List() {
-		if a, ok := o.(elemental.AttributeSpecifiable); ok {
-			elemental.ResetDefaultForZeroValues(a)
+	if !mctx.PreserveZeroValues() {
+		for _, o := range dest.List() {
+			if a, ok := o.(elemental.AttributeSpecifiable); ok {
+				elemental.ResetDefaultForZeroValues(a)
+			}
 		}
 	}
 
 	return nil
 }
 
-func (s *httpManipulator) Retrieve(mctx manipulate.Context, object elemental.Identifiable) error {
+func (s *httpManipulator) Retrieve(mctx manipulate.Context, object elemental.Identifiable) (err error) {
+
+	defer func() { s.stats.Operation(elemental.OperationRetrieve, err) }()
 
 	if object == nil {
 		return manipulate.NewErrCannotBuildQuery("nil object")
@@ -228,6 +296,9 @@ func (s *httpManipulator) Retrieve(mctx manipulate.Context, object elemental.Ide
 
 	response, err := s.send(mctx, http.MethodGet, url, nil, object, sp)
 	if err != nil {
+		if errs, ok := err.(elemental.Errors); ok && errs.Code() == http.StatusNotFound && mctx.NotFoundAsEmpty() {
+			return nil
+		}
 		sp.SetTag("error", true)
 		sp.LogFields(log.Error(err))
 		return err
@@ -238,14 +309,16 @@ func (s *httpManipulator) Retrieve(mctx manipulate.Context, object elemental.Ide
 	}
 
 	// backport all default values that are empty.
-	if a, ok := object.(elemental.AttributeSpecifiable); ok {
+	if a, ok := object.(elemental.AttributeSpecifiable); ok && !mctx.PreserveZeroValues() {
 		elemental.ResetDefaultForZeroValues(a)
 	}
 
 	return nil
 }
 
-func (s *httpManipulator) Create(mctx manipulate.Context, object elemental.Identifiable) error {
+func (s *httpManipulator) Create(mctx manipulate.Context, object elemental.Identifiable) (err error) {
+
+	defer func() { s.stats.Operation(elemental.OperationCreate, err) }()
 
 	if object == nil {
 		return manipulate.NewErrCannotBuildQuery("nil object")
@@ -262,6 +335,10 @@ func (s *httpManipulator) Create(mctx manipulate.Context, object elemental.Ident
 		kmctx.SetIdempotencyKey(uuid.Must(uuid.NewV4()).String())
 	}
 
+	if s.idGenerator != nil {
+		object.SetIdentifier(s.idGenerator(object))
+	}
+
 	sp := tracing.StartTrace(mctx, fmt.Sprintf("maniphttp.create.object.%s", object.Identity().Name))
 	sp.LogFields(log.String("object_id", object.Identifier()))
 	defer sp.Finish()
@@ -292,7 +369,7 @@ func (s *httpManipulator) Create(mctx manipulate.Context, object elemental.Ident
 	}
 
 	// backport all default values that are empty.
-	if a, ok := object.(elemental.AttributeSpecifiable); ok {
+	if a, ok := object.(elemental.AttributeSpecifiable); ok && !mctx.PreserveZeroValues() {
 		elemental.ResetDefaultForZeroValues(a)
 	}
 
@@ -303,7 +380,9 @@ func (s *httpManipulator) Create(mctx manipulate.Context, object elemental.Ident
 	return nil
 }
 
-func (s *httpManipulator) Update(mctx manipulate.Context, object elemental.Identifiable) error {
+func (s *httpManipulator) Update(mctx manipulate.Context, object elemental.Identifiable) (err error) {
+
+	defer func() { s.stats.Operation(elemental.OperationUpdate, err) }()
 
 	if object == nil {
 		return manipulate.NewErrCannotBuildQuery("nil object")
@@ -355,7 +434,7 @@ func (s *httpManipulator) Update(mctx manipulate.Context, object elemental.Ident
 	}
 
 	// backport all default values that are empty.
-	if a, ok := object.(elemental.AttributeSpecifiable); ok {
+	if a, ok := object.(elemental.AttributeSpecifiable); ok && !mctx.PreserveZeroValues() {
 		elemental.ResetDefaultForZeroValues(a)
 	}
 
@@ -366,7 +445,9 @@ func (s *httpManipulator) Update(mctx manipulate.Context, object elemental.Ident
 	return nil
 }
 
-func (s *httpManipulator) Delete(mctx manipulate.Context, object elemental.Identifiable) error {
+func (s *httpManipulator) Delete(mctx manipulate.Context, object elemental.Identifiable) (err error) {
+
+	defer func() { s.stats.Operation(elemental.OperationDelete, err) }()
 
 	if object == nil {
 		return manipulate.NewErrCannotBuildQuery("nil object")
@@ -401,7 +482,7 @@ func (s *httpManipulator) Delete(mctx manipulate.Context, object elemental.Ident
 	}
 
 	// backport all default values that are empty.
-	if a, ok := object.(elemental.AttributeSpecifiable); ok {
+	if a, ok := object.(elemental.AttributeSpecifiable); ok && !mctx.PreserveZeroValues() {
 		elemental.ResetDefaultForZeroValues(a)
 	}
 
@@ -412,7 +493,9 @@ func (s *httpManipulator) DeleteMany(mctx manipulate.Context, identity elemental
 	return manipulate.NewErrNotImplemented("DeleteMany not implemented in maniphttp")
 }
 
-func (s *httpManipulator) Count(mctx manipulate.Context, identity elemental.Identity) (int, error) {
+func (s *httpManipulator) Count(mctx manipulate.Context, identity elemental.Identity) (n int, err error) {
+
+	defer func() { s.stats.Operation(elemental.OperationInfo, err) }()
 
 	if mctx == nil {
 		ctx, cancel := context.WithTimeout(context.Background(), defaultGlobalContextTimeout)
@@ -439,6 +522,11 @@ func (s *httpManipulator) Count(mctx manipulate.Context, identity elemental.Iden
 	return mctx.Count(), nil
 }
 
+// Stats is part of the implementation of the StatsProvider interface.
+func (s *httpManipulator) Stats() manipulate.Stats {
+	return s.stats.Snapshot()
+}
+
 func (s *httpManipulator) makeAuthorizationHeaders(username, password string) string {
 
 	return username + " " + password
@@ -451,6 +539,10 @@ func (s *httpManipulator) prepareHeaders(request *http.Request, mctx manipulate.
 		ns = s.namespace
 	}
 
+	if s.userAgent != "" {
+		request.Header.Set("User-Agent", s.userAgent)
+	}
+
 	for k, v := range s.globalHeaders {
 		request.Header[k] = v
 	}
@@ -469,6 +561,10 @@ func (s *httpManipulator) prepareHeaders(request *http.Request, mctx manipulate.
 		request.Header.Set("Accept", string(s.encoding))
 	}
 
+	if value, ok := opaque[opaqueKeyLocale]; ok && value.(string) != "" {
+		request.Header.Set("Accept-Language", value.(string))
+	}
+
 	if ns != "" {
 		request.Header.Set("X-Namespace", ns)
 	}
@@ -515,6 +611,10 @@ func (s *httpManipulator) prepareHeaders(request *http.Request, mctx manipulate.
 	if v := mctx.ClientIP(); v != "" {
 		request.Header.Set("X-Forwarded-For", v)
 	}
+
+	if v := mctx.Actor(); v != "" {
+		request.Header.Set("X-Actor", v)
+	}
 }
 
 func (s *httpManipulator) readHeaders(response *http.Response, mctx manipulate.Context) {
@@ -657,13 +757,25 @@ func (s *httpManipulator) send(
 			return nil, err
 		}
 
-		if err = sp.Tracer().Inject(sp.Context(), opentracing.TextMap, opentracing.HTTPHeadersCarrier(req.Header)); err != nil {
-			return nil, err
+		if _, ok := dest.(elemental.Identifiables); ok && s.maxResults > 0 {
+			capResults(req, mctx, s.maxResults)
+		}
+
+		// We propagate the trace to the backend. A tracer unable to
+		// inject the headers must not prevent the request from being sent.
+		if err = sp.Tracer().Inject(sp.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(req.Header)); err != nil {
+			sp.LogFields(log.Error(err))
 		}
 
 		// We injects the header from mctx.
 		s.prepareHeaders(req, mctx)
 
+		if s.requestSigner != nil {
+			if err = s.signRequest(req, body); err != nil {
+				return nil, err
+			}
+		}
+
 		ctx, cancel := context.WithTimeout(mctx.Context(), subContextTimeout)
 		cancelReq = cancel
 
@@ -679,6 +791,12 @@ func (s *httpManipulator) send(
 			return nil, err
 		}
 
+		var etagKey string
+		if s.etagStore != nil && method == http.MethodGet {
+			etagKey = makeETagKey(request)
+			setIfNoneMatch(s.etagStore, etagKey, request)
+		}
+
 		// We launch the request
 		response, err := s.client.Do(request)
 
@@ -706,6 +824,11 @@ func (s *httpManipulator) send(
 				goto RETRY
 			}
 
+			// We check for TLS errors, that are not worth retrying.
+			if isTLSError(uerr.Err) {
+				return nil, manipulate.NewErrTLSWithCause(err.Error(), uerr.Err)
+			}
+
 			// We check for error types.
 			switch uerr.Err.(type) {
 
@@ -727,16 +850,17 @@ func (s *httpManipulator) send(
 
 				goto RETRY
 
-			case x509.UnknownAuthorityError, x509.CertificateInvalidError, x509.HostnameError:
-				return nil, manipulate.NewErrTLS(err.Error())
-
 			default:
 				return nil, manipulate.NewErrCannotExecuteQuery(err.Error())
 			}
 		}
 
 		// We passed the basic error, we have a body.
-		// We register it so next loop will be clean.
+		// We limit its size, and we register it so next
+		// loop will be clean.
+		if s.maxResponseBytes > 0 {
+			response.Body = newLimitedBody(response.Body, s.maxResponseBytes)
+		}
 		responseBodyCloser = response.Body
 
 		// We check for http status codes that triggers a retry
@@ -768,6 +892,15 @@ func (s *httpManipulator) send(
 			goto RETRY
 		}
 
+		// We serve the cached response if it has not been modified,
+		// or we cache it if it has an ETag.
+		if etagKey != "" {
+			if err := applyETagStore(s.etagStore, etagKey, response); err != nil {
+				return nil, err
+			}
+			responseBodyCloser = response.Body
+		}
+
 		// We backport header info into mctx
 		s.readHeaders(response, mctx)
 
@@ -811,12 +944,23 @@ func (s *httpManipulator) send(
 			return response, nil
 		}
 
-		if dest == nil {
+		// Some servers send empty bodies without content
+		// length, for instance using chunked encoding.
+		if dest == nil || isEmptyBody(response) {
 			return response, nil
 		}
 
 		// If we have a given dest to decode, we decode it now.
-		if err := decodeData(response, dest); err != nil {
+		// Lists are streamed to avoid reading the whole body in memory.
+		if list, ok := dest.(elemental.Identifiables); ok {
+			handler, _ := mctx.(opaquer).Opaque()[opaqueKeyStreamHandler].(StreamHandler)
+			if handler != nil && !mctx.PreserveZeroValues() {
+				handler = withDefaultValues(handler)
+			}
+			if err := decodeStream(response, list, handler, s.maxResults); err != nil {
+				return nil, err
+			}
+		} else if err := decodeData(response, dest); err != nil {
 			return nil, err
 		}
 
@@ -865,12 +1009,42 @@ func (s *httpManipulator) send(
 		default:
 			// Otherwise we sleep backoff and we restart the retry loop.
 
+			s.stats.Retry()
 			time.Sleep(backoff.NextWithCurve(try, deadline, retryCurve))
 			try++
 		}
 	}
 }
 
+// signRequest calls the RequestSigner of the manipulator
+// with the given request and the content of the given body.
+func (s *httpManipulator) signRequest(request *http.Request, body *bytes.Reader) error {
+
+	var data []byte
+
+	if body != nil {
+
+		if _, err := body.Seek(0, 0); err != nil {
+			return manipulate.NewErrCannotBuildQuery(err.Error())
+		}
+
+		var err error
+		if data, err = ioutil.ReadAll(body); err != nil {
+			return manipulate.NewErrCannotBuildQuery(err.Error())
+		}
+
+		if _, err := body.Seek(0, 0); err != nil {
+			return manipulate.NewErrCannotBuildQuery(err.Error())
+		}
+	}
+
+	if err := s.requestSigner(request, data); err != nil {
+		return manipulate.NewErrCannotBuildQuery(fmt.Sprintf("unable to sign request: %s", err))
+	}
+
+	return nil
+}
+
 func (s *httpManipulator) registerRenewNotifier(id string, f func(string)) {
 
 	s.renewNotifiersLock.Lock()
@@ -12,9 +12,14 @@
 package maniphttp
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"net/http/httptest"
@@ -22,6 +27,8 @@ import (
 	"testing"
 	"time"
 
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
 	. "github.com/smartystreets/goconvey/convey"
 	"go.aporeto.io/elemental"
 	testmodel "go.aporeto.io/elemental/test/model"
@@ -124,6 +131,22 @@ func TestHTTP_New(t *testing.T) {
 		})
 	})
 
+	Convey("When I create a simple manipulator with HTTP/2 disabled", t, func() {
+
+		mm, _ := New(
+			context.Background(),
+			"http://url.com/",
+			OptionDisableHTTP2(),
+		)
+		m := mm.(*httpManipulator)
+
+		Convey("Then the transport should not attempt HTTP/2", func() {
+			So(m.transport.ForceAttemptHTTP2, ShouldBeFalse)
+			So(m.transport.TLSNextProto, ShouldNotBeNil)
+			So(m.transport.TLSNextProto, ShouldBeEmpty)
+		})
+	})
+
 	Convey("When I create a simple manipulator with custom transport", t, func() {
 
 		transport := &http.Transport{}
@@ -217,6 +240,38 @@ func TestHTTP_New(t *testing.T) {
 
 func TestHTTP_RetrieveMany(t *testing.T) {
 
+	Convey("Given I have a manipulator and a server returning the total count", t, func() {
+
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("X-Count-Total", "12")
+			fmt.Fprint(w, `[{"ID": "1", "name": "name1"}, {"ID": "2", "name": "name2"}]`)
+		}))
+		defer ts.Close()
+
+		m, _ := New(context.Background(), ts.URL)
+
+		Convey("When I retrieve a page of objects", func() {
+
+			mctx := manipulate.NewContext(
+				context.Background(),
+				manipulate.ContextOptionPage(1, 2),
+			)
+
+			var l testmodel.ListsList
+			err := m.RetrieveMany(mctx, &l)
+
+			Convey("Then err should be nil", func() {
+				So(err, ShouldBeNil)
+			})
+
+			Convey("Then the total count should be populated in the context", func() {
+				So(mctx.Count(), ShouldEqual, 12)
+				So(manipulate.PaginationFromContext(mctx), ShouldResemble, manipulate.Pagination{TotalCount: 12, TotalPages: 6, HasNext: true})
+			})
+		})
+	})
+
 	Convey("Given I have a manipulator and a working server", t, func() {
 
 		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -918,6 +973,103 @@ func TestHTTP_Delete(t *testing.T) {
 	})
 }
 
+func TestHTTP_SuccessStatusCodes(t *testing.T) {
+
+	Convey("Given I have a manipulator and a server returning a body with 200", t, func() {
+
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprint(w, `{"ID": "zzz", "name": "hello"}`)
+		}))
+		defer ts.Close()
+
+		m, _ := New(context.Background(), ts.URL)
+
+		Convey("When I update an object", func() {
+
+			list := testmodel.NewList()
+			list.ID = "zzz"
+			err := m.Update(nil, list)
+
+			Convey("Then the object should be decoded", func() {
+				So(err, ShouldBeNil)
+				So(list.Name, ShouldEqual, "hello")
+			})
+		})
+	})
+
+	Convey("Given I have a manipulator and a server returning 204", t, func() {
+
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer ts.Close()
+
+		m, _ := New(context.Background(), ts.URL)
+
+		Convey("When I delete an object", func() {
+
+			list := testmodel.NewList()
+			list.ID = "zzz"
+			err := m.Delete(nil, list)
+
+			Convey("Then err should be nil", func() {
+				So(err, ShouldBeNil)
+			})
+		})
+	})
+
+	Convey("Given I have a manipulator and a server returning 202", t, func() {
+
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusAccepted)
+			fmt.Fprint(w, `{"ID": "zzz", "name": "accepted"}`)
+		}))
+		defer ts.Close()
+
+		m, _ := New(context.Background(), ts.URL)
+
+		Convey("When I update an object", func() {
+
+			list := testmodel.NewList()
+			list.ID = "zzz"
+			err := m.Update(nil, list)
+
+			Convey("Then the object should be decoded", func() {
+				So(err, ShouldBeNil)
+				So(list.Name, ShouldEqual, "accepted")
+			})
+		})
+	})
+
+	Convey("Given I have a manipulator and a server returning an empty chunked body with 200", t, func() {
+
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+		}))
+		defer ts.Close()
+
+		m, _ := New(context.Background(), ts.URL)
+
+		Convey("When I update an object", func() {
+
+			list := testmodel.NewList()
+			list.ID = "zzz"
+			list.Name = "hello"
+			err := m.Update(nil, list)
+
+			Convey("Then err should be nil and the object untouched", func() {
+				So(err, ShouldBeNil)
+				So(list.Name, ShouldEqual, "hello")
+			})
+		})
+	})
+}
+
 func TestHTTP_DeleteMany(t *testing.T) {
 
 	Convey("Given I have a manipulator", t, func() {
@@ -1005,6 +1157,117 @@ func TestHTTP_Count(t *testing.T) {
 	})
 }
 
+func TestHTTP_Stats(t *testing.T) {
+
+	Convey("Given I have a manipulator and a working server", t, func() {
+
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"ID":"yyy"}`)) // nolint
+		}))
+		defer ts.Close()
+
+		mm, _ := New(context.Background(), ts.URL)
+
+		Convey("When I run some operations", func() {
+
+			list := testmodel.NewList()
+			list.ID = "xxx"
+
+			_ = mm.Delete(nil, list)
+			_ = mm.Delete(nil, nil)
+
+			Convey("Then the stats should be correct", func() {
+				st := mm.(manipulate.StatsProvider).Stats()
+				So(st.Operations, ShouldResemble, map[elemental.Operation]int64{elemental.OperationDelete: 2})
+				So(st.Errors, ShouldEqual, 1)
+				So(st.Retries, ShouldEqual, 0)
+			})
+		})
+	})
+}
+
+func TestHTTP_MaxResults(t *testing.T) {
+
+	Convey("Given I have a server returning a list of 3 objects", t, func() {
+
+		var pageSize string
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			pageSize = r.URL.Query().Get("pagesize")
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `[{"ID":"1"},{"ID":"2"},{"ID":"3"}]`)
+		}))
+		defer ts.Close()
+
+		Convey("When I retrieve it with a max of 2 results", func() {
+
+			m, _ := New(context.Background(), ts.URL, OptionMaxResults(2))
+
+			dest := testmodel.ListsList{}
+			err := m.RetrieveMany(manipulate.NewContext(context.Background()), &dest)
+
+			Convey("Then I should get an ErrTooManyResults", func() {
+				So(err, ShouldNotBeNil)
+				So(manipulate.IsTooManyResultsError(err), ShouldBeTrue)
+				So(pageSize, ShouldEqual, "3")
+			})
+		})
+
+		Convey("When I retrieve it with a max of 3 results", func() {
+
+			m, _ := New(context.Background(), ts.URL, OptionMaxResults(3))
+
+			dest := testmodel.ListsList{}
+			err := m.RetrieveMany(manipulate.NewContext(context.Background()), &dest)
+
+			Convey("Then it should work", func() {
+				So(err, ShouldBeNil)
+				So(len(dest), ShouldEqual, 3)
+				So(pageSize, ShouldEqual, "4")
+			})
+		})
+	})
+}
+
+func TestHTTP_MaxResponseBytes(t *testing.T) {
+
+	Convey("Given I have a server returning a large list", t, func() {
+
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `[{"ID":"1"},{"ID":"2"},{"ID":"3"}]`)
+		}))
+		defer ts.Close()
+
+		Convey("When I retrieve it with a small response size limit", func() {
+
+			m, _ := New(context.Background(), ts.URL, OptionMaxResponseBytes(16))
+
+			dest := testmodel.ListsList{}
+			err := m.RetrieveMany(manipulate.NewContext(context.Background()), &dest)
+
+			Convey("Then I should get an error", func() {
+				So(err, ShouldNotBeNil)
+				So(err, ShouldHaveSameTypeAs, manipulate.ErrCannotUnmarshal{})
+				So(err.Error(), ShouldContainSubstring, "response body is larger than 16 bytes")
+			})
+		})
+
+		Convey("When I retrieve it with the default response size limit", func() {
+
+			m, _ := New(context.Background(), ts.URL)
+
+			dest := testmodel.ListsList{}
+			err := m.RetrieveMany(manipulate.NewContext(context.Background()), &dest)
+
+			Convey("Then it should work", func() {
+				So(err, ShouldBeNil)
+				So(len(dest), ShouldEqual, 3)
+			})
+		})
+	})
+}
+
 func TestHTTP_send(t *testing.T) {
 
 	sp := tracing.StartTrace(nil, "test")
@@ -1156,6 +1419,63 @@ func TestHTTP_send(t *testing.T) {
 		So(resp, ShouldBeNil)
 	})
 
+	Convey("Given I have a server whose authority is given as a pool", t, func() {
+
+		ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer ts.Close()
+
+		pool := x509.NewCertPool()
+		pool.AddCert(ts.Certificate())
+
+		tlsConfig := &tls.Config{}
+
+		m, _ := New(
+			context.Background(),
+			ts.URL,
+			OptionTLSConfig(tlsConfig),
+			OptionRootCAs(pool),
+		)
+
+		_, err := m.(*httpManipulator).send(manipulate.NewContext(context.Background()), http.MethodGet, ts.URL, nil, nil, sp)
+
+		So(err, ShouldBeNil)
+		So(tlsConfig.RootCAs, ShouldBeNil)
+	})
+
+	Convey("Given I have a server whose certificate does not match the pinned one", t, func() {
+
+		ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		defer ts.Close()
+
+		pool := x509.NewCertPool()
+		pool.AddCert(ts.Certificate())
+
+		m, _ := New(
+			context.Background(),
+			ts.URL,
+			OptionTLSConfig(&tls.Config{RootCAs: pool}),
+			OptionPinnedCertificates([sha256.Size]byte{}),
+			OptionBackoffCurve(testingBackoffCurve),
+			OptionStrongBackoffCurve(testingBackoffCurve),
+		)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+		defer cancel()
+
+		resp, err := m.(*httpManipulator).send(manipulate.NewContext(ctx), http.MethodGet, ts.URL, nil, nil, sp)
+
+		var verificationErr *tls.CertificateVerificationError
+
+		So(err, ShouldNotBeNil)
+		So(err, ShouldHaveSameTypeAs, manipulate.ErrTLS{})
+		So(errors.As(err, &verificationErr), ShouldBeTrue)
+		So(verificationErr.Err.Error(), ShouldEndWith, "does not match any pinned hash")
+
+		So(resp, ShouldBeNil)
+	})
+
 	Convey("Given I have a server and a retry func and I call send", t, func() {
 
 		m, _ := New(
@@ -1822,6 +2142,161 @@ func TestHTTP_send(t *testing.T) {
 	})
 }
 
+func TestHTTP_RequestSigner(t *testing.T) {
+
+	sp := tracing.StartTrace(nil, "test")
+	defer sp.Finish()
+
+	Convey("Given I have a server expecting signed requests", t, func() {
+
+		var signature, data string
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			signature = r.Header.Get("X-Signature")
+			b, _ := ioutil.ReadAll(r.Body)
+			data = string(b)
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer ts.Close()
+
+		Convey("When I send a request with a signer", func() {
+
+			m, _ := New(
+				context.Background(),
+				ts.URL,
+				OptionRequestSigner(func(r *http.Request, body []byte) error {
+					r.Header.Set("X-Signature", r.Method+" "+r.URL.Path+" "+string(body))
+					return nil
+				}),
+			)
+
+			_, err := m.(*httpManipulator).send(manipulate.NewContext(context.Background()), http.MethodPost, ts.URL+"/lists", bytes.NewReader([]byte("hello")), nil, sp)
+
+			Convey("Then the request should be signed", func() {
+				So(err, ShouldBeNil)
+				So(signature, ShouldEqual, "POST /lists hello")
+				So(data, ShouldEqual, "hello")
+			})
+		})
+
+		Convey("When I send a request with a failing signer", func() {
+
+			m, _ := New(
+				context.Background(),
+				ts.URL,
+				OptionRequestSigner(func(r *http.Request, body []byte) error {
+					return fmt.Errorf("no key")
+				}),
+			)
+
+			_, err := m.(*httpManipulator).send(manipulate.NewContext(context.Background()), http.MethodGet, ts.URL+"/lists", nil, nil, sp)
+
+			Convey("Then I should get an error", func() {
+				So(err, ShouldNotBeNil)
+				So(err, ShouldHaveSameTypeAs, manipulate.ErrCannotBuildQuery{})
+				So(err.Error(), ShouldEqual, "Unable to build query: unable to sign request: no key")
+				So(signature, ShouldEqual, "")
+			})
+		})
+	})
+}
+
+func TestHTTP_TracePropagation(t *testing.T) {
+
+	tracer := mocktracer.New()
+	opentracing.SetGlobalTracer(tracer)
+	defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+
+	Convey("Given I have a server recording the trace headers", t, func() {
+
+		var traceID string
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			traceID = r.Header.Get("Mockpfx-Ids-Traceid")
+			w.Header().Set("X-Count-Total", "10")
+		}))
+		defer ts.Close()
+
+		m, _ := New(context.Background(), ts.URL)
+
+		parent := tracer.StartSpan("parent")
+		defer parent.Finish()
+		expectedTraceID := fmt.Sprintf("%d", parent.Context().(mocktracer.MockSpanContext).TraceID)
+
+		Convey("When I send a request with a span in the context", func() {
+
+			mctx := manipulate.NewContext(opentracing.ContextWithSpan(context.Background(), parent))
+
+			_, err := m.Count(mctx, testmodel.TaskIdentity)
+
+			Convey("Then the trace should be propagated", func() {
+				So(err, ShouldBeNil)
+				So(traceID, ShouldEqual, expectedTraceID)
+			})
+		})
+
+		Convey("When I send a request with a SpanContext", func() {
+
+			mctx := manipulate.NewContext(
+				context.Background(),
+				manipulate.ContextOptionSpanContext(parent.Context()),
+			)
+
+			_, err := m.Count(mctx, testmodel.TaskIdentity)
+
+			Convey("Then the trace should be propagated", func() {
+				So(err, ShouldBeNil)
+				So(traceID, ShouldEqual, expectedTraceID)
+			})
+		})
+	})
+}
+
+func TestHTTP_ClientCertificateSelector(t *testing.T) {
+
+	sp := tracing.StartTrace(nil, "test")
+	defer sp.Finish()
+
+	Convey("Given I have a TLS server requesting client certificates", t, func() {
+
+		ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		ts.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+		ts.StartTLS()
+		defer ts.Close()
+
+		pool := x509.NewCertPool()
+		pool.AddCert(ts.Certificate())
+
+		type ctxKey struct{}
+
+		var tenant interface{}
+		m, _ := New(
+			context.Background(),
+			ts.URL,
+			OptionTLSConfig(&tls.Config{RootCAs: pool}),
+			OptionClientCertificateSelector(func(ctx context.Context, info *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+				tenant = ctx.Value(ctxKey{})
+				return &tls.Certificate{}, nil
+			}),
+		)
+
+		Convey("When I send a request", func() {
+
+			ctx := context.WithValue(context.Background(), ctxKey{}, "tenant-a")
+			_, err := m.(*httpManipulator).send(manipulate.NewContext(ctx), http.MethodGet, ts.URL, nil, nil, sp)
+
+			Convey("Then the selector should have been called with the request context", func() {
+				So(err, ShouldBeNil)
+				So(tenant, ShouldEqual, "tenant-a")
+			})
+
+			Convey("Then the extracted tls config should use the selector", func() {
+				So(ExtractTLSConfig(m).GetClientCertificate, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
 func TestHTTP_makeAuthorizationHeaders(t *testing.T) {
 
 	Convey("Given I create a new HTTP manipulator", t, func() {
@@ -1875,6 +2350,17 @@ func TestHTTP_prepareHeaders(t *testing.T) {
 					So(req.Header.Get("Header-1"), ShouldEqual, "hey")
 					So(req.Header.Get("Header-2"), ShouldEqual, "ho")
 					So(req.Header.Get("Content-Type"), ShouldEqual, "application/json")
+					So(req.Header.Get("User-Agent"), ShouldEqual, defaultUserAgent)
+				})
+			})
+
+			Convey("When I prepareHeaders with a custom user agent", func() {
+
+				OptionUserAgent("my-client/1.0")(m)
+				m.prepareHeaders(req, manipulate.NewContext(context.Background()))
+
+				Convey("Then header should be correct", func() {
+					So(req.Header.Get("User-Agent"), ShouldEqual, "my-client/1.0")
 				})
 			})
 
@@ -1888,6 +2374,7 @@ func TestHTTP_prepareHeaders(t *testing.T) {
 					manipulate.ContextOptionFields([]string{"a", "b"}),
 					manipulate.ContextOptionCredentials("username", "password"),
 					manipulate.ContextOptionClientIP("10.1.1.1"),
+					manipulate.ContextOptionActor("bob"),
 				)
 
 				ctx.(idempotency.Keyer).SetIdempotencyKey("coucou")
@@ -1903,6 +2390,7 @@ func TestHTTP_prepareHeaders(t *testing.T) {
 					So(req.Header["X-Fields"], ShouldResemble, []string{"a", "b"})
 					So(req.Header.Get("Authorization"), ShouldResemble, "username password")
 					So(req.Header["X-Forwarded-For"], ShouldResemble, []string{"10.1.1.1"})
+					So(req.Header.Get("X-Actor"), ShouldEqual, "bob")
 					So(req.Header.Get("Content-Type"), ShouldEqual, "application/json")
 				})
 			})
@@ -1922,6 +2410,20 @@ func TestHTTP_prepareHeaders(t *testing.T) {
 					So(req.Header.Get("Accept"), ShouldEqual, "mew")
 				})
 			})
+
+			Convey("When I prepareHeaders with using ContextOptionLocale", func() {
+
+				ctx := manipulate.NewContext(
+					context.Background(),
+					ContextOptionLocale("fr-FR"),
+				)
+
+				m.prepareHeaders(req, ctx)
+
+				Convey("Then header should be correct", func() {
+					So(req.Header.Get("Accept-Language"), ShouldEqual, "fr-FR")
+				})
+			})
 		})
 	})
 }
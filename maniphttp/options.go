@@ -12,7 +12,10 @@
 package maniphttp
 
 import (
+	"context"
+	"crypto/sha256"
 	"crypto/tls"
+	"crypto/x509"
 	"net/http"
 	"time"
 
@@ -86,12 +89,109 @@ func OptionHTTPTransport(transport *http.Transport) Option {
 }
 
 // OptionTLSConfig sets the tls.Config to use for the manipulator.
+// It can be built with manipulate.NewTLSConfig.
 func OptionTLSConfig(tlsConfig *tls.Config) Option {
 	return func(m *httpManipulator) {
 		m.tlsConfig = tlsConfig
 	}
 }
 
+// OptionRootCAs sets the certificate pool used to verify the certificate
+// of the server, instead of the system one or the one of the tls.Config
+// given to OptionTLSConfig. This allows to assemble the trusted authorities
+// from several sources in code, without reading them from disk.
+// This option has no effect if you use OptionHTTPClient.
+func OptionRootCAs(pool *x509.CertPool) Option {
+	return func(m *httpManipulator) {
+		m.rootCAs = pool
+	}
+}
+
+// A ClientCertificateSelector returns the client certificate to present
+// during a TLS handshake requested by the server. The given context is the
+// one of the request that caused the connection to be established.
+type ClientCertificateSelector func(ctx context.Context, info *tls.CertificateRequestInfo) (*tls.Certificate, error)
+
+// OptionClientCertificateSelector sets the ClientCertificateSelector used to
+// choose the client certificate at handshake time, for instance according to
+// values stored in the context of the request, instead of always presenting
+// the certificates of the tls.Config.
+//
+// As connections are reused, the selected certificate is used by all the
+// requests sent through the connection. If the certificate depends on the
+// request, you must also disable the keep alives using OptionHTTPTransport.
+// This option has no effect if you use OptionHTTPClient.
+func OptionClientCertificateSelector(selector ClientCertificateSelector) Option {
+	return func(m *httpManipulator) {
+		m.clientCertificateSelector = selector
+	}
+}
+
+// OptionClientCertificateFiles sets the PEM encoded files containing the
+// client certificate and its key. The files are checked at the given interval,
+// and the certificate is reloaded when they change, so rotated certificates
+// are used without creating a new manipulator. The new certificate is used by
+// the new connections, and the idle ones are closed. If the new files cannot
+// be loaded, the current certificate is kept until they can. The files are
+// checked until the context given to New is canceled.
+// This option cannot be used with OptionClientCertificateSelector, and
+// has no effect if you use OptionHTTPClient.
+func OptionClientCertificateFiles(certPath string, keyPath string, interval time.Duration) Option {
+
+	if interval <= 0 {
+		panic("interval must be greater than 0")
+	}
+
+	return func(m *httpManipulator) {
+		m.certificateFiles = &certificateFiles{
+			certPath: certPath,
+			keyPath:  keyPath,
+			interval: interval,
+		}
+	}
+}
+
+// OptionRevocationLists sets the certificate revocation lists used to
+// reject the connections to a server whose certificate, or the certificate
+// of one of its intermediate authorities, has been revoked. The revocation
+// lists must be signed by the issuers of the revoked certificates. This
+// is done in addition to the normal verification of the certificates.
+// The connections are also rejected if a list of the issuer of a certificate
+// is past its next update, so the lists must be refreshed by the caller.
+// OCSP is not supported, and by default, the revocation of the certificates
+// is not checked.
+// This option has no effect if you use OptionHTTPClient.
+func OptionRevocationLists(crls ...*x509.RevocationList) Option {
+	return func(m *httpManipulator) {
+		m.peerCertificateVerifiers = append(m.peerCertificateVerifiers, makeRevocationVerifier(crls))
+	}
+}
+
+// OptionPinnedCertificates pins the certificate of the server. The connections
+// are rejected if the SHA-256 hash of the DER encoded certificate of the server
+// is not one of the given hashes. This is done in addition to the normal
+// verification of the certificates, and it is useful when talking to
+// a known endpoint to defend against compromised authorities.
+// This option has no effect if you use OptionHTTPClient.
+func OptionPinnedCertificates(hashes ...[sha256.Size]byte) Option {
+	return func(m *httpManipulator) {
+		m.peerCertificateVerifiers = append(m.peerCertificateVerifiers, makePinningVerifier(hashes, hashCertificate))
+	}
+}
+
+// OptionPinnedPublicKeys pins the public key of the server. The connections
+// are rejected if the SHA-256 hash of the DER encoded public key info of the
+// certificate of the server is not one of the given hashes. Unlike
+// OptionPinnedCertificates, the pins remain valid when the certificate
+// is renewed with the same key. This is done in addition to the normal
+// verification of the certificates.
+// This option has no effect if you use OptionHTTPClient.
+func OptionPinnedPublicKeys(hashes ...[sha256.Size]byte) Option {
+	return func(m *httpManipulator) {
+		m.peerCertificateVerifiers = append(m.peerCertificateVerifiers, makePinningVerifier(hashes, hashPublicKey))
+	}
+}
+
 // OptionDisableBuiltInRetry disables the auto retry mechanism
 // built in maniphttp Manipulator.
 // By default, the manipulator will silently retry on communication
@@ -126,6 +226,15 @@ func OptionDisableCompression() Option {
 	}
 }
 
+// OptionDisableHTTP2 forces the http transport to
+// use HTTP/1.1. This only has effect if you don't set
+// a custom transport.
+func OptionDisableHTTP2() Option {
+	return func(m *httpManipulator) {
+		m.disableHTTP2 = true
+	}
+}
+
 // OptionSendCredentialsAsCookie configures the manipulator to
 // send the password as a cookie using the provided key.
 func OptionSendCredentialsAsCookie(key string) Option {
@@ -186,9 +295,88 @@ func OptionStrongBackoffCurve(curve []time.Duration) Option {
 	}
 }
 
+// OptionIdentifierGenerator sets the manipulate.IdentifierGenerator used
+// to generate the identifiers of the created objects on the client side.
+// The generated identifier is sent to the server along with the object.
+// By default, the identifiers are assigned by the server.
+func OptionIdentifierGenerator(generator manipulate.IdentifierGenerator) Option {
+	return func(m *httpManipulator) {
+		m.idGenerator = generator
+	}
+}
+
+// A RequestSigner signs the given request before it is sent, usually by
+// setting a signature header computed from its method, URL, headers and
+// the given body, that is nil if the request has no body.
+type RequestSigner func(request *http.Request, body []byte) error
+
+// OptionRequestSigner sets the RequestSigner to call before sending every
+// request, for instance to use HMAC signatures expected by some gateways.
+// It is called for every try, after all the other headers have been set,
+// so the signature can include a fresh timestamp. If it returns an error,
+// the request is not sent.
+func OptionRequestSigner(signer RequestSigner) Option {
+	return func(m *httpManipulator) {
+		m.requestSigner = signer
+	}
+}
+
+// OptionETagStore enables the caching of GET responses having an ETag
+// in the given store, which can be created with NewETagStore. The cached
+// ETag is sent in the If-None-Match header of the next identical request,
+// and a 304 Not Modified response is served from the cached copy.
+func OptionETagStore(store ETagStore) Option {
+	return func(m *httpManipulator) {
+		m.etagStore = store
+	}
+}
+
+// OptionMaxResults sets the maximum number of objects RetrieveMany
+// accepts. List requests asking for more, or for an unbounded number
+// of objects, are capped so the server returns at most max + 1 objects,
+// and RetrieveMany returns a manipulate.ErrTooManyResults if it
+// returns more than max. This protects the client from unexpectedly
+// large responses. Set 0 to disable, which is the default.
+func OptionMaxResults(max int) Option {
+
+	if max < 0 {
+		panic("max must be positive")
+	}
+
+	return func(m *httpManipulator) {
+		m.maxResults = max
+	}
+}
+
+// OptionMaxResponseBytes sets the maximum size of the response bodies.
+// Reading a larger body fails, which protects the client from servers
+// sending unbounded responses. It defaults to 1GiB. Set 0 to disable.
+func OptionMaxResponseBytes(max int64) Option {
+
+	if max < 0 {
+		panic("max must be positive")
+	}
+
+	return func(m *httpManipulator) {
+		m.maxResponseBytes = max
+	}
+}
+
+// OptionUserAgent sets the User-Agent header sent with every request,
+// so servers can identify the clients in their logs. It defaults to
+// manipulate/<version>. Set an empty string to send the default
+// User-Agent of the Go http client instead.
+func OptionUserAgent(userAgent string) Option {
+	return func(m *httpManipulator) {
+		m.userAgent = userAgent
+	}
+}
+
 var (
 	opaqueKeyOverrideHeaderContentType = "maniphttp.opaqueKeyOverrideHeaderContentType"
 	opaqueKeyOverrideHeaderAccept      = "maniphttp.opaqueKeyOverrideHeaderAccept"
+	opaqueKeyLocale                    = "maniphttp.opaqueKeyLocale"
+	opaqueKeyStreamHandler             = "maniphttp.opaqueKeyStreamHandler"
 )
 
 type opaquer interface {
@@ -214,3 +402,30 @@ func ContextOptionOverrideAccept(accept string) manipulate.ContextOption {
 		c.(opaquer).Opaque()[opaqueKeyOverrideHeaderAccept] = accept
 	}
 }
+
+// ContextOptionLocale sets the locale, like "fr-FR" or "fr;q=0.9, en;q=0.8",
+// sent in the Accept-Language header of the request, so the server can
+// return localized content. It takes precedence over a global Accept-Language header.
+func ContextOptionLocale(locale string) manipulate.ContextOption {
+
+	return func(c manipulate.Context) {
+		c.(opaquer).Opaque()[opaqueKeyLocale] = locale
+	}
+}
+
+// StreamHandler is called with each object of a RetrieveMany response.
+// If it returns an error, the decoding stops and the error is returned
+// by RetrieveMany.
+type StreamHandler func(object elemental.Identifiable) error
+
+// ContextOptionStreamHandler sets a handler that receives the objects of a
+// RetrieveMany response one by one as they are decoded, instead of them
+// being appended to the destination, which stays empty. Combined with the
+// streaming decoding of JSON responses, this bounds the memory used to
+// retrieve very large lists.
+func ContextOptionStreamHandler(handler StreamHandler) manipulate.ContextOption {
+
+	return func(c manipulate.Context) {
+		c.(opaquer).Opaque()[opaqueKeyStreamHandler] = handler
+	}
+}
@@ -13,7 +13,10 @@ package maniphttp
 
 import (
 	"context"
+	"crypto/sha256"
 	"crypto/tls"
+	"crypto/x509"
+	"fmt"
 	"net/http"
 	"testing"
 	"time"
@@ -110,6 +113,12 @@ func Test_Options(t *testing.T) {
 		So(m.disableCompression, ShouldEqual, true)
 	})
 
+	Convey("Calling OptionDisableHTTP2 should work", t, func() {
+		m := &httpManipulator{}
+		OptionDisableHTTP2()(m)
+		So(m.disableHTTP2, ShouldEqual, true)
+	})
+
 	Convey("Calling OptionSimulateFailures should work", t, func() {
 		m := &httpManipulator{}
 		f := map[float64]error{}
@@ -144,6 +153,94 @@ func Test_Options(t *testing.T) {
 		So(m.strongBackoffCurve, ShouldResemble, t)
 	})
 
+	Convey("Calling OptionIdentifierGenerator should work", t, func() {
+		m := &httpManipulator{}
+		OptionIdentifierGenerator(func(elemental.Identifiable) string { return "id" })(m)
+		So(m.idGenerator(nil), ShouldEqual, "id")
+	})
+
+	Convey("Calling OptionRootCAs should work", t, func() {
+		m := &httpManipulator{}
+		pool := x509.NewCertPool()
+		OptionRootCAs(pool)(m)
+		So(m.rootCAs, ShouldEqual, pool)
+	})
+
+	Convey("Calling OptionClientCertificateSelector should work", t, func() {
+		m := &httpManipulator{}
+		cert := &tls.Certificate{}
+		OptionClientCertificateSelector(func(context.Context, *tls.CertificateRequestInfo) (*tls.Certificate, error) { return cert, nil })(m)
+		c, _ := m.clientCertificateSelector(context.Background(), nil)
+		So(c, ShouldEqual, cert)
+	})
+
+	Convey("Calling OptionClientCertificateFiles should work", t, func() {
+		m := &httpManipulator{}
+		OptionClientCertificateFiles("cert.pem", "key.pem", time.Minute)(m)
+		So(m.certificateFiles, ShouldResemble, &certificateFiles{certPath: "cert.pem", keyPath: "key.pem", interval: time.Minute})
+	})
+
+	Convey("Calling OptionClientCertificateFiles with an invalid interval should panic", t, func() {
+		So(func() { OptionClientCertificateFiles("cert.pem", "key.pem", 0) }, ShouldPanicWith, "interval must be greater than 0")
+	})
+
+	Convey("Calling OptionRevocationLists should work", t, func() {
+		m := &httpManipulator{}
+		OptionRevocationLists(&x509.RevocationList{})(m)
+		So(len(m.peerCertificateVerifiers), ShouldEqual, 1)
+	})
+
+	Convey("Calling OptionPinnedCertificates should work", t, func() {
+		m := &httpManipulator{}
+		OptionPinnedCertificates([sha256.Size]byte{})(m)
+		So(len(m.peerCertificateVerifiers), ShouldEqual, 1)
+	})
+
+	Convey("Calling OptionPinnedPublicKeys should work", t, func() {
+		m := &httpManipulator{}
+		OptionPinnedPublicKeys([sha256.Size]byte{})(m)
+		So(len(m.peerCertificateVerifiers), ShouldEqual, 1)
+	})
+
+	Convey("Calling OptionRequestSigner should work", t, func() {
+		m := &httpManipulator{}
+		OptionRequestSigner(func(*http.Request, []byte) error { return fmt.Errorf("signed") })(m)
+		So(m.requestSigner(nil, nil), ShouldResemble, fmt.Errorf("signed"))
+	})
+
+	Convey("Calling OptionETagStore should work", t, func() {
+		m := &httpManipulator{}
+		store := NewETagStore()
+		OptionETagStore(store)(m)
+		So(m.etagStore, ShouldEqual, store)
+	})
+
+	Convey("Calling OptionMaxResults should work", t, func() {
+		m := &httpManipulator{}
+		OptionMaxResults(10)(m)
+		So(m.maxResults, ShouldEqual, 10)
+	})
+
+	Convey("Calling OptionMaxResults with a negative value should panic", t, func() {
+		So(func() { OptionMaxResults(-1) }, ShouldPanicWith, "max must be positive")
+	})
+
+	Convey("Calling OptionMaxResponseBytes should work", t, func() {
+		m := &httpManipulator{}
+		OptionMaxResponseBytes(42)(m)
+		So(m.maxResponseBytes, ShouldEqual, 42)
+	})
+
+	Convey("Calling OptionMaxResponseBytes with a negative value should panic", t, func() {
+		So(func() { OptionMaxResponseBytes(-1) }, ShouldPanicWith, "max must be positive")
+	})
+
+	Convey("Calling OptionUserAgent should work", t, func() {
+		m := &httpManipulator{}
+		OptionUserAgent("my-client/1.0")(m)
+		So(m.userAgent, ShouldEqual, "my-client/1.0")
+	})
+
 	Convey("Calling ContextOptionOverrideContentType should work", t, func() {
 		mctx := manipulate.NewContext(context.Background())
 		ContextOptionOverrideContentType("chien")(mctx)
@@ -155,4 +252,16 @@ func Test_Options(t *testing.T) {
 		ContextOptionOverrideAccept("chien")(mctx)
 		So(mctx.(opaquer).Opaque()[opaqueKeyOverrideHeaderAccept], ShouldEqual, "chien")
 	})
+
+	Convey("Calling ContextOptionLocale should work", t, func() {
+		mctx := manipulate.NewContext(context.Background())
+		ContextOptionLocale("fr-FR")(mctx)
+		So(mctx.(opaquer).Opaque()[opaqueKeyLocale], ShouldEqual, "fr-FR")
+	})
+
+	Convey("Calling ContextOptionStreamHandler should work", t, func() {
+		mctx := manipulate.NewContext(context.Background())
+		ContextOptionStreamHandler(func(elemental.Identifiable) error { return nil })(mctx)
+		So(mctx.(opaquer).Opaque()[opaqueKeyStreamHandler], ShouldNotBeNil)
+	})
 }
@@ -0,0 +1,135 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package maniphttp
+
+import (
+	"context"
+	"crypto/tls"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// certificateFiles holds the configuration of
+// a client certificate loaded from files.
+type certificateFiles struct {
+	certPath string
+	keyPath  string
+	interval time.Duration
+}
+
+// A certificateReloader holds a client certificate loaded
+// from files and reloads it when the files change.
+type certificateReloader struct {
+	certPath string
+	keyPath  string
+	onReload func()
+
+	cert    atomic.Value // *tls.Certificate
+	modTime time.Time
+}
+
+// newCertificateReloader returns a new certificateReloader with the
+// certificate loaded from the given files. The given onReload function,
+// that can be nil, is called every time the certificate is reloaded.
+func newCertificateReloader(certPath string, keyPath string, onReload func()) (*certificateReloader, error) {
+
+	r := &certificateReloader{
+		certPath: certPath,
+		keyPath:  keyPath,
+		onReload: onReload,
+	}
+
+	modTime, err := r.lastModTime()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+
+	r.modTime = modTime
+
+	return r, nil
+}
+
+// certificate returns the current certificate.
+func (r *certificateReloader) certificate() *tls.Certificate {
+	return r.cert.Load().(*tls.Certificate)
+}
+
+// run checks the files at the given interval, and reloads the certificate
+// when they change, until the given context is canceled. If the files cannot
+// be loaded, the current certificate is kept and the files are checked again
+// at the next interval.
+func (r *certificateReloader) run(ctx context.Context, interval time.Duration) {
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+
+		case <-ticker.C:
+
+			modTime, err := r.lastModTime()
+			if err != nil || modTime.Equal(r.modTime) {
+				continue
+			}
+
+			if err := r.load(); err != nil {
+				continue
+			}
+
+			r.modTime = modTime
+
+			if r.onReload != nil {
+				r.onReload()
+			}
+
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (r *certificateReloader) load() error {
+
+	cert, err := tls.LoadX509KeyPair(r.certPath, r.keyPath)
+	if err != nil {
+		return err
+	}
+
+	r.cert.Store(&cert)
+
+	return nil
+}
+
+// lastModTime returns the most recent modification time of the files.
+func (r *certificateReloader) lastModTime() (time.Time, error) {
+
+	var last time.Time
+
+	for _, p := range []string{r.certPath, r.keyPath} {
+
+		info, err := os.Stat(p)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		if info.ModTime().After(last) {
+			last = info.ModTime()
+		}
+	}
+
+	return last, nil
+}
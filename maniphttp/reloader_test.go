@@ -0,0 +1,138 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package maniphttp
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func writeTestCertificateFiles(dir string, cn string, modTime time.Time) (string, string) {
+
+	cert, key := makeTestCertificate(cn, 1, nil, nil)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		panic(err)
+	}
+
+	certPath := filepath.Join(dir, "cert.pem")
+	keyPath := filepath.Join(dir, "key.pem")
+
+	if err := ioutil.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0600); err != nil {
+		panic(err)
+	}
+
+	if err := ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		panic(err)
+	}
+
+	for _, p := range []string{certPath, keyPath} {
+		if err := os.Chtimes(p, modTime, modTime); err != nil {
+			panic(err)
+		}
+	}
+
+	return certPath, keyPath
+}
+
+func certificateCommonName(r *certificateReloader) string {
+
+	cert, err := x509.ParseCertificate(r.certificate().Certificate[0])
+	if err != nil {
+		panic(err)
+	}
+
+	return cert.Subject.CommonName
+}
+
+func TestCertificateReloader(t *testing.T) {
+
+	Convey("Given I have certificate files", t, func() {
+
+		dir, err := ioutil.TempDir("", "reloader")
+		if err != nil {
+			panic(err)
+		}
+		defer os.RemoveAll(dir) // nolint
+
+		now := time.Now()
+		certPath, keyPath := writeTestCertificateFiles(dir, "first", now.Add(-time.Minute))
+
+		var reloads int64
+		r, err := newCertificateReloader(certPath, keyPath, func() { atomic.AddInt64(&reloads, 1) })
+
+		Convey("Then the certificate should be loaded", func() {
+			So(err, ShouldBeNil)
+			So(certificateCommonName(r), ShouldEqual, "first")
+		})
+
+		Convey("When I run the reloader and the files change", func() {
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			go r.run(ctx, 10*time.Millisecond)
+
+			writeTestCertificateFiles(dir, "second", now)
+
+			Convey("Then the certificate should be reloaded", func() {
+				So(func() bool {
+					for i := 0; i < 100; i++ {
+						if atomic.LoadInt64(&reloads) == 1 {
+							return true
+						}
+						time.Sleep(10 * time.Millisecond)
+					}
+					return false
+				}(), ShouldBeTrue)
+				So(certificateCommonName(r), ShouldEqual, "second")
+			})
+		})
+
+		Convey("When I run the reloader and the files become invalid", func() {
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			go r.run(ctx, 10*time.Millisecond)
+
+			if err := ioutil.WriteFile(keyPath, []byte("nope"), 0600); err != nil {
+				panic(err)
+			}
+
+			time.Sleep(50 * time.Millisecond)
+
+			Convey("Then the current certificate should be kept", func() {
+				So(atomic.LoadInt64(&reloads), ShouldEqual, 0)
+				So(certificateCommonName(r), ShouldEqual, "first")
+			})
+		})
+	})
+
+	Convey("Given I have missing certificate files", t, func() {
+
+		_, err := newCertificateReloader("/not/cert.pem", "/not/key.pem", nil)
+
+		Convey("Then err should not be nil", func() {
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
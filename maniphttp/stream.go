@@ -0,0 +1,164 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package maniphttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+
+	"go.aporeto.io/elemental"
+	"go.aporeto.io/manipulate"
+)
+
+// decodeStream decodes the list contained in the given response into dest,
+// replacing its content.
+//
+// JSON lists are decoded element by element from the body so the raw
+// response is never held in memory. If handler is not nil, each decoded
+// object is given to it instead of being appended to dest. Other encodings
+// are decoded at once, and the objects are then given to the handler.
+// If max is greater than 0, a manipulate.ErrTooManyResults is returned
+// as soon as the list holds more than max objects, before giving the
+// extra object to the handler.
+func decodeStream(r *http.Response, dest elemental.Identifiables, handler StreamHandler, max int) error {
+
+	encoding := elemental.EncodingTypeJSON
+	if r.Header.Get("Content-Type") != "" {
+		var err error
+		if encoding, _, err = elemental.EncodingFromHeaders(r.Header); err != nil {
+			return elemental.NewErrors(err)
+		}
+	}
+
+	if encoding != elemental.EncodingTypeJSON {
+
+		if err := decodeData(r, dest); err != nil {
+			return err
+		}
+
+		if max > 0 && len(dest.List()) > max {
+			return tooManyResults(max)
+		}
+
+		if handler == nil {
+			return nil
+		}
+
+		for _, o := range dest.List() {
+			if err := handler(o); err != nil {
+				return err
+			}
+		}
+
+		return truncate(dest)
+	}
+
+	list := reflect.ValueOf(dest)
+	if list.Kind() != reflect.Ptr || list.Elem().Kind() != reflect.Slice || list.Elem().Type().Elem().Kind() != reflect.Ptr {
+		return manipulate.NewErrCannotUnmarshal(fmt.Sprintf("unable to stream into %T: dest must be a pointer to a slice of pointers", dest))
+	}
+
+	list = list.Elem()
+	list.SetLen(0)
+	typ := list.Type().Elem().Elem()
+
+	dec := json.NewDecoder(r.Body)
+
+	token, err := dec.Token()
+	if err != nil {
+		return manipulate.NewErrCannotUnmarshal(fmt.Sprintf("unable to read data: %s", err.Error()))
+	}
+
+	// A null list is an empty list.
+	if token == nil {
+		return nil
+	}
+
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return manipulate.NewErrCannotUnmarshal(fmt.Sprintf("unable to read data: expected a list but got %v", token))
+	}
+
+	var n int
+	for dec.More() {
+
+		n++
+		if max > 0 && n > max {
+			return tooManyResults(max)
+		}
+
+		var data json.RawMessage
+		if err := dec.Decode(&data); err != nil {
+			return manipulate.NewErrCannotUnmarshal(fmt.Sprintf("unable to read data: %s", err.Error()))
+		}
+
+		obj := reflect.New(typ)
+		if err := elemental.Decode(elemental.EncodingTypeJSON, data, obj.Interface()); err != nil {
+			return manipulate.NewErrCannotUnmarshal(fmt.Sprintf("%s. original data:\n%s", err.Error(), string(data)))
+		}
+
+		if handler == nil {
+			list.Set(reflect.Append(list, obj))
+			continue
+		}
+
+		o, ok := obj.Interface().(elemental.Identifiable)
+		if !ok {
+			return manipulate.NewErrCannotUnmarshal(fmt.Sprintf("unable to stream into %T: %s is not an elemental.Identifiable", dest, obj.Type()))
+		}
+
+		if err := handler(o); err != nil {
+			return err
+		}
+	}
+
+	if _, err := dec.Token(); err != nil && err != io.EOF {
+		return manipulate.NewErrCannotUnmarshal(fmt.Sprintf("unable to read data: %s", err.Error()))
+	}
+
+	return nil
+}
+
+// tooManyResults returns the error returned when
+// a list holds more than the given max objects.
+func tooManyResults(max int) error {
+	return manipulate.NewErrTooManyResults(fmt.Sprintf("more than %d objects returned", max))
+}
+
+// withDefaultValues returns a StreamHandler backporting the default
+// values of the objects before giving them to the given handler.
+func withDefaultValues(handler StreamHandler) StreamHandler {
+
+	return func(o elemental.Identifiable) error {
+
+		if a, ok := o.(elemental.AttributeSpecifiable); ok {
+			elemental.ResetDefaultForZeroValues(a)
+		}
+
+		return handler(o)
+	}
+}
+
+// truncate empties the given list.
+func truncate(dest elemental.Identifiables) error {
+
+	list := reflect.ValueOf(dest)
+	if list.Kind() != reflect.Ptr || list.Elem().Kind() != reflect.Slice {
+		return manipulate.NewErrCannotUnmarshal(fmt.Sprintf("unable to stream into %T: dest must be a pointer to a slice", dest))
+	}
+
+	list.Elem().SetLen(0)
+
+	return nil
+}
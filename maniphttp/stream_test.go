@@ -0,0 +1,204 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package maniphttp
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.aporeto.io/elemental"
+	testmodel "go.aporeto.io/elemental/test/model"
+	"go.aporeto.io/manipulate"
+)
+
+func TestStream_decodeStream(t *testing.T) {
+
+	makeResponse := func(body string) *http.Response {
+		return &http.Response{
+			Header: http.Header{"Content-Type": []string{"application/json"}},
+			Body:   ioutil.NopCloser(strings.NewReader(body)),
+		}
+	}
+
+	Convey("Given I have a response containing a list", t, func() {
+
+		r := makeResponse(`[{"ID":"1"},{"ID":"2"},{"ID":"3"}]`)
+
+		Convey("When I decode it without handler", func() {
+
+			dest := testmodel.ListsList{}
+			err := decodeStream(r, &dest, nil, 0)
+
+			Convey("Then the objects should be in the list", func() {
+				So(err, ShouldBeNil)
+				So(len(dest), ShouldEqual, 3)
+			})
+		})
+
+		Convey("When I decode it with a handler", func() {
+
+			var handled []elemental.Identifiable
+			dest := testmodel.ListsList{}
+			err := decodeStream(r, &dest, func(o elemental.Identifiable) error {
+				handled = append(handled, o)
+				return nil
+			}, 0)
+
+			Convey("Then the handler should have received the objects", func() {
+				So(err, ShouldBeNil)
+				So(len(handled), ShouldEqual, 3)
+				So(handled[0], ShouldHaveSameTypeAs, &testmodel.List{})
+				So(len(dest), ShouldEqual, 0)
+			})
+		})
+
+		Convey("When I decode it into a list that is not empty", func() {
+
+			dest := testmodel.ListsList{&testmodel.List{ID: "old"}}
+			err := decodeStream(r, &dest, nil, 0)
+
+			Convey("Then the list should only hold the decoded objects", func() {
+				So(err, ShouldBeNil)
+				So(len(dest), ShouldEqual, 3)
+				So(dest[0].ID, ShouldNotEqual, "old")
+			})
+		})
+
+		Convey("When I decode it with a handler and a max lower than the number of objects", func() {
+
+			var handled int
+			dest := testmodel.ListsList{}
+			err := decodeStream(r, &dest, func(o elemental.Identifiable) error {
+				handled++
+				return nil
+			}, 2)
+
+			Convey("Then the handler should not receive more than max objects", func() {
+				So(manipulate.IsTooManyResultsError(err), ShouldBeTrue)
+				So(err.Error(), ShouldEqual, "Too many results: more than 2 objects returned")
+				So(handled, ShouldEqual, 2)
+			})
+		})
+
+		Convey("When I decode it with a max equal to the number of objects", func() {
+
+			dest := testmodel.ListsList{}
+			err := decodeStream(r, &dest, nil, 3)
+
+			Convey("Then it should work", func() {
+				So(err, ShouldBeNil)
+				So(len(dest), ShouldEqual, 3)
+			})
+		})
+
+		Convey("When I decode it with a failing handler", func() {
+
+			var calls int
+			dest := testmodel.ListsList{}
+			err := decodeStream(r, &dest, func(o elemental.Identifiable) error {
+				calls++
+				return fmt.Errorf("boom")
+			}, 0)
+
+			Convey("Then the decoding should stop", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldEqual, "boom")
+				So(calls, ShouldEqual, 1)
+			})
+		})
+
+		Convey("When I decode it into a list that is not a pointer", func() {
+
+			err := decodeStream(r, testmodel.ListsList{}, nil, 0)
+
+			Convey("Then I should get an error", func() {
+				So(err, ShouldNotBeNil)
+				So(err, ShouldHaveSameTypeAs, manipulate.ErrCannotUnmarshal{})
+			})
+		})
+	})
+
+	Convey("Given I have a response containing null", t, func() {
+
+		dest := testmodel.ListsList{}
+		err := decodeStream(makeResponse("null"), &dest, nil, 0)
+
+		Convey("Then the list should be empty", func() {
+			So(err, ShouldBeNil)
+			So(len(dest), ShouldEqual, 0)
+		})
+	})
+
+	Convey("Given I have a response that is not a list", t, func() {
+
+		dest := testmodel.ListsList{}
+		err := decodeStream(makeResponse(`{"ID":"1"}`), &dest, nil, 0)
+
+		Convey("Then I should get an error", func() {
+			So(err, ShouldNotBeNil)
+			So(err, ShouldHaveSameTypeAs, manipulate.ErrCannotUnmarshal{})
+		})
+	})
+
+	Convey("Given I have a truncated response", t, func() {
+
+		dest := testmodel.ListsList{}
+		err := decodeStream(makeResponse(`[{"ID":"1"},{"ID":`), &dest, nil, 0)
+
+		Convey("Then I should get an error", func() {
+			So(err, ShouldNotBeNil)
+			So(err, ShouldHaveSameTypeAs, manipulate.ErrCannotUnmarshal{})
+		})
+	})
+}
+
+func TestStream_RetrieveMany(t *testing.T) {
+
+	Convey("Given I have a server returning a list", t, func() {
+
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `[{"ID":"1"},{"ID":"2"}]`)
+		}))
+		defer ts.Close()
+
+		m, _ := New(context.Background(), ts.URL)
+
+		Convey("When I retrieve the list with a stream handler", func() {
+
+			var handled int
+			dest := testmodel.ListsList{}
+			err := m.RetrieveMany(
+				manipulate.NewContext(
+					context.Background(),
+					ContextOptionStreamHandler(func(elemental.Identifiable) error {
+						handled++
+						return nil
+					}),
+				),
+				&dest,
+			)
+
+			Convey("Then the handler should have received the objects", func() {
+				So(err, ShouldBeNil)
+				So(handled, ShouldEqual, 2)
+				So(len(dest), ShouldEqual, 0)
+			})
+		})
+	})
+}
@@ -103,6 +103,15 @@ func NewSubscriber(manipulator manipulate.Manipulator, options ...SubscriberOpti
 		cfg.tlsConfig.NextProtos = nil
 	}
 
+	headers := http.Header{
+		"Content-Type": []string{string(m.encoding)},
+		"Accept":       []string{string(m.encoding)},
+	}
+
+	if m.userAgent != "" {
+		headers.Set("User-Agent", m.userAgent)
+	}
+
 	return push.NewSubscriber(
 		fmt.Sprintf("%s/%s", m.url, cfg.endpoint),
 		cfg.namespace,
@@ -110,10 +119,7 @@ func NewSubscriber(manipulator manipulate.Manipulator, options ...SubscriberOpti
 		m.registerRenewNotifier,
 		m.unregisterRenewNotifier,
 		cfg.tlsConfig,
-		http.Header{
-			"Content-Type": []string{string(m.encoding)},
-			"Accept":       []string{string(m.encoding)},
-		},
+		headers,
 		cfg.supportErrorEvents,
 		cfg.recursive,
 		cfg.credentialCookieKey,
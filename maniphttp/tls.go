@@ -0,0 +1,245 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package maniphttp
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/pkcs12"
+)
+
+// isTLSError returns true if the given error is caused by
+// a failed TLS handshake or an invalid server certificate.
+func isTLSError(err error) bool {
+
+	var (
+		verificationErr *tls.CertificateVerificationError
+		recordHeaderErr tls.RecordHeaderError
+		alertErr        tls.AlertError
+		unknownAuthErr  x509.UnknownAuthorityError
+		invalidErr      x509.CertificateInvalidError
+		hostnameErr     x509.HostnameError
+	)
+
+	return errors.As(err, &verificationErr) ||
+		errors.As(err, &recordHeaderErr) ||
+		errors.As(err, &alertErr) ||
+		errors.As(err, &unknownAuthErr) ||
+		errors.As(err, &invalidErr) ||
+		errors.As(err, &hostnameErr)
+}
+
+// TLSConfigFromPEM returns a tls.Config, to use with OptionTLSConfig, built
+// from PEM encoded data held in memory, for instance retrieved from a secret
+// store, so it does not have to be written to disk first. The client
+// certificate is only used if certPEM and keyPEM are not empty, and the given
+// certificate authorities are trusted instead of the system ones if caPEM is
+// not empty. The key must not be encrypted.
+func TLSConfigFromPEM(certPEM []byte, keyPEM []byte, caPEM []byte) (*tls.Config, error) {
+
+	tlsConfig := getDefaultTLSConfig()
+
+	if len(certPEM) > 0 || len(keyPEM) > 0 {
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client certificate: %s", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if len(caPEM) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("unable to load certificate authorities: no valid certificate found")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
+
+// TLSConfigFromPKCS12 returns a tls.Config, to use with OptionTLSConfig, built
+// from a PKCS12 bundle held in memory, for instance retrieved from a secret
+// store, so it does not have to be written to disk first. The bundle must
+// contain the client certificate and its key, and can contain the chain of
+// the certificate. The given certificate authorities are trusted instead of
+// the system ones if caPEM is not empty.
+func TLSConfigFromPKCS12(data []byte, password string, caPEM []byte) (*tls.Config, error) {
+
+	blocks, err := pkcs12.ToPEM(data, password)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode pkcs12 bundle: %s", err)
+	}
+
+	var keyPEM []byte
+	var keyID string
+	for _, block := range blocks {
+		if block.Type == "PRIVATE KEY" {
+			keyPEM = pem.EncodeToMemory(block)
+			keyID = block.Headers["localKeyId"]
+		}
+	}
+
+	// The certificate of the key must be the first one.
+	var certPEM []byte
+	for _, block := range blocks {
+
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		if keyID != "" && block.Headers["localKeyId"] == keyID {
+			certPEM = append(pem.EncodeToMemory(block), certPEM...)
+		} else {
+			certPEM = append(certPEM, pem.EncodeToMemory(block)...)
+		}
+	}
+
+	if len(certPEM) == 0 || len(keyPEM) == 0 {
+		return nil, fmt.Errorf("unable to decode pkcs12 bundle: certificate or key not found")
+	}
+
+	return TLSConfigFromPEM(certPEM, keyPEM, caPEM)
+}
+
+// withRootCAs returns a copy of the given tls.Config, or of
+// the default one if nil, trusting the given certificate pool.
+func withRootCAs(tlsConfig *tls.Config, pool *x509.CertPool) *tls.Config {
+
+	if tlsConfig == nil {
+		tlsConfig = getDefaultTLSConfig()
+	} else {
+		tlsConfig = tlsConfig.Clone()
+	}
+
+	tlsConfig.RootCAs = pool
+
+	return tlsConfig
+}
+
+// A peerCertificateVerifier verifies the certificates of the server,
+// in addition to the verification done by the tls package.
+type peerCertificateVerifier func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error
+
+// withPeerCertificateVerifiers returns a copy of the given tls.Config, or
+// of the default one if nil, also verifying the server certificates with the
+// given verifiers. The existing VerifyPeerCertificate function, if any, is
+// kept and called first.
+func withPeerCertificateVerifiers(tlsConfig *tls.Config, verifiers ...peerCertificateVerifier) *tls.Config {
+
+	if tlsConfig == nil {
+		tlsConfig = getDefaultTLSConfig()
+	} else {
+		tlsConfig = tlsConfig.Clone()
+	}
+
+	if existing := tlsConfig.VerifyPeerCertificate; existing != nil {
+		verifiers = append([]peerCertificateVerifier{existing}, verifiers...)
+	}
+
+	tlsConfig.VerifyPeerCertificate = func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		for _, v := range verifiers {
+			if err := v(rawCerts, verifiedChains); err != nil {
+				return &tls.CertificateVerificationError{Err: err}
+			}
+		}
+		return nil
+	}
+
+	return tlsConfig
+}
+
+// makeRevocationVerifier returns a peerCertificateVerifier rejecting the
+// verified chains containing a certificate revoked by one of the given
+// revocation lists. A revocation list is only trusted if it is signed by
+// the issuer of the certificate in the chain, and the chain is rejected
+// if such a list is past its next update, as it may miss revocations.
+func makeRevocationVerifier(crls []*x509.RevocationList) peerCertificateVerifier {
+
+	return func(_ [][]byte, verifiedChains [][]*x509.Certificate) error {
+
+		now := time.Now()
+
+		for _, chain := range verifiedChains {
+			for i := 0; i < len(chain)-1; i++ {
+
+				cert, issuer := chain[i], chain[i+1]
+
+				for _, crl := range crls {
+
+					if !bytes.Equal(crl.RawIssuer, cert.RawIssuer) {
+						continue
+					}
+
+					if err := crl.CheckSignatureFrom(issuer); err != nil {
+						continue
+					}
+
+					if !crl.NextUpdate.IsZero() && now.After(crl.NextUpdate) {
+						return fmt.Errorf("revocation list of '%s' has expired", issuer.Subject.CommonName)
+					}
+
+					for _, revoked := range crl.RevokedCertificateEntries {
+						if revoked.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+							return fmt.Errorf("certificate '%s' has been revoked", cert.Subject.CommonName)
+						}
+					}
+				}
+			}
+		}
+
+		return nil
+	}
+}
+
+// makePinningVerifier returns a peerCertificateVerifier rejecting the server
+// certificates whose SHA-256 hash, as computed by the given function, is not
+// one of the given pins.
+func makePinningVerifier(pins [][sha256.Size]byte, hash func(*x509.Certificate) [sha256.Size]byte) peerCertificateVerifier {
+
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+
+		if len(rawCerts) == 0 {
+			return fmt.Errorf("no server certificate to verify against the pinned hashes")
+		}
+
+		cert, err := x509.ParseCertificate(rawCerts[0])
+		if err != nil {
+			return fmt.Errorf("unable to parse server certificate: %s", err)
+		}
+
+		h := hash(cert)
+		for _, pin := range pins {
+			if pin == h {
+				return nil
+			}
+		}
+
+		return fmt.Errorf("certificate '%s' does not match any pinned hash", cert.Subject.CommonName)
+	}
+}
+
+// hashCertificate returns the SHA-256 hash of the given certificate.
+func hashCertificate(cert *x509.Certificate) [sha256.Size]byte {
+	return sha256.Sum256(cert.Raw)
+}
+
+// hashPublicKey returns the SHA-256 hash of the public key of the given certificate.
+func hashPublicKey(cert *x509.Certificate) [sha256.Size]byte {
+	return sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+}
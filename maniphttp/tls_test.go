@@ -0,0 +1,375 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package maniphttp
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// testPKCS12Bundle is a PKCS12 bundle containing a self signed
+// certificate for 'client' and its key, protected by 'secret'.
+const testPKCS12Bundle = "MIIDegIBAzCCA0AGCSqGSIb3DQEHAaCCAzEEggMtMIIDKTCCAh8GCSqGSIb3DQEHBqCCAhAwggIMAgEAMIICBQYJKoZIhvcN" +
+	"AQcBMBwGCiqGSIb3DQEMAQMwDgQI7GOZ1u6Usj8CAggAgIIB2NIoXm1Fyr3xqznZj53CRGP37jqojb/GdfM4QL9mvDE6HTDi" +
+	"5WC2rhLAVGug3iLa8ylWGucMADcY8VzL0RG3APN4ESmWAYs66zq2vCsaVzyq6uYqaD9InRfXJ2iA2ZWUIWO02LReqJGpJrA0" +
+	"EQDard+TkOnzUM06Xn1/TyeoNvjgvggXhC+4xn1HdO8rotvTCal7+j0qZlViMz+mO2frEMjdHcAFVVjSwlDQ24+kIcz1dRJm" +
+	"sS2KDiwJJUOqXetPluhTRQg78PyOAWzZSrK9M0c+w9UWbZBW2hjTGJNRhK7S2lREsn5Wl+KxRJJE7FayvObjQrsT6a+v3J91" +
+	"DNGdRifTdBRy+4grkv0IkeN97/DHSifEupIcNdGBucivaavx6CEhI0EX1eCcwmVxXy5+g9DVqTABqMJHupo3OiHJm85Jj6aP" +
+	"rzBEtM8Xrbh7MIm/t4mRmgQ/u+lZjrn4ZSPxo0Jm48VlV+DwKSDUIdYez4xEsa3UBNt8i0KL0MrR/2UsIPTdqOVHjEa4E3ZJ" +
+	"bhtPeorxll++2RyfUvaRBIXOtF3fRzPLzb9vyaiGfmlbZibvbvEnUPubCH6S8hjQkfYDoI3NnkZAVDt0knkLDlYTEoWWP/f8" +
+	"9w9rpFAwggECBgkqhkiG9w0BBwGggfQEgfEwge4wgesGCyqGSIb3DQEMCgECoIG0MIGxMBwGCiqGSIb3DQEMAQMwDgQIBwnf" +
+	"+qcutfUCAggABIGQS4MDaZ4jv6nwtrhI5EZqJ2kCptAzPrCVVgCgtOf4G+JlGPQuf0gj7WgXj4PoswZZMOOhKJiMRajt837K" +
+	"cySpCbnI/FNf7zfHV4PTUifUn6EDzZwCHF+mxr1S2TdSJy2uLrTGhT1LRYyIk6j0YyQTpd+QePA1D5ljB4mKarQadKPHxwOh" +
+	"P4lRCzJgFyKL+oqXMSUwIwYJKoZIhvcNAQkVMRYEFEE1dW9oQeaf3Ja043YDwbw5WWbbMDEwITAJBgUrDgMCGgUABBQS8xdf" +
+	"aHPmp2RY41atmBVM6hGLMwQIJ0+ViAB/TkACAggA"
+
+func makeTestCertificate(cn string, serial int64, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  parent == nil,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+
+	if parent == nil {
+		parent, parentKey = tmpl, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		panic(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		panic(err)
+	}
+
+	return cert, key
+}
+
+func makeTestRevocationList(issuer *x509.Certificate, issuerKey *ecdsa.PrivateKey, nextUpdate time.Time, serials ...int64) *x509.RevocationList {
+
+	tmpl := &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: nextUpdate.Add(-2 * time.Hour),
+		NextUpdate: nextUpdate,
+	}
+
+	for _, s := range serials {
+		tmpl.RevokedCertificateEntries = append(tmpl.RevokedCertificateEntries, x509.RevocationListEntry{
+			SerialNumber:   big.NewInt(s),
+			RevocationTime: time.Now(),
+		})
+	}
+
+	der, err := x509.CreateRevocationList(rand.Reader, tmpl, issuer, issuerKey)
+	if err != nil {
+		panic(err)
+	}
+
+	crl, err := x509.ParseRevocationList(der)
+	if err != nil {
+		panic(err)
+	}
+
+	return crl
+}
+
+func TestTLSConfigFromPEM(t *testing.T) {
+
+	Convey("Given I have a PEM encoded certificate and key", t, func() {
+
+		cert, key := makeTestCertificate("client", 1, nil, nil)
+
+		keyDER, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			panic(err)
+		}
+
+		certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+		keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+
+		Convey("When I build a tls config with a client certificate and authorities", func() {
+
+			tlsConfig, err := TLSConfigFromPEM(certPEM, keyPEM, certPEM)
+
+			Convey("Then the tls config should be correct", func() {
+				So(err, ShouldBeNil)
+				So(len(tlsConfig.Certificates), ShouldEqual, 1)
+				So(tlsConfig.Certificates[0].Certificate[0], ShouldResemble, cert.Raw)
+				So(tlsConfig.RootCAs.Equal(systemCertPool), ShouldBeFalse)
+			})
+		})
+
+		Convey("When I build a tls config without anything", func() {
+
+			tlsConfig, err := TLSConfigFromPEM(nil, nil, nil)
+
+			Convey("Then the tls config should be the default one", func() {
+				So(err, ShouldBeNil)
+				So(tlsConfig.Certificates, ShouldBeNil)
+				So(tlsConfig.RootCAs, ShouldEqual, systemCertPool)
+			})
+		})
+
+		Convey("When I build a tls config with a missing key", func() {
+
+			_, err := TLSConfigFromPEM(certPEM, nil, nil)
+
+			Convey("Then err should not be nil", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("When I build a tls config with invalid authorities", func() {
+
+			_, err := TLSConfigFromPEM(nil, nil, []byte("nope"))
+
+			Convey("Then err should be correct", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldEqual, "unable to load certificate authorities: no valid certificate found")
+			})
+		})
+	})
+}
+
+func TestTLSConfigFromPKCS12(t *testing.T) {
+
+	Convey("Given I have a PKCS12 bundle and some authorities", t, func() {
+
+		data, err := base64.StdEncoding.DecodeString(testPKCS12Bundle)
+		if err != nil {
+			panic(err)
+		}
+
+		ca, _ := makeTestCertificate("ca", 1, nil, nil)
+		caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw})
+
+		Convey("When I build a tls config with the bundle and the authorities", func() {
+
+			tlsConfig, err := TLSConfigFromPKCS12(data, "secret", caPEM)
+
+			Convey("Then err should be nil", func() {
+				So(err, ShouldBeNil)
+			})
+
+			Convey("Then the tls config should be correct", func() {
+				So(len(tlsConfig.Certificates), ShouldEqual, 1)
+				cert, err := x509.ParseCertificate(tlsConfig.Certificates[0].Certificate[0])
+				So(err, ShouldBeNil)
+				So(cert.Subject.CommonName, ShouldEqual, "client")
+				So(tlsConfig.RootCAs.Equal(systemCertPool), ShouldBeFalse)
+			})
+		})
+
+		Convey("When I build a tls config with the bundle only", func() {
+
+			tlsConfig, err := TLSConfigFromPKCS12(data, "secret", nil)
+
+			Convey("Then the tls config should use the system authorities", func() {
+				So(err, ShouldBeNil)
+				So(len(tlsConfig.Certificates), ShouldEqual, 1)
+				So(tlsConfig.RootCAs, ShouldEqual, systemCertPool)
+			})
+		})
+
+		Convey("When I build a tls config with the wrong password", func() {
+
+			_, err := TLSConfigFromPKCS12(data, "nope", nil)
+
+			Convey("Then err should be correct", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldStartWith, "unable to decode pkcs12 bundle: ")
+			})
+		})
+
+		Convey("When I build a tls config with an invalid bundle", func() {
+
+			_, err := TLSConfigFromPKCS12([]byte("nope"), "secret", nil)
+
+			Convey("Then err should not be nil", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func Test_withPeerCertificateVerifiers(t *testing.T) {
+
+	Convey("Given I have a tls config with a verifier", t, func() {
+
+		var calls []string
+		tlsConfig := &tls.Config{
+			VerifyPeerCertificate: func([][]byte, [][]*x509.Certificate) error {
+				calls = append(calls, "existing")
+				return nil
+			},
+		}
+
+		Convey("When I add some verifiers", func() {
+
+			out := withPeerCertificateVerifiers(
+				tlsConfig,
+				func([][]byte, [][]*x509.Certificate) error {
+					calls = append(calls, "first")
+					return nil
+				},
+				func([][]byte, [][]*x509.Certificate) error {
+					calls = append(calls, "second")
+					return fmt.Errorf("boom")
+				},
+			)
+
+			err := out.VerifyPeerCertificate(nil, nil)
+
+			Convey("Then all verifiers should have been called in order", func() {
+				So(err, ShouldNotBeNil)
+				So(err, ShouldHaveSameTypeAs, &tls.CertificateVerificationError{})
+				So(errors.Unwrap(err).Error(), ShouldEqual, "boom")
+				So(calls, ShouldResemble, []string{"existing", "first", "second"})
+			})
+
+			Convey("Then the original tls config should not be modified", func() {
+				So(out, ShouldNotEqual, tlsConfig)
+			})
+		})
+	})
+}
+
+func Test_makeRevocationVerifier(t *testing.T) {
+
+	Convey("Given I have a certificate authority and some certificates", t, func() {
+
+		ca, caKey := makeTestCertificate("ca", 1, nil, nil)
+		good, _ := makeTestCertificate("good", 2, ca, caKey)
+		revoked, _ := makeTestCertificate("revoked", 3, ca, caKey)
+
+		other, otherKey := makeTestCertificate("ca", 4, nil, nil)
+
+		Convey("When I verify a certificate that is not revoked", func() {
+
+			err := makeRevocationVerifier([]*x509.RevocationList{makeTestRevocationList(ca, caKey, time.Now().Add(time.Hour), 3)})(nil, [][]*x509.Certificate{{good, ca}})
+
+			Convey("Then err should be nil", func() {
+				So(err, ShouldBeNil)
+			})
+		})
+
+		Convey("When I verify a certificate that is revoked", func() {
+
+			err := makeRevocationVerifier([]*x509.RevocationList{makeTestRevocationList(ca, caKey, time.Now().Add(time.Hour), 3)})(nil, [][]*x509.Certificate{{revoked, ca}})
+
+			Convey("Then err should be correct", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldEqual, "certificate 'revoked' has been revoked")
+			})
+		})
+
+		Convey("When I verify a certificate with an expired list", func() {
+
+			err := makeRevocationVerifier([]*x509.RevocationList{makeTestRevocationList(ca, caKey, time.Now().Add(-time.Minute), 3)})(nil, [][]*x509.Certificate{{good, ca}})
+
+			Convey("Then err should be correct", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldEqual, "revocation list of 'ca' has expired")
+			})
+		})
+
+		Convey("When I verify a certificate with an expired list not signed by its issuer", func() {
+
+			err := makeRevocationVerifier([]*x509.RevocationList{makeTestRevocationList(other, otherKey, time.Now().Add(-time.Minute), 3)})(nil, [][]*x509.Certificate{{good, ca}})
+
+			Convey("Then err should be nil", func() {
+				So(err, ShouldBeNil)
+			})
+		})
+
+		Convey("When I verify a certificate revoked by a list not signed by its issuer", func() {
+
+			err := makeRevocationVerifier([]*x509.RevocationList{makeTestRevocationList(other, otherKey, time.Now().Add(time.Hour), 3)})(nil, [][]*x509.Certificate{{revoked, ca}})
+
+			Convey("Then err should be nil", func() {
+				So(err, ShouldBeNil)
+			})
+		})
+	})
+}
+
+func Test_makePinningVerifier(t *testing.T) {
+
+	Convey("Given I have a certificate", t, func() {
+
+		cert, _ := makeTestCertificate("server", 1, nil, nil)
+		other, _ := makeTestCertificate("other", 2, nil, nil)
+
+		Convey("When I verify it against its pinned certificate hash", func() {
+
+			err := makePinningVerifier([][sha256.Size]byte{sha256.Sum256(other.Raw), sha256.Sum256(cert.Raw)}, hashCertificate)([][]byte{cert.Raw}, nil)
+
+			Convey("Then err should be nil", func() {
+				So(err, ShouldBeNil)
+			})
+		})
+
+		Convey("When I verify it against its pinned public key hash", func() {
+
+			err := makePinningVerifier([][sha256.Size]byte{sha256.Sum256(cert.RawSubjectPublicKeyInfo)}, hashPublicKey)([][]byte{cert.Raw}, nil)
+
+			Convey("Then err should be nil", func() {
+				So(err, ShouldBeNil)
+			})
+		})
+
+		Convey("When I verify it against another pinned hash", func() {
+
+			err := makePinningVerifier([][sha256.Size]byte{sha256.Sum256(other.Raw)}, hashCertificate)([][]byte{cert.Raw}, nil)
+
+			Convey("Then err should be correct", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldEqual, "certificate 'server' does not match any pinned hash")
+			})
+		})
+
+		Convey("When I verify no certificate", func() {
+
+			err := makePinningVerifier([][sha256.Size]byte{sha256.Sum256(cert.Raw)}, hashCertificate)(nil, nil)
+
+			Convey("Then err should be correct", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldEqual, "no server certificate to verify against the pinned hashes")
+			})
+		})
+	})
+}
@@ -12,13 +12,16 @@
 package maniphttp
 
 import (
+	"bufio"
 	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
+	"runtime/debug"
 	"strconv"
 	"strings"
 	"sync"
@@ -30,6 +33,42 @@ import (
 	"go.aporeto.io/manipulate/maniphttp/internal/syscall"
 )
 
+var defaultUserAgent = makeUserAgent(debug.ReadBuildInfo())
+
+// makeUserAgent returns the User-Agent identifying manipulate,
+// with its version when it can be found in the given build info.
+func makeUserAgent(info *debug.BuildInfo, ok bool) string {
+
+	const name = "manipulate"
+
+	if !ok || info == nil {
+		return name
+	}
+
+	mod := &info.Main
+	for _, dep := range info.Deps {
+		if dep.Path == "go.aporeto.io/manipulate" {
+			mod = dep
+			break
+		}
+	}
+
+	if mod.Path != "go.aporeto.io/manipulate" {
+		return name
+	}
+
+	version := mod.Version
+	if mod.Replace != nil {
+		version = mod.Replace.Version
+	}
+
+	if version == "" || version == "(devel)" {
+		return name
+	}
+
+	return name + "/" + version
+}
+
 // AddQueryParameters appends each key-value pair from ctx.Parameters
 // to a request as query parameters with proper escaping.
 func addQueryParameters(req *http.Request, ctx manipulate.Context) error {
@@ -83,6 +122,80 @@ func addQueryParameters(req *http.Request, ctx manipulate.Context) error {
 	return nil
 }
 
+// isEmptyBody returns true if the body of the given response is empty.
+// If the length of the body is unknown, it reads ahead the first byte,
+// and replaces the body of the response so it can still be fully read.
+func isEmptyBody(r *http.Response) bool {
+
+	if r.Body == nil || r.ContentLength == 0 {
+		return true
+	}
+
+	if r.ContentLength > 0 {
+		return false
+	}
+
+	br := bufio.NewReader(r.Body)
+	if _, err := br.Peek(1); err == io.EOF {
+		return true
+	}
+
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{br, r.Body}
+
+	return false
+}
+
+// capResults caps the number of objects the server can return for
+// the given request to max + 1, so a larger result can be detected
+// without retrieving it entirely. Requests asking for max objects
+// or less are left untouched.
+func capResults(req *http.Request, ctx manipulate.Context, max int) {
+
+	param, size := "pagesize", ctx.PageSize()
+	if ctx.After() != "" {
+		param, size = "limit", ctx.Limit()
+	}
+
+	if size > 0 && size <= max {
+		return
+	}
+
+	q := req.URL.Query()
+	q.Set(param, strconv.Itoa(max+1))
+	req.URL.RawQuery = q.Encode()
+}
+
+// A limitedBody is a response body returning an
+// error once more than max bytes have been read.
+type limitedBody struct {
+	io.Reader
+	io.Closer
+	max  int64
+	read int64
+}
+
+func newLimitedBody(body io.ReadCloser, max int64) *limitedBody {
+	return &limitedBody{
+		Reader: io.LimitReader(body, max+1),
+		Closer: body,
+		max:    max,
+	}
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+
+	n, err := b.Reader.Read(p)
+
+	if b.read += int64(n); b.read > b.max {
+		return n, fmt.Errorf("response body is larger than %d bytes", b.max)
+	}
+
+	return n, err
+}
+
 func decodeData(r *http.Response, dest interface{}) (err error) {
 
 	if r.Body == nil {
@@ -130,6 +243,23 @@ func getDefaultTLSConfig() *tls.Config {
 	}
 }
 
+// withClientCertificateSelector returns a copy of the given tls.Config,
+// or of the default one if nil, using the given ClientCertificateSelector.
+func withClientCertificateSelector(tlsConfig *tls.Config, selector ClientCertificateSelector) *tls.Config {
+
+	if tlsConfig == nil {
+		tlsConfig = getDefaultTLSConfig()
+	} else {
+		tlsConfig = tlsConfig.Clone()
+	}
+
+	tlsConfig.GetClientCertificate = func(info *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		return selector(info.Context(), info)
+	}
+
+	return tlsConfig
+}
+
 func getDefaultHTTPTransport(url string, disableCompression bool, tcpUserTimeout time.Duration) (*http.Transport, string) {
 
 	dialer := (&net.Dialer{
@@ -18,6 +18,8 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"runtime/debug"
+	"strings"
 	"testing"
 
 	. "github.com/smartystreets/goconvey/convey"
@@ -185,10 +187,150 @@ func Test_addQueryParameters(t *testing.T) {
 	})
 }
 
+func Test_capResults(t *testing.T) {
+
+	Convey("Given I have a request", t, func() {
+
+		request, _ := http.NewRequest(http.MethodGet, "http://test.com", nil)
+
+		Convey("When I cap a request without pagination", func() {
+
+			capResults(request, manipulate.NewContext(context.Background()), 10)
+
+			Convey("Then the page size should be capped", func() {
+				So(request.URL.RawQuery, ShouldEqual, "pagesize=11")
+			})
+		})
+
+		Convey("When I cap a request with a larger page size", func() {
+
+			ctx := manipulate.NewContext(context.Background(), manipulate.ContextOptionPage(1, 100))
+			_ = addQueryParameters(request, ctx)
+			capResults(request, ctx, 10)
+
+			Convey("Then the page size should be capped", func() {
+				So(request.URL.RawQuery, ShouldEqual, "page=1&pagesize=11")
+			})
+		})
+
+		Convey("When I cap a request with a smaller page size", func() {
+
+			ctx := manipulate.NewContext(context.Background(), manipulate.ContextOptionPage(1, 5))
+			_ = addQueryParameters(request, ctx)
+			capResults(request, ctx, 10)
+
+			Convey("Then the page size should be untouched", func() {
+				So(request.URL.RawQuery, ShouldEqual, "page=1&pagesize=5")
+			})
+		})
+
+		Convey("When I cap a request using after and a larger limit", func() {
+
+			ctx := manipulate.NewContext(context.Background(), manipulate.ContextOptionAfter("42", 100))
+			_ = addQueryParameters(request, ctx)
+			capResults(request, ctx, 10)
+
+			Convey("Then the limit should be capped", func() {
+				So(request.URL.RawQuery, ShouldEqual, "after=42&limit=11")
+			})
+		})
+	})
+}
+
 type fakeReader struct{}
 
 func (r *fakeReader) Read(p []byte) (n int, err error) { return 0, errors.New("boom") }
 
+func Test_limitedBody(t *testing.T) {
+
+	Convey("Given I have a limited body", t, func() {
+
+		Convey("When I read a body smaller than the limit", func() {
+
+			data, err := ioutil.ReadAll(newLimitedBody(ioutil.NopCloser(strings.NewReader("hello")), 5))
+
+			Convey("Then it should work", func() {
+				So(err, ShouldBeNil)
+				So(string(data), ShouldEqual, "hello")
+			})
+		})
+
+		Convey("When I read a body larger than the limit", func() {
+
+			_, err := ioutil.ReadAll(newLimitedBody(ioutil.NopCloser(strings.NewReader("hello world")), 5))
+
+			Convey("Then I should get an error", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldEqual, "response body is larger than 5 bytes")
+			})
+		})
+	})
+}
+
+func Test_makeUserAgent(t *testing.T) {
+
+	Convey("Given I have various build infos", t, func() {
+
+		So(makeUserAgent(nil, false), ShouldEqual, "manipulate")
+
+		So(makeUserAgent(&debug.BuildInfo{
+			Main: debug.Module{Path: "example.com/app", Version: "(devel)"},
+		}, true), ShouldEqual, "manipulate")
+
+		So(makeUserAgent(&debug.BuildInfo{
+			Main: debug.Module{Path: "example.com/app", Version: "(devel)"},
+			Deps: []*debug.Module{{Path: "go.aporeto.io/manipulate", Version: "v1.2.3"}},
+		}, true), ShouldEqual, "manipulate/v1.2.3")
+
+		So(makeUserAgent(&debug.BuildInfo{
+			Main: debug.Module{Path: "example.com/app", Version: "(devel)"},
+			Deps: []*debug.Module{{
+				Path:    "go.aporeto.io/manipulate",
+				Version: "v1.2.3",
+				Replace: &debug.Module{Path: "../manipulate"},
+			}},
+		}, true), ShouldEqual, "manipulate")
+
+		So(makeUserAgent(&debug.BuildInfo{
+			Main: debug.Module{Path: "go.aporeto.io/manipulate", Version: "v1.4.0"},
+		}, true), ShouldEqual, "manipulate/v1.4.0")
+	})
+}
+
+func Test_isEmptyBody(t *testing.T) {
+
+	Convey("Given I have a response without body", t, func() {
+		So(isEmptyBody(&http.Response{}), ShouldBeTrue)
+	})
+
+	Convey("Given I have a response with a zero content length", t, func() {
+		r := &http.Response{Body: ioutil.NopCloser(bytes.NewBuffer(nil)), ContentLength: 0}
+		So(isEmptyBody(r), ShouldBeTrue)
+	})
+
+	Convey("Given I have a response with an unknown content length and an empty body", t, func() {
+		r := &http.Response{Body: ioutil.NopCloser(bytes.NewBuffer(nil)), ContentLength: -1}
+		So(isEmptyBody(r), ShouldBeTrue)
+	})
+
+	Convey("Given I have a response with an unknown content length and a body", t, func() {
+
+		r := &http.Response{Body: ioutil.NopCloser(bytes.NewBuffer([]byte(`{"a":1}`))), ContentLength: -1}
+
+		Convey("When I call isEmptyBody", func() {
+
+			empty := isEmptyBody(r)
+
+			Convey("Then it should not be empty and the body should be intact", func() {
+				So(empty, ShouldBeFalse)
+				data, err := ioutil.ReadAll(r.Body)
+				So(err, ShouldBeNil)
+				So(string(data), ShouldEqual, `{"a":1}`)
+			})
+		})
+	})
+}
+
 func Test_decodeData(t *testing.T) {
 
 	Convey("Given I have valid json data in a reader", t, func() {
@@ -17,6 +17,7 @@ import (
 	"reflect"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/globalsign/mgo/bson"
 	memdb "github.com/hashicorp/go-memdb"
@@ -25,18 +26,61 @@ import (
 	"go.aporeto.io/manipulate"
 )
 
-type txnRegistry map[manipulate.TransactionID]*memdb.Txn
+type transaction struct {
+	txn      *memdb.Txn
+	label    string
+	deadline time.Time
+	lock     sync.Mutex
+	closed   bool
+}
+
+// name returns the ID of the transaction
+// followed by its label, if any, to use in errors.
+func (t *transaction) name(id manipulate.TransactionID) string {
+
+	if t.label == "" {
+		return string(id)
+	}
+
+	return fmt.Sprintf("%s (%s)", id, t.label)
+}
+
+// use locks the transaction for the duration of an operation.
+// It returns false if the transaction has been closed meanwhile.
+func (t *transaction) use() bool {
+
+	t.lock.Lock()
+	if t.closed {
+		t.lock.Unlock()
+		return false
+	}
+
+	return true
+}
+
+type txnRegistry map[manipulate.TransactionID]*transaction
 
 // A memoryManipulator is an empty manipulator that can be used with ApoMock.
 type memdbManipulator struct {
 	db              *memdb.MemDB
 	schema          *memdb.DBSchema
 	txnRegistry     txnRegistry
+	expiredTxns     map[manipulate.TransactionID]struct{}
 	txnRegistryLock sync.RWMutex
 	dbLock          sync.RWMutex
 	noCopy          bool
+	txnTimeout      time.Duration
+	sweeperStop     chan struct{}
+	shutdownOnce    sync.Once
+	idGenerator     manipulate.IdentifierGenerator
 }
 
+var (
+	_ manipulate.TransactionalManipulator = (*memdbManipulator)(nil)
+	_ manipulate.FlushableManipulator     = (*memdbManipulator)(nil)
+	_ manipulate.ClosableManipulator      = (*memdbManipulator)(nil)
+)
+
 // New creates a new datastore backed by a memdb.
 func New(c map[string]*IdentitySchema, options ...Option) (manipulate.TransactionalManipulator, error) {
 
@@ -62,12 +106,22 @@ func New(c map[string]*IdentitySchema, options ...Option) (manipulate.Transactio
 		return nil, err
 	}
 
-	return &memdbManipulator{
+	m := &memdbManipulator{
 		schema:      schema,
 		db:          db,
 		noCopy:      cfg.noCopy,
 		txnRegistry: txnRegistry{},
-	}, nil
+		expiredTxns: map[manipulate.TransactionID]struct{}{},
+		txnTimeout:  cfg.transactionTimeout,
+		sweeperStop: make(chan struct{}),
+		idGenerator: cfg.idGenerator,
+	}
+
+	if m.txnTimeout > 0 {
+		go m.sweepTransactions()
+	}
+
+	return m, nil
 }
 
 // Flush will flush the datastore essentially creating a new one.
@@ -90,15 +144,32 @@ func (m *memdbManipulator) RetrieveMany(mctx manipulate.Context, dest elemental.
 		mctx = manipulate.NewContext(context.Background())
 	}
 
+	// The transaction is released before running the hook
+	// so it can read using the same transaction.
+	txn, release := m.readTxn(mctx)
 	items := map[string]elemental.Identifiable{}
+	err := m.retrieveFromFilter(txn, dest.Identity().Category, mctx.Filter(), &items, true)
+	release()
 
-	if err := m.retrieveFromFilter(dest.Identity().Category, mctx.Filter(), &items, true); err != nil {
+	if err != nil {
 		return err
 	}
 
 	out := reflect.ValueOf(dest).Elem()
+	hook := mctx.PostReadHook()
 
 	for _, obj := range items {
+
+		if hook != nil {
+			ok, err := hook(obj)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+		}
+
 		out.Set(reflect.Append(out, reflect.ValueOf(obj)))
 	}
 
@@ -108,15 +179,55 @@ func (m *memdbManipulator) RetrieveMany(mctx manipulate.Context, dest elemental.
 // Retrieve is part of the implementation of the Manipulator interface.
 func (m *memdbManipulator) Retrieve(mctx manipulate.Context, object elemental.Identifiable) error {
 
-	txn := m.getDB().Txn(false)
+	found, err := m.retrieve(mctx, object)
+	if err != nil || !found {
+		return err
+	}
+
+	if mctx != nil {
+		if hook := mctx.PostReadHook(); hook != nil {
+			ok, err := hook(object)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return manipulate.NewErrObjectNotFound("cannot find the object for the given ID")
+			}
+		}
+	}
+
+	return nil
+}
+
+// retrieve fills the given object with the stored one. It returns false if
+// the object is not found and the context asks for an empty result instead
+// of an error. The transaction is released before the post read hook is run
+// so the hook can read using the same transaction.
+func (m *memdbManipulator) retrieve(mctx manipulate.Context, object elemental.Identifiable) (bool, error) {
+
+	txn, release := m.readTxn(mctx)
+	defer release()
 
 	raw, err := txn.First(object.Identity().Category, "id", object.Identifier())
 	if err != nil {
-		return manipulate.NewErrCannotExecuteQuery(err.Error())
+		return false, manipulate.NewErrCannotExecuteQuery(err.Error())
+	}
+
+	if raw != nil && mctx != nil {
+		ok, err := m.matchesFilter(txn, object.Identity().Category, object.Identifier(), mctx.Filter())
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			raw = nil
+		}
 	}
 
 	if raw == nil {
-		return manipulate.NewErrObjectNotFound("cannot find the object for the given ID")
+		if mctx != nil && mctx.NotFoundAsEmpty() {
+			return false, nil
+		}
+		return false, manipulate.NewErrObjectNotFound("cannot find the object for the given ID")
 	}
 
 	var cp interface{}
@@ -125,13 +236,13 @@ func (m *memdbManipulator) Retrieve(mctx manipulate.Context, object elemental.Id
 	} else {
 		cp, err = copystructure.Copy(raw)
 		if err != nil {
-			return manipulate.NewErrCannotExecuteQuery(err.Error())
+			return false, manipulate.NewErrCannotExecuteQuery(err.Error())
 		}
 	}
 
 	reflect.ValueOf(object).Elem().Set(reflect.ValueOf(cp).Elem())
 
-	return nil
+	return true, nil
 }
 
 // Create is part of the implementation of the Manipulator interface.
@@ -142,13 +253,20 @@ func (m *memdbManipulator) Create(mctx manipulate.Context, object elemental.Iden
 	}
 
 	tid := mctx.TransactionID()
-	txn := m.txnForID(tid)
-	defer txn.Abort()
+	txn, release, err := m.txnForID(tid, mctx.TransactionLabel())
+	if err != nil {
+		return err
+	}
+	defer release()
 
 	// In caching scenarios the identifier is already set. Do not insert
 	// here. We will get it pre-populated from the master DB.
 	if object.Identifier() == "" {
-		object.SetIdentifier(bson.NewObjectId().Hex())
+		if m.idGenerator != nil {
+			object.SetIdentifier(m.idGenerator(object))
+		} else {
+			object.SetIdentifier(bson.NewObjectId().Hex())
+		}
 	}
 
 	var cp interface{}
@@ -181,8 +299,11 @@ func (m *memdbManipulator) Update(mctx manipulate.Context, object elemental.Iden
 	}
 
 	tid := mctx.TransactionID()
-	txn := m.txnForID(tid)
-	defer txn.Abort()
+	txn, release, err := m.txnForID(tid, mctx.TransactionLabel())
+	if err != nil {
+		return err
+	}
+	defer release()
 
 	o, err := txn.Get(object.Identity().Category, "id", object.Identifier())
 	if err != nil || o.Next() == nil {
@@ -218,8 +339,19 @@ func (m *memdbManipulator) Delete(mctx manipulate.Context, object elemental.Iden
 	}
 
 	tid := mctx.TransactionID()
-	txn := m.txnForID(tid)
-	defer txn.Abort()
+	txn, release, err := m.txnForID(tid, mctx.TransactionLabel())
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	ok, err := m.matchesFilter(txn, object.Identity().Category, object.Identifier(), mctx.Filter())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return manipulate.NewErrObjectNotFound("cannot find the object for the given ID")
+	}
 
 	if err := txn.Delete(object.Identity().Category, object); err != nil {
 		if err == memdb.ErrNotFound {
@@ -243,9 +375,11 @@ func (m *memdbManipulator) DeleteMany(mctx manipulate.Context, identity elementa
 // Count is part of the implementation of the Manipulator interface. Count is very expensive.
 func (m *memdbManipulator) Count(mctx manipulate.Context, identity elemental.Identity) (int, error) {
 
+	txn, release := m.readTxn(mctx)
+	defer release()
 	items := map[string]elemental.Identifiable{}
 
-	if err := m.retrieveFromFilter(identity.Category, mctx.Filter(), &items, true); err != nil {
+	if err := m.retrieveFromFilter(txn, identity.Category, mctx.Filter(), &items, true); err != nil {
 		return 0, err
 	}
 
@@ -255,14 +389,19 @@ func (m *memdbManipulator) Count(mctx manipulate.Context, identity elemental.Ide
 // Commit is part of the implementation of the TransactionalManipulator interface.
 func (m *memdbManipulator) Commit(id manipulate.TransactionID) error {
 
-	txn := m.registeredTxnWithID(id)
+	t, expired := m.popTxn(id)
 
-	if txn == nil {
+	if expired {
+		return manipulate.NewErrCannotCommit(fmt.Sprintf("transaction %s has expired and has been aborted", id))
+	}
+
+	if t == nil {
 		return manipulate.NewErrCannotCommit("Cannot find transaction " + string(id))
 	}
 
-	txn.Commit()
-	m.unregisterTxn(id)
+	if err := m.closeTxn(t, true); err != nil {
+		return manipulate.NewErrCannotCommit(fmt.Sprintf("unable to commit transaction %s: %s", t.name(id), err))
+	}
 
 	return nil
 }
@@ -270,61 +409,298 @@ func (m *memdbManipulator) Commit(id manipulate.TransactionID) error {
 // Abort is part of the implementation of the TransactionalManipulator interface.
 func (m *memdbManipulator) Abort(id manipulate.TransactionID) bool {
 
-	txn := m.registeredTxnWithID(id)
-	if txn == nil {
+	t, _ := m.popTxn(id)
+	if t == nil {
 		return false
 	}
 
-	txn.Abort()
-	m.unregisterTxn(id)
+	_ = m.closeTxn(t, false)
 
 	return true
 }
 
-func (m *memdbManipulator) txnForID(id manipulate.TransactionID) *memdb.Txn {
+// Shutdown is part of the implementation of the ClosableManipulator interface.
+func (m *memdbManipulator) Shutdown(ctx context.Context, commit bool) error {
+
+	m.shutdownOnce.Do(func() { close(m.sweeperStop) })
+
+	m.txnRegistryLock.Lock()
+	registry := m.txnRegistry
+	m.txnRegistry = txnRegistry{}
+	m.expiredTxns = map[manipulate.TransactionID]struct{}{}
+	m.txnRegistryLock.Unlock()
+
+	var err error
+	for _, t := range registry {
+
+		if err == nil {
+			err = ctx.Err()
+		}
+
+		if commit && err == nil {
+			err = m.closeTxn(t, true)
+			continue
+		}
+
+		_ = m.closeTxn(t, false)
+	}
+
+	return err
+}
+
+// txnForID returns the transaction to use to write data for the given
+// transaction ID, and the function to call once the write is done. If the
+// ID is empty, a new write transaction is returned. Otherwise, the registered
+// transaction is locked until the function is called, and created with the
+// given label if needed. It returns an error if the transaction has expired,
+// so the writes made after it has been aborted are not committed alone.
+func (m *memdbManipulator) txnForID(id manipulate.TransactionID, label string) (*memdb.Txn, func(), error) {
 
 	if id == "" {
-		return m.getDB().Txn(true)
+		txn := m.getDB().Txn(true)
+		return txn, txn.Abort, nil
+	}
+
+	for {
+		t := m.acquireTxn(id, label, true)
+		if t == nil {
+			return nil, nil, manipulate.NewErrCannotExecuteQuery(fmt.Sprintf("transaction %s has expired and has been aborted", id))
+		}
+		if t.use() {
+			return t.txn, t.lock.Unlock, nil
+		}
+	}
+}
+
+// commitTxn applies the changes of the given registered transaction
+// to the database. As the transaction has been made on a snapshot, the
+// writer lock of the database is only held while the changes are replayed.
+// It fails without applying anything if one of the changed objects has been
+// written outside of the transaction since it has been read by it.
+func (m *memdbManipulator) commitTxn(txn *memdb.Txn) error {
+
+	changes := txn.Changes()
+	txn.Abort()
+
+	if len(changes) == 0 {
+		return nil
+	}
+
+	wtxn := m.getDB().Txn(true)
+	defer wtxn.Abort()
+
+	for _, change := range changes {
+
+		if err := checkConflict(wtxn, change); err != nil {
+			return err
+		}
+
+		var err error
+
+		switch {
+		case change.After != nil:
+			err = wtxn.Insert(change.Table, change.After)
+		case change.Before != nil:
+			err = wtxn.Delete(change.Table, change.Before)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	wtxn.Commit()
+
+	return nil
+}
+
+// checkConflict returns an error if the object of the given change
+// is not in the database as it was before the change was made.
+func checkConflict(txn *memdb.Txn, change memdb.Change) error {
+
+	obj := change.After
+	if obj == nil {
+		obj = change.Before
+	}
+
+	id := obj.(elemental.Identifiable).Identifier()
+
+	current, err := txn.First(change.Table, "id", id)
+	if err != nil {
+		return err
 	}
 
-	txn := m.registeredTxnWithID(id)
+	if current != change.Before {
+		return fmt.Errorf("%s %s has been modified outside of the transaction", change.Table, id)
+	}
+
+	return nil
+}
+
+// closeTxn commits or aborts the given transaction once it is not used
+// by any operation anymore. It must have been removed from the registry.
+func (m *memdbManipulator) closeTxn(t *transaction, commit bool) error {
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.closed = true
+
+	if commit {
+		return m.commitTxn(t.txn)
+	}
+
+	t.txn.Abort()
+
+	return nil
+}
+
+// readTxn returns the transaction to use to read data for the given context,
+// and the function to call once the read is done. If the context holds the ID
+// of an open transaction, its transaction is used so pending writes are visible.
+// Otherwise, a new read only transaction is returned.
+func (m *memdbManipulator) readTxn(mctx manipulate.Context) (*memdb.Txn, func()) {
 
-	if txn == nil {
-		txn = m.getDB().Txn(true)
-		m.registerTxn(id, txn)
+	if mctx != nil {
+		if id := mctx.TransactionID(); id != "" {
+			if t := m.acquireTxn(id, "", false); t != nil && t.use() {
+				return t.txn, t.lock.Unlock
+			}
+		}
 	}
 
-	return txn
+	return m.getDB().Txn(false), func() {}
 }
 
 func (m *memdbManipulator) registerTxn(id manipulate.TransactionID, txn *memdb.Txn) {
 
 	m.txnRegistryLock.Lock()
 	defer m.txnRegistryLock.Unlock()
-	m.txnRegistry[id] = txn
+	m.txnRegistry[id] = &transaction{
+		txn:      txn,
+		deadline: m.txnDeadline(),
+	}
 }
 
-func (m *memdbManipulator) unregisterTxn(id manipulate.TransactionID) {
+// acquireTxn returns the registered transaction with the given ID and pushes
+// back its deadline. If there is none and create is true, a new transaction
+// is registered with the given label, unless the transaction with this ID has
+// expired. Otherwise nil is returned.
+func (m *memdbManipulator) acquireTxn(id manipulate.TransactionID, label string, create bool) *transaction {
 
 	m.txnRegistryLock.Lock()
 	defer m.txnRegistryLock.Unlock()
+
+	t, ok := m.txnRegistry[id]
+	if !ok {
+		if _, expired := m.expiredTxns[id]; expired || !create {
+			return nil
+		}
+
+		// The transaction writes to a snapshot so it does not hold the
+		// writer lock of the database until it is committed.
+		txn := m.getDB().Snapshot().Txn(true)
+		txn.TrackChanges()
+
+		t = &transaction{txn: txn, label: label}
+		m.txnRegistry[id] = t
+	}
+
+	t.deadline = m.txnDeadline()
+
+	return t
+}
+
+// popTxn removes the transaction with the given ID from the registry and
+// returns it. It also returns true if the transaction had expired, and
+// forgets about it.
+func (m *memdbManipulator) popTxn(id manipulate.TransactionID) (*transaction, bool) {
+
+	m.txnRegistryLock.Lock()
+	defer m.txnRegistryLock.Unlock()
+
+	t := m.txnRegistry[id]
 	delete(m.txnRegistry, id)
+
+	_, expired := m.expiredTxns[id]
+	delete(m.expiredTxns, id)
+
+	return t, expired
 }
 
 func (m *memdbManipulator) registeredTxnWithID(id manipulate.TransactionID) *memdb.Txn {
 
 	m.txnRegistryLock.RLock()
 	defer m.txnRegistryLock.RUnlock()
-	b := m.txnRegistry[id]
+	t := m.txnRegistry[id]
+	if t == nil {
+		return nil
+	}
 
-	return b
+	return t.txn
+}
+
+func (m *memdbManipulator) txnDeadline() time.Time {
+
+	if m.txnTimeout <= 0 {
+		return time.Time{}
+	}
+
+	return time.Now().Add(m.txnTimeout)
+}
+
+// sweepTransactions periodically aborts the registered
+// transactions that have been idle for longer than the
+// configured transaction timeout.
+func (m *memdbManipulator) sweepTransactions() {
+
+	ticker := time.NewTicker(m.txnTimeout)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			m.sweepExpiredTxns(now)
+		case <-m.sweeperStop:
+			return
+		}
+	}
+}
+
+// sweepExpiredTxns aborts the transactions that expired at the given time.
+// A transaction used by an operation is aborted once the operation is done.
+// Their IDs are kept until they are committed or aborted so the writes made
+// with them afterwards fail.
+func (m *memdbManipulator) sweepExpiredTxns(now time.Time) {
+
+	for _, t := range m.popExpiredTxns(now) {
+		_ = m.closeTxn(t, false)
+	}
+}
+
+func (m *memdbManipulator) popExpiredTxns(now time.Time) []*transaction {
+
+	m.txnRegistryLock.Lock()
+	defer m.txnRegistryLock.Unlock()
+
+	var out []*transaction
+	for id, t := range m.txnRegistry {
+		if t.deadline.IsZero() || now.Before(t.deadline) {
+			continue
+		}
+		out = append(out, t)
+		delete(m.txnRegistry, id)
+		m.expiredTxns[id] = struct{}{}
+	}
+
+	return out
 }
 
 // RetrieveFromFilter compiles the given manipulate Filter into a mongo filter.
-func (m *memdbManipulator) retrieveFromFilter(identity string, f *elemental.Filter, items *map[string]elemental.Identifiable, fullQuery bool) error {
+func (m *memdbManipulator) retrieveFromFilter(txn *memdb.Txn, identity string, f *elemental.Filter, items *map[string]elemental.Identifiable, fullQuery bool) error {
 
 	if f == nil {
-		return m.retrieveIntersection(identity, "id", nil, items, fullQuery)
+		return m.retrieveIntersection(txn, identity, "id", nil, items, fullQuery)
 	}
 
 	if len(f.Operators()) == 0 {
@@ -343,7 +719,7 @@ func (m *memdbManipulator) retrieveFromFilter(identity string, f *elemental.Filt
 
 			case elemental.EqualComparator:
 
-				if err := m.retrieveIntersection(identity, k, f.Values()[i][0], items, fullQuery); err != nil {
+				if err := m.retrieveIntersection(txn, identity, k, f.Values()[i][0], items, fullQuery); err != nil {
 					return err
 				}
 
@@ -361,7 +737,7 @@ func (m *memdbManipulator) retrieveFromFilter(identity string, f *elemental.Filt
 					fv = strings.TrimSuffix(fv, "$")
 
 					valueItems := map[string]elemental.Identifiable{}
-					if err := m.retrieveIntersection(identity, k+"_prefix", fv, &valueItems, fullQuery); err != nil {
+					if err := m.retrieveIntersection(txn, identity, k+"_prefix", fv, &valueItems, fullQuery); err != nil {
 						return err
 					}
 					mergeIn(items, &valueItems)
@@ -371,11 +747,18 @@ func (m *memdbManipulator) retrieveFromFilter(identity string, f *elemental.Filt
 
 				values := f.Values()[i]
 
+				if em, ok := values[0].(manipulate.ElementMatch); ok && len(values) == 1 {
+					if err := m.retrieveElementMatch(txn, identity, f.Keys()[i], em.Filter, items, fullQuery); err != nil {
+						return err
+					}
+					break
+				}
+
 				containItems := map[string]elemental.Identifiable{}
 
 				for _, value := range values {
 					valueItems := map[string]elemental.Identifiable{}
-					if err := m.retrieveIntersection(identity, k, value, &valueItems, true); err != nil {
+					if err := m.retrieveIntersection(txn, identity, k, value, &valueItems, true); err != nil {
 						return err
 					}
 					mergeIn(&containItems, &valueItems)
@@ -390,7 +773,7 @@ func (m *memdbManipulator) retrieveFromFilter(identity string, f *elemental.Filt
 		case elemental.AndFilterOperator:
 
 			for _, sub := range f.AndFilters()[i] {
-				if err := m.retrieveFromFilter(identity, sub, items, fullQuery); err != nil {
+				if err := m.retrieveFromFilter(txn, identity, sub, items, fullQuery); err != nil {
 					return err
 				}
 				fullQuery = false
@@ -403,7 +786,7 @@ func (m *memdbManipulator) retrieveFromFilter(identity string, f *elemental.Filt
 			for _, sub := range f.OrFilters()[i] {
 				valueItems := map[string]elemental.Identifiable{}
 
-				if err := m.retrieveFromFilter(identity, sub, &valueItems, true); err != nil {
+				if err := m.retrieveFromFilter(txn, identity, sub, &valueItems, true); err != nil {
 					return err
 				}
 
@@ -422,15 +805,65 @@ func (m *memdbManipulator) retrieveFromFilter(identity string, f *elemental.Filt
 	return nil
 }
 
-func (m *memdbManipulator) retrieveIntersection(identity string, k string, value interface{}, items *map[string]elemental.Identifiable, fullquery bool) error {
+// matchesFilter returns true if the object of the given identity with
+// the given ID matches the given filter, or if the filter is nil.
+func (m *memdbManipulator) matchesFilter(txn *memdb.Txn, identity string, id string, f *elemental.Filter) (bool, error) {
+
+	if f == nil {
+		return true, nil
+	}
+
+	items := map[string]elemental.Identifiable{}
+	if err := m.retrieveFromFilter(txn, identity, f, &items, true); err != nil {
+		return false, err
+	}
+
+	_, ok := items[id]
+
+	return ok, nil
+}
+
+// retrieveElementMatch intersects the given items with the objects having at least
+// one element of the array stored in the given attribute that matches the given filter.
+// As there is no index on the elements, all objects of the identity are evaluated.
+func (m *memdbManipulator) retrieveElementMatch(txn *memdb.Txn, identity string, attribute string, f *elemental.Filter, items *map[string]elemental.Identifiable, fullQuery bool) error {
+
+	allItems := map[string]elemental.Identifiable{}
+	if err := m.retrieveIntersection(txn, identity, "id", nil, &allItems, true); err != nil {
+		return err
+	}
+
+	matchItems := map[string]elemental.Identifiable{}
+
+	for id, o := range allItems {
+
+		a, ok := o.(elemental.AttributeSpecifiable)
+		if !ok {
+			return manipulate.NewErrCannotExecuteQuery(fmt.Sprintf("element match is not supported on %T", o))
+		}
+
+		matched, err := matchElements(a.ValueForAttribute(attribute), f)
+		if err != nil {
+			return manipulate.NewErrCannotExecuteQuery(err.Error())
+		}
+
+		if matched {
+			matchItems[id] = o
+		}
+	}
+
+	intersection(items, &matchItems, fullQuery)
+
+	return nil
+}
+
+func (m *memdbManipulator) retrieveIntersection(txn *memdb.Txn, identity string, k string, value interface{}, items *map[string]elemental.Identifiable, fullquery bool) error {
 
 	var iterator memdb.ResultIterator
 	var err error
 
 	existingItems := *items
 
-	txn := m.getDB().Txn(false)
-
 	if value == nil {
 		iterator, err = txn.Get(identity, k)
 	} else {
@@ -14,9 +14,11 @@ package manipmemory
 import (
 	"context"
 	"crypto/rand"
+	"fmt"
 	"reflect"
 	"strconv"
 	"testing"
+	"time"
 
 	"go.aporeto.io/elemental"
 
@@ -24,6 +26,7 @@ import (
 	. "github.com/smartystreets/goconvey/convey"
 	testmodel "go.aporeto.io/elemental/test/model"
 	"go.aporeto.io/manipulate"
+	"go.aporeto.io/manipulate/maniptest"
 )
 
 func datastoreIndexConfig() map[string]*IdentitySchema {
@@ -303,6 +306,41 @@ func TestMemManipulator_Retrieve(t *testing.T) {
 			})
 		})
 
+		Convey("When I retrieve a non existing list with ContextOptionNotFoundAsEmpty", func() {
+
+			ps := &testmodel.List{
+				ID: "not-good",
+			}
+
+			err := m.Retrieve(manipulate.NewContext(context.Background(), manipulate.ContextOptionNotFoundAsEmpty()), ps)
+
+			Convey("Then err should be nil", func() {
+				So(err, ShouldBeNil)
+			})
+
+			Convey("Then the object should be untouched", func() {
+				So(ps, ShouldResemble, &testmodel.List{ID: "not-good"})
+			})
+		})
+
+		Convey("When I retrieve the list with a post read hook that filters it out", func() {
+
+			ps := &testmodel.List{
+				ID: l1.ID,
+			}
+
+			mctx := manipulate.NewContext(
+				context.Background(),
+				manipulate.ContextOptionPostReadHook(func(elemental.Identifiable) (bool, error) { return false, nil }),
+			)
+
+			err := m.Retrieve(mctx, ps)
+
+			Convey("Then err should be correct", func() {
+				So(manipulate.IsObjectNotFoundError(err), ShouldBeTrue)
+			})
+		})
+
 		Convey("When I retrieve an object that is not part of the schema", func() {
 
 			err := m.Retrieve(nil, &testmodel.Task{})
@@ -362,6 +400,51 @@ func TestMemManipulator_RetrieveMany(t *testing.T) {
 			})
 		})
 
+		Convey("When I retrieve the lists with a post read hook", func() {
+
+			ps := testmodel.ListsList{}
+
+			mctx := manipulate.NewContext(
+				context.Background(),
+				manipulate.ContextOptionPostReadHook(func(o elemental.Identifiable) (bool, error) {
+					l := o.(*testmodel.List)
+					l.Description = "hooked"
+					return l.Name != "Antoine1", nil
+				}),
+			)
+
+			err := m.RetrieveMany(mctx, &ps)
+
+			Convey("Then err should be nil", func() {
+				So(err, ShouldBeNil)
+			})
+
+			Convey("Then the filtered out item should not be returned", func() {
+				So(len(ps), ShouldEqual, 3)
+				for _, l := range ps {
+					So(l.Name, ShouldNotEqual, "Antoine1")
+					So(l.Description, ShouldEqual, "hooked")
+				}
+			})
+		})
+
+		Convey("When I retrieve the lists with a post read hook that fails", func() {
+
+			ps := testmodel.ListsList{}
+
+			mctx := manipulate.NewContext(
+				context.Background(),
+				manipulate.ContextOptionPostReadHook(func(elemental.Identifiable) (bool, error) { return false, fmt.Errorf("boom") }),
+			)
+
+			err := m.RetrieveMany(mctx, &ps)
+
+			Convey("Then err should be correct", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldEqual, "boom")
+			})
+		})
+
 		Convey("When I retrieve the lists with a filter that matches l1 Equals", func() {
 
 			ps := testmodel.ListsList{}
@@ -883,7 +966,9 @@ func TestMemManipulator_txnForID(t *testing.T) {
 
 		Convey("When I call txnForID with an empty ID", func() {
 
-			txn := m.(*memdbManipulator).txnForID("")
+			txn, release, err := m.(*memdbManipulator).txnForID("", "")
+			So(err, ShouldBeNil)
+			defer release()
 
 			Convey("Then txn should not be nil", func() {
 				So(txn, ShouldNotBeNil)
@@ -894,7 +979,9 @@ func TestMemManipulator_txnForID(t *testing.T) {
 
 			btxn := m.(*memdbManipulator).db.Txn(true)
 			m.(*memdbManipulator).registerTxn(tid, btxn)
-			txn := m.(*memdbManipulator).txnForID(tid)
+			txn, release, err := m.(*memdbManipulator).txnForID(tid, "")
+			So(err, ShouldBeNil)
+			defer release()
 
 			Convey("Then txn should not be nil", func() {
 				So(txn, ShouldEqual, btxn)
@@ -903,7 +990,9 @@ func TestMemManipulator_txnForID(t *testing.T) {
 
 		Convey("When I call txnForID with an non existing ID", func() {
 
-			txn := m.(*memdbManipulator).txnForID(tid)
+			txn, release, err := m.(*memdbManipulator).txnForID(tid, "")
+			So(err, ShouldBeNil)
+			defer release()
 
 			Convey("Then txn should not be nil", func() {
 				So(txn, ShouldNotBeNil)
@@ -912,6 +1001,540 @@ func TestMemManipulator_txnForID(t *testing.T) {
 	})
 }
 
+func TestMemManipulator_ReadYourWrites(t *testing.T) {
+
+	Convey("Given I have a memory manipulator and an object created in a transaction", t, func() {
+
+		m, err := New(datastoreIndexConfig())
+		So(err, ShouldBeNil)
+
+		tid := manipulate.NewTransactionID()
+		tctx := manipulate.NewContext(context.Background(), manipulate.ContextOptionTransactionID(tid))
+		So(m.Create(tctx, &testmodel.List{ID: "1", Name: "hello"}), ShouldBeNil)
+
+		Convey("When I retrieve the object in the transaction", func() {
+
+			obj := &testmodel.List{ID: "1"}
+			err := m.Retrieve(tctx, obj)
+
+			Convey("Then err should be nil", func() {
+				So(err, ShouldBeNil)
+			})
+
+			Convey("Then the object should be correct", func() {
+				So(obj.Name, ShouldEqual, "hello")
+			})
+		})
+
+		Convey("When I retrieve many objects in the transaction", func() {
+
+			lists := testmodel.ListsList{}
+			err := m.RetrieveMany(tctx, &lists)
+
+			Convey("Then err should be nil", func() {
+				So(err, ShouldBeNil)
+			})
+
+			Convey("Then I should get the object", func() {
+				So(len(lists), ShouldEqual, 1)
+			})
+		})
+
+		Convey("When I count the objects in the transaction", func() {
+
+			n, err := m.Count(tctx, testmodel.ListIdentity)
+
+			Convey("Then err should be nil", func() {
+				So(err, ShouldBeNil)
+			})
+
+			Convey("Then the count should be correct", func() {
+				So(n, ShouldEqual, 1)
+			})
+		})
+
+		Convey("When I retrieve objects with a hook that reads in the same transaction", func() {
+
+			read := func(obj elemental.Identifiable) (bool, error) {
+				return true, m.Retrieve(tctx, &testmodel.List{ID: obj.Identifier()})
+			}
+			hctx := tctx.Derive(manipulate.ContextOptionPostReadHook(read))
+
+			done := make(chan error, 2)
+			go func() {
+				done <- m.Retrieve(hctx, &testmodel.List{ID: "1"})
+				done <- m.RetrieveMany(hctx, &testmodel.ListsList{})
+			}()
+
+			Convey("Then it should not deadlock", func() {
+				for i := 0; i < 2; i++ {
+					select {
+					case err := <-done:
+						So(err, ShouldBeNil)
+					case <-time.After(5 * time.Second):
+						t.Fatal("the hook could not use the transaction")
+					}
+				}
+			})
+		})
+
+		Convey("When I retrieve the object outside of the transaction", func() {
+
+			err := m.Retrieve(manipulate.NewContext(context.Background()), &testmodel.List{ID: "1"})
+
+			Convey("Then err should be correct", func() {
+				So(manipulate.IsObjectNotFoundError(err), ShouldBeTrue)
+			})
+		})
+
+		Convey("When I commit the transaction and retrieve the object outside of it", func() {
+
+			So(m.Commit(tid), ShouldBeNil)
+			err := m.Retrieve(manipulate.NewContext(context.Background()), &testmodel.List{ID: "1"})
+
+			Convey("Then err should be nil", func() {
+				So(err, ShouldBeNil)
+			})
+		})
+	})
+}
+
+func TestMemManipulator_ConcurrentWrites(t *testing.T) {
+
+	Convey("Given I have a memory manipulator and an object created in a transaction", t, func() {
+
+		m, err := New(datastoreIndexConfig())
+		So(err, ShouldBeNil)
+
+		tid := manipulate.NewTransactionID()
+		tctx := manipulate.NewContext(context.Background(), manipulate.ContextOptionTransactionID(tid))
+		So(m.Create(tctx, &testmodel.List{ID: "1", Name: "hello"}), ShouldBeNil)
+
+		Convey("When I create an object outside of the transaction", func() {
+
+			errCh := make(chan error, 1)
+			go func() {
+				errCh <- m.Create(manipulate.NewContext(context.Background()), &testmodel.List{ID: "2", Name: "world"})
+			}()
+
+			var err error
+			var blocked bool
+			select {
+			case err = <-errCh:
+			case <-time.After(5 * time.Second):
+				blocked = true
+			}
+
+			Convey("Then it should not be blocked by the transaction", func() {
+				So(blocked, ShouldBeFalse)
+			})
+
+			Convey("Then err should be nil", func() {
+				So(err, ShouldBeNil)
+			})
+
+			Convey("Then the object should not be visible in the transaction", func() {
+				err := m.Retrieve(tctx, &testmodel.List{ID: "2"})
+				So(manipulate.IsObjectNotFoundError(err), ShouldBeTrue)
+			})
+
+			Convey("When I commit the transaction", func() {
+
+				err := m.Commit(tid)
+
+				Convey("Then err should be nil", func() {
+					So(err, ShouldBeNil)
+				})
+
+				Convey("Then both objects should be stored", func() {
+					n, err := m.Count(manipulate.NewContext(context.Background()), testmodel.ListIdentity)
+					So(err, ShouldBeNil)
+					So(n, ShouldEqual, 2)
+				})
+			})
+		})
+
+		Convey("When I delete the object in the transaction and commit it", func() {
+
+			So(m.Delete(tctx, &testmodel.List{ID: "1"}), ShouldBeNil)
+			err := m.Commit(tid)
+
+			Convey("Then err should be nil", func() {
+				So(err, ShouldBeNil)
+			})
+
+			Convey("Then the object should not be stored", func() {
+				n, err := m.Count(manipulate.NewContext(context.Background()), testmodel.ListIdentity)
+				So(err, ShouldBeNil)
+				So(n, ShouldEqual, 0)
+			})
+		})
+	})
+}
+
+func TestMemManipulator_CommitConflict(t *testing.T) {
+
+	Convey("Given I have a memory manipulator and an object deleted in a labeled transaction", t, func() {
+
+		m, err := New(datastoreIndexConfig())
+		So(err, ShouldBeNil)
+
+		So(m.Create(nil, &testmodel.List{ID: "1", Name: "hello"}), ShouldBeNil)
+
+		tid := manipulate.NewTransactionID()
+		tctx := manipulate.NewContext(
+			context.Background(),
+			manipulate.ContextOptionTransactionID(tid),
+			manipulate.ContextOptionTransactionLabel("cleanup"),
+		)
+		So(m.Delete(tctx, &testmodel.List{ID: "1"}), ShouldBeNil)
+
+		Convey("When the object is deleted outside of the transaction and I commit it", func() {
+
+			So(m.Delete(nil, &testmodel.List{ID: "1"}), ShouldBeNil)
+			err := m.Commit(tid)
+
+			Convey("Then err should be correct", func() {
+				So(err, ShouldHaveSameTypeAs, manipulate.ErrCannotCommit{})
+				So(err.Error(), ShouldContainSubstring, "unable to commit transaction "+string(tid)+" (cleanup): ")
+			})
+		})
+	})
+
+	Convey("Given I have a memory manipulator and an object updated in a transaction", t, func() {
+
+		m, err := New(datastoreIndexConfig())
+		So(err, ShouldBeNil)
+
+		So(m.Create(nil, &testmodel.List{ID: "1", Name: "hello"}), ShouldBeNil)
+
+		tid := manipulate.NewTransactionID()
+		tctx := manipulate.NewContext(context.Background(), manipulate.ContextOptionTransactionID(tid))
+		So(m.Update(tctx, &testmodel.List{ID: "1", Name: "from transaction"}), ShouldBeNil)
+
+		Convey("When the object is updated outside of the transaction and I commit it", func() {
+
+			So(m.Update(nil, &testmodel.List{ID: "1", Name: "from outside"}), ShouldBeNil)
+			err := m.Commit(tid)
+
+			Convey("Then err should be correct", func() {
+				So(err, ShouldHaveSameTypeAs, manipulate.ErrCannotCommit{})
+				So(err.Error(), ShouldContainSubstring, "has been modified outside of the transaction")
+			})
+
+			Convey("Then the object should not have been overwritten", func() {
+				obj := &testmodel.List{ID: "1"}
+				So(m.Retrieve(nil, obj), ShouldBeNil)
+				So(obj.Name, ShouldEqual, "from outside")
+			})
+		})
+
+		Convey("When the object is deleted outside of the transaction and I commit it", func() {
+
+			So(m.Delete(nil, &testmodel.List{ID: "1"}), ShouldBeNil)
+			err := m.Commit(tid)
+
+			Convey("Then err should be correct", func() {
+				So(err, ShouldHaveSameTypeAs, manipulate.ErrCannotCommit{})
+			})
+
+			Convey("Then the object should not have been restored", func() {
+				err := m.Retrieve(nil, &testmodel.List{ID: "1"})
+				So(manipulate.IsObjectNotFoundError(err), ShouldBeTrue)
+			})
+		})
+	})
+
+	Convey("Given I have a memory manipulator and an object created in a transaction", t, func() {
+
+		m, err := New(datastoreIndexConfig())
+		So(err, ShouldBeNil)
+
+		tid := manipulate.NewTransactionID()
+		tctx := manipulate.NewContext(context.Background(), manipulate.ContextOptionTransactionID(tid))
+		So(m.Create(tctx, &testmodel.List{ID: "1", Name: "from transaction"}), ShouldBeNil)
+
+		Convey("When an object with the same ID is created outside of the transaction and I commit it", func() {
+
+			So(m.Create(nil, &testmodel.List{ID: "1", Name: "from outside"}), ShouldBeNil)
+			err := m.Commit(tid)
+
+			Convey("Then err should be correct", func() {
+				So(err, ShouldHaveSameTypeAs, manipulate.ErrCannotCommit{})
+			})
+
+			Convey("Then the object should not have been overwritten", func() {
+				obj := &testmodel.List{ID: "1"}
+				So(m.Retrieve(nil, obj), ShouldBeNil)
+				So(obj.Name, ShouldEqual, "from outside")
+			})
+		})
+	})
+}
+
+func TestMemManipulator_ScopedManipulator(t *testing.T) {
+
+	Convey("Given I have a scoped memory manipulator and two objects", t, func() {
+
+		m, err := New(datastoreIndexConfig())
+		So(err, ShouldBeNil)
+
+		So(m.Create(nil, &testmodel.List{ID: "1", Name: "inside"}), ShouldBeNil)
+		So(m.Create(nil, &testmodel.List{ID: "2", Name: "outside"}), ShouldBeNil)
+
+		sm := manipulate.NewScopedManipulator(m, func(elemental.Identity) *elemental.Filter {
+			return elemental.NewFilterComposer().WithKey("name").Equals("inside").Done()
+		})
+
+		Convey("When I retrieve the object in the scope", func() {
+
+			obj := &testmodel.List{ID: "1"}
+			err := sm.Retrieve(nil, obj)
+
+			Convey("Then err should be nil", func() {
+				So(err, ShouldBeNil)
+			})
+
+			Convey("Then the object should be correct", func() {
+				So(obj.Name, ShouldEqual, "inside")
+			})
+		})
+
+		Convey("When I retrieve the object outside of the scope", func() {
+
+			err := sm.Retrieve(nil, &testmodel.List{ID: "2"})
+
+			Convey("Then err should be correct", func() {
+				So(manipulate.IsObjectNotFoundError(err), ShouldBeTrue)
+			})
+		})
+
+		Convey("When I delete the object in the scope", func() {
+
+			err := sm.Delete(nil, &testmodel.List{ID: "1"})
+
+			Convey("Then err should be nil", func() {
+				So(err, ShouldBeNil)
+			})
+
+			Convey("Then the object should be deleted", func() {
+				err := m.Retrieve(nil, &testmodel.List{ID: "1"})
+				So(manipulate.IsObjectNotFoundError(err), ShouldBeTrue)
+			})
+		})
+
+		Convey("When I delete the object outside of the scope", func() {
+
+			err := sm.Delete(nil, &testmodel.List{ID: "2"})
+
+			Convey("Then err should be correct", func() {
+				So(manipulate.IsObjectNotFoundError(err), ShouldBeTrue)
+			})
+
+			Convey("Then the object should not be deleted", func() {
+				So(m.Retrieve(nil, &testmodel.List{ID: "2"}), ShouldBeNil)
+			})
+		})
+	})
+}
+
+func TestMemManipulator_Shutdown(t *testing.T) {
+
+	Convey("Given I have a memory manipulator and a pending transaction", t, func() {
+
+		m, err := New(datastoreIndexConfig(), OptionTransactionTimeout(time.Hour))
+		So(err, ShouldBeNil)
+
+		tid := manipulate.NewTransactionID()
+		obj := &testmodel.List{ID: "1", Name: "hello"}
+		So(m.Create(manipulate.NewContext(context.Background(), manipulate.ContextOptionTransactionID(tid)), obj), ShouldBeNil)
+
+		Convey("When I call Shutdown with commit set to true", func() {
+
+			err := m.(manipulate.ClosableManipulator).Shutdown(context.Background(), true)
+
+			Convey("Then err should be nil", func() {
+				So(err, ShouldBeNil)
+			})
+
+			Convey("Then the registry should be empty", func() {
+				So(m.(*memdbManipulator).txnRegistry, ShouldBeEmpty)
+			})
+
+			Convey("Then calling Shutdown again should not panic", func() {
+				So(func() { _ = m.(manipulate.ClosableManipulator).Shutdown(context.Background(), true) }, ShouldNotPanic)
+			})
+		})
+
+		Convey("When I call Shutdown with commit set to false", func() {
+
+			err := m.(manipulate.ClosableManipulator).Shutdown(context.Background(), false)
+
+			Convey("Then err should be nil", func() {
+				So(err, ShouldBeNil)
+			})
+
+			Convey("Then the registry should be empty", func() {
+				So(m.(*memdbManipulator).txnRegistry, ShouldBeEmpty)
+			})
+		})
+
+		Convey("When I call Shutdown with a canceled context", func() {
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			err := m.(manipulate.ClosableManipulator).Shutdown(ctx, true)
+
+			Convey("Then err should be correct", func() {
+				So(err, ShouldEqual, context.Canceled)
+			})
+
+			Convey("Then the registry should be empty", func() {
+				So(m.(*memdbManipulator).txnRegistry, ShouldBeEmpty)
+			})
+		})
+	})
+}
+
+func TestMemManipulator_TransactionTimeout(t *testing.T) {
+
+	Convey("Given I have a memory manipulator with a transaction timeout", t, func() {
+
+		m, err := New(datastoreIndexConfig(), OptionTransactionTimeout(time.Hour))
+		So(err, ShouldBeNil)
+		defer m.(manipulate.ClosableManipulator).Shutdown(context.Background(), false) // nolint
+
+		tid := manipulate.NewTransactionID()
+		tctx := manipulate.NewContext(context.Background(), manipulate.ContextOptionTransactionID(tid))
+
+		Convey("When I create an object in a transaction and forget about it", func() {
+
+			So(m.Create(tctx, &testmodel.List{ID: "1", Name: "hello"}), ShouldBeNil)
+
+			m.(*memdbManipulator).sweepExpiredTxns(time.Now().Add(2 * time.Hour))
+
+			Convey("Then it should have been removed from the registry", func() {
+				So(m.(*memdbManipulator).registeredTxnWithID(tid), ShouldBeNil)
+			})
+
+			Convey("Then it should not be possible to commit it", func() {
+				err := m.Commit(tid)
+				So(err, ShouldHaveSameTypeAs, manipulate.ErrCannotCommit{})
+				So(err.Error(), ShouldContainSubstring, "has expired")
+			})
+
+			Convey("Then the object should not have been stored", func() {
+				err := m.Retrieve(manipulate.NewContext(context.Background()), &testmodel.List{ID: "1"})
+				So(manipulate.IsObjectNotFoundError(err), ShouldBeTrue)
+			})
+
+			Convey("When I write again in the transaction and commit it", func() {
+
+				err := m.Create(tctx, &testmodel.List{ID: "2", Name: "world"})
+				cerr := m.Commit(tid)
+
+				Convey("Then the write should have failed", func() {
+					So(err, ShouldHaveSameTypeAs, manipulate.ErrCannotExecuteQuery{})
+				})
+
+				Convey("Then the commit should have failed", func() {
+					So(cerr, ShouldHaveSameTypeAs, manipulate.ErrCannotCommit{})
+				})
+
+				Convey("Then none of the objects should have been stored", func() {
+					n, err := m.Count(manipulate.NewContext(context.Background()), testmodel.ListIdentity)
+					So(err, ShouldBeNil)
+					So(n, ShouldEqual, 0)
+				})
+
+				Convey("Then the transaction ID should be usable again", func() {
+					So(m.Create(tctx, &testmodel.List{ID: "3", Name: "again"}), ShouldBeNil)
+					So(m.Commit(tid), ShouldBeNil)
+				})
+			})
+
+			Convey("When I abort it", func() {
+
+				So(m.Abort(tid), ShouldBeFalse)
+
+				Convey("Then the transaction ID should be usable again", func() {
+					So(m.Create(tctx, &testmodel.List{ID: "2", Name: "world"}), ShouldBeNil)
+					So(m.Commit(tid), ShouldBeNil)
+				})
+			})
+		})
+
+		Convey("When I sweep before the transaction expired", func() {
+
+			So(m.Create(tctx, &testmodel.List{ID: "1", Name: "hello"}), ShouldBeNil)
+
+			m.(*memdbManipulator).sweepExpiredTxns(time.Now())
+
+			Convey("Then it should still be registered", func() {
+				So(m.(*memdbManipulator).registeredTxnWithID(tid), ShouldNotBeNil)
+			})
+
+			Convey("Then I should be able to commit it", func() {
+				So(m.Commit(tid), ShouldBeNil)
+			})
+		})
+
+		Convey("When the transaction expires while it is in use", func() {
+
+			txn, release, err := m.(*memdbManipulator).txnForID(tid, "")
+			So(err, ShouldBeNil)
+
+			done := make(chan struct{})
+			go func() {
+				m.(*memdbManipulator).sweepExpiredTxns(time.Now().Add(2 * time.Hour))
+				close(done)
+			}()
+
+			err = txn.Insert(testmodel.ListIdentity.Category, &testmodel.List{ID: "1", Name: "hello"})
+			release()
+			<-done
+
+			Convey("Then the write should have succeeded", func() {
+				So(err, ShouldBeNil)
+			})
+
+			Convey("Then it should have been aborted", func() {
+				So(m.(*memdbManipulator).registeredTxnWithID(tid), ShouldBeNil)
+				So(m.Abort(tid), ShouldBeFalse)
+			})
+
+			Convey("Then the next write should fail", func() {
+				ntxn, _, err := m.(*memdbManipulator).txnForID(tid, "")
+				So(ntxn, ShouldBeNil)
+				So(err, ShouldHaveSameTypeAs, manipulate.ErrCannotExecuteQuery{})
+			})
+		})
+	})
+
+	Convey("Given I have a memory manipulator without transaction timeout", t, func() {
+
+		m, err := New(datastoreIndexConfig())
+		So(err, ShouldBeNil)
+
+		tid := manipulate.NewTransactionID()
+
+		Convey("When I create a transaction", func() {
+
+			txn, release, err := m.(*memdbManipulator).txnForID(tid, "")
+			So(err, ShouldBeNil)
+			release()
+			So(txn, ShouldNotBeNil)
+
+			Convey("Then it should never expire", func() {
+				So(m.(*memdbManipulator).popExpiredTxns(time.Now().Add(time.Hour)), ShouldBeEmpty)
+				So(m.(*memdbManipulator).registeredTxnWithID(tid), ShouldEqual, txn)
+				m.Abort(tid)
+			})
+		})
+	})
+}
+
 func BenchmarkRetrieveMany(b *testing.B) {
 	b.StopTimer()
 
@@ -980,3 +1603,14 @@ func populateDB(m manipulate.TransactionalManipulator, num int) error {
 
 	return nil
 }
+
+func TestMemManipulator_Conformance(t *testing.T) {
+
+	maniptest.RunConformance(t, func() manipulate.Manipulator {
+		m, err := New(datastoreIndexConfig())
+		if err != nil {
+			t.Fatalf("unable to create the manipulator: %s", err)
+		}
+		return m
+	})
+}
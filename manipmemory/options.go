@@ -11,11 +11,19 @@
 
 package manipmemory
 
+import (
+	"time"
+
+	"go.aporeto.io/manipulate"
+)
+
 // An Option represents a maniphttp.Manipulator option.
 type Option func(*config)
 
 type config struct {
-	noCopy bool
+	noCopy             bool
+	transactionTimeout time.Duration
+	idGenerator        manipulate.IdentifierGenerator
 }
 
 func newConfig() *config {
@@ -33,3 +41,27 @@ func OptionNoCopy(noCopy bool) Option {
 		c.noCopy = noCopy
 	}
 }
+
+// OptionTransactionTimeout sets the duration after which
+// a transaction that has not been used is automatically
+// aborted and removed from the registry. A transaction is
+// considered used every time an operation is performed
+// using its ID. Idle transactions are swept at the same
+// interval, so a forgotten transaction can live up to twice
+// the given duration. Writes and commits using the ID of an
+// expired transaction fail until it is committed or aborted.
+// If zero (the default), transactions never expire.
+func OptionTransactionTimeout(timeout time.Duration) Option {
+	return func(c *config) {
+		c.transactionTimeout = timeout
+	}
+}
+
+// OptionIdentifierGenerator sets the manipulate.IdentifierGenerator
+// used to generate the identifiers of the created objects that
+// don't already have one. By default, a new ObjectId is used.
+func OptionIdentifierGenerator(generator manipulate.IdentifierGenerator) Option {
+	return func(c *config) {
+		c.idGenerator = generator
+	}
+}
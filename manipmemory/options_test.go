@@ -13,8 +13,10 @@ package manipmemory
 
 import (
 	"testing"
+	"time"
 
 	. "github.com/smartystreets/goconvey/convey"
+	"go.aporeto.io/elemental"
 )
 
 func Test_newConfig(t *testing.T) {
@@ -36,4 +38,16 @@ func Test_Options(t *testing.T) {
 		OptionNoCopy(true)(c)
 		So(c.noCopy, ShouldBeTrue)
 	})
+
+	Convey("Calling OptionTransactionTimeout should work", t, func() {
+		c := newConfig()
+		OptionTransactionTimeout(time.Minute)(c)
+		So(c.transactionTimeout, ShouldEqual, time.Minute)
+	})
+
+	Convey("Calling OptionIdentifierGenerator should work", t, func() {
+		c := newConfig()
+		OptionIdentifierGenerator(func(elemental.Identifiable) string { return "id" })(c)
+		So(c.idGenerator(nil), ShouldEqual, "id")
+	})
 }
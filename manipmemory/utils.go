@@ -157,3 +157,93 @@ func intersection(target, source *map[string]elemental.Identifiable, queryStart
 
 	*target = combined
 }
+
+// matchElements returns true if at least one element of the given
+// array matches all the conditions of the given filter.
+func matchElements(array interface{}, f *elemental.Filter) (bool, error) {
+
+	v := reflect.Indirect(reflect.ValueOf(array))
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return false, nil
+	}
+
+	for i := 0; i < v.Len(); i++ {
+
+		ok, err := matchElement(v.Index(i).Interface(), f)
+		if err != nil {
+			return false, err
+		}
+
+		if ok {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// matchElement returns true if the given element matches all the conditions
+// of the given filter. Only equality comparisons combined with and are supported.
+func matchElement(elem interface{}, f *elemental.Filter) (bool, error) {
+
+	for i, operator := range f.Operators() {
+
+		switch operator {
+
+		case elemental.AndOperator:
+
+			if f.Comparators()[i] != elemental.EqualComparator {
+				return false, fmt.Errorf("invalid comparator for element match: %d", f.Comparators()[i])
+			}
+
+			if !reflect.DeepEqual(elementValue(elem, f.Keys()[i]), f.Values()[i][0]) {
+				return false, nil
+			}
+
+		case elemental.AndFilterOperator:
+
+			for _, sub := range f.AndFilters()[i] {
+				ok, err := matchElement(elem, sub)
+				if err != nil || !ok {
+					return false, err
+				}
+			}
+
+		default:
+			return false, fmt.Errorf("invalid operator for element match: %d", operator)
+		}
+	}
+
+	return true, nil
+}
+
+// elementValue returns the value of the given key of the given element.
+// The element can be an elemental.AttributeSpecifiable, a map or a struct,
+// in which case the field is matched without case sensitivity.
+func elementValue(elem interface{}, key string) interface{} {
+
+	if a, ok := elem.(elemental.AttributeSpecifiable); ok {
+		return a.ValueForAttribute(key)
+	}
+
+	v := reflect.Indirect(reflect.ValueOf(elem))
+
+	switch v.Kind() {
+
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil
+		}
+		if fv := v.MapIndex(reflect.ValueOf(key).Convert(v.Type().Key())); fv.IsValid() {
+			return fv.Interface()
+		}
+
+	case reflect.Struct:
+		fv := v.FieldByNameFunc(func(name string) bool { return strings.EqualFold(name, key) })
+		if fv.IsValid() && fv.CanInterface() {
+			return fv.Interface()
+		}
+	}
+
+	return nil
+}
@@ -15,6 +15,7 @@ import (
 	"testing"
 
 	. "github.com/smartystreets/goconvey/convey"
+	"go.aporeto.io/elemental"
 )
 
 func Test_boolIndex(t *testing.T) {
@@ -68,3 +69,55 @@ func Test_boolIndex(t *testing.T) {
 		})
 	})
 }
+
+func Test_matchElements(t *testing.T) {
+
+	type tag struct {
+		Name  string
+		Value string
+	}
+
+	Convey("Given I have an array of elements", t, func() {
+
+		tags := []tag{{Name: "x", Value: "z"}, {Name: "w", Value: "y"}}
+
+		Convey("When I match conditions that are true in the same element", func() {
+
+			ok, err := matchElements(tags, elemental.NewFilterComposer().WithKey("name").Equals("x").WithKey("value").Equals("z").Done())
+
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+		})
+
+		Convey("When I match conditions that are true in different elements", func() {
+
+			ok, err := matchElements(tags, elemental.NewFilterComposer().WithKey("name").Equals("x").WithKey("value").Equals("y").Done())
+
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("When I match elements of a map", func() {
+
+			ok, err := matchElements([]map[string]interface{}{{"name": "x"}}, elemental.NewFilterComposer().WithKey("name").Equals("x").Done())
+
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+		})
+
+		Convey("When I use an unsupported comparator", func() {
+
+			_, err := matchElements(tags, elemental.NewFilterComposer().WithKey("name").NotEquals("x").Done())
+
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("When I match something that is not an array", func() {
+
+			ok, err := matchElements("x", elemental.NewFilterComposer().WithKey("name").Equals("x").Done())
+
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeFalse)
+		})
+	})
+}
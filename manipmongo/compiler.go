@@ -18,11 +18,16 @@ import (
 
 	"github.com/globalsign/mgo/bson"
 	"go.aporeto.io/elemental"
+	"go.aporeto.io/manipulate"
 	"go.aporeto.io/manipulate/internal/objectid"
 )
 
+// bsonTypeNull is the bson type number of null values.
+const bsonTypeNull = 10
+
 type compilerConfig struct {
 	translateKeysFromSpec bool
+	coerceValuesFromSpec  bool
 	attrSpecs             map[string]elemental.AttributeSpecification
 }
 
@@ -41,19 +46,100 @@ func CompilerOptionTranslateKeysFromSpec(attrSpecs map[string]elemental.Attribut
 	}
 }
 
+// CompilerOptionCoerceValuesFromSpec is an option that will configure the compiler to use the provided attribute specs
+// to convert the filter values to the type stored in Mongo. Strings compared to an identifier attribute are converted
+// to bson.ObjectId and RFC3339 strings compared to a time attribute are converted to time.Time.
+// Values that cannot be converted are left untouched.
+//
+// Without this option, such comparisons would silently match nothing.
+func CompilerOptionCoerceValuesFromSpec(attrSpecs map[string]elemental.AttributeSpecification) CompilerOption {
+	return func(config *compilerConfig) {
+		config.attrSpecs = attrSpecs
+		config.coerceValuesFromSpec = true
+	}
+}
+
 // CompileFilter compiles the given manipulate Filter into a mongo filter.
+//
+// Regarding empty and missing values, the semantics are the following:
+//  - key == "" only matches documents where the field is an empty string.
+//  - key == nil only matches documents where the field is explicitly null.
+//  - key != nil matches all documents except the ones where the field is explicitly null.
+//  - key == false matches documents where the field is false or missing, as false
+//    booleans are usually omitted when stored.
+//  - use Exists and NotExists to match the presence or absence of a field.
 func CompileFilter(f *elemental.Filter, opts ...CompilerOption) bson.D {
 
-	config := compilerConfig{}
+	c := &compiler{
+		elems: make([]bson.DocElem, estimateDocs(f)),
+	}
 	for _, o := range opts {
-		o(&config)
+		o(&c.config)
 	}
 
+	return c.compile(f)
+}
+
+// estimateDocs returns the number of single element documents
+// most filters need once compiled.
+func estimateDocs(f *elemental.Filter) int {
+
+	n := 1
+	for i, operator := range f.Operators() {
+
+		switch operator {
+		case elemental.AndFilterOperator:
+			for _, sub := range f.AndFilters()[i] {
+				n += estimateDocs(sub)
+			}
+		case elemental.OrFilterOperator:
+			for _, sub := range f.OrFilters()[i] {
+				n += estimateDocs(sub)
+			}
+		}
+
+		n += 2
+	}
+
+	return n
+}
+
+// compiler holds the state of a single CompileFilter call.
+// Single element documents are carved out of a shared buffer
+// instead of being allocated one by one.
+type compiler struct {
+	config compilerConfig
+	elems  []bson.DocElem
+}
+
+// doc returns a bson.D containing the single given element.
+// The returned document has a capacity of 1 so appending to it
+// never overwrites the rest of the buffer.
+func (c *compiler) doc(name string, value interface{}) bson.D {
+
+	if len(c.elems) == 0 {
+		c.elems = make([]bson.DocElem, 8)
+	}
+
+	c.elems[0] = bson.DocElem{Name: name, Value: value}
+	d := c.elems[0:1:1]
+	c.elems = c.elems[1:]
+
+	return d
+}
+
+// cmp returns the document {k: {op: value}}.
+func (c *compiler) cmp(k string, op string, value interface{}) bson.D {
+	return c.doc(k, c.doc(op, value))
+}
+
+func (c *compiler) compile(f *elemental.Filter) bson.D {
+
 	if len(f.Operators()) == 0 {
 		return bson.D{}
 	}
 
-	ands := []bson.D{}
+	ands := make([]bson.D, 0, len(f.Operators()))
 
 	for i, operator := range f.Operators() {
 
@@ -61,10 +147,17 @@ func CompileFilter(f *elemental.Filter, opts ...CompilerOption) bson.D {
 
 		case elemental.AndOperator:
 
-			items := []bson.D{}
+			values := f.Values()[i]
 			k := massageKey(f.Keys()[i])
-			if config.translateKeysFromSpec {
-				if specs, ok := config.attrSpecs[k]; ok {
+
+			if c.config.coerceValuesFromSpec {
+				if spec, ok := lookupSpec(c.config.attrSpecs, f.Keys()[i], k); ok {
+					values = coerceValues(spec, values)
+				}
+			}
+
+			if c.config.translateKeysFromSpec {
+				if specs, ok := c.config.attrSpecs[k]; ok {
 					k = specs.BSONFieldName
 				}
 			}
@@ -72,94 +165,164 @@ func CompileFilter(f *elemental.Filter, opts ...CompilerOption) bson.D {
 			switch f.Comparators()[i] {
 
 			case elemental.EqualComparator:
-				v := f.Values()[i][0]
+				v := values[0]
 				switch b := v.(type) {
+				case nil:
+					ands = append(ands, c.doc(k, c.doc("$type", bsonTypeNull)))
 				case bool:
 					if b {
-						items = append(items, bson.D{{Name: k, Value: bson.M{"$eq": v}}})
+						ands = append(ands, c.doc(k, bson.M{"$eq": v}))
 					} else {
-						items = append(
-							items,
-							bson.D{{
-								Name: "$or",
-								Value: []bson.D{
-									{{Name: k, Value: bson.D{{Name: "$eq", Value: v}}}},
-									{{Name: k, Value: bson.D{{Name: "$exists", Value: false}}}},
-								},
-							}},
+						ands = append(
+							ands,
+							c.doc("$or", []bson.D{
+								c.cmp(k, "$eq", v),
+								c.cmp(k, "$exists", false),
+							}),
 						)
 					}
 				default:
-					items = append(items, bson.D{{Name: k, Value: bson.D{{Name: "$eq", Value: massageValue(k, v)}}}})
+					ands = append(ands, c.cmp(k, "$eq", massageValue(k, v)))
 				}
 
 			case elemental.NotEqualComparator:
-				items = append(items, bson.D{{Name: k, Value: bson.D{{Name: "$ne", Value: massageValue(k, f.Values()[i][0])}}}})
+				if values[0] == nil {
+					ands = append(ands, c.cmp(k, "$not", c.doc("$type", bsonTypeNull)))
+				} else {
+					ands = append(ands, c.cmp(k, "$ne", massageValue(k, values[0])))
+				}
+
+			case elemental.ContainComparator:
+				// Mongo natively matches an equality against any element
+				// of an array, so a single value does not need $in.
+				if em, ok := values[0].(manipulate.ElementMatch); ok && len(values) == 1 {
+					// The keys of the sub filter are the ones of the
+					// elements, so we don't apply the attribute specs.
+					config := c.config
+					c.config = compilerConfig{}
+					ands = append(ands, c.cmp(k, "$elemMatch", c.compile(em.Filter)))
+					c.config = config
+				} else if len(values) == 1 {
+					ands = append(ands, c.cmp(k, "$eq", massageValue(k, values[0])))
+				} else {
+					ands = append(ands, c.cmp(k, "$in", massageValues(k, values)))
+				}
 
-			case elemental.InComparator, elemental.ContainComparator:
-				items = append(items, bson.D{{Name: k, Value: bson.D{{Name: "$in", Value: massageValues(k, f.Values()[i])}}}})
+			case elemental.InComparator:
+				ands = append(ands, c.cmp(k, "$in", massageValues(k, values)))
 
 			case elemental.NotInComparator, elemental.NotContainComparator:
-				items = append(items, bson.D{{Name: k, Value: bson.D{{Name: "$nin", Value: massageValues(k, f.Values()[i])}}}})
+				ands = append(ands, c.cmp(k, "$nin", massageValues(k, values)))
 
 			case elemental.GreaterOrEqualComparator:
-				items = append(items, bson.D{{Name: k, Value: bson.D{{Name: "$gte", Value: massageValue(k, f.Values()[i][0])}}}})
+				ands = append(ands, c.cmp(k, "$gte", massageValue(k, values[0])))
 
 			case elemental.GreaterComparator:
-				items = append(items, bson.D{{Name: k, Value: bson.D{{Name: "$gt", Value: massageValue(k, f.Values()[i][0])}}}})
+				ands = append(ands, c.cmp(k, "$gt", massageValue(k, values[0])))
 
 			case elemental.LesserOrEqualComparator:
-				items = append(items, bson.D{{Name: k, Value: bson.D{{Name: "$lte", Value: massageValue(k, f.Values()[i][0])}}}})
+				ands = append(ands, c.cmp(k, "$lte", massageValue(k, values[0])))
 
 			case elemental.LesserComparator:
-				items = append(items, bson.D{{Name: k, Value: bson.D{{Name: "$lt", Value: massageValue(k, f.Values()[i][0])}}}})
+				ands = append(ands, c.cmp(k, "$lt", massageValue(k, values[0])))
 
 			case elemental.ExistsComparator:
-				items = append(items, bson.D{{Name: k, Value: bson.D{{Name: "$exists", Value: true}}}})
+				ands = append(ands, c.cmp(k, "$exists", true))
 
 			case elemental.NotExistsComparator:
-				items = append(items, bson.D{{Name: k, Value: bson.D{{Name: "$exists", Value: false}}}})
+				ands = append(ands, c.cmp(k, "$exists", false))
 
 			case elemental.MatchComparator:
-				dest := []bson.D{}
-				for _, v := range f.Values()[i] {
-					dest = append(dest, bson.D{{Name: k, Value: bson.D{{Name: "$regex", Value: v}}}})
+				dest := make([]bson.D, len(values))
+				for j, v := range values {
+					dest[j] = c.cmp(k, "$regex", v)
 				}
-				items = append(items, bson.D{{Name: "$or", Value: dest}})
+				ands = append(ands, c.doc("$or", dest))
 			}
 
-			ands = append(ands, items...)
-
 		case elemental.AndFilterOperator:
-			subs := []bson.D{}
-			for _, sub := range f.AndFilters()[i] {
-				subs = append(subs, CompileFilter(sub, opts...))
+			subs := make([]bson.D, len(f.AndFilters()[i]))
+			for j, sub := range f.AndFilters()[i] {
+				subs[j] = c.compile(sub)
 			}
-			ands = append(ands, bson.D{{Name: "$and", Value: subs}})
+			ands = append(ands, c.doc("$and", subs))
 
 		case elemental.OrFilterOperator:
-			subs := []bson.D{}
-			for _, sub := range f.OrFilters()[i] {
-				subs = append(subs, CompileFilter(sub, opts...))
+			subs := make([]bson.D, len(f.OrFilters()[i]))
+			for j, sub := range f.OrFilters()[i] {
+				subs[j] = c.compile(sub)
 			}
-			ands = append(ands, bson.D{{Name: "$or", Value: subs}})
+			ands = append(ands, c.doc("$or", subs))
 		}
 	}
 
-	return bson.D{
-		{
-			Name:  "$and",
-			Value: ands,
-		},
+	return c.doc("$and", ands)
+}
+
+func lookupSpec(attrSpecs map[string]elemental.AttributeSpecification, keys ...string) (elemental.AttributeSpecification, bool) {
+
+	for _, k := range keys {
+		if spec, ok := attrSpecs[k]; ok {
+			return spec, true
+		}
 	}
+
+	return elemental.AttributeSpecification{}, false
+}
+
+func coerceValues(spec elemental.AttributeSpecification, values []interface{}) []interface{} {
+
+	// The values are only copied once one of them is converted.
+	out := values
+	var copied bool
+
+	for i, v := range values {
+
+		sv, ok := v.(string)
+		if !ok {
+			continue
+		}
+
+		var coerced interface{}
+
+		switch {
+
+		case spec.Identifier:
+			if oid, ok := objectid.Parse(sv); ok {
+				coerced = oid
+			}
+
+		case spec.Type == "time":
+			if t, err := time.Parse(time.RFC3339Nano, sv); err == nil {
+				coerced = t
+			}
+		}
+
+		if coerced == nil {
+			continue
+		}
+
+		if !copied {
+			out = append([]interface{}{}, values...)
+			copied = true
+		}
+
+		out[i] = coerced
+	}
+
+	return out
 }
 
 func massageKey(key string) string {
 
-	var k string
-	if path := strings.SplitN(key, ".", 2); len(path) > 1 {
-		path[0] = strings.ToLower(path[0])
-		k = strings.Join(path, ".")
+	// Only the first element of a path is lowercased. strings.ToLower
+	// returns its input when there is nothing to change, so already
+	// lowercased keys don't allocate.
+	k := key
+	if i := strings.IndexByte(key, '.'); i >= 0 {
+		if head := strings.ToLower(key[:i]); head != key[:i] {
+			k = head + key[i:]
+		}
 	} else {
 		k = strings.ToLower(key)
 	}
@@ -173,6 +336,10 @@ func massageKey(key string) string {
 
 func massageValue(k string, v interface{}) interface{} {
 
+	if v == nil {
+		return nil
+	}
+
 	if reflect.TypeOf(v).Name() == "Duration" {
 		return time.Now().Add(v.(time.Duration))
 	}
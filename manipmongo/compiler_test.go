@@ -20,6 +20,7 @@ import (
 	"github.com/globalsign/mgo/bson"
 	. "github.com/smartystreets/goconvey/convey"
 	"go.aporeto.io/elemental"
+	"go.aporeto.io/manipulate"
 )
 
 func TestCompilerOption(t *testing.T) {
@@ -47,6 +48,24 @@ func TestCompilerOption(t *testing.T) {
 					t.Fatalf("expected 'config.attrSpecs' to not be nil, but it was")
 				}
 
+				if !reflect.DeepEqual(config.attrSpecs, specs) {
+					t.Errorf("expected 'config.attrSpecs' to deeply equal the specification provided in the option, but it didn't")
+				}
+			},
+		},
+		"CompilerOptionCoerceValuesFromSpec": {
+			verify: func(t *testing.T) {
+				config := &compilerConfig{}
+				specs := map[string]elemental.AttributeSpecification{
+					"field_a": {
+						Type: "time",
+					},
+				}
+				CompilerOptionCoerceValuesFromSpec(specs)(config)
+				if !config.coerceValuesFromSpec {
+					t.Error("expected 'config.coerceValuesFromSpec' to be true, but it wasn't")
+				}
+
 				if !reflect.DeepEqual(config.attrSpecs, specs) {
 					t.Errorf("expected 'config.attrSpecs' to deeply equal the specification provided in the option, but it didn't")
 				}
@@ -147,6 +166,58 @@ func TestCompiler_WithCompilerOption(t *testing.T) {
 			},
 			want: `{"$and":[{"a":{"$eq":"test_value"}},{"$and":[{"$and":[{"b":{"$eq":"test_value"}},{"c":{"$eq":"test_value"}}]},{"$and":[{"d":{"$eq":"test_value"}},{"$or":[{"$and":[{"e":{"$eq":"test_value"}}]},{"$and":[{"f":{"$eq":"test_value"}}]},{"$and":[{"g":{"$nin":["test_value_a","test_value_b","test_value_c"]}}]}]}]}]}]}`,
 		},
+		"CompilerOptionCoerceValuesFromSpec should convert identifiers to object ids": {
+			filter: elemental.NewFilterComposer().
+				WithKey("parentID").Equals("5d83e7eedb40280001887565").
+				WithKey("otherID").In("5d83e7eedb40280001887565", "not-an-object-id").
+				Done(),
+			opts: []CompilerOption{
+				CompilerOptionCoerceValuesFromSpec(map[string]elemental.AttributeSpecification{
+					"parentID": {
+						Identifier: true,
+					},
+					"otherid": {
+						Identifier: true,
+					},
+				}),
+			},
+			want: `{"$and":[{"parentid":{"$eq":{"$oid":"5d83e7eedb40280001887565"}}},{"otherid":{"$in":[{"$oid":"5d83e7eedb40280001887565"},"not-an-object-id"]}}]}`,
+		},
+		"CompilerOptionCoerceValuesFromSpec should convert RFC3339 strings to time": {
+			filter: elemental.NewFilterComposer().
+				WithKey("createTime").GreaterOrEqualThan("2019-01-01T00:00:00Z").
+				WithKey("updateTime").LesserThan("not a date").
+				Done(),
+			opts: []CompilerOption{
+				CompilerOptionCoerceValuesFromSpec(map[string]elemental.AttributeSpecification{
+					"createTime": {
+						Type: "time",
+					},
+					"updateTime": {
+						Type: "time",
+					},
+				}),
+			},
+			want: `{"$and":[{"createtime":{"$gte":{"$date":"2019-01-01T00:00:00Z"}}},{"updatetime":{"$lt":"not a date"}}]}`,
+		},
+		"CompilerOptionCoerceValuesFromSpec should work with CompilerOptionTranslateKeysFromSpec": {
+			filter: elemental.NewFilterComposer().
+				WithKey("createtime").Equals("2019-01-01T00:00:00Z").
+				Done(),
+			opts: func() []CompilerOption {
+				specs := map[string]elemental.AttributeSpecification{
+					"createtime": {
+						Type:          "time",
+						BSONFieldName: "ct",
+					},
+				}
+				return []CompilerOption{
+					CompilerOptionTranslateKeysFromSpec(specs),
+					CompilerOptionCoerceValuesFromSpec(specs),
+				}
+			}(),
+			want: `{"$and":[{"ct":{"$eq":{"$date":"2019-01-01T00:00:00Z"}}}]}`,
+		},
 	}
 
 	for summary, tc := range tests {
@@ -317,6 +388,78 @@ func TestUtils_compiler(t *testing.T) {
 		})
 	})
 
+	Convey("Given I have a manipulate.Filter comparing to empty and null values", t, func() {
+
+		f := elemental.NewFilterComposer().
+			WithKey("a").Equals("").
+			WithKey("b").Equals(nil).
+			WithKey("c").NotEquals(nil).
+			WithKey("d").NotExists().
+			WithKey("e").In("x", nil).
+			Done()
+
+		Convey("When I compile the filter", func() {
+
+			b, _ := bson.MarshalJSON(toMap(CompileFilter(f)))
+
+			Convey("Then the bson should be correct", func() {
+				So(strings.Replace(string(b), "\n", "", 1), ShouldEqual, `{"$and":[{"a":{"$eq":""}},{"b":{"$type":10}},{"c":{"$not":{"$type":10}}},{"d":{"$exists":false}},{"e":{"$in":["x",null]}}]}`)
+			})
+		})
+	})
+
+	Convey("Given I have a manipulate.Filter with a single value Contains", t, func() {
+
+		f := elemental.NewFilterComposer().
+			WithKey("tags").Contains("prod").
+			Done()
+
+		Convey("When I compile the filter", func() {
+
+			b, _ := bson.MarshalJSON(toMap(CompileFilter(f)))
+
+			Convey("Then the bson should be correct", func() {
+				So(strings.Replace(string(b), "\n", "", 1), ShouldEqual, `{"$and":[{"tags":{"$eq":"prod"}}]}`)
+			})
+		})
+	})
+
+	Convey("Given I have a manipulate.Filter with an element match", t, func() {
+
+		f := manipulate.NewElementMatchFilter(
+			"tags",
+			elemental.NewFilterComposer().
+				WithKey("name").Equals("x").
+				WithKey("value").Equals("y").
+				Done(),
+		)
+
+		Convey("When I compile the filter", func() {
+
+			b, _ := bson.MarshalJSON(toMap(CompileFilter(f)))
+
+			Convey("Then the bson should be correct", func() {
+				So(strings.Replace(string(b), "\n", "", 1), ShouldEqual, `{"$and":[{"tags":{"$elemMatch":{"$and":[{"name":{"$eq":"x"}},{"value":{"$eq":"y"}}]}}}]}`)
+			})
+		})
+	})
+
+	Convey("Given I have a manipulate.Filter with a single value In", t, func() {
+
+		f := elemental.NewFilterComposer().
+			WithKey("tags").In("prod").
+			Done()
+
+		Convey("When I compile the filter", func() {
+
+			b, _ := bson.MarshalJSON(toMap(CompileFilter(f)))
+
+			Convey("Then the bson should be correct", func() {
+				So(strings.Replace(string(b), "\n", "", 1), ShouldEqual, `{"$and":[{"tags":{"$in":["prod"]}}]}`)
+			})
+		})
+	})
+
 	Convey("Given I have filter that contains Match", t, func() {
 
 		f := elemental.NewFilterComposer().
@@ -517,3 +660,62 @@ func TestUtils_compiler(t *testing.T) {
 		})
 	})
 }
+
+func BenchmarkCompileFilter(b *testing.B) {
+
+	specs := map[string]elemental.AttributeSpecification{
+		"namespace": {BSONFieldName: "n"},
+		"name":      {BSONFieldName: "na"},
+	}
+
+	filters := map[string]*elemental.Filter{
+		"simple": elemental.NewFilterComposer().
+			WithKey("namespace").Equals("/a/b").
+			WithKey("name").Equals("toto").
+			Done(),
+		"mixed": elemental.NewFilterComposer().
+			WithKey("ID").Equals("5d85727b919e0c397a58e940").
+			WithKey("Tags").Contains("a=a", "b=b").
+			WithKey("size").GreaterOrEqualThan(42).
+			WithKey("list").NotIn("a", "b", "c").
+			WithKey("deleted").Equals(false).
+			Done(),
+		"composed": elemental.NewFilterComposer().
+			WithKey("namespace").Equals("coucou").
+			And(
+				elemental.NewFilterComposer().
+					WithKey("name").Equals("toto").
+					WithKey("surname").Equals("titi").
+					Done(),
+				elemental.NewFilterComposer().
+					WithKey("color").Equals("blue").
+					Or(
+						elemental.NewFilterComposer().
+							WithKey("size").Equals("big").
+							Done(),
+						elemental.NewFilterComposer().
+							WithKey("size").Equals("medium").
+							Done(),
+					).
+					Done(),
+			).
+			Done(),
+	}
+
+	for name, f := range filters {
+
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				CompileFilter(f)
+			}
+		})
+
+		b.Run(name+" with specs", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				CompileFilter(f, CompilerOptionTranslateKeysFromSpec(specs), CompilerOptionCoerceValuesFromSpec(specs))
+			}
+		})
+	}
+}
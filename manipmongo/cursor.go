@@ -0,0 +1,94 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manipmongo
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/globalsign/mgo/bson"
+	"go.aporeto.io/elemental"
+	"go.aporeto.io/manipulate"
+	"go.aporeto.io/manipulate/internal/objectid"
+)
+
+// cursor is the content of a cursor token.
+type cursor struct {
+	Value interface{} `bson:"v"`
+	ID    string      `bson:"i,omitempty"`
+}
+
+// EncodeCursor returns an opaque token pointing after the given object,
+// that is the last one of a page retrieved with the given order. The order
+// must have at most one field and, unless it is the identifier, the object
+// must be an elemental.AttributeSpecifiable. The token can be given to
+// clients as the next page marker, and converted back with DecodeCursor.
+func EncodeCursor(object elemental.Identifiable, order ...string) (string, error) {
+
+	if len(order) > 1 {
+		return "", fmt.Errorf("cannot use multiple ordering fields in a cursor")
+	}
+
+	var field string
+	if len(order) == 1 {
+		field = strings.TrimPrefix(order[0], "-")
+	}
+
+	var c cursor
+
+	switch strings.ToLower(field) {
+
+	case "", "id", "_id":
+
+		if oid, ok := objectid.Parse(object.Identifier()); ok {
+			c.Value = oid
+		} else {
+			c.Value = object.Identifier()
+		}
+
+	default:
+
+		a, ok := object.(elemental.AttributeSpecifiable)
+		if !ok {
+			return "", fmt.Errorf("cannot read attribute '%s' of an object that is not an elemental.AttributeSpecifiable", field)
+		}
+
+		c.Value = a.ValueForAttribute(field)
+		c.ID = object.Identifier()
+	}
+
+	data, err := bson.Marshal(c)
+	if err != nil {
+		return "", fmt.Errorf("unable to encode cursor: %s", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// DecodeCursor decodes the given token returned by EncodeCursor and returns
+// the ContextOptionAfterValue to use to retrieve the next page. The context
+// must use the same order as the one given to EncodeCursor.
+func DecodeCursor(token string) (manipulate.ContextOption, error) {
+
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, manipulate.NewErrCannotBuildQuery(fmt.Sprintf("invalid cursor: %s", err))
+	}
+
+	var c cursor
+	if err := bson.Unmarshal(data, &c); err != nil {
+		return nil, manipulate.NewErrCannotBuildQuery(fmt.Sprintf("invalid cursor: %s", err))
+	}
+
+	return ContextOptionAfterValue(c.Value, c.ID), nil
+}
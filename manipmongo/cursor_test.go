@@ -0,0 +1,129 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manipmongo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/globalsign/mgo/bson"
+	. "github.com/smartystreets/goconvey/convey"
+	"go.aporeto.io/elemental"
+	"go.aporeto.io/manipulate"
+)
+
+type cursorIdentifiable struct {
+	ID string
+}
+
+func (o *cursorIdentifiable) Identity() elemental.Identity {
+	return elemental.MakeIdentity("thing", "things")
+}
+func (o *cursorIdentifiable) Identifier() string      { return o.ID }
+func (o *cursorIdentifiable) SetIdentifier(id string) { o.ID = id }
+func (o *cursorIdentifiable) Version() int            { return 1 }
+
+type cursorObject struct {
+	cursorIdentifiable
+	Name string
+}
+
+func (o *cursorObject) SpecificationForAttribute(name string) elemental.AttributeSpecification {
+	return o.AttributeSpecifications()[name]
+}
+
+func (o *cursorObject) AttributeSpecifications() map[string]elemental.AttributeSpecification {
+	return map[string]elemental.AttributeSpecification{
+		"name": {Name: "name", ConvertedName: "Name"},
+	}
+}
+
+func (o *cursorObject) ValueForAttribute(name string) interface{} {
+	if name == "name" {
+		return o.Name
+	}
+	return nil
+}
+
+func TestCursor(t *testing.T) {
+
+	Convey("Given I have an object", t, func() {
+
+		oid := bson.NewObjectId()
+		obj := &cursorObject{cursorIdentifiable: cursorIdentifiable{ID: oid.Hex()}, Name: "hello"}
+
+		Convey("When I encode a cursor without order", func() {
+
+			token, err := EncodeCursor(obj)
+			So(err, ShouldBeNil)
+
+			Convey("Then I should be able to decode it", func() {
+				opt, err := DecodeCursor(token)
+				So(err, ShouldBeNil)
+
+				mctx := manipulate.NewContext(context.Background(), opt)
+				So(mctx.(opaquer).Opaque()[opaqueKeyAfterValue], ShouldResemble, afterValue{value: oid})
+			})
+		})
+
+		Convey("When I encode a cursor with a descending order on a field", func() {
+
+			token, err := EncodeCursor(obj, "-name")
+			So(err, ShouldBeNil)
+
+			Convey("Then I should be able to decode it", func() {
+				opt, err := DecodeCursor(token)
+				So(err, ShouldBeNil)
+
+				mctx := manipulate.NewContext(context.Background(), opt)
+				So(mctx.(opaquer).Opaque()[opaqueKeyAfterValue], ShouldResemble, afterValue{value: "hello", id: oid.Hex()})
+			})
+		})
+
+		Convey("When I encode a cursor with multiple ordering fields", func() {
+
+			_, err := EncodeCursor(obj, "name", "id")
+
+			Convey("Then err should be correct", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldEqual, "cannot use multiple ordering fields in a cursor")
+			})
+		})
+	})
+
+	Convey("Given I have an object that is not attribute specifiable", t, func() {
+
+		obj := &cursorIdentifiable{}
+
+		Convey("When I encode a cursor with an order on a field", func() {
+
+			_, err := EncodeCursor(obj, "name")
+
+			Convey("Then err should be correct", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldEqual, "cannot read attribute 'name' of an object that is not an elemental.AttributeSpecifiable")
+			})
+		})
+	})
+
+	Convey("Given I have an invalid token", t, func() {
+
+		Convey("When I decode it", func() {
+
+			_, err := DecodeCursor("not a token")
+
+			Convey("Then err should be correct", func() {
+				So(manipulate.IsCannotBuildQueryError(err), ShouldBeTrue)
+			})
+		})
+	})
+}
@@ -0,0 +1,39 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manipmongo
+
+import (
+	"go.aporeto.io/elemental"
+)
+
+// An AttributeEncoder is the interface of an object that can
+// transform the attributes of the objects of a given identity
+// before they are written in database, and restore them once they are read.
+// This allows to customize the stored representation of some attributes
+// (for instance to compress them) without changing the model.
+type AttributeEncoder interface {
+
+	// EncodeAttributes will be called before the given
+	// elemental.Identifiable is written in database.
+	EncodeAttributes(elemental.Identifiable) error
+
+	// DecodeAttributes will be called after the given
+	// elemental.Identifiable has been read from database, and
+	// after it has been written to restore its original values.
+	DecodeAttributes(elemental.Identifiable) error
+}
+
+// attributeEncoder returns the AttributeEncoder registered for the
+// given identity, or nil if there is none.
+func (m *mongoManipulator) attributeEncoder(identity elemental.Identity) AttributeEncoder {
+	return m.attributeEncoders[identity]
+}
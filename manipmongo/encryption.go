@@ -0,0 +1,253 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manipmongo
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"go.aporeto.io/elemental"
+	"go.aporeto.io/manipulate"
+)
+
+// A FilterValidator is the interface of an AttributeEncoder
+// that needs to validate the filters used to query the
+// objects of the identity it is registered for.
+type FilterValidator interface {
+
+	// ValidateFilter returns an error if the given
+	// filter cannot be used to query the encoded objects.
+	ValidateFilter(*elemental.Filter) error
+}
+
+// A FieldEncrypter is an AttributeEncoder that encrypts
+// the given string attributes at rest using AES-GCM.
+//
+// By default the encryption is randomized, and filtering on
+// the encrypted attributes is rejected. When the encryption is
+// deterministic, the same value always gives the same cipher
+// text, so equality queries are possible using values encrypted
+// with EncryptValue, at the cost of revealing which objects share
+// the same value.
+type FieldEncrypter struct {
+	aead          cipher.AEAD
+	nonceKey      []byte
+	deterministic bool
+	fields        map[string]struct{}
+}
+
+// NewFieldEncrypter returns a new FieldEncrypter that encrypts the given
+// attributes with the given AES key. The key must be 16, 24 or 32 bytes long.
+func NewFieldEncrypter(key []byte, deterministic bool, attributes ...string) (*FieldEncrypter, error) {
+
+	if len(attributes) == 0 {
+		return nil, fmt.Errorf("at least one attribute must be given")
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create cipher: %s", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create gcm: %s", err)
+	}
+
+	nonceKey := sha256.Sum256(append([]byte("manipmongo.fieldencrypter.nonce"), key...))
+
+	fields := make(map[string]struct{}, len(attributes))
+	for _, a := range attributes {
+		fields[strings.ToLower(a)] = struct{}{}
+	}
+
+	return &FieldEncrypter{
+		aead:          aead,
+		nonceKey:      nonceKey[:],
+		deterministic: deterministic,
+		fields:        fields,
+	}, nil
+}
+
+// EncodeAttributes is part of the AttributeEncoder interface.
+func (e *FieldEncrypter) EncodeAttributes(object elemental.Identifiable) error {
+	return e.transform(object, e.EncryptValue)
+}
+
+// DecodeAttributes is part of the AttributeEncoder interface.
+func (e *FieldEncrypter) DecodeAttributes(object elemental.Identifiable) error {
+	return e.transform(object, e.DecryptValue)
+}
+
+// ValidateFilter is part of the FilterValidator interface.
+// It rejects the filters using an encrypted attribute, unless
+// the encryption is deterministic and the comparator is an
+// equality or an inclusion.
+func (e *FieldEncrypter) ValidateFilter(f *elemental.Filter) error {
+
+	if f == nil {
+		return nil
+	}
+
+	for i, operator := range f.Operators() {
+
+		switch operator {
+
+		case elemental.AndOperator:
+
+			k := strings.ToLower(f.Keys()[i])
+			if _, ok := e.fields[k]; !ok {
+				continue
+			}
+
+			if !e.deterministic {
+				return fmt.Errorf("cannot filter on encrypted attribute '%s'", k)
+			}
+
+			switch f.Comparators()[i] {
+			case elemental.EqualComparator, elemental.NotEqualComparator, elemental.InComparator, elemental.NotInComparator:
+			default:
+				return fmt.Errorf("only equality filters are supported on encrypted attribute '%s'", k)
+			}
+
+		case elemental.AndFilterOperator:
+
+			for _, sub := range f.AndFilters()[i] {
+				if err := e.ValidateFilter(sub); err != nil {
+					return err
+				}
+			}
+
+		case elemental.OrFilterOperator:
+
+			for _, sub := range f.OrFilters()[i] {
+				if err := e.ValidateFilter(sub); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// EncryptValue encrypts the given value. Empty values are left untouched.
+// When the encryption is deterministic, the result can be used in an
+// equality filter on an encrypted attribute.
+func (e *FieldEncrypter) EncryptValue(value string) (string, error) {
+
+	if value == "" {
+		return "", nil
+	}
+
+	nonce := make([]byte, e.aead.NonceSize())
+
+	if e.deterministic {
+		h := hmac.New(sha256.New, e.nonceKey)
+		_, _ = h.Write([]byte(value))
+		copy(nonce, h.Sum(nil))
+	} else if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", fmt.Errorf("unable to generate nonce: %s", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(e.aead.Seal(nonce, nonce, []byte(value), nil)), nil
+}
+
+// DecryptValue decrypts the given value. Empty values are left untouched.
+func (e *FieldEncrypter) DecryptValue(value string) (string, error) {
+
+	if value == "" {
+		return "", nil
+	}
+
+	data, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return "", fmt.Errorf("unable to decode value: %s", err)
+	}
+
+	if len(data) < e.aead.NonceSize() {
+		return "", fmt.Errorf("invalid encrypted value")
+	}
+
+	out, err := e.aead.Open(nil, data[:e.aead.NonceSize()], data[e.aead.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("unable to decrypt value: %s", err)
+	}
+
+	return string(out), nil
+}
+
+// transform applies the given function to all the encrypted
+// attributes of the given object.
+func (e *FieldEncrypter) transform(object elemental.Identifiable, f func(string) (string, error)) error {
+
+	v := reflect.Indirect(reflect.ValueOf(object))
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("cannot encrypt attributes of %T", object)
+	}
+
+	for i := 0; i < v.NumField(); i++ {
+
+		if _, ok := e.fields[strings.ToLower(v.Type().Field(i).Name)]; !ok {
+			continue
+		}
+
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Ptr {
+			if fv.IsNil() {
+				continue
+			}
+			fv = fv.Elem()
+		}
+
+		if fv.Kind() != reflect.String || !fv.CanSet() {
+			return fmt.Errorf("attribute '%s' of %T is not a string", v.Type().Field(i).Name, object)
+		}
+
+		out, err := f(fv.String())
+		if err != nil {
+			return fmt.Errorf("attribute '%s': %s", v.Type().Field(i).Name, err)
+		}
+
+		fv.SetString(out)
+	}
+
+	return nil
+}
+
+// validateFilter validates the given filter using the AttributeEncoder
+// registered for the given identity, if it implements FilterValidator.
+func (m *mongoManipulator) validateFilter(identity elemental.Identity, f *elemental.Filter) error {
+
+	if f == nil {
+		return nil
+	}
+
+	v, ok := m.attributeEncoder(identity).(FilterValidator)
+	if !ok {
+		return nil
+	}
+
+	if err := v.ValidateFilter(f); err != nil {
+		return manipulate.NewErrCannotBuildQuery(err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,211 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manipmongo
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.aporeto.io/elemental"
+	testmodel "go.aporeto.io/elemental/test/model"
+	"go.aporeto.io/manipulate"
+)
+
+func TestNewFieldEncrypter(t *testing.T) {
+
+	Convey("Calling NewFieldEncrypter with an invalid key should fail", t, func() {
+		_, err := NewFieldEncrypter([]byte("nope"), false, "secret")
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "unable to create cipher: crypto/aes: invalid key size 4")
+	})
+
+	Convey("Calling NewFieldEncrypter without attributes should fail", t, func() {
+		_, err := NewFieldEncrypter([]byte("0123456789ABCDEF"), false)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "at least one attribute must be given")
+	})
+}
+
+func TestFieldEncrypter_Attributes(t *testing.T) {
+
+	Convey("Given I have a randomized field encrypter", t, func() {
+
+		enc, err := NewFieldEncrypter([]byte("0123456789ABCDEF"), false, "Secret", "description")
+		So(err, ShouldBeNil)
+
+		obj := &testmodel.List{Name: "name", Secret: "secret", Description: ""}
+
+		Convey("When I encode the object", func() {
+
+			err := enc.EncodeAttributes(obj)
+
+			Convey("Then err should be nil", func() {
+				So(err, ShouldBeNil)
+			})
+
+			Convey("Then the configured attributes should be encrypted", func() {
+				So(obj.Name, ShouldEqual, "name")
+				So(obj.Secret, ShouldNotEqual, "secret")
+				So(obj.Description, ShouldEqual, "")
+			})
+
+			Convey("Then encrypting the same value again should give a different result", func() {
+				v, err := enc.EncryptValue("secret")
+				So(err, ShouldBeNil)
+				So(v, ShouldNotEqual, obj.Secret)
+			})
+
+			Convey("When I decode the object", func() {
+
+				err := enc.DecodeAttributes(obj)
+
+				Convey("Then err should be nil", func() {
+					So(err, ShouldBeNil)
+				})
+
+				Convey("Then the attributes should be restored", func() {
+					So(obj.Name, ShouldEqual, "name")
+					So(obj.Secret, ShouldEqual, "secret")
+				})
+			})
+		})
+
+		Convey("When I decode an object that is not encrypted", func() {
+
+			err := enc.DecodeAttributes(obj)
+
+			Convey("Then err should not be nil", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldStartWith, "attribute 'Secret': unable to decode value:")
+			})
+		})
+
+		Convey("When I decode an object encrypted with another key", func() {
+
+			other, _ := NewFieldEncrypter([]byte("FEDCBA9876543210"), false, "secret")
+			_ = other.EncodeAttributes(obj)
+			err := enc.DecodeAttributes(obj)
+
+			Convey("Then err should not be nil", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldStartWith, "attribute 'Secret': unable to decrypt value:")
+			})
+		})
+	})
+
+	Convey("Given I have a field encrypter on a non string attribute", t, func() {
+
+		enc, _ := NewFieldEncrypter([]byte("0123456789ABCDEF"), false, "slice")
+
+		Convey("When I encode the object", func() {
+
+			err := enc.EncodeAttributes(&testmodel.List{Slice: []string{"a"}})
+
+			Convey("Then err should not be nil", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldEndWith, "List is not a string")
+			})
+		})
+	})
+
+	Convey("Given I have a deterministic field encrypter", t, func() {
+
+		enc, _ := NewFieldEncrypter([]byte("0123456789ABCDEF"), true, "secret")
+
+		Convey("When I encrypt the same value twice", func() {
+
+			v1, err1 := enc.EncryptValue("secret")
+			v2, err2 := enc.EncryptValue("secret")
+
+			Convey("Then the results should be identical", func() {
+				So(err1, ShouldBeNil)
+				So(err2, ShouldBeNil)
+				So(v1, ShouldEqual, v2)
+			})
+
+			Convey("Then I should be able to decrypt it", func() {
+				v, err := enc.DecryptValue(v1)
+				So(err, ShouldBeNil)
+				So(v, ShouldEqual, "secret")
+			})
+		})
+	})
+}
+
+func TestFieldEncrypter_ValidateFilter(t *testing.T) {
+
+	Convey("Given I have a randomized field encrypter", t, func() {
+
+		enc, _ := NewFieldEncrypter([]byte("0123456789ABCDEF"), false, "secret")
+
+		Convey("Then filters on other attributes should be accepted", func() {
+			So(enc.ValidateFilter(elemental.NewFilterComposer().WithKey("name").Equals("a").Done()), ShouldBeNil)
+			So(enc.ValidateFilter(nil), ShouldBeNil)
+		})
+
+		Convey("Then filters on encrypted attributes should be rejected", func() {
+			err := enc.ValidateFilter(elemental.NewFilterComposer().WithKey("Secret").Equals("a").Done())
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, "cannot filter on encrypted attribute 'secret'")
+		})
+
+		Convey("Then filters on encrypted attributes in sub filters should be rejected", func() {
+			err := enc.ValidateFilter(
+				elemental.NewFilterComposer().Or(
+					elemental.NewFilterComposer().WithKey("name").Equals("a").Done(),
+					elemental.NewFilterComposer().WithKey("secret").Equals("a").Done(),
+				).Done(),
+			)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, "cannot filter on encrypted attribute 'secret'")
+		})
+	})
+
+	Convey("Given I have a deterministic field encrypter", t, func() {
+
+		enc, _ := NewFieldEncrypter([]byte("0123456789ABCDEF"), true, "secret")
+
+		Convey("Then equality filters on encrypted attributes should be accepted", func() {
+			So(enc.ValidateFilter(elemental.NewFilterComposer().WithKey("secret").Equals("a").Done()), ShouldBeNil)
+			So(enc.ValidateFilter(elemental.NewFilterComposer().WithKey("secret").In("a", "b").Done()), ShouldBeNil)
+		})
+
+		Convey("Then other filters on encrypted attributes should be rejected", func() {
+			err := enc.ValidateFilter(elemental.NewFilterComposer().WithKey("secret").Matches("^a").Done())
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, "only equality filters are supported on encrypted attribute 'secret'")
+		})
+	})
+}
+
+func TestMongoManipulator_validateFilter(t *testing.T) {
+
+	Convey("Given I have a mongo manipulator with a field encrypter", t, func() {
+
+		enc, _ := NewFieldEncrypter([]byte("0123456789ABCDEF"), false, "secret")
+		m := &mongoManipulator{
+			attributeEncoders: map[elemental.Identity]AttributeEncoder{
+				testmodel.ListIdentity: enc,
+			},
+		}
+
+		Convey("Then filters on the encrypted attribute should be rejected", func() {
+			err := m.validateFilter(testmodel.ListIdentity, elemental.NewFilterComposer().WithKey("secret").Equals("a").Done())
+			So(manipulate.IsCannotBuildQueryError(err), ShouldBeTrue)
+		})
+
+		Convey("Then filters on other identities should be accepted", func() {
+			err := m.validateFilter(testmodel.TaskIdentity, elemental.NewFilterComposer().WithKey("secret").Equals("a").Done())
+			So(err, ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,140 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manipmongo
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+	"go.aporeto.io/elemental"
+	"go.aporeto.io/manipulate"
+)
+
+// A filterCache is a bounded LRU cache of compiled filters,
+// keyed by the string representation of the elemental.Filter.
+type filterCache struct {
+	size  int
+	items map[string]*list.Element
+	order *list.List
+	lock  sync.Mutex
+}
+
+type filterCacheEntry struct {
+	key    string
+	filter bson.D
+}
+
+func newFilterCache(size int) *filterCache {
+
+	return &filterCache{
+		size:  size,
+		items: make(map[string]*list.Element, size),
+		order: list.New(),
+	}
+}
+
+// compile returns the compiled version of the given filter
+// from the cache, or compiles it and stores it in the cache.
+// Filters containing relative times are never cached as their
+// compiled version depends on the current time.
+func (c *filterCache) compile(f *elemental.Filter) bson.D {
+
+	if hasRelativeTime(f) {
+		return CompileFilter(f)
+	}
+
+	key := f.String()
+
+	if cf, ok := c.get(key); ok {
+		return cf
+	}
+
+	cf := CompileFilter(f)
+	c.set(key, cf)
+
+	return cf
+}
+
+func (c *filterCache) get(key string) (bson.D, bool) {
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	e, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	c.order.MoveToFront(e)
+
+	// We make sure appending to the returned filter
+	// will never modify the cached one.
+	cf := e.Value.(*filterCacheEntry).filter
+
+	return cf[:len(cf):len(cf)], true
+}
+
+func (c *filterCache) set(key string, filter bson.D) {
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if e, ok := c.items[key]; ok {
+		e.Value.(*filterCacheEntry).filter = filter
+		c.order.MoveToFront(e)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&filterCacheEntry{key: key, filter: filter})
+
+	for c.order.Len() > c.size {
+		e := c.order.Back()
+		c.order.Remove(e)
+		delete(c.items, e.Value.(*filterCacheEntry).key)
+	}
+}
+
+func hasRelativeTime(f *elemental.Filter) bool {
+
+	for _, values := range f.Values() {
+		for _, v := range values {
+			switch tv := v.(type) {
+			case time.Duration:
+				return true
+			case manipulate.ElementMatch:
+				if hasRelativeTime(tv.Filter) {
+					return true
+				}
+			}
+		}
+	}
+
+	for _, subs := range f.AndFilters() {
+		for _, sub := range subs {
+			if hasRelativeTime(sub) {
+				return true
+			}
+		}
+	}
+
+	for _, subs := range f.OrFilters() {
+		for _, sub := range subs {
+			if hasRelativeTime(sub) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,115 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manipmongo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+	. "github.com/smartystreets/goconvey/convey"
+	"go.aporeto.io/elemental"
+)
+
+func TestFilterCache(t *testing.T) {
+
+	Convey("Given I have a filter cache of size 2", t, func() {
+
+		c := newFilterCache(2)
+
+		Convey("When I compile a filter", func() {
+
+			f := elemental.NewFilterComposer().WithKey("name").Equals("hello").Done()
+			cf := c.compile(f)
+
+			Convey("Then it should be the same as CompileFilter", func() {
+				So(cf, ShouldResemble, CompileFilter(f))
+			})
+
+			Convey("Then it should have been cached", func() {
+				cached, ok := c.get(f.String())
+				So(ok, ShouldBeTrue)
+				So(cached, ShouldResemble, cf)
+			})
+
+			Convey("Then appending to the returned filter should not modify the cache", func() {
+				cached, _ := c.get(f.String())
+				_ = append(cached, bson.DocElem{Name: "_id", Value: "x"})
+				cached2, _ := c.get(f.String())
+				So(len(cached2), ShouldEqual, len(cf))
+				So(cap(cached2), ShouldEqual, len(cf))
+			})
+		})
+
+		Convey("When I compile a filter with a relative time", func() {
+
+			f := elemental.NewFilterComposer().WithKey("date").GreaterThan(-time.Hour).Done()
+			_ = c.compile(f)
+
+			Convey("Then it should not have been cached", func() {
+				So(c.order.Len(), ShouldEqual, 0)
+			})
+		})
+
+		Convey("When I set more entries than the cache size", func() {
+
+			c.set("a", bson.D{{Name: "a"}})
+			c.set("b", bson.D{{Name: "b"}})
+			_, _ = c.get("a")
+			c.set("c", bson.D{{Name: "c"}})
+
+			Convey("Then the least recently used entry should have been evicted", func() {
+				_, okA := c.get("a")
+				_, okB := c.get("b")
+				_, okC := c.get("c")
+				So(okA, ShouldBeTrue)
+				So(okB, ShouldBeFalse)
+				So(okC, ShouldBeTrue)
+				So(len(c.items), ShouldEqual, 2)
+			})
+		})
+
+		Convey("When I set an existing entry", func() {
+
+			c.set("a", bson.D{{Name: "a"}})
+			c.set("a", bson.D{{Name: "a2"}})
+
+			Convey("Then it should have been updated", func() {
+				cf, ok := c.get("a")
+				So(ok, ShouldBeTrue)
+				So(cf, ShouldResemble, bson.D{{Name: "a2"}})
+				So(c.order.Len(), ShouldEqual, 1)
+			})
+		})
+	})
+}
+
+func Test_hasRelativeTime(t *testing.T) {
+
+	Convey("Given I have a filter without relative time", t, func() {
+		f := elemental.NewFilterComposer().WithKey("a").Equals(1).Done()
+		So(hasRelativeTime(f), ShouldBeFalse)
+	})
+
+	Convey("Given I have a filter with a relative time", t, func() {
+		f := elemental.NewFilterComposer().WithKey("a").LesserThan(-time.Hour).Done()
+		So(hasRelativeTime(f), ShouldBeTrue)
+	})
+
+	Convey("Given I have a filter with a relative time in a sub filter", t, func() {
+		f := elemental.NewFilterComposer().Or(
+			elemental.NewFilterComposer().WithKey("a").Equals(1).Done(),
+			elemental.NewFilterComposer().WithKey("b").GreaterOrEqualThan(-time.Hour).Done(),
+		).Done()
+		So(hasRelativeTime(f), ShouldBeTrue)
+	})
+}
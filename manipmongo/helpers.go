@@ -12,15 +12,20 @@
 package manipmongo
 
 import (
+	"context"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
 	"go.aporeto.io/elemental"
 	"go.aporeto.io/manipulate"
 	"go.aporeto.io/manipulate/internal/backoff"
+	"go.aporeto.io/manipulate/internal/objectid"
 )
 
 // DoesDatabaseExist checks if the database used by the given manipulator exists.
@@ -161,6 +166,22 @@ func CreateCollection(manipulator manipulate.Manipulator, identity elemental.Ide
 	return collection.Create(info)
 }
 
+// CreateCappedCollection creates a capped collection for the given identity
+// with the given maximum size in bytes and optional maximum number of documents.
+// Capped collections keep the insertion order and can be streamed using TailFunc.
+func CreateCappedCollection(manipulator manipulate.Manipulator, identity elemental.Identity, maxBytes int, maxDocs int) error {
+
+	if _, ok := manipulator.(*mongoManipulator); !ok {
+		panic("you can only pass a mongo manipulator to CreateCappedCollection")
+	}
+
+	return CreateCollection(manipulator, identity, &mgo.CollectionInfo{
+		Capped:   true,
+		MaxBytes: maxBytes,
+		MaxDocs:  maxDocs,
+	})
+}
+
 // GetDatabase returns a ready to use mgo.Database. Use at your own risks.
 // You are responsible for closing the session by calling the returner close function
 func GetDatabase(manipulator manipulate.Manipulator) (*mgo.Database, func(), error) {
@@ -172,7 +193,184 @@ func GetDatabase(manipulator manipulate.Manipulator) (*mgo.Database, func(), err
 
 	session := m.rootSession.Copy()
 
-	return session.DB(m.dbName), func() { session.Close() }, nil
+	var tracker *sessionTracker
+	if m.detectLeaks {
+		tracker = newSessionTracker(logLeakedSession)
+	}
+
+	return session.DB(m.dbName), func() {
+		session.Close()
+		tracker.release()
+	}, nil
+}
+
+// PoolStats contains statistics about the sessions of a mongo manipulator.
+type PoolStats struct {
+	// ActiveSessions is the number of sessions currently in use
+	// by operations of the manipulator.
+	ActiveSessions int
+
+	// PoolLimit is the configured connection pool limit.
+	PoolLimit int
+
+	// Available is the number of sessions that can still be used
+	// before reaching the pool limit.
+	Available int
+}
+
+// GetPoolStats returns the current PoolStats of the given manipulator.
+// Only the sessions used by the operations of the manipulator are
+// counted, not the ones returned by GetDatabase or GetMongoSession.
+func GetPoolStats(manipulator manipulate.Manipulator) PoolStats {
+
+	m, ok := manipulator.(*mongoManipulator)
+	if !ok {
+		panic("you can only pass a mongo manipulator to GetPoolStats")
+	}
+
+	active := int(atomic.LoadInt64(&m.activeSessions))
+
+	available := m.poolLimit - active
+	if available < 0 {
+		available = 0
+	}
+
+	return PoolStats{
+		ActiveSessions: active,
+		PoolLimit:      m.poolLimit,
+		Available:      available,
+	}
+}
+
+// GetMongoSession returns a copy of the root mgo.Session of the given manipulator.
+// This is an escape hatch for operations that are not covered by manipulate,
+// like administrative commands. Use at your own risks.
+// You are responsible for closing the returned session.
+func GetMongoSession(manipulator manipulate.Manipulator) (*mgo.Session, error) {
+
+	m, ok := manipulator.(*mongoManipulator)
+	if !ok {
+		panic("you can only pass a mongo manipulator to GetMongoSession")
+	}
+
+	session := m.rootSession.Copy()
+
+	if m.detectLeaks {
+		trackSession(session, logLeakedSession)
+	}
+
+	return session, nil
+}
+
+// RetrieveRaw retrieves the raw documents of the given identity
+// matching the filter set in the given manipulate.Context, without
+// decoding them into elemental.Identifiables. This gives access to
+// the fields stored in the database that are not part of the model,
+// which can be useful for diagnostics or migration tools.
+// Sharding filter, forced read filter, ordering, pagination and fields
+// selection are applied as in RetrieveMany. Lazy pagination using 'after'
+// is not supported.
+func RetrieveRaw(manipulator manipulate.Manipulator, mctx manipulate.Context, identity elemental.Identity) ([]bson.M, error) {
+
+	m, ok := manipulator.(*mongoManipulator)
+	if !ok {
+		panic("you can only pass a mongo manipulator to RetrieveRaw")
+	}
+
+	if mctx == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), defaultGlobalContextTimeout)
+		defer cancel()
+		mctx = manipulate.NewContext(ctx)
+	}
+
+	if err := validatePagination(mctx); err != nil {
+		return nil, err
+	}
+
+	if mctx.After() != "" {
+		return nil, manipulate.NewErrCannotBuildQuery("retrieveraw: 'after' is not supported")
+	}
+
+	c, close := m.makeSession(mctx, identity)
+	defer close()
+
+	if err := m.checkCollection(c); err != nil {
+		return nil, err
+	}
+
+	filter := bson.D{}
+	if f := mctx.Filter(); f != nil {
+		if err := m.validateFilter(identity, f); err != nil {
+			return nil, err
+		}
+		filter = m.compileFilter(identity, f)
+	}
+
+	var ands []bson.D
+
+	if m.sharder != nil {
+		sq, err := m.sharder.FilterMany(m, mctx, identity)
+		if err != nil {
+			return nil, manipulate.NewErrCannotBuildQuery(fmt.Sprintf("cannot compute sharding filter: %s", err))
+		}
+		if sq != nil {
+			ands = append(ands, sq)
+		}
+	}
+
+	if m.forcedReadFilter != nil {
+		ands = append(ands, m.forcedReadFilter)
+	}
+
+	if len(ands) > 0 {
+		filter = bson.D{{Name: "$and", Value: append(ands, filter)}}
+	}
+
+	q := c.Find(filter)
+
+	limit := mctx.Limit()
+	if limit <= 0 {
+		limit = mctx.PageSize()
+	}
+	if limit > 0 {
+		q = q.Limit(limit)
+	}
+
+	if p := mctx.Page(); p > 0 {
+		q = q.Skip((p - 1) * mctx.PageSize())
+	}
+
+	if o := mctx.Order(); len(o) > 0 {
+		q = q.Sort(applyOrdering(m.mapFields(identity, o))...)
+	}
+
+	if sels := makeFieldsSelector(m.mapFields(identity, mctx.Fields())); sels != nil {
+		q = q.Select(sels)
+	}
+
+	q = q.SetMaxTime(maxExecutionTime(mctx))
+
+	out, err := RunQuery(
+		mctx,
+		func() (interface{}, error) {
+			var docs []bson.M
+			if err := q.All(&docs); err != nil {
+				return nil, err
+			}
+			return docs, nil
+		},
+		RetryInfo{
+			Operation:        elemental.OperationRetrieveMany,
+			Identity:         identity,
+			defaultRetryFunc: m.defaultRetryFunc,
+			stats:            m.stats,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return out.([]bson.M), nil
 }
 
 // SetConsistencyMode sets the mongo consistency mode of the mongo session.
@@ -229,6 +427,8 @@ func RunQuery(mctx manipulate.Context, operationFunc func() (interface{}, error)
 		default:
 		}
 
+		baseRetryInfo.stats.Retry()
+
 		deadline, _ := mctx.Context().Deadline()
 		time.Sleep(backoff.NextWithCurve(try, deadline, defaultBackoffCurve))
 		try++
@@ -257,3 +457,966 @@ func GetAttributeEncrypter(manipulator manipulate.Manipulator) elemental.Attribu
 
 	return m.attributeEncrypter
 }
+
+// SetAttributeEncoder sets the AttributeEncoder to use for the given identity
+// in the given mongo manipulator. Passing a nil encoder removes the current one.
+// It must not be called while the manipulator is in use.
+func SetAttributeEncoder(manipulator manipulate.Manipulator, identity elemental.Identity, enc AttributeEncoder) {
+
+	m, ok := manipulator.(*mongoManipulator)
+	if !ok {
+		panic("you can only pass a mongo manipulator to SetAttributeEncoder")
+	}
+
+	encoders := make(map[elemental.Identity]AttributeEncoder, len(m.attributeEncoders)+1)
+	for k, v := range m.attributeEncoders {
+		encoders[k] = v
+	}
+
+	if enc == nil {
+		delete(encoders, identity)
+	} else {
+		encoders[identity] = enc
+	}
+
+	m.attributeEncoders = encoders
+}
+
+// UpdateMany sets the given fields on all the documents of the given identity
+// matching the filter of the given manipulate.Context, using a single $set operation.
+// The sharding filter and the forced read filter are applied as in DeleteMany.
+// The number of updated documents is set as the count of the given context.
+func UpdateMany(manipulator manipulate.Manipulator, mctx manipulate.Context, identity elemental.Identity, update map[string]interface{}) error {
+
+	m, ok := manipulator.(*mongoManipulator)
+	if !ok {
+		panic("you can only pass a mongo manipulator to UpdateMany")
+	}
+
+	if len(update) == 0 {
+		return manipulate.NewErrCannotBuildQuery("updatemany: no field to update")
+	}
+
+	if mctx == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), defaultGlobalContextTimeout)
+		defer cancel()
+		mctx = manipulate.NewContext(ctx)
+	}
+
+	if err := m.validateFilter(identity, mctx.Filter()); err != nil {
+		return err
+	}
+
+	c, close := m.makeSession(mctx, identity)
+	defer close()
+
+	if err := m.checkCollection(c); err != nil {
+		return err
+	}
+
+	filter := m.compileFilter(identity, mctx.Filter())
+	if m.sharder != nil {
+		sq, err := m.sharder.FilterMany(m, mctx, identity)
+		if err != nil {
+			return manipulate.NewErrCannotBuildQuery(fmt.Sprintf("cannot compute sharding filter: %s", err))
+		}
+		if sq != nil {
+			filter = bson.D{{Name: "$and", Value: []bson.D{sq, filter}}}
+		}
+	}
+
+	if m.forcedReadFilter != nil {
+		filter = bson.D{{Name: "$and", Value: []bson.D{m.forcedReadFilter, filter}}}
+	}
+
+	set := bson.M{}
+	for k, v := range update {
+		set[k] = v
+	}
+
+	if actor := mctx.Actor(); actor != "" {
+		set[actorFieldName] = actor
+	}
+
+	out, err := RunQuery(
+		mctx,
+		func() (interface{}, error) { return c.UpdateAll(filter, bson.M{"$set": set}) },
+		RetryInfo{
+			Operation:        elemental.OperationUpdate,
+			Identity:         identity,
+			defaultRetryFunc: m.defaultRetryFunc,
+			stats:            m.stats,
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	if info, ok := out.(*mgo.ChangeInfo); ok && info != nil {
+		mctx.SetCount(info.Updated)
+	}
+
+	return nil
+}
+
+// UpdateManyRaw applies the given update document to all the documents of the
+// given identity matching the filter of the given manipulate.Context. Unlike
+// UpdateMany, the update document is passed as is, so several update operators
+// can be mixed, like {"$set": {...}, "$inc": {...}, "$push": {...}}. It is checked
+// to only contain update operators applied to at least one field before being run.
+// The sharding filter and the forced read filter are applied as in DeleteMany.
+// The number of updated documents is set as the count of the given context.
+func UpdateManyRaw(manipulator manipulate.Manipulator, mctx manipulate.Context, identity elemental.Identity, update map[string]interface{}) error {
+
+	m, ok := manipulator.(*mongoManipulator)
+	if !ok {
+		panic("you can only pass a mongo manipulator to UpdateManyRaw")
+	}
+
+	if mctx == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), defaultGlobalContextTimeout)
+		defer cancel()
+		mctx = manipulate.NewContext(ctx)
+	}
+
+	doc, err := makeRawUpdate(update, mctx.Actor())
+	if err != nil {
+		return manipulate.NewErrCannotBuildQuery(fmt.Sprintf("updatemanyraw: %s", err))
+	}
+
+	if err := m.validateFilter(identity, mctx.Filter()); err != nil {
+		return err
+	}
+
+	c, close := m.makeSession(mctx, identity)
+	defer close()
+
+	if err := m.checkCollection(c); err != nil {
+		return err
+	}
+
+	filter := m.compileFilter(identity, mctx.Filter())
+	if m.sharder != nil {
+		sq, err := m.sharder.FilterMany(m, mctx, identity)
+		if err != nil {
+			return manipulate.NewErrCannotBuildQuery(fmt.Sprintf("cannot compute sharding filter: %s", err))
+		}
+		if sq != nil {
+			filter = bson.D{{Name: "$and", Value: []bson.D{sq, filter}}}
+		}
+	}
+
+	if m.forcedReadFilter != nil {
+		filter = bson.D{{Name: "$and", Value: []bson.D{m.forcedReadFilter, filter}}}
+	}
+
+	out, err := RunQuery(
+		mctx,
+		func() (interface{}, error) { return c.UpdateAll(filter, doc) },
+		RetryInfo{
+			Operation:        elemental.OperationUpdate,
+			Identity:         identity,
+			defaultRetryFunc: m.defaultRetryFunc,
+			stats:            m.stats,
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	if info, ok := out.(*mgo.ChangeInfo); ok && info != nil {
+		mctx.SetCount(info.Updated)
+	}
+
+	return nil
+}
+
+// UpdateAndRetrieve atomically sets the given fields on the first document
+// of the identity of the given object matching the filter of the given
+// manipulate.Context, and populates the given object with the updated document.
+// If the object has an identifier, only the document with that identifier can match.
+// The order of the context decides which document is updated when several match.
+// If no document matches, an ErrObjectNotFound is returned, so callers can, for
+// instance, loop to claim the next pending job of a queue.
+func UpdateAndRetrieve(manipulator manipulate.Manipulator, mctx manipulate.Context, object elemental.Identifiable, update map[string]interface{}) error {
+
+	m, ok := manipulator.(*mongoManipulator)
+	if !ok {
+		panic("you can only pass a mongo manipulator to UpdateAndRetrieve")
+	}
+
+	if len(update) == 0 {
+		return manipulate.NewErrCannotBuildQuery("updateandretrieve: no field to update")
+	}
+
+	if mctx == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), defaultGlobalContextTimeout)
+		defer cancel()
+		mctx = manipulate.NewContext(ctx)
+	}
+
+	if err := m.validateFilter(object.Identity(), mctx.Filter()); err != nil {
+		return err
+	}
+
+	c, close := m.makeSession(mctx, object.Identity())
+	defer close()
+
+	filter := m.compileFilter(object.Identity(), mctx.Filter())
+
+	if id := object.Identifier(); id != "" {
+		if oid, ok := objectid.Parse(id); ok {
+			filter = append(filter, bson.DocElem{Name: "_id", Value: oid})
+		} else {
+			filter = append(filter, bson.DocElem{Name: "_id", Value: id})
+		}
+	}
+
+	if m.sharder != nil {
+		sq, err := m.sharder.FilterMany(m, mctx, object.Identity())
+		if err != nil {
+			return manipulate.NewErrCannotBuildQuery(fmt.Sprintf("cannot compute sharding filter: %s", err))
+		}
+		if sq != nil {
+			filter = bson.D{{Name: "$and", Value: []bson.D{sq, filter}}}
+		}
+	}
+
+	if m.forcedReadFilter != nil {
+		filter = bson.D{{Name: "$and", Value: []bson.D{m.forcedReadFilter, filter}}}
+	}
+
+	set := bson.M{}
+	for k, v := range update {
+		set[k] = v
+	}
+
+	if actor := mctx.Actor(); actor != "" {
+		set[actorFieldName] = actor
+	}
+
+	q := c.Find(filter)
+	if order := applyOrdering(m.mapFields(object.Identity(), mctx.Order())); len(order) > 0 {
+		q = q.Sort(order...)
+	}
+
+	if _, err := RunQuery(
+		mctx,
+		func() (interface{}, error) {
+			return q.Apply(mgo.Change{Update: bson.M{"$set": set}, ReturnNew: true}, object)
+		},
+		RetryInfo{
+			Operation:        elemental.OperationUpdate,
+			Identity:         object.Identity(),
+			defaultRetryFunc: m.defaultRetryFunc,
+			stats:            m.stats,
+		},
+	); err != nil {
+		return err
+	}
+
+	if encoder := m.attributeEncoder(object.Identity()); encoder != nil {
+		if err := encoder.DecodeAttributes(object); err != nil {
+			return manipulate.NewErrCannotBuildQuery(fmt.Sprintf("updateandretrieve: unable to decode attributes: %s", err))
+		}
+	}
+
+	if m.attributeEncrypter != nil {
+		if a, ok := object.(elemental.AttributeEncryptable); ok {
+			if err := a.DecryptAttributes(m.attributeEncrypter); err != nil {
+				return manipulate.NewErrCannotBuildQuery(fmt.Sprintf("updateandretrieve: unable to decrypt attributes: %s", err))
+			}
+		}
+	}
+
+	return nil
+}
+
+// RetrieveMultiple retrieves all the given objects using a single query
+// instead of calling Retrieve for each of them. All objects must be of the same
+// identity, and each retrieved document is decoded into the object with the
+// matching identifier, regardless of the order returned by mongo.
+// The filter of the given manipulate.Context, the sharding filter, the forced
+// read filter, the fields selection and the post read hook are applied as in Retrieve.
+// If some objects cannot be found, the others are still populated
+// and a manipulate.ErrObjectNotFound is returned, or a manipulate.ErrPartialRetrieve
+// listing the missing objects when using ContextOptionPartialRetrieve.
+func RetrieveMultiple(manipulator manipulate.Manipulator, mctx manipulate.Context, objects ...elemental.Identifiable) error {
+
+	m, ok := manipulator.(*mongoManipulator)
+	if !ok {
+		panic("you can only pass a mongo manipulator to RetrieveMultiple")
+	}
+
+	if len(objects) == 0 {
+		return nil
+	}
+
+	if mctx == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), defaultGlobalContextTimeout)
+		defer cancel()
+		mctx = manipulate.NewContext(ctx)
+	}
+
+	identity := objects[0].Identity()
+
+	ids := make([]interface{}, 0, len(objects))
+	targets := make(map[string][]elemental.Identifiable, len(objects))
+
+	for _, o := range objects {
+
+		if !o.Identity().IsEqual(identity) {
+			return manipulate.NewErrCannotBuildQuery(fmt.Sprintf("retrievemultiple: all objects must be of identity %s", identity.Name))
+		}
+
+		id := o.Identifier()
+		if _, ok := targets[id]; !ok {
+			if oid, ok := objectid.Parse(id); ok {
+				ids = append(ids, oid)
+			} else {
+				ids = append(ids, id)
+			}
+		}
+
+		targets[id] = append(targets[id], o)
+	}
+
+	c, close := m.makeSession(mctx, identity)
+	defer close()
+
+	if err := m.checkCollection(c); err != nil {
+		return err
+	}
+
+	filter := bson.D{}
+	if f := mctx.Filter(); f != nil {
+		if err := m.validateFilter(identity, f); err != nil {
+			return err
+		}
+		filter = m.compileFilter(identity, f)
+	}
+
+	filter = append(filter, bson.DocElem{Name: "_id", Value: bson.M{"$in": ids}})
+
+	if m.sharder != nil {
+		sq, err := m.sharder.FilterMany(m, mctx, identity)
+		if err != nil {
+			return manipulate.NewErrCannotBuildQuery(fmt.Sprintf("cannot compute sharding filter: %s", err))
+		}
+		if sq != nil {
+			filter = bson.D{{Name: "$and", Value: []bson.D{sq, filter}}}
+		}
+	}
+
+	if m.forcedReadFilter != nil {
+		filter = bson.D{{Name: "$and", Value: []bson.D{m.forcedReadFilter, filter}}}
+	}
+
+	q := c.Find(filter)
+	if sels := makeFieldsSelector(m.mapFields(identity, mctx.Fields())); sels != nil {
+		q = q.Select(sels)
+	}
+
+	q = q.SetMaxTime(maxExecutionTime(mctx))
+
+	out, err := RunQuery(
+		mctx,
+		func() (interface{}, error) {
+			var docs []bson.Raw
+			if err := q.All(&docs); err != nil {
+				return nil, err
+			}
+			return docs, nil
+		},
+		RetryInfo{
+			Operation:        elemental.OperationRetrieve,
+			Identity:         identity,
+			defaultRetryFunc: m.defaultRetryFunc,
+			stats:            m.stats,
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, raw := range out.([]bson.Raw) {
+
+		doc := struct {
+			ID interface{} `bson:"_id"`
+		}{}
+		if err := raw.Unmarshal(&doc); err != nil {
+			return manipulate.NewErrCannotUnmarshal(fmt.Sprintf("retrievemultiple: unable to decode document: %s", err))
+		}
+
+		id := identifierString(doc.ID)
+		found := true
+
+		for _, o := range targets[id] {
+
+			if err := raw.Unmarshal(o); err != nil {
+				return manipulate.NewErrCannotUnmarshal(fmt.Sprintf("retrievemultiple: unable to decode object: %s", err))
+			}
+
+			// backport all default values that are empty.
+			if a, ok := o.(elemental.AttributeSpecifiable); ok && !mctx.PreserveZeroValues() {
+				resetDefaultForZeroValues(a, mctx.Fields())
+			}
+
+			if encoder := m.attributeEncoder(o.Identity()); encoder != nil {
+				if err := encoder.DecodeAttributes(o); err != nil {
+					return manipulate.NewErrCannotBuildQuery(fmt.Sprintf("retrievemultiple: unable to decode attributes: %s", err))
+				}
+			}
+
+			if m.attributeEncrypter != nil {
+				if a, ok := o.(elemental.AttributeEncryptable); ok {
+					if err := a.DecryptAttributes(m.attributeEncrypter); err != nil {
+						return manipulate.NewErrCannotBuildQuery(fmt.Sprintf("retrievemultiple: unable to decrypt attributes: %s", err))
+					}
+				}
+			}
+
+			if hook := mctx.PostReadHook(); hook != nil {
+				ok, err := hook(o)
+				if err != nil {
+					return err
+				}
+				if !ok {
+					found = false
+				}
+			}
+		}
+
+		if found {
+			delete(targets, id)
+		}
+	}
+
+	if len(targets) > 0 {
+
+		if _, ok := mctx.(opaquer).Opaque()[opaqueKeyPartialRetrieve]; ok {
+			errs := make(map[string]error, len(targets))
+			for id := range targets {
+				errs[identity.Name+"/"+id] = manipulate.NewErrObjectNotFound("cannot find the object for the given ID")
+			}
+			return manipulate.NewErrPartialRetrieve(errs)
+		}
+
+		missing := make([]string, 0, len(targets))
+		for id := range targets {
+			missing = append(missing, id)
+		}
+		sort.Strings(missing)
+		return manipulate.NewErrObjectNotFound(fmt.Sprintf("cannot find the objects for the given IDs: %s", strings.Join(missing, ", ")))
+	}
+
+	return nil
+}
+
+// FindOrCreate creates the given object. If the creation fails because
+// of a unique constraint violation (for instance when retrying a creation
+// that actually succeeded), the existing object matching the given filter
+// is retrieved into the given object instead. If the given filter is nil,
+// the existing object is retrieved using the identifier of the given object.
+// This requires the identifier to be kept by the creation, using
+// OptionPreserveIdentifiers, or generated deterministically, using
+// OptionIdentifierGenerator. Otherwise, a filter must be given. The sharding
+// filter and the forced read filter are applied to the lookup as in
+// RetrieveMany, and the retrieved object is processed as in Retrieve.
+//
+// It returns true if the object has been created, false if it already existed.
+func FindOrCreate(manipulator manipulate.Manipulator, mctx manipulate.Context, object elemental.Identifiable, filter *elemental.Filter) (bool, error) {
+
+	m, ok := manipulator.(*mongoManipulator)
+	if !ok {
+		panic("you can only pass a mongo manipulator to FindOrCreate")
+	}
+
+	if mctx == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), defaultGlobalContextTimeout)
+		defer cancel()
+		mctx = manipulate.NewContext(ctx)
+	}
+
+	if filter == nil && !m.preserveIdentifiers && m.idGenerator == nil {
+		return false, manipulate.NewErrCannotBuildQuery("findorcreate: filter must be set unless identifiers are preserved or generated")
+	}
+
+	err := m.Create(mctx, object)
+	if err == nil {
+		return true, nil
+	}
+
+	if !manipulate.IsConstraintViolationError(err) {
+		return false, err
+	}
+
+	if filter == nil {
+		return false, m.Retrieve(mctx, object)
+	}
+
+	if err := m.validateFilter(object.Identity(), filter); err != nil {
+		return false, err
+	}
+
+	c, close := m.makeSession(mctx, object.Identity())
+	defer close()
+
+	if err := m.checkCollection(c); err != nil {
+		return false, err
+	}
+
+	query := m.compileFilter(object.Identity(), filter)
+
+	var ands []bson.D
+
+	if m.sharder != nil {
+		sq, err := m.sharder.FilterMany(m, mctx, object.Identity())
+		if err != nil {
+			return false, manipulate.NewErrCannotBuildQuery(fmt.Sprintf("cannot compute sharding filter: %s", err))
+		}
+		if sq != nil {
+			ands = append(ands, sq)
+		}
+	}
+
+	if m.forcedReadFilter != nil {
+		ands = append(ands, m.forcedReadFilter)
+	}
+
+	if len(ands) > 0 {
+		query = bson.D{{Name: "$and", Value: append(ands, query)}}
+	}
+
+	q := c.Find(query).SetMaxTime(maxExecutionTime(mctx))
+
+	if _, err := RunQuery(
+		mctx,
+		func() (interface{}, error) { return nil, q.One(object) },
+		RetryInfo{
+			Operation:        elemental.OperationRetrieve,
+			Identity:         object.Identity(),
+			defaultRetryFunc: m.defaultRetryFunc,
+			stats:            m.stats,
+		},
+	); err != nil {
+		return false, err
+	}
+
+	return false, m.processRetrieved(mctx, object, "findorcreate")
+}
+
+// UpsertMany creates or updates the given objects using a single unordered
+// bulk operation. Each object is matched on the value of the given key field,
+// which must be backed by a unique index to guarantee idempotency: matching
+// documents are updated and keep their identifier, the others are inserted
+// with a new identifier. All objects must have the same identity.
+// As the bulk operation does not report the identifiers of the inserted
+// documents, the identifiers of the given objects are left untouched.
+// When timestamps are enabled, the creation time is only written to the
+// inserted documents, and the update time is written to all of them.
+// The number of documents reported as matched by the bulk operation is set
+// as the count of the given context. The bulk result of mgo does not report
+// the number of inserted documents separately.
+func UpsertMany(manipulator manipulate.Manipulator, mctx manipulate.Context, keyField string, objects ...elemental.Identifiable) error {
+
+	m, ok := manipulator.(*mongoManipulator)
+	if !ok {
+		panic("you can only pass a mongo manipulator to UpsertMany")
+	}
+
+	if keyField == "" {
+		return manipulate.NewErrCannotBuildQuery("upsertmany: key field must be set")
+	}
+
+	if len(objects) == 0 {
+		return nil
+	}
+
+	identity := objects[0].Identity()
+	for _, object := range objects[1:] {
+		if !object.Identity().IsEqual(identity) {
+			return manipulate.NewErrCannotBuildQuery(fmt.Sprintf("upsertmany: all objects must be of identity %s", identity.Name))
+		}
+	}
+
+	if mctx == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), defaultGlobalContextTimeout)
+		defer cancel()
+		mctx = manipulate.NewContext(ctx)
+	}
+
+	key := massageKey(m.mapFields(identity, []string{keyField})[0])
+
+	var encoded []elemental.Identifiable
+	var encryptables []elemental.AttributeEncryptable
+	encoder := m.attributeEncoder(identity)
+
+	// Whatever happens, we restore the objects as we found them.
+	defer func() {
+		for _, object := range encoded {
+			_ = encoder.DecodeAttributes(object)
+		}
+		for _, a := range encryptables {
+			_ = a.DecryptAttributes(m.attributeEncrypter)
+		}
+	}()
+
+	c, close := m.makeSession(mctx, identity)
+	defer close()
+
+	bulk := c.Bulk()
+	bulk.Unordered()
+
+	now := time.Now()
+
+	for _, object := range objects {
+
+		var createdAtFields []string
+		if m.timestamps {
+			if t, ok := object.(manipulate.Timestampable); ok {
+				t.SetUpdatedAt(now)
+				fields, err := creationTimeFields(t)
+				if err != nil {
+					return manipulate.NewErrCannotBuildQuery(fmt.Sprintf("upsertmany: unable to find creation time fields: %s", err))
+				}
+				createdAtFields = fields
+			}
+		}
+
+		if f := mctx.Finalizer(); f != nil {
+			if err := f(object); err != nil {
+				return err
+			}
+		}
+
+		if m.validate {
+			if err := validateObject(object); err != nil {
+				return err
+			}
+		}
+
+		if m.sharder != nil {
+			if err := m.sharder.Shard(m, mctx, object); err != nil {
+				return manipulate.NewErrCannotBuildQuery(fmt.Sprintf("unable to execute sharder.Shard: %s", err))
+			}
+		}
+
+		if m.attributeEncrypter != nil {
+			if a, ok := object.(elemental.AttributeEncryptable); ok {
+				if err := a.EncryptAttributes(m.attributeEncrypter); err != nil {
+					return manipulate.NewErrCannotBuildQuery(fmt.Sprintf("upsertmany: unable to encrypt attributes: %s", err))
+				}
+				encryptables = append(encryptables, a)
+			}
+		}
+
+		if encoder != nil {
+			if err := encoder.EncodeAttributes(object); err != nil {
+				return manipulate.NewErrCannotBuildQuery(fmt.Sprintf("upsertmany: unable to encode attributes: %s", err))
+			}
+			encoded = append(encoded, object)
+		}
+
+		data, err := bson.Marshal(object)
+		if err != nil {
+			return manipulate.NewErrCannotBuildQuery(fmt.Sprintf("upsertmany: unable to marshal object: %s", err))
+		}
+
+		doc := bson.M{}
+		if err := bson.Unmarshal(data, &doc); err != nil {
+			return manipulate.NewErrCannotBuildQuery(fmt.Sprintf("upsertmany: unable to unmarshal object: %s", err))
+		}
+
+		value, ok := doc[key]
+		if !ok {
+			return manipulate.NewErrCannotBuildQuery(fmt.Sprintf("upsertmany: object has no field '%s'", key))
+		}
+
+		delete(doc, "_id")
+
+		// The creation time must only be written when the document is inserted.
+		setOnInsert := bson.M{"_id": m.newIdentifier(object)}
+		for _, f := range createdAtFields {
+			setOnInsert[f] = now
+			delete(doc, f)
+		}
+
+		if actor := mctx.Actor(); actor != "" {
+			doc[actorFieldName] = actor
+		}
+
+		selector := bson.D{{Name: key, Value: value}}
+		if m.sharder != nil {
+			sq, err := m.sharder.FilterOne(m, mctx, object)
+			if err != nil {
+				return manipulate.NewErrCannotBuildQuery(fmt.Sprintf("cannot compute sharding filter: %s", err))
+			}
+			if sq != nil {
+				selector = bson.D{{Name: "$and", Value: []bson.D{sq, selector}}}
+			}
+		}
+
+		bulk.Upsert(selector, bson.M{
+			"$set":         doc,
+			"$setOnInsert": setOnInsert,
+		})
+	}
+
+	out, err := RunQuery(
+		mctx,
+		func() (interface{}, error) { return bulk.Run() },
+		RetryInfo{
+			Operation:        elemental.OperationCreate,
+			Identity:         identity,
+			defaultRetryFunc: m.defaultRetryFunc,
+			stats:            m.stats,
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	if res, ok := out.(*mgo.BulkResult); ok && res != nil {
+		mctx.SetCount(res.Matched)
+	}
+
+	if m.sharder != nil {
+		for _, object := range objects {
+			if err := m.sharder.OnShardedWrite(m, mctx, elemental.OperationCreate, object); err != nil {
+				return manipulate.NewErrCannotBuildQuery(fmt.Sprintf("unable to execute sharder.OnShardedWrite on upsertmany: %s", err))
+			}
+		}
+	}
+
+	return nil
+}
+
+// RetrieveFirst retrieves into the given object the first document of its identity
+// matching the filter of the given manipulate.Context. The order of the context,
+// or the default order of the identity, decides which document comes first.
+// Sharding filter, forced read filter and fields selection are applied as in RetrieveMany.
+// If no document matches, an ErrObjectNotFound is returned.
+func RetrieveFirst(manipulator manipulate.Manipulator, mctx manipulate.Context, object elemental.Identifiable) error {
+
+	m, ok := manipulator.(*mongoManipulator)
+	if !ok {
+		panic("you can only pass a mongo manipulator to RetrieveFirst")
+	}
+
+	if mctx == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), defaultGlobalContextTimeout)
+		defer cancel()
+		mctx = manipulate.NewContext(ctx)
+	}
+
+	identity := object.Identity()
+
+	c, close := m.makeSession(mctx, identity)
+	defer close()
+
+	if err := m.checkCollection(c); err != nil {
+		return err
+	}
+
+	filter := bson.D{}
+	if f := mctx.Filter(); f != nil {
+		if err := m.validateFilter(identity, f); err != nil {
+			return err
+		}
+		filter = m.compileFilter(identity, f)
+	}
+
+	var ands []bson.D
+
+	if m.sharder != nil {
+		sq, err := m.sharder.FilterMany(m, mctx, identity)
+		if err != nil {
+			return manipulate.NewErrCannotBuildQuery(fmt.Sprintf("cannot compute sharding filter: %s", err))
+		}
+		if sq != nil {
+			ands = append(ands, sq)
+		}
+	}
+
+	if m.forcedReadFilter != nil {
+		ands = append(ands, m.forcedReadFilter)
+	}
+
+	if len(ands) > 0 {
+		filter = bson.D{{Name: "$and", Value: append(ands, filter)}}
+	}
+
+	q := c.Find(filter).Limit(1)
+
+	if o := mctx.Order(); len(o) > 0 {
+		q = q.Sort(applyOrdering(m.mapFields(identity, o))...)
+	} else if o, ok := m.defaultOrders[identity]; ok {
+		q = q.Sort(applyOrdering(m.mapFields(identity, o))...)
+	}
+
+	if sels := makeFieldsSelector(m.mapFields(identity, mctx.Fields())); sels != nil {
+		q = q.Select(sels)
+	}
+
+	q = q.SetMaxTime(maxExecutionTime(mctx))
+
+	if _, err := RunQuery(
+		mctx,
+		func() (interface{}, error) { return nil, q.One(object) },
+		RetryInfo{
+			Operation:        elemental.OperationRetrieve,
+			Identity:         identity,
+			defaultRetryFunc: m.defaultRetryFunc,
+			stats:            m.stats,
+		},
+	); err != nil {
+		if manipulate.IsObjectNotFoundError(err) {
+			return manipulate.NewErrObjectNotFound("cannot find any object matching the filter")
+		}
+		return err
+	}
+
+	if a, ok := object.(elemental.AttributeSpecifiable); ok && !mctx.PreserveZeroValues() {
+		resetDefaultForZeroValues(a, mctx.Fields())
+	}
+
+	if encoder := m.attributeEncoder(identity); encoder != nil {
+		if err := encoder.DecodeAttributes(object); err != nil {
+			return manipulate.NewErrCannotBuildQuery(fmt.Sprintf("retrievefirst: unable to decode attributes: %s", err))
+		}
+	}
+
+	if m.attributeEncrypter != nil {
+		if a, ok := object.(elemental.AttributeEncryptable); ok {
+			if err := a.DecryptAttributes(m.attributeEncrypter); err != nil {
+				return manipulate.NewErrCannotBuildQuery(fmt.Sprintf("retrievefirst: unable to decrypt attributes: %s", err))
+			}
+		}
+	}
+
+	return nil
+}
+
+// AppendToArray atomically appends the given values to the array stored in
+// the given field of the document of the given object, using $push. If the
+// context has ContextOptionAppendUnique, only the values that are not already
+// in the array are appended, using $addToSet. As the rest of the document is
+// not rewritten, concurrent appends are never lost. The values are stored as
+// is, without attribute encoding nor encryption, and the given object is not
+// updated. If the document does not exist, an ErrObjectNotFound is returned.
+func AppendToArray(manipulator manipulate.Manipulator, mctx manipulate.Context, object elemental.Identifiable, field string, values ...interface{}) error {
+
+	m, ok := manipulator.(*mongoManipulator)
+	if !ok {
+		panic("you can only pass a mongo manipulator to AppendToArray")
+	}
+
+	if mctx == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), defaultGlobalContextTimeout)
+		defer cancel()
+		mctx = manipulate.NewContext(ctx)
+	}
+
+	operator := "$push"
+	if _, ok := mctx.(opaquer).Opaque()[opaqueKeyAppendUnique]; ok {
+		operator = "$addToSet"
+	}
+
+	return m.updateArray(mctx, object, "appendtoarray", field, values, func(key string) bson.M {
+		return bson.M{operator: bson.M{key: bson.M{"$each": values}}}
+	})
+}
+
+// RemoveFromArray atomically removes all the occurrences of the given values
+// from the array stored in the given field of the document of the given object,
+// using $pull. As the rest of the document is not rewritten, concurrent changes
+// are never lost. The values are compared as is, without attribute encoding
+// nor encryption, and the given object is not updated. If the document does
+// not exist, an ErrObjectNotFound is returned.
+func RemoveFromArray(manipulator manipulate.Manipulator, mctx manipulate.Context, object elemental.Identifiable, field string, values ...interface{}) error {
+
+	m, ok := manipulator.(*mongoManipulator)
+	if !ok {
+		panic("you can only pass a mongo manipulator to RemoveFromArray")
+	}
+
+	if mctx == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), defaultGlobalContextTimeout)
+		defer cancel()
+		mctx = manipulate.NewContext(ctx)
+	}
+
+	return m.updateArray(mctx, object, "removefromarray", field, values, func(key string) bson.M {
+		return bson.M{"$pull": bson.M{key: bson.M{"$in": values}}}
+	})
+}
+
+// updateArray runs the update returned by the given function for the stored
+// name of the given field on the document of the given object.
+func (m *mongoManipulator) updateArray(
+	mctx manipulate.Context,
+	object elemental.Identifiable,
+	operation string,
+	field string,
+	values []interface{},
+	makeUpdate func(key string) bson.M,
+) error {
+
+	if field == "" {
+		return manipulate.NewErrCannotBuildQuery(fmt.Sprintf("%s: field must be set", operation))
+	}
+
+	if len(values) == 0 {
+		return manipulate.NewErrCannotBuildQuery(fmt.Sprintf("%s: no value given", operation))
+	}
+
+	identity := object.Identity()
+
+	c, close := m.makeSession(mctx, identity)
+	defer close()
+
+	if err := m.checkCollection(c); err != nil {
+		return err
+	}
+
+	var filter bson.D
+	if oid, ok := objectid.Parse(object.Identifier()); ok {
+		filter = append(filter, bson.DocElem{Name: "_id", Value: oid})
+	} else {
+		filter = append(filter, bson.DocElem{Name: "_id", Value: object.Identifier()})
+	}
+
+	if m.sharder != nil {
+		sq, err := m.sharder.FilterOne(m, mctx, object)
+		if err != nil {
+			return manipulate.NewErrCannotBuildQuery(fmt.Sprintf("cannot compute sharding filter: %s", err))
+		}
+		if sq != nil {
+			filter = bson.D{{Name: "$and", Value: []bson.D{sq, filter}}}
+		}
+	}
+
+	if m.forcedReadFilter != nil {
+		filter = bson.D{{Name: "$and", Value: []bson.D{m.forcedReadFilter, filter}}}
+	}
+
+	update := makeUpdate(massageKey(m.mapFields(identity, []string{field})[0]))
+
+	if actor := mctx.Actor(); actor != "" {
+		update["$set"] = bson.M{actorFieldName: actor}
+	}
+
+	_, err := RunQuery(
+		mctx,
+		func() (interface{}, error) { return nil, c.Update(filter, update) },
+		RetryInfo{
+			Operation:        elemental.OperationUpdate,
+			Identity:         identity,
+			defaultRetryFunc: m.defaultRetryFunc,
+			stats:            m.stats,
+		},
+	)
+
+	return err
+}
@@ -22,6 +22,7 @@ import (
 	"github.com/globalsign/mgo/bson"
 	. "github.com/smartystreets/goconvey/convey"
 	"go.aporeto.io/elemental"
+	testmodel "go.aporeto.io/elemental/test/model"
 	"go.aporeto.io/manipulate"
 	"go.aporeto.io/manipulate/maniptest"
 )
@@ -160,6 +161,185 @@ func TestGetDatabase(t *testing.T) {
 	})
 }
 
+func TestGetPoolStats(t *testing.T) {
+
+	Convey("Given I a test manipulator", t, func() {
+
+		m := maniptest.NewTestManipulator()
+
+		Convey("When I call GetPoolStats", func() {
+			Convey("Then it should panic", func() {
+				So(func() { GetPoolStats(m) }, ShouldPanicWith, "you can only pass a mongo manipulator to GetPoolStats")
+			})
+		})
+	})
+
+	Convey("Given I a mongo manipulator with active sessions", t, func() {
+
+		m := &mongoManipulator{poolLimit: 10, activeSessions: 3}
+
+		Convey("When I call GetPoolStats", func() {
+
+			stats := GetPoolStats(m)
+
+			Convey("Then the stats should be correct", func() {
+				So(stats, ShouldResemble, PoolStats{ActiveSessions: 3, PoolLimit: 10, Available: 7})
+			})
+		})
+	})
+}
+
+func TestGetMongoSession(t *testing.T) {
+
+	Convey("Given I a test manipulator", t, func() {
+
+		m := maniptest.NewTestManipulator()
+
+		Convey("When I call GetMongoSession", func() {
+			Convey("Then it should panic", func() {
+				So(func() { _, _ = GetMongoSession(m) }, ShouldPanicWith, "you can only pass a mongo manipulator to GetMongoSession")
+			})
+		})
+	})
+}
+
+func TestRetrieveRaw(t *testing.T) {
+
+	Convey("Given I a test manipulator", t, func() {
+
+		m := maniptest.NewTestManipulator()
+
+		Convey("When I call RetrieveRaw", func() {
+			Convey("Then it should panic", func() {
+				So(func() { _, _ = RetrieveRaw(m, nil, elemental.MakeIdentity("a", "a")) }, ShouldPanicWith, "you can only pass a mongo manipulator to RetrieveRaw")
+			})
+		})
+	})
+	Convey("Given I have a mongo manipulator", t, func() {
+
+		m := &mongoManipulator{}
+
+		Convey("When I call RetrieveRaw with an invalid pagination", func() {
+
+			_, err := RetrieveRaw(m, manipulate.NewContext(context.Background(), manipulate.ContextOptionPage(2, 0)), testmodel.ListIdentity)
+
+			Convey("Then err should be correct", func() {
+				So(err, ShouldHaveSameTypeAs, manipulate.ErrCannotBuildQuery{})
+				So(err.Error(), ShouldContainSubstring, "invalid pagination: page requires a page size")
+			})
+		})
+
+		Convey("When I call RetrieveRaw with 'after'", func() {
+
+			_, err := RetrieveRaw(m, manipulate.NewContext(context.Background(), manipulate.ContextOptionAfter("abc", 10)), testmodel.ListIdentity)
+
+			Convey("Then err should be correct", func() {
+				So(err, ShouldHaveSameTypeAs, manipulate.ErrCannotBuildQuery{})
+				So(err.Error(), ShouldContainSubstring, "retrieveraw: 'after' is not supported")
+			})
+		})
+	})
+}
+
+func TestCreateCappedCollection(t *testing.T) {
+
+	Convey("Given I a test manipulator", t, func() {
+
+		m := maniptest.NewTestManipulator()
+
+		Convey("When I call CreateCappedCollection", func() {
+			Convey("Then it should panic", func() {
+				So(func() { _ = CreateCappedCollection(m, elemental.MakeIdentity("a", "a"), 1024, 0) }, ShouldPanicWith, "you can only pass a mongo manipulator to CreateCappedCollection")
+			})
+		})
+	})
+}
+
+func TestTailFunc(t *testing.T) {
+
+	Convey("Given I a test manipulator", t, func() {
+
+		m := maniptest.NewTestManipulator()
+
+		Convey("When I call TailFunc", func() {
+			Convey("Then it should panic", func() {
+				So(func() { _ = TailFunc(m, nil, nil, elemental.MakeIdentity("a", "a"), nil) }, ShouldPanicWith, "you can only pass a mongo manipulator to TailFunc")
+			})
+		})
+	})
+}
+
+func TestUpdateMany(t *testing.T) {
+
+	Convey("Given I a test manipulator", t, func() {
+
+		m := maniptest.NewTestManipulator()
+
+		Convey("When I call UpdateMany", func() {
+			Convey("Then it should panic", func() {
+				So(func() { _ = UpdateMany(m, nil, elemental.MakeIdentity("a", "a"), map[string]interface{}{"a": 1}) }, ShouldPanicWith, "you can only pass a mongo manipulator to UpdateMany")
+			})
+		})
+	})
+}
+
+func TestUpdateAndRetrieve(t *testing.T) {
+
+	Convey("Given I a test manipulator", t, func() {
+
+		m := maniptest.NewTestManipulator()
+
+		Convey("When I call UpdateAndRetrieve", func() {
+			Convey("Then it should panic", func() {
+				So(func() { _ = UpdateAndRetrieve(m, nil, nil, nil) }, ShouldPanicWith, "you can only pass a mongo manipulator to UpdateAndRetrieve")
+			})
+		})
+	})
+}
+
+func TestRetrieveMultiple(t *testing.T) {
+
+	Convey("Given I a test manipulator", t, func() {
+
+		m := maniptest.NewTestManipulator()
+
+		Convey("When I call RetrieveMultiple", func() {
+			Convey("Then it should panic", func() {
+				So(func() { _ = RetrieveMultiple(m, nil) }, ShouldPanicWith, "you can only pass a mongo manipulator to RetrieveMultiple")
+			})
+		})
+	})
+}
+
+func TestFindOrCreate(t *testing.T) {
+
+	Convey("Given I a test manipulator", t, func() {
+
+		m := maniptest.NewTestManipulator()
+
+		Convey("When I call FindOrCreate", func() {
+			Convey("Then it should panic", func() {
+				So(func() { _, _ = FindOrCreate(m, nil, nil, nil) }, ShouldPanicWith, "you can only pass a mongo manipulator to FindOrCreate")
+			})
+		})
+	})
+	Convey("Given I have a mongo manipulator that generates the identifiers", t, func() {
+
+		m := &mongoManipulator{}
+
+		Convey("When I call FindOrCreate without filter", func() {
+
+			created, err := FindOrCreate(m, nil, testmodel.NewList(), nil)
+
+			Convey("Then err should be correct", func() {
+				So(created, ShouldBeFalse)
+				So(err, ShouldHaveSameTypeAs, manipulate.ErrCannotBuildQuery{})
+				So(err.Error(), ShouldContainSubstring, "findorcreate: filter must be set unless identifiers are preserved or generated")
+			})
+		})
+	})
+}
+
 func TestSetConsistencyMode(t *testing.T) {
 
 	Convey("Given I a test manipulator", t, func() {
@@ -508,6 +688,50 @@ func TestRunQuery(t *testing.T) {
 	})
 }
 
+type fakeAttributeEncoder struct{}
+
+func (*fakeAttributeEncoder) EncodeAttributes(elemental.Identifiable) error { return nil }
+func (*fakeAttributeEncoder) DecodeAttributes(elemental.Identifiable) error { return nil }
+
+func TestSetAttributeEncoder(t *testing.T) {
+
+	Convey("Given I a test manipulator", t, func() {
+
+		m := maniptest.NewTestManipulator()
+
+		Convey("When I call SetAttributeEncoder", func() {
+			Convey("Then it should panic", func() {
+				So(func() { SetAttributeEncoder(m, elemental.MakeIdentity("test", "tests"), nil) }, ShouldPanicWith, "you can only pass a mongo manipulator to SetAttributeEncoder")
+			})
+		})
+	})
+
+	Convey("Given I a mongo manipulator", t, func() {
+
+		m := &mongoManipulator{}
+		enc := &fakeAttributeEncoder{}
+		identity := elemental.MakeIdentity("test", "tests")
+
+		Convey("When I call SetAttributeEncoder", func() {
+
+			SetAttributeEncoder(m, identity, enc)
+
+			Convey("Then the encoder should be set", func() {
+				So(m.attributeEncoder(identity), ShouldEqual, enc)
+			})
+
+			Convey("When I call SetAttributeEncoder with a nil encoder", func() {
+
+				SetAttributeEncoder(m, identity, nil)
+
+				Convey("Then the encoder should be removed", func() {
+					So(m.attributeEncoder(identity), ShouldBeNil)
+				})
+			})
+		})
+	})
+}
+
 func TestSetAttributeEncrypter(t *testing.T) {
 
 	Convey("Given I a test manipulator", t, func() {
@@ -535,3 +759,164 @@ func TestGetAttributeEncrypter(t *testing.T) {
 		})
 	})
 }
+
+func TestUpsertMany(t *testing.T) {
+
+	Convey("Given I a test manipulator", t, func() {
+
+		m := maniptest.NewTestManipulator()
+
+		Convey("When I call UpsertMany", func() {
+			Convey("Then it should panic", func() {
+				So(func() { _ = UpsertMany(m, nil, "name") }, ShouldPanicWith, "you can only pass a mongo manipulator to UpsertMany")
+			})
+		})
+	})
+
+	Convey("Given I have a mongo manipulator", t, func() {
+
+		m := &mongoManipulator{}
+
+		Convey("When I call UpsertMany without key field", func() {
+
+			err := UpsertMany(m, nil, "")
+
+			Convey("Then err should be correct", func() {
+				So(err, ShouldNotBeNil)
+				So(manipulate.IsCannotBuildQueryError(err), ShouldBeTrue)
+				So(err.Error(), ShouldEqual, "Unable to build query: upsertmany: key field must be set")
+			})
+		})
+
+		Convey("When I call UpsertMany without objects", func() {
+
+			err := UpsertMany(m, nil, "name")
+
+			Convey("Then err should be nil", func() {
+				So(err, ShouldBeNil)
+			})
+		})
+
+		Convey("When I call UpsertMany with objects of different identities", func() {
+
+			err := UpsertMany(m, nil, "name", testmodel.NewList(), testmodel.NewTask())
+
+			Convey("Then err should be correct", func() {
+				So(err, ShouldNotBeNil)
+				So(manipulate.IsCannotBuildQueryError(err), ShouldBeTrue)
+				So(err.Error(), ShouldEqual, "Unable to build query: upsertmany: all objects must be of identity list")
+			})
+		})
+	})
+}
+
+func TestRetrieveFirst(t *testing.T) {
+
+	Convey("Given I a test manipulator", t, func() {
+
+		m := maniptest.NewTestManipulator()
+
+		Convey("When I call RetrieveFirst", func() {
+			Convey("Then it should panic", func() {
+				So(func() { _ = RetrieveFirst(m, nil, testmodel.NewList()) }, ShouldPanicWith, "you can only pass a mongo manipulator to RetrieveFirst")
+			})
+		})
+	})
+}
+
+func TestAppendToArray(t *testing.T) {
+
+	Convey("Given I a test manipulator", t, func() {
+
+		m := maniptest.NewTestManipulator()
+
+		Convey("When I call AppendToArray", func() {
+			Convey("Then it should panic", func() {
+				So(func() { _ = AppendToArray(m, nil, testmodel.NewList(), "slice", "a") }, ShouldPanicWith, "you can only pass a mongo manipulator to AppendToArray")
+			})
+		})
+	})
+
+	Convey("Given I have a mongo manipulator", t, func() {
+
+		m := &mongoManipulator{}
+
+		Convey("When I call AppendToArray without field", func() {
+
+			err := AppendToArray(m, nil, testmodel.NewList(), "", "a")
+
+			Convey("Then err should be correct", func() {
+				So(manipulate.IsCannotBuildQueryError(err), ShouldBeTrue)
+				So(err.Error(), ShouldEqual, "Unable to build query: appendtoarray: field must be set")
+			})
+		})
+
+		Convey("When I call AppendToArray without values", func() {
+
+			err := AppendToArray(m, nil, testmodel.NewList(), "slice")
+
+			Convey("Then err should be correct", func() {
+				So(manipulate.IsCannotBuildQueryError(err), ShouldBeTrue)
+				So(err.Error(), ShouldEqual, "Unable to build query: appendtoarray: no value given")
+			})
+		})
+	})
+}
+
+func TestRemoveFromArray(t *testing.T) {
+
+	Convey("Given I a test manipulator", t, func() {
+
+		m := maniptest.NewTestManipulator()
+
+		Convey("When I call RemoveFromArray", func() {
+			Convey("Then it should panic", func() {
+				So(func() { _ = RemoveFromArray(m, nil, testmodel.NewList(), "slice", "a") }, ShouldPanicWith, "you can only pass a mongo manipulator to RemoveFromArray")
+			})
+		})
+	})
+
+	Convey("Given I have a mongo manipulator", t, func() {
+
+		m := &mongoManipulator{}
+
+		Convey("When I call RemoveFromArray without values", func() {
+
+			err := RemoveFromArray(m, nil, testmodel.NewList(), "slice")
+
+			Convey("Then err should be correct", func() {
+				So(manipulate.IsCannotBuildQueryError(err), ShouldBeTrue)
+				So(err.Error(), ShouldEqual, "Unable to build query: removefromarray: no value given")
+			})
+		})
+	})
+}
+
+func TestUpdateManyRaw(t *testing.T) {
+
+	Convey("Given I a test manipulator", t, func() {
+
+		m := maniptest.NewTestManipulator()
+
+		Convey("When I call UpdateManyRaw", func() {
+			Convey("Then it should panic", func() {
+				So(func() { _ = UpdateManyRaw(m, nil, elemental.MakeIdentity("a", "a"), map[string]interface{}{"$inc": 1}) }, ShouldPanicWith, "you can only pass a mongo manipulator to UpdateManyRaw")
+			})
+		})
+	})
+
+	Convey("Given I have a mongo manipulator", t, func() {
+
+		m := &mongoManipulator{}
+
+		Convey("When I call UpdateManyRaw with an invalid update document", func() {
+
+			err := UpdateManyRaw(m, nil, elemental.MakeIdentity("a", "a"), map[string]interface{}{"a": 1})
+
+			Convey("Then err should be correct", func() {
+				So(manipulate.IsCannotBuildQueryError(err), ShouldBeTrue)
+				So(err.Error(), ShouldEqual, "Unable to build query: updatemanyraw: invalid update operator 'a'")
+			})
+		})
+	})
+}
@@ -0,0 +1,91 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manipmongo
+
+import (
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"sync/atomic"
+
+	"github.com/globalsign/mgo"
+	"go.uber.org/zap"
+)
+
+// A sessionTracker records where a session has been created
+// and reports it if it is garbage collected before being released.
+type sessionTracker struct {
+	stack    []byte
+	released int32
+	report   func(stack []byte)
+}
+
+func newSessionTracker(report func(stack []byte)) *sessionTracker {
+
+	t := &sessionTracker{
+		stack:  debug.Stack(),
+		report: report,
+	}
+
+	runtime.SetFinalizer(t, (*sessionTracker).finalize)
+
+	return t
+}
+
+// release marks the tracked session as closed.
+// It is safe to call it on a nil sessionTracker.
+func (t *sessionTracker) release() {
+
+	if t == nil {
+		return
+	}
+
+	atomic.StoreInt32(&t.released, 1)
+}
+
+func (t *sessionTracker) finalize() {
+
+	if atomic.LoadInt32(&t.released) == 1 {
+		return
+	}
+
+	t.report(t.stack)
+}
+
+// trackSession reports the given session if it is garbage collected
+// without being closed. It is used for the sessions given to the caller
+// without a release function, which can only be checked once collected.
+func trackSession(session *mgo.Session, report func(stack []byte)) {
+
+	stack := debug.Stack()
+
+	runtime.SetFinalizer(session, func(s *mgo.Session) {
+		if !isSessionClosed(s) {
+			report(stack)
+		}
+	})
+}
+
+// isSessionClosed returns true if the given session has been closed.
+// mgo does not expose it, so this checks the mgoCluster reference that
+// Session.Close clears. If it cannot be found, the session is considered
+// closed so nothing is wrongly reported.
+func isSessionClosed(session *mgo.Session) bool {
+
+	f := reflect.ValueOf(session).Elem().FieldByName("mgoCluster")
+
+	return !f.IsValid() || f.IsNil()
+}
+
+func logLeakedSession(stack []byte) {
+	zap.L().Warn("Mongo session garbage collected without being closed", zap.ByteString("stack", stack))
+}
@@ -0,0 +1,105 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manipmongo
+
+import (
+	"reflect"
+	"testing"
+	"unsafe"
+
+	"github.com/globalsign/mgo"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSessionTracker(t *testing.T) {
+
+	Convey("Given I have a session tracker", t, func() {
+
+		var reported []byte
+		tracker := newSessionTracker(func(stack []byte) { reported = stack })
+
+		Convey("Then the stack should have been recorded", func() {
+			So(string(tracker.stack), ShouldContainSubstring, "TestSessionTracker")
+		})
+
+		Convey("When I finalize it without releasing it", func() {
+
+			tracker.finalize()
+
+			Convey("Then the leak should have been reported", func() {
+				So(reported, ShouldResemble, tracker.stack)
+			})
+		})
+
+		Convey("When I finalize it after releasing it", func() {
+
+			tracker.release()
+			tracker.finalize()
+
+			Convey("Then nothing should have been reported", func() {
+				So(reported, ShouldBeNil)
+			})
+		})
+	})
+
+	Convey("Given I have a nil session tracker", t, func() {
+
+		var tracker *sessionTracker
+
+		Convey("Then releasing it should not panic", func() {
+			So(func() { tracker.release() }, ShouldNotPanic)
+		})
+	})
+}
+
+func TestIsSessionClosed(t *testing.T) {
+
+	Convey("Given I have a session that was never opened", t, func() {
+
+		session := &mgo.Session{}
+
+		Convey("Then it should be closed", func() {
+			So(isSessionClosed(session), ShouldBeTrue)
+		})
+	})
+
+	Convey("Given I have a copied session", t, func() {
+
+		session := newUnconnectedSession()
+		copied := session.Copy()
+
+		Convey("Then it should not be closed", func() {
+			So(isSessionClosed(copied), ShouldBeFalse)
+		})
+
+		Convey("When I close it", func() {
+
+			copied.Close()
+
+			Convey("Then it should be closed", func() {
+				So(isSessionClosed(copied), ShouldBeTrue)
+			})
+		})
+	})
+}
+
+// newUnconnectedSession returns a session holding an empty cluster so it
+// can be copied and closed without a mongo server.
+func newUnconnectedSession() *mgo.Session {
+
+	session := &mgo.Session{}
+
+	f := reflect.ValueOf(session).Elem().FieldByName("mgoCluster")
+	reflect.NewAt(f.Type(), unsafe.Pointer(f.UnsafeAddr())).Elem().Set(reflect.New(f.Type().Elem()))
+
+	return session
+}
@@ -16,30 +16,61 @@ import (
 	"crypto/tls"
 	"fmt"
 	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/globalsign/mgo"
 	"github.com/globalsign/mgo/bson"
+	"github.com/gofrs/uuid"
 	"github.com/opentracing/opentracing-go/log"
 	"go.aporeto.io/elemental"
 	"go.aporeto.io/manipulate"
 	"go.aporeto.io/manipulate/internal/objectid"
+	"go.aporeto.io/manipulate/internal/stats"
 	"go.aporeto.io/manipulate/internal/tracing"
 )
 
 const defaultGlobalContextTimeout = 60 * time.Second
 
+// actorFieldName is the name of the field where the
+// actor of the last write operation is recorded.
+const actorFieldName = "_actor"
+
 // MongoStore represents a MongoDB session.
 type mongoManipulator struct {
-	rootSession        *mgo.Session
-	dbName             string
-	sharder            Sharder
-	defaultRetryFunc   manipulate.RetryFunc
-	forcedReadFilter   bson.D
-	attributeEncrypter elemental.AttributeEncrypter
-	explain            map[elemental.Identity]map[elemental.Operation]struct{}
+	activeSessions      int64 // must be first for 64-bit alignment of atomic operations.
+	stats               *stats.Counters
+	rootSession         *mgo.Session
+	dbName              string
+	sharder             Sharder
+	defaultRetryFunc    manipulate.RetryFunc
+	forcedReadFilter    bson.D
+	attributeEncrypter  elemental.AttributeEncrypter
+	attributeEncoders   map[elemental.Identity]AttributeEncoder
+	defaultOrders       map[elemental.Identity][]string
+	fieldMappings       map[elemental.Identity]map[string]string
+	explain             map[elemental.Identity]map[elemental.Operation]struct{}
+	timestamps          bool
+	filterCache         *filterCache
+	poolLimit           int
+	detectLeaks         bool
+	uuidIdentifiers     bool
+	idGenerator         manipulate.IdentifierGenerator
+	preserveIdentifiers bool
+	validate            bool
+	strictCollections   bool
+	knownCollections    sync.Map
 }
 
+var (
+	_ manipulate.TransactionalManipulator = (*mongoManipulator)(nil)
+	_ manipulate.ClosableManipulator      = (*mongoManipulator)(nil)
+	_ manipulate.StatsProvider            = (*mongoManipulator)(nil)
+	_ manipulate.ExistenceChecker         = (*mongoManipulator)(nil)
+)
+
 // New returns a new manipulator backed by MongoDB.
 func New(url string, db string, options ...Option) (manipulate.TransactionalManipulator, error) {
 
@@ -79,18 +110,38 @@ func New(url string, db string, options ...Option) (manipulate.TransactionalMani
 	session.SetMode(convertReadConsistency(cfg.readConsistency), true)
 	session.SetSafe(convertWriteConsistency(cfg.writeConsistency))
 
+	var fc *filterCache
+	if cfg.filterCacheSize > 0 {
+		fc = newFilterCache(cfg.filterCacheSize)
+	}
+
 	return &mongoManipulator{
-		dbName:             db,
-		rootSession:        session,
-		sharder:            cfg.sharder,
-		defaultRetryFunc:   cfg.defaultRetryFunc,
-		forcedReadFilter:   cfg.forcedReadFilter,
-		attributeEncrypter: cfg.attributeEncrypter,
-		explain:            cfg.explain,
+		dbName:              db,
+		rootSession:         session,
+		sharder:             cfg.sharder,
+		defaultRetryFunc:    cfg.defaultRetryFunc,
+		forcedReadFilter:    cfg.forcedReadFilter,
+		attributeEncrypter:  cfg.attributeEncrypter,
+		attributeEncoders:   cfg.attributeEncoders,
+		defaultOrders:       cfg.defaultOrders,
+		fieldMappings:       cfg.fieldMappings,
+		explain:             cfg.explain,
+		timestamps:          cfg.timestamps,
+		filterCache:         fc,
+		poolLimit:           cfg.poolLimit,
+		detectLeaks:         cfg.detectLeaks,
+		uuidIdentifiers:     cfg.uuidIdentifiers,
+		idGenerator:         cfg.idGenerator,
+		preserveIdentifiers: cfg.preserveIdentifiers,
+		validate:            cfg.validate,
+		strictCollections:   cfg.strictCollections,
+		stats:               stats.New(),
 	}, nil
 }
 
-func (m *mongoManipulator) RetrieveMany(mctx manipulate.Context, dest elemental.Identifiables) error {
+func (m *mongoManipulator) RetrieveMany(mctx manipulate.Context, dest elemental.Identifiables) (err error) {
+
+	defer func() { m.stats.Operation(elemental.OperationRetrieveMany, err) }()
 
 	if mctx == nil {
 		ctx, cancel := context.WithTimeout(context.Background(), defaultGlobalContextTimeout)
@@ -98,23 +149,43 @@ func (m *mongoManipulator) RetrieveMany(mctx manipulate.Context, dest elemental.
 		mctx = manipulate.NewContext(ctx)
 	}
 
+	if err := validatePagination(mctx); err != nil {
+		return err
+	}
+
 	sp := tracing.StartTrace(mctx, fmt.Sprintf("manipmongo.retrieve_many.%s", dest.Identity().Category))
 	defer sp.Finish()
 
-	c, close := m.makeSession(dest.Identity(), mctx.ReadConsistency(), mctx.WriteConsistency())
+	c, close := m.makeSession(mctx, dest.Identity())
 	defer close()
 
+	if err := m.checkCollection(c); err != nil {
+		return err
+	}
+
+	hints := makeQueryHints(mctx)
+	if hints.noCursorTimeout {
+		c.Database.Session.SetCursorTimeout(0)
+	}
+
 	var order []string
 	if o := mctx.Order(); len(o) > 0 {
-		order = applyOrdering(o)
+		order = applyOrdering(m.mapFields(dest.Identity(), o))
+	} else if o, ok := m.defaultOrders[dest.Identity()]; ok {
+		order = applyOrdering(m.mapFields(dest.Identity(), o))
 	} else if orderer, ok := dest.(elemental.DefaultOrderer); ok {
-		order = applyOrdering(orderer.DefaultOrder())
+		order = applyOrdering(m.mapFields(dest.Identity(), orderer.DefaultOrder()))
 	}
 
+	fields := m.mapFields(dest.Identity(), mctx.Fields())
+
 	// Filtering
 	filter := bson.D{}
 	if f := mctx.Filter(); f != nil {
-		filter = CompileFilter(f)
+		if err := m.validateFilter(dest.Identity(), f); err != nil {
+			return err
+		}
+		filter = m.compileFilter(dest.Identity(), f)
 	}
 
 	var ands []bson.D
@@ -133,6 +204,12 @@ func (m *mongoManipulator) RetrieveMany(mctx manipulate.Context, dest elemental.
 		ands = append(ands, m.forcedReadFilter)
 	}
 
+	// The total count ignores the lazy pagination filters.
+	countFilter := filter
+	if len(ands) > 0 {
+		countFilter = bson.D{{Name: "$and", Value: append(append([]bson.D{}, ands...), filter)}}
+	}
+
 	if after := mctx.After(); after != "" {
 
 		if len(order) > 1 {
@@ -152,6 +229,34 @@ func (m *mongoManipulator) RetrieveMany(mctx manipulate.Context, dest elemental.
 		ands = append(ands, f)
 	}
 
+	if after, ok := mctx.(opaquer).Opaque()[opaqueKeyAfterValue].(afterValue); ok {
+
+		if mctx.After() != "" {
+			return manipulate.NewErrCannotBuildQuery("cannot use 'after' and an after value at the same time")
+		}
+
+		if len(order) > 1 {
+			return manipulate.NewErrCannotBuildQuery("cannot use multiple ordering fields when using an after value")
+		}
+
+		var o string
+		if len(order) == 1 {
+			o = order[0]
+		}
+
+		ands = append(ands, makeAfterValueFilter(o, after))
+
+		switch {
+		case o == "":
+			order = []string{"_id"}
+		case strings.TrimPrefix(o, "-") == "_id":
+		case strings.HasPrefix(o, "-"):
+			order = append(order, "-_id")
+		default:
+			order = append(order, "_id")
+		}
+	}
+
 	if len(ands) > 0 {
 		filter = bson.D{{Name: "$and", Value: append(ands, filter)}}
 	}
@@ -160,15 +265,19 @@ func (m *mongoManipulator) RetrieveMany(mctx manipulate.Context, dest elemental.
 	q := c.Find(filter)
 
 	// limiting
-	if limit := mctx.Limit(); limit > 0 {
+	limit := mctx.Limit()
+	if limit <= 0 {
+		limit = mctx.PageSize()
+	}
+	if limit > 0 {
 		q = q.Limit(limit)
-	} else if pageSize := mctx.PageSize(); pageSize > 0 {
-		q = q.Limit(pageSize)
 	}
 
 	// Old pagination
+	var skip int
 	if p := mctx.Page(); p > 0 {
-		q = q.Skip((p - 1) * mctx.PageSize())
+		skip = (p - 1) * mctx.PageSize()
+		q = q.Skip(skip)
 	}
 
 	// Ordering
@@ -177,30 +286,67 @@ func (m *mongoManipulator) RetrieveMany(mctx manipulate.Context, dest elemental.
 	}
 
 	// Fields selection
-	if sels := makeFieldsSelector(mctx.Fields()); sels != nil {
+	if sels := makeFieldsSelector(fields); sels != nil {
 		q = q.Select(sels)
 	}
 
 	// Query timing limiting
-	q = q.SetMaxTime(defaultGlobalContextTimeout)
-	if d, ok := mctx.Context().Deadline(); ok {
-		q = q.SetMaxTime(time.Until(d))
+	q = q.SetMaxTime(maxExecutionTime(mctx))
+
+	// Collation
+	collation := makeCollation(mctx)
+	if collation != nil {
+		q = q.Collation(collation)
+	}
+
+	run := func() error { return q.All(dest) }
+	var explained explainer = q
+
+	// Disk use, which is only supported by the find command.
+	if hints.allowDiskUse && len(order) > 0 {
+		cmd := hints.applyToFindCommand(makeFindCommand(c.Name, filter, order, skip, limit, makeFieldsSelector(fields), maxExecutionTime(mctx), collation))
+		run = func() error { return runCursorCommand(c, cmd, dest) }
+		explained = commandExplainer{db: c.Database, cmd: cmd}
+	}
+
+	// Total count
+	cq := c.Find(countFilter).SetMaxTime(maxExecutionTime(mctx))
+	if collation != nil {
+		cq = cq.Collation(collation)
+	}
+	countFunc := cq.Count
+
+	// Deduplication
+	if dedup, ok := mctx.(opaquer).Opaque()[opaqueKeyDeduplicate].(deduplication); ok {
+
+		countPipeline := makeDeduplicateCountPipeline(countFilter, dedup)
+		countPipe := c.Pipe(countPipeline).Collation(collation).SetMaxTime(maxExecutionTime(mctx))
+		countFunc = func() (int, error) { return runCountPipeline(countPipe) }
+
+		pipe := c.Pipe(makeDeduplicatePipeline(filter, dedup, order, skip, limit, makeFieldsSelector(fields)))
+		pipe = pipe.Collation(collation).SetMaxTime(maxExecutionTime(mctx))
+		if hints.allowDiskUse {
+			pipe = pipe.AllowDiskUse()
+		}
+		run = func() error { return pipe.All(dest) }
+		explained = pipe
 	}
 
 	if _, err := RunQuery(
 		mctx,
 		func() (interface{}, error) {
-			if exp := explainIfNeeded(q, filter, dest.Identity(), elemental.OperationRetrieveMany, m.explain); exp != nil {
+			if exp := explainIfNeeded(explained, filter, dest.Identity(), elemental.OperationRetrieveMany, m.explain); exp != nil {
 				if err := exp(); err != nil {
 					return nil, manipulate.NewErrCannotBuildQuery(fmt.Sprintf("retrievemany: unable to explain: %s", err))
 				}
 			}
-			return nil, q.All(dest)
+			return nil, run()
 		},
 		RetryInfo{
 			Operation:        elemental.OperationRetrieveMany,
 			Identity:         dest.Identity(),
 			defaultRetryFunc: m.defaultRetryFunc,
+			stats:            m.stats,
 		},
 	); err != nil {
 		sp.SetTag("error", true)
@@ -208,14 +354,47 @@ func (m *mongoManipulator) RetrieveMany(mctx manipulate.Context, dest elemental.
 		return err
 	}
 
+	if _, ok := mctx.(opaquer).Opaque()[opaqueKeyCountTotal]; ok {
+
+		out, err := RunQuery(
+			mctx,
+			func() (interface{}, error) { return countFunc() },
+			RetryInfo{
+				Operation:        elemental.OperationInfo,
+				Identity:         dest.Identity(),
+				defaultRetryFunc: m.defaultRetryFunc,
+				stats:            m.stats,
+			},
+		)
+		if err != nil {
+			sp.SetTag("error", true)
+			sp.LogFields(log.Error(err))
+			return err
+		}
+
+		mctx.SetCount(out.(int))
+	}
+
 	var lastID string
 
+	encoder := m.attributeEncoder(dest.Identity())
+
+	hook := mctx.PostReadHook()
+	var kept elemental.IdentifiablesList
+
 	lst := dest.List()
 	for _, o := range lst {
 
 		// backport all default values that are empty.
-		if a, ok := o.(elemental.AttributeSpecifiable); ok {
-			elemental.ResetDefaultForZeroValues(a)
+		if a, ok := o.(elemental.AttributeSpecifiable); ok && !mctx.PreserveZeroValues() {
+			resetDefaultForZeroValues(a, mctx.Fields())
+		}
+
+		// Decode attributes if needed.
+		if encoder != nil {
+			if err := encoder.DecodeAttributes(o); err != nil {
+				return manipulate.NewErrCannotBuildQuery(fmt.Sprintf("retrievemany: unable to decode attributes: %s", err))
+			}
 		}
 
 		// Decrypt attributes if needed.
@@ -228,6 +407,21 @@ func (m *mongoManipulator) RetrieveMany(mctx manipulate.Context, dest elemental.
 		}
 
 		lastID = o.Identifier()
+
+		// Apply the post read hook if needed.
+		if hook != nil {
+			ok, err := hook(o)
+			if err != nil {
+				return err
+			}
+			if ok {
+				kept = append(kept, o)
+			}
+		}
+	}
+
+	if hook != nil && len(kept) != len(lst) {
+		replaceIdentifiables(dest, kept)
 	}
 
 	if lastID != "" && (mctx.After() != "" || mctx.Limit() > 0) && len(lst) == mctx.Limit() {
@@ -239,7 +433,9 @@ func (m *mongoManipulator) RetrieveMany(mctx manipulate.Context, dest elemental.
 	return nil
 }
 
-func (m *mongoManipulator) Retrieve(mctx manipulate.Context, object elemental.Identifiable) error {
+func (m *mongoManipulator) Retrieve(mctx manipulate.Context, object elemental.Identifiable) (err error) {
+
+	defer func() { m.stats.Operation(elemental.OperationRetrieve, err) }()
 
 	if mctx == nil {
 		ctx, cancel := context.WithTimeout(context.Background(), defaultGlobalContextTimeout)
@@ -247,13 +443,20 @@ func (m *mongoManipulator) Retrieve(mctx manipulate.Context, object elemental.Id
 		mctx = manipulate.NewContext(ctx)
 	}
 
-	c, close := m.makeSession(object.Identity(), mctx.ReadConsistency(), mctx.WriteConsistency())
+	c, close := m.makeSession(mctx, object.Identity())
 	defer close()
 
+	if err := m.checkCollection(c); err != nil {
+		return err
+	}
+
 	filter := bson.D{}
 
 	if f := mctx.Filter(); f != nil {
-		filter = CompileFilter(f)
+		if err := m.validateFilter(object.Identity(), f); err != nil {
+			return err
+		}
+		filter = m.compileFilter(object.Identity(), f)
 	}
 
 	if oid, ok := objectid.Parse(object.Identifier()); ok {
@@ -281,14 +484,11 @@ func (m *mongoManipulator) Retrieve(mctx manipulate.Context, object elemental.Id
 	defer sp.Finish()
 
 	q := c.Find(filter)
-	if sels := makeFieldsSelector(mctx.Fields()); sels != nil {
+	if sels := makeFieldsSelector(m.mapFields(object.Identity(), mctx.Fields())); sels != nil {
 		q = q.Select(sels)
 	}
 
-	q = q.SetMaxTime(defaultGlobalContextTimeout)
-	if d, ok := mctx.Context().Deadline(); ok {
-		q = q.SetMaxTime(time.Until(d))
-	}
+	q = q.SetMaxTime(maxExecutionTime(mctx))
 
 	if _, err := RunQuery(
 		mctx,
@@ -304,30 +504,60 @@ func (m *mongoManipulator) Retrieve(mctx manipulate.Context, object elemental.Id
 			Operation:        elemental.OperationRetrieve,
 			Identity:         object.Identity(),
 			defaultRetryFunc: m.defaultRetryFunc,
+			stats:            m.stats,
 		},
 	); err != nil {
+		if mctx.NotFoundAsEmpty() && manipulate.IsObjectNotFoundError(err) {
+			return nil
+		}
 		sp.SetTag("error", true)
 		sp.LogFields(log.Error(err))
 		return err
 	}
 
+	return m.processRetrieved(mctx, object, "retrieve")
+}
+
+// processRetrieved prepares the given object that has just been read for
+// the given operation: it backports the default values, decodes and decrypts
+// the attributes, and runs the post read hook of the given context.
+func (m *mongoManipulator) processRetrieved(mctx manipulate.Context, object elemental.Identifiable, operation string) error {
+
 	// backport all default values that are empty.
-	if a, ok := object.(elemental.AttributeSpecifiable); ok {
-		elemental.ResetDefaultForZeroValues(a)
+	if a, ok := object.(elemental.AttributeSpecifiable); ok && !mctx.PreserveZeroValues() {
+		resetDefaultForZeroValues(a, mctx.Fields())
+	}
+
+	if encoder := m.attributeEncoder(object.Identity()); encoder != nil {
+		if err := encoder.DecodeAttributes(object); err != nil {
+			return manipulate.NewErrCannotBuildQuery(fmt.Sprintf("%s: unable to decode attributes: %s", operation, err))
+		}
 	}
 
 	if m.attributeEncrypter != nil {
 		if a, ok := object.(elemental.AttributeEncryptable); ok {
 			if err := a.DecryptAttributes(m.attributeEncrypter); err != nil {
-				return manipulate.NewErrCannotBuildQuery(fmt.Sprintf("retrieve: unable to decrypt attributes: %s", err))
+				return manipulate.NewErrCannotBuildQuery(fmt.Sprintf("%s: unable to decrypt attributes: %s", operation, err))
 			}
 		}
 	}
 
+	if hook := mctx.PostReadHook(); hook != nil {
+		ok, err := hook(object)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return manipulate.NewErrObjectNotFound("cannot find the object for the given ID")
+		}
+	}
+
 	return nil
 }
 
-func (m *mongoManipulator) Create(mctx manipulate.Context, object elemental.Identifiable) error {
+func (m *mongoManipulator) Create(mctx manipulate.Context, object elemental.Identifiable) (err error) {
+
+	defer func() { m.stats.Operation(elemental.OperationCreate, err) }()
 
 	if mctx == nil {
 		ctx, cancel := context.WithTimeout(context.Background(), defaultGlobalContextTimeout)
@@ -335,16 +565,24 @@ func (m *mongoManipulator) Create(mctx manipulate.Context, object elemental.Iden
 		mctx = manipulate.NewContext(ctx)
 	}
 
-	c, close := m.makeSession(object.Identity(), mctx.ReadConsistency(), mctx.WriteConsistency())
+	c, close := m.makeSession(mctx, object.Identity())
 	defer close()
 
-	oid := bson.NewObjectId()
-	object.SetIdentifier(oid.Hex())
+	oid := m.newIdentifier(object)
+	object.SetIdentifier(identifierString(oid))
 
 	sp := tracing.StartTrace(mctx, fmt.Sprintf("manipmongo.create.object.%s", object.Identity().Name))
 	sp.LogFields(log.String("object_id", object.Identifier()))
 	defer sp.Finish()
 
+	if m.timestamps {
+		if t, ok := object.(manipulate.Timestampable); ok {
+			now := time.Now()
+			t.SetCreatedAt(now)
+			t.SetUpdatedAt(now)
+		}
+	}
+
 	if f := mctx.Finalizer(); f != nil {
 		if err := f(object); err != nil {
 			sp.SetTag("error", true)
@@ -353,6 +591,14 @@ func (m *mongoManipulator) Create(mctx manipulate.Context, object elemental.Iden
 		}
 	}
 
+	if m.validate {
+		if err := validateObject(object); err != nil {
+			sp.SetTag("error", true)
+			sp.LogFields(log.Error(err))
+			return err
+		}
+	}
+
 	if m.sharder != nil {
 		if err := m.sharder.Shard(m, mctx, object); err != nil {
 			return manipulate.NewErrCannotBuildQuery(fmt.Sprintf("unable to execute sharder.Shard: %s", err))
@@ -369,6 +615,13 @@ func (m *mongoManipulator) Create(mctx manipulate.Context, object elemental.Iden
 		}
 	}
 
+	encoder := m.attributeEncoder(object.Identity())
+	if encoder != nil {
+		if err := encoder.EncodeAttributes(object); err != nil {
+			return manipulate.NewErrCannotBuildQuery(fmt.Sprintf("create: unable to encode attributes: %s", err))
+		}
+	}
+
 	if operations, upsert := mctx.(opaquer).Opaque()[opaqueKeyUpsert]; upsert {
 
 		object.SetIdentifier("")
@@ -383,6 +636,14 @@ func (m *mongoManipulator) Create(mctx manipulate.Context, object elemental.Iden
 			"$setOnInsert": bson.M{"_id": oid},
 		}
 
+		if actor := mctx.Actor(); actor != "" {
+			doc, err := stampActor(object, actor)
+			if err != nil {
+				return manipulate.NewErrCannotBuildQuery(fmt.Sprintf("create: unable to record actor: %s", err))
+			}
+			baseOps["$set"] = doc
+		}
+
 		if len(ops) > 0 {
 
 			if soi, ok := ops["$setOnInsert"]; ok {
@@ -399,7 +660,7 @@ func (m *mongoManipulator) Create(mctx manipulate.Context, object elemental.Iden
 			}
 		}
 
-		filter := CompileFilter(mctx.Filter())
+		filter := m.compileFilter(object.Identity(), mctx.Filter())
 		if m.sharder != nil {
 			sq, err := m.sharder.FilterOne(m, mctx, object)
 			if err != nil {
@@ -417,6 +678,7 @@ func (m *mongoManipulator) Create(mctx manipulate.Context, object elemental.Iden
 				Operation:        elemental.OperationCreate,
 				Identity:         object.Identity(),
 				defaultRetryFunc: m.defaultRetryFunc,
+				stats:            m.stats,
 			},
 		)
 		if err != nil {
@@ -427,19 +689,30 @@ func (m *mongoManipulator) Create(mctx manipulate.Context, object elemental.Iden
 
 		switch chinfo := info.(type) {
 		case *mgo.ChangeInfo:
-			if noid, ok := chinfo.UpsertedId.(bson.ObjectId); ok {
-				object.SetIdentifier(noid.Hex())
+			if chinfo.UpsertedId != nil {
+				object.SetIdentifier(identifierString(chinfo.UpsertedId))
 			}
 		}
 
 	} else {
+
+		var doc interface{} = object
+		if actor := mctx.Actor(); actor != "" {
+			sdoc, err := stampActor(object, actor)
+			if err != nil {
+				return manipulate.NewErrCannotBuildQuery(fmt.Sprintf("create: unable to record actor: %s", err))
+			}
+			doc = sdoc
+		}
+
 		_, err := RunQuery(
 			mctx,
-			func() (interface{}, error) { return nil, c.Insert(object) },
+			func() (interface{}, error) { return nil, c.Insert(doc) },
 			RetryInfo{
 				Operation:        elemental.OperationCreate,
 				Identity:         object.Identity(),
 				defaultRetryFunc: m.defaultRetryFunc,
+				stats:            m.stats,
 			},
 		)
 
@@ -450,6 +723,12 @@ func (m *mongoManipulator) Create(mctx manipulate.Context, object elemental.Iden
 		}
 	}
 
+	if encoder != nil {
+		if err := encoder.DecodeAttributes(object); err != nil {
+			return manipulate.NewErrCannotBuildQuery(fmt.Sprintf("create: unable to decode attributes: %s", err))
+		}
+	}
+
 	if encryptable != nil {
 		if err := encryptable.DecryptAttributes(m.attributeEncrypter); err != nil {
 			return manipulate.NewErrCannotBuildQuery(fmt.Sprintf("create: unable to decrypt attributes: %s", err))
@@ -465,7 +744,9 @@ func (m *mongoManipulator) Create(mctx manipulate.Context, object elemental.Iden
 	return nil
 }
 
-func (m *mongoManipulator) Update(mctx manipulate.Context, object elemental.Identifiable) error {
+func (m *mongoManipulator) Update(mctx manipulate.Context, object elemental.Identifiable) (err error) {
+
+	defer func() { m.stats.Operation(elemental.OperationUpdate, err) }()
 
 	if mctx == nil {
 		ctx, cancel := context.WithTimeout(context.Background(), defaultGlobalContextTimeout)
@@ -473,6 +754,24 @@ func (m *mongoManipulator) Update(mctx manipulate.Context, object elemental.Iden
 		mctx = manipulate.NewContext(ctx)
 	}
 
+	if m.timestamps {
+		if t, ok := object.(manipulate.Timestampable); ok {
+			t.SetUpdatedAt(time.Now())
+		}
+	}
+
+	if f := mctx.UpdateFinalizer(); f != nil {
+		if err := f(object); err != nil {
+			return err
+		}
+	}
+
+	if m.validate {
+		if err := validateObject(object); err != nil {
+			return err
+		}
+	}
+
 	var encryptable elemental.AttributeEncryptable
 	if m.attributeEncrypter != nil {
 		if a, ok := object.(elemental.AttributeEncryptable); ok {
@@ -483,9 +782,20 @@ func (m *mongoManipulator) Update(mctx manipulate.Context, object elemental.Iden
 		}
 	}
 
-	c, close := m.makeSession(object.Identity(), mctx.ReadConsistency(), mctx.WriteConsistency())
+	encoder := m.attributeEncoder(object.Identity())
+	if encoder != nil {
+		if err := encoder.EncodeAttributes(object); err != nil {
+			return manipulate.NewErrCannotBuildQuery(fmt.Sprintf("update: unable to encode attributes: %s", err))
+		}
+	}
+
+	c, close := m.makeSession(mctx, object.Identity())
 	defer close()
 
+	if err := m.checkCollection(c); err != nil {
+		return err
+	}
+
 	var filter bson.D
 
 	sp := tracing.StartTrace(mctx, fmt.Sprintf("manipmongo.update.object.%s", object.Identity().Name))
@@ -517,13 +827,46 @@ func (m *mongoManipulator) Update(mctx manipulate.Context, object elemental.Iden
 		}
 	}
 
+	// We use $set so the fields stored in the document that are not part
+	// of the model (i.e. written by a newer version) are left untouched.
+	update := bson.M{"$set": object}
+
+	if fields, ok := mctx.(opaquer).Opaque()[opaqueKeyUpdateFields].([]string); ok && len(fields) > 0 {
+		partial, err := makePartialUpdate(object, fields)
+		if err != nil {
+			return manipulate.NewErrCannotBuildQuery(fmt.Sprintf("update: unable to build partial update: %s", err))
+		}
+		update = partial
+	}
+
+	if actor := mctx.Actor(); actor != "" {
+		set, ok := update["$set"]
+		if !ok {
+			set = bson.M{}
+		}
+		doc, err := stampActor(set, actor)
+		if err != nil {
+			return manipulate.NewErrCannotBuildQuery(fmt.Sprintf("update: unable to record actor: %s", err))
+		}
+		update["$set"] = doc
+	}
+
+	updateFunc := func() (interface{}, error) { return nil, c.Update(filter, update) }
+
+	if _, ok := mctx.(opaquer).Opaque()[opaqueKeyReturnUpdated]; ok {
+		updateFunc = func() (interface{}, error) {
+			return c.Find(filter).Apply(mgo.Change{Update: update, ReturnNew: true}, object)
+		}
+	}
+
 	if _, err := RunQuery(
 		mctx,
-		func() (interface{}, error) { return nil, c.Update(filter, bson.M{"$set": object}) },
+		updateFunc,
 		RetryInfo{
 			Operation:        elemental.OperationUpdate,
 			Identity:         object.Identity(),
 			defaultRetryFunc: m.defaultRetryFunc,
+			stats:            m.stats,
 		},
 	); err != nil {
 		sp.SetTag("error", true)
@@ -531,6 +874,12 @@ func (m *mongoManipulator) Update(mctx manipulate.Context, object elemental.Iden
 		return err
 	}
 
+	if encoder != nil {
+		if err := encoder.DecodeAttributes(object); err != nil {
+			return manipulate.NewErrCannotBuildQuery(fmt.Sprintf("update: unable to decode attributes: %s", err))
+		}
+	}
+
 	if encryptable != nil {
 		if err := encryptable.DecryptAttributes(m.attributeEncrypter); err != nil {
 			return manipulate.NewErrCannotBuildQuery(fmt.Sprintf("update: unable to decrypt attributes: %s", err))
@@ -540,7 +889,9 @@ func (m *mongoManipulator) Update(mctx manipulate.Context, object elemental.Iden
 	return nil
 }
 
-func (m *mongoManipulator) Delete(mctx manipulate.Context, object elemental.Identifiable) error {
+func (m *mongoManipulator) Delete(mctx manipulate.Context, object elemental.Identifiable) (err error) {
+
+	defer func() { m.stats.Operation(elemental.OperationDelete, err) }()
 
 	if mctx == nil {
 		ctx, cancel := context.WithTimeout(context.Background(), defaultGlobalContextTimeout)
@@ -548,15 +899,34 @@ func (m *mongoManipulator) Delete(mctx manipulate.Context, object elemental.Iden
 		mctx = manipulate.NewContext(ctx)
 	}
 
-	c, close := m.makeSession(object.Identity(), mctx.ReadConsistency(), mctx.WriteConsistency())
+	c, close := m.makeSession(mctx, object.Identity())
 	defer close()
 
-	var filter bson.D
+	if err := m.checkCollection(c); err != nil {
+		return err
+	}
+
+	filter := bson.D{}
+
+	if f := mctx.Filter(); f != nil {
+		if err := m.validateFilter(object.Identity(), f); err != nil {
+			return err
+		}
+		filter = m.compileFilter(object.Identity(), f)
+	}
 
 	sp := tracing.StartTrace(mctx, fmt.Sprintf("manipmongobject.delete.object.%s", object.Identity().Name))
 	sp.LogFields(log.String("object_id", object.Identifier()))
 	defer sp.Finish()
 
+	if f := mctx.DeleteFinalizer(); f != nil {
+		if err := f(object); err != nil {
+			sp.SetTag("error", true)
+			sp.LogFields(log.Error(err))
+			return err
+		}
+	}
+
 	if oid, ok := objectid.Parse(object.Identifier()); ok {
 		filter = append(filter, bson.DocElem{Name: "_id", Value: oid})
 	} else {
@@ -584,6 +954,7 @@ func (m *mongoManipulator) Delete(mctx manipulate.Context, object elemental.Iden
 			Operation:        elemental.OperationDelete,
 			Identity:         object.Identity(),
 			defaultRetryFunc: m.defaultRetryFunc,
+			stats:            m.stats,
 		},
 	); err != nil {
 		sp.SetTag("error", true)
@@ -598,14 +969,16 @@ func (m *mongoManipulator) Delete(mctx manipulate.Context, object elemental.Iden
 	}
 
 	// backport all default values that are empty.
-	if a, ok := object.(elemental.AttributeSpecifiable); ok {
+	if a, ok := object.(elemental.AttributeSpecifiable); ok && !mctx.PreserveZeroValues() {
 		elemental.ResetDefaultForZeroValues(a)
 	}
 
 	return nil
 }
 
-func (m *mongoManipulator) DeleteMany(mctx manipulate.Context, identity elemental.Identity) error {
+func (m *mongoManipulator) DeleteMany(mctx manipulate.Context, identity elemental.Identity) (err error) {
+
+	defer func() { m.stats.Operation(elemental.OperationDelete, err) }()
 
 	if mctx == nil {
 		ctx, cancel := context.WithTimeout(context.Background(), defaultGlobalContextTimeout)
@@ -616,10 +989,18 @@ func (m *mongoManipulator) DeleteMany(mctx manipulate.Context, identity elementa
 	sp := tracing.StartTrace(mctx, fmt.Sprintf("manipmongo.delete_many.%s", identity.Name))
 	defer sp.Finish()
 
-	c, close := m.makeSession(identity, mctx.ReadConsistency(), mctx.WriteConsistency())
+	if err := m.validateFilter(identity, mctx.Filter()); err != nil {
+		return err
+	}
+
+	c, close := m.makeSession(mctx, identity)
 	defer close()
 
-	filter := CompileFilter(mctx.Filter())
+	if err := m.checkCollection(c); err != nil {
+		return err
+	}
+
+	filter := m.compileFilter(identity, mctx.Filter())
 	if m.sharder != nil {
 		sq, err := m.sharder.FilterMany(m, mctx, identity)
 		if err != nil {
@@ -634,24 +1015,121 @@ func (m *mongoManipulator) DeleteMany(mctx manipulate.Context, identity elementa
 		filter = bson.D{{Name: "$and", Value: []bson.D{m.forcedReadFilter, filter}}}
 	}
 
-	if _, err := RunQuery(
+	if batch, ok := mctx.(opaquer).Opaque()[opaqueKeyDeleteBatch].(deleteBatch); ok && batch.size > 0 {
+		removed, err := m.deleteManyInBatches(mctx, c, identity, filter, batch)
+		mctx.SetCount(removed)
+		if err != nil {
+			sp.SetTag("error", true)
+			sp.LogFields(log.Error(err))
+			return err
+		}
+		return nil
+	}
+
+	out, err := RunQuery(
 		mctx,
 		func() (interface{}, error) { return c.RemoveAll(filter) },
 		RetryInfo{
 			Operation:        elemental.OperationDelete, // we miss DeleteMany
 			Identity:         identity,
 			defaultRetryFunc: m.defaultRetryFunc,
+			stats:            m.stats,
 		},
-	); err != nil {
+	)
+	if err != nil {
 		sp.SetTag("error", true)
 		sp.LogFields(log.Error(err))
 		return err
 	}
 
+	if info, ok := out.(*mgo.ChangeInfo); ok && info != nil {
+		mctx.SetCount(info.Removed)
+	}
+
 	return nil
 }
 
-func (m *mongoManipulator) Count(mctx manipulate.Context, identity elemental.Identity) (int, error) {
+// deleteManyInBatches removes the documents matching the given filter by
+// batches of the given size, until no document matches anymore. It returns
+// the total number of removed documents.
+func (m *mongoManipulator) deleteManyInBatches(
+	mctx manipulate.Context,
+	c *mgo.Collection,
+	identity elemental.Identity,
+	filter bson.D,
+	batch deleteBatch,
+) (int, error) {
+
+	var removed int
+
+	for {
+
+		out, err := RunQuery(
+			mctx,
+			func() (interface{}, error) {
+				var docs []struct {
+					ID interface{} `bson:"_id"`
+				}
+				if err := c.Find(filter).Select(bson.M{"_id": 1}).Limit(batch.size).All(&docs); err != nil {
+					return nil, err
+				}
+				ids := make([]interface{}, len(docs))
+				for i, d := range docs {
+					ids[i] = d.ID
+				}
+				return ids, nil
+			},
+			RetryInfo{
+				Operation:        elemental.OperationDelete,
+				Identity:         identity,
+				defaultRetryFunc: m.defaultRetryFunc,
+				stats:            m.stats,
+			},
+		)
+		if err != nil {
+			return removed, err
+		}
+
+		ids := out.([]interface{})
+		if len(ids) == 0 {
+			return removed, nil
+		}
+
+		out, err = RunQuery(
+			mctx,
+			func() (interface{}, error) { return c.RemoveAll(bson.M{"_id": bson.M{"$in": ids}}) },
+			RetryInfo{
+				Operation:        elemental.OperationDelete,
+				Identity:         identity,
+				defaultRetryFunc: m.defaultRetryFunc,
+				stats:            m.stats,
+			},
+		)
+		if err != nil {
+			return removed, err
+		}
+
+		if info, ok := out.(*mgo.ChangeInfo); ok && info != nil {
+			removed += info.Removed
+		}
+
+		if len(ids) < batch.size {
+			return removed, nil
+		}
+
+		if batch.pause > 0 {
+			select {
+			case <-time.After(batch.pause):
+			case <-mctx.Context().Done():
+				return removed, manipulate.NewErrCannotExecuteQuery(mctx.Context().Err().Error())
+			}
+		}
+	}
+}
+
+func (m *mongoManipulator) Count(mctx manipulate.Context, identity elemental.Identity) (n int, err error) {
+
+	defer func() { m.stats.Operation(elemental.OperationInfo, err) }()
 
 	if mctx == nil {
 		ctx, cancel := context.WithTimeout(context.Background(), defaultGlobalContextTimeout)
@@ -659,13 +1137,20 @@ func (m *mongoManipulator) Count(mctx manipulate.Context, identity elemental.Ide
 		mctx = manipulate.NewContext(ctx)
 	}
 
-	c, close := m.makeSession(identity, mctx.ReadConsistency(), mctx.WriteConsistency())
+	c, close := m.makeSession(mctx, identity)
 	defer close()
 
+	if err := m.checkCollection(c); err != nil {
+		return 0, err
+	}
+
 	filter := bson.D{}
 
 	if f := mctx.Filter(); f != nil {
-		filter = CompileFilter(f)
+		if err := m.validateFilter(identity, f); err != nil {
+			return 0, err
+		}
+		filter = m.compileFilter(identity, f)
 	}
 
 	if m.sharder != nil {
@@ -685,10 +1170,17 @@ func (m *mongoManipulator) Count(mctx manipulate.Context, identity elemental.Ide
 	sp := tracing.StartTrace(mctx, fmt.Sprintf("manipmongo.count.%s", identity.Category))
 	defer sp.Finish()
 
-	q := c.Find(filter).SetMaxTime(defaultGlobalContextTimeout)
+	q := c.Find(filter).SetMaxTime(maxExecutionTime(mctx))
 
-	if d, ok := mctx.Context().Deadline(); ok {
-		q = q.SetMaxTime(time.Until(d))
+	if collation := makeCollation(mctx); collation != nil {
+		q = q.Collation(collation)
+	}
+
+	countFunc := q.Count
+
+	if _, ok := mctx.(opaquer).Opaque()[opaqueKeyCountEstimated]; ok && len(filter) == 0 {
+		cmd := makeEstimatedCountCommand(c.Name, maxExecutionTime(mctx))
+		countFunc = func() (int, error) { return runCountCommand(c, cmd) }
 	}
 
 	out, err := RunQuery(
@@ -699,12 +1191,13 @@ func (m *mongoManipulator) Count(mctx manipulate.Context, identity elemental.Ide
 					return nil, manipulate.NewErrCannotBuildQuery(fmt.Sprintf("count: unable to explain: %s", err))
 				}
 			}
-			return q.Count()
+			return countFunc()
 		},
 		RetryInfo{
 			Operation:        elemental.OperationInfo,
 			Identity:         identity,
 			defaultRetryFunc: m.defaultRetryFunc,
+			stats:            m.stats,
 		},
 	)
 	if err != nil {
@@ -716,10 +1209,98 @@ func (m *mongoManipulator) Count(mctx manipulate.Context, identity elemental.Ide
 	return out.(int), nil
 }
 
+// Exists is part of the implementation of the manipulate.ExistenceChecker interface.
+// It stops counting at the first document matching the filter of the given context.
+func (m *mongoManipulator) Exists(mctx manipulate.Context, identity elemental.Identity) (ok bool, err error) {
+
+	defer func() { m.stats.Operation(elemental.OperationInfo, err) }()
+
+	if mctx == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), defaultGlobalContextTimeout)
+		defer cancel()
+		mctx = manipulate.NewContext(ctx)
+	}
+
+	c, close := m.makeSession(mctx, identity)
+	defer close()
+
+	if err := m.checkCollection(c); err != nil {
+		return false, err
+	}
+
+	filter := bson.D{}
+
+	if f := mctx.Filter(); f != nil {
+		if err := m.validateFilter(identity, f); err != nil {
+			return false, err
+		}
+		filter = m.compileFilter(identity, f)
+	}
+
+	if m.sharder != nil {
+		sq, err := m.sharder.FilterMany(m, mctx, identity)
+		if err != nil {
+			return false, manipulate.NewErrCannotBuildQuery(fmt.Sprintf("cannot compute sharding filter: %s", err))
+		}
+		if sq != nil {
+			filter = bson.D{{Name: "$and", Value: []bson.D{sq, filter}}}
+		}
+	}
+
+	if m.forcedReadFilter != nil {
+		filter = bson.D{{Name: "$and", Value: []bson.D{m.forcedReadFilter, filter}}}
+	}
+
+	sp := tracing.StartTrace(mctx, fmt.Sprintf("manipmongo.exists.%s", identity.Category))
+	defer sp.Finish()
+
+	q := c.Find(filter).Limit(1).SetMaxTime(maxExecutionTime(mctx))
+
+	out, err := RunQuery(
+		mctx,
+		func() (interface{}, error) { return q.Count() },
+		RetryInfo{
+			Operation:        elemental.OperationInfo,
+			Identity:         identity,
+			defaultRetryFunc: m.defaultRetryFunc,
+			stats:            m.stats,
+		},
+	)
+	if err != nil {
+		sp.SetTag("error", true)
+		sp.LogFields(log.Error(err))
+		return false, err
+	}
+
+	return out.(int) > 0, nil
+}
+
+// Commit is part of the implementation of the TransactionalManipulator interface.
+// The mongo manipulator does not buffer writes into bulks: every operation is
+// sent to the server as soon as it is called, so there is nothing to commit
+// and no risk of exceeding the server's per-command size limits here.
 func (m *mongoManipulator) Commit(id manipulate.TransactionID) error { return nil }
 
+// Abort is part of the implementation of the TransactionalManipulator interface.
+// As writes are not buffered, there is nothing to abort.
 func (m *mongoManipulator) Abort(id manipulate.TransactionID) bool { return true }
 
+// Shutdown is part of the implementation of the ClosableManipulator interface.
+// As the mongo manipulator does not keep track of transactions, it only
+// closes the root session.
+func (m *mongoManipulator) Shutdown(ctx context.Context, commit bool) error {
+
+	m.rootSession.Close()
+
+	return nil
+}
+
+// Stats is part of the implementation of the StatsProvider interface.
+// Only the queries run against the database are counted.
+func (m *mongoManipulator) Stats() manipulate.Stats {
+	return m.stats.Snapshot()
+}
+
 func (m *mongoManipulator) Ping(timeout time.Duration) error {
 
 	errChannel := make(chan error, 1)
@@ -736,19 +1317,114 @@ func (m *mongoManipulator) Ping(timeout time.Duration) error {
 	}
 }
 
-func (m *mongoManipulator) makeSession(
-	identity elemental.Identity,
-	readConsistency manipulate.ReadConsistency,
-	writeConsistency manipulate.WriteConsistency,
-) (*mgo.Collection, func()) {
+func (m *mongoManipulator) compileFilter(identity elemental.Identity, f *elemental.Filter) bson.D {
+
+	f = m.mapFilterFields(identity, f)
+
+	if m.filterCache == nil {
+		return CompileFilter(f)
+	}
+
+	return m.filterCache.compile(f)
+}
+
+// newIdentifier returns a new identifier for the given object to be inserted.
+// It is either the current identifier of the object if it is set and the
+// manipulator has been configured with OptionPreserveIdentifiers, the one
+// returned by the configured identifier generator, a UUID string if the
+// manipulator has been configured with OptionUUIDIdentifiers, or a new
+// bson.ObjectId.
+func (m *mongoManipulator) newIdentifier(object elemental.Identifiable) interface{} {
+
+	if id := object.Identifier(); id != "" && m.preserveIdentifiers {
+		if oid, ok := objectid.Parse(id); ok {
+			return oid
+		}
+		return id
+	}
+
+	if m.idGenerator != nil {
+		id := m.idGenerator(object)
+		if oid, ok := objectid.Parse(id); ok {
+			return oid
+		}
+		return id
+	}
+
+	if m.uuidIdentifiers {
+		return uuid.Must(uuid.NewV4()).String()
+	}
+
+	return bson.NewObjectId()
+}
+
+// checkCollection returns an error if strict collections are enabled
+// and the given collection does not exist in its database.
+func (m *mongoManipulator) checkCollection(c *mgo.Collection) error {
+
+	if !m.strictCollections {
+		return nil
+	}
+
+	if _, ok := m.knownCollections.Load(c.FullName); ok {
+		return nil
+	}
+
+	names, err := c.Database.CollectionNames()
+	if err != nil {
+		return HandleQueryError(err)
+	}
+
+	for _, name := range names {
+		if name == c.Name {
+			m.knownCollections.Store(c.FullName, struct{}{})
+			return nil
+		}
+	}
+
+	return manipulate.NewErrCannotExecuteQuery(fmt.Sprintf("collection '%s' does not exist in database '%s'", c.Name, c.Database.Name))
+}
+
+// makeSession returns a collection bound to a copy of the root session
+// and the function to call to release it.
+//
+// Copied sessions are not pooled. Copying only duplicates the session
+// struct and refreshes it, and mgo already pools the underlying sockets
+// per cluster. A pooled session would need the same refresh and a reset
+// of its mode and safety before reuse, and sync.Pool drops entries without
+// closing them, which would leak references to the cluster.
+// BenchmarkMakeSession quantifies the remaining cost.
+func (m *mongoManipulator) makeSession(mctx manipulate.Context, identity elemental.Identity) (*mgo.Collection, func()) {
 
 	session := m.rootSession.Copy()
+	atomic.AddInt64(&m.activeSessions, 1)
 
-	if mrc := convertReadConsistency(readConsistency); mrc != -1 {
+	if mrc := convertReadConsistency(mctx.ReadConsistency()); mrc != -1 {
 		session.SetMode(mrc, true)
 	}
 
-	session.SetSafe(convertWriteConsistency(writeConsistency))
+	session.SetSafe(convertWriteConsistency(mctx.WriteConsistency()))
+
+	var tracker *sessionTracker
+	if m.detectLeaks {
+		tracker = newSessionTracker(logLeakedSession)
+	}
+
+	return session.DB(m.databaseName(mctx)).C(identity.Name), func() {
+		session.Close()
+		atomic.AddInt64(&m.activeSessions, -1)
+		tracker.release()
+	}
+}
+
+// databaseName returns the database to use for the given context.
+// It is the one set by ContextOptionDatabase if any, or
+// the database of the manipulator.
+func (m *mongoManipulator) databaseName(mctx manipulate.Context) string {
+
+	if db, ok := mctx.(opaquer).Opaque()[opaqueKeyDatabase].(string); ok && db != "" {
+		return db
+	}
 
-	return session.DB(m.dbName).C(identity.Name), session.Close
+	return m.dbName
 }
@@ -0,0 +1,99 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manipmongo
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/globalsign/mgo"
+	. "github.com/smartystreets/goconvey/convey"
+	testmodel "go.aporeto.io/elemental/test/model"
+	"go.aporeto.io/manipulate"
+)
+
+func TestMongo_checkCollection(t *testing.T) {
+
+	Convey("Given I have a mongo manipulator", t, func() {
+
+		m := &mongoManipulator{}
+		c := &mgo.Collection{Name: "lists", FullName: "db.lists"}
+
+		Convey("When strict collections are disabled", func() {
+
+			err := m.checkCollection(c)
+
+			Convey("Then err should be nil", func() {
+				So(err, ShouldBeNil)
+			})
+		})
+
+		Convey("When strict collections are enabled and the collection is known", func() {
+
+			m.strictCollections = true
+			m.knownCollections.Store("db.lists", struct{}{})
+
+			err := m.checkCollection(c)
+
+			Convey("Then err should be nil", func() {
+				So(err, ShouldBeNil)
+			})
+		})
+	})
+}
+
+// BenchmarkMakeSession measures the per operation session cost.
+// It needs a running mongo server and is skipped unless
+// MANIPMONGO_BENCHMARK_URL is set, for instance:
+//
+//     MANIPMONGO_BENCHMARK_URL=127.0.0.1:27017 go test -run XXX -bench MakeSession
+func BenchmarkMakeSession(b *testing.B) {
+
+	url := os.Getenv("MANIPMONGO_BENCHMARK_URL")
+	if url == "" {
+		b.Skip("MANIPMONGO_BENCHMARK_URL is not set")
+	}
+
+	mctx := manipulate.NewContext(context.Background())
+
+	for _, detectLeaks := range []bool{false, true} {
+
+		m, err := New(url, "benchmark", OptionSessionLeakDetection(detectLeaks))
+		if err != nil {
+			b.Fatalf("unable to connect to %s: %s", url, err)
+		}
+		mm := m.(*mongoManipulator)
+
+		name := "makeSession"
+		if detectLeaks {
+			name += " with leak detection"
+		}
+
+		b.Run("copy and close", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				mm.rootSession.Copy().Close()
+			}
+		})
+
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_, release := mm.makeSession(mctx, testmodel.ListIdentity)
+				release()
+			}
+		})
+
+		mm.rootSession.Close()
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manipmongo
+
+import (
+	"strings"
+
+	"go.aporeto.io/elemental"
+	"go.aporeto.io/manipulate"
+)
+
+// makeFieldMapping returns the given field mapping with lowercased keys.
+func makeFieldMapping(mapping map[string]string) map[string]string {
+
+	out := make(map[string]string, len(mapping))
+	for k, v := range mapping {
+		out[strings.ToLower(k)] = v
+	}
+
+	return out
+}
+
+// mapFieldName returns the stored name of the given field according
+// to the given mapping. Only the first part of a dotted path is mapped,
+// and the ordering prefix, if any, is kept.
+func mapFieldName(mapping map[string]string, name string) string {
+
+	var prefix string
+	if strings.HasPrefix(name, "-") {
+		prefix = "-"
+		name = name[1:]
+	}
+
+	path := strings.SplitN(name, ".", 2)
+	if stored, ok := mapping[strings.ToLower(path[0])]; ok {
+		path[0] = stored
+	}
+
+	return prefix + strings.Join(path, ".")
+}
+
+// mapFields returns the stored names of the given fields of
+// the given identity. It returns the given fields as is if there
+// is no field mapping for the identity.
+func (m *mongoManipulator) mapFields(identity elemental.Identity, fields []string) []string {
+
+	mapping, ok := m.fieldMappings[identity]
+	if !ok || len(fields) == 0 {
+		return fields
+	}
+
+	out := make([]string, len(fields))
+	for i, f := range fields {
+		out[i] = mapFieldName(mapping, f)
+	}
+
+	return out
+}
+
+// mapFilterFields returns a copy of the given filter where the keys
+// are replaced by their stored names for the given identity. It returns
+// the given filter as is if there is no field mapping for the identity.
+func (m *mongoManipulator) mapFilterFields(identity elemental.Identity, f *elemental.Filter) *elemental.Filter {
+
+	mapping, ok := m.fieldMappings[identity]
+	if !ok || f == nil {
+		return f
+	}
+
+	out, err := manipulate.WalkFilter(f, func(c *manipulate.FilterComparison) error {
+		c.Key = mapFieldName(mapping, c.Key)
+		return nil
+	})
+
+	// The walk only fails on filters that cannot be compiled
+	// anyway, so we let the compiler deal with the original one.
+	if err != nil {
+		return f
+	}
+
+	return out
+}
@@ -0,0 +1,74 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manipmongo
+
+import (
+	"reflect"
+	"testing"
+
+	"go.aporeto.io/elemental"
+)
+
+func Test_mapFieldName(t *testing.T) {
+
+	mapping := makeFieldMapping(map[string]string{"Name": "n", "address": "addr"})
+
+	tests := []struct {
+		name  string
+		field string
+		want  string
+	}{
+		{"mapped", "name", "n"},
+		{"mapped with different case", "NAME", "n"},
+		{"mapped with ordering prefix", "-name", "-n"},
+		{"mapped dotted path", "address.city", "addr.city"},
+		{"not mapped", "date", "date"},
+		{"not mapped with ordering prefix", "-date", "-date"},
+		{"not mapped dotted path", "city.address", "city.address"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mapFieldName(mapping, tt.field); got != tt.want {
+				t.Errorf("mapFieldName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_mapFields(t *testing.T) {
+
+	identity := elemental.MakeIdentity("thing", "things")
+
+	m := &mongoManipulator{
+		fieldMappings: map[elemental.Identity]map[string]string{
+			identity: makeFieldMapping(map[string]string{"name": "n"}),
+		},
+	}
+
+	tests := []struct {
+		name     string
+		identity elemental.Identity
+		fields   []string
+		want     []string
+	}{
+		{"mapped identity", identity, []string{"-name", "date"}, []string{"-n", "date"}},
+		{"unmapped identity", elemental.MakeIdentity("other", "others"), []string{"name"}, []string{"name"}},
+		{"no fields", identity, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := m.mapFields(tt.identity, tt.fields); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mapFields() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -15,6 +15,7 @@ import (
 	"crypto/tls"
 	"time"
 
+	"github.com/globalsign/mgo"
 	"github.com/globalsign/mgo/bson"
 	"go.aporeto.io/elemental"
 	"go.aporeto.io/manipulate"
@@ -24,20 +25,31 @@ import (
 type Option func(*config)
 
 type config struct {
-	username           string
-	password           string
-	authsource         string
-	tlsConfig          *tls.Config
-	poolLimit          int
-	connectTimeout     time.Duration
-	socketTimeout      time.Duration
-	readConsistency    manipulate.ReadConsistency
-	writeConsistency   manipulate.WriteConsistency
-	sharder            Sharder
-	defaultRetryFunc   manipulate.RetryFunc
-	forcedReadFilter   bson.D
-	attributeEncrypter elemental.AttributeEncrypter
-	explain            map[elemental.Identity]map[elemental.Operation]struct{}
+	username            string
+	password            string
+	authsource          string
+	tlsConfig           *tls.Config
+	poolLimit           int
+	connectTimeout      time.Duration
+	socketTimeout       time.Duration
+	readConsistency     manipulate.ReadConsistency
+	writeConsistency    manipulate.WriteConsistency
+	sharder             Sharder
+	defaultRetryFunc    manipulate.RetryFunc
+	forcedReadFilter    bson.D
+	attributeEncrypter  elemental.AttributeEncrypter
+	attributeEncoders   map[elemental.Identity]AttributeEncoder
+	defaultOrders       map[elemental.Identity][]string
+	fieldMappings       map[elemental.Identity]map[string]string
+	explain             map[elemental.Identity]map[elemental.Operation]struct{}
+	timestamps          bool
+	filterCacheSize     int
+	detectLeaks         bool
+	uuidIdentifiers     bool
+	idGenerator         manipulate.IdentifierGenerator
+	preserveIdentifiers bool
+	validate            bool
+	strictCollections   bool
 }
 
 func newConfig() *config {
@@ -60,6 +72,7 @@ func OptionCredentials(username, password, authsource string) Option {
 }
 
 // OptionTLS sets the tls configuration for the connection.
+// It can be built with manipulate.NewTLSConfig.
 func OptionTLS(tlsConfig *tls.Config) Option {
 	return func(c *config) {
 		c.tlsConfig = tlsConfig
@@ -132,6 +145,46 @@ func OptionAttributeEncrypter(enc elemental.AttributeEncrypter) Option {
 	}
 }
 
+// OptionAttributeEncoder allows to set an AttributeEncoder to use
+// to encode/decode the attributes of the objects of the given identity.
+// When no AttributeEncoder is set for an identity, the objects are
+// stored using the standard bson marshaling.
+func OptionAttributeEncoder(identity elemental.Identity, enc AttributeEncoder) Option {
+	return func(c *config) {
+		if c.attributeEncoders == nil {
+			c.attributeEncoders = map[elemental.Identity]AttributeEncoder{}
+		}
+		c.attributeEncoders[identity] = enc
+	}
+}
+
+// OptionDefaultOrder sets the order used by RetrieveMany operations on
+// the given identity when the context does not specify one. It takes
+// precedence over the default order of the model, if any.
+func OptionDefaultOrder(identity elemental.Identity, order ...string) Option {
+	return func(c *config) {
+		if c.defaultOrders == nil {
+			c.defaultOrders = map[elemental.Identity][]string{}
+		}
+		c.defaultOrders[identity] = order
+	}
+}
+
+// OptionFieldMapping sets the mapping between the names of the attributes
+// of the given identity, as used in filters, fields selections and orderings,
+// and the names of the fields where they are stored, when they differ.
+// For instance, {"createdAt": "created_at"} makes a filter on createdAt
+// query the created_at field. The attribute names are case insensitive.
+// Note that the objects are still decoded according to their bson tags.
+func OptionFieldMapping(identity elemental.Identity, mapping map[string]string) Option {
+	return func(c *config) {
+		if c.fieldMappings == nil {
+			c.fieldMappings = map[elemental.Identity]map[string]string{}
+		}
+		c.fieldMappings[identity] = makeFieldMapping(mapping)
+	}
+}
+
 // OptionExplain allows to tell manipmongo to explain the query before it
 // runs it for the given identities on the given operations.
 // For example, consider passing:
@@ -148,7 +201,126 @@ func OptionExplain(explain map[elemental.Identity]map[elemental.Operation]struct
 	}
 }
 
-const opaqueKeyUpsert = "manipmongo.upsert"
+// OptionTimestamps tells the manipulator to automatically set the
+// creation and update times of the objects implementing
+// manipulate.Timestampable. On Create, both times are set. On Update, only
+// the update time is set. Note that when using ContextOptionUpdateFields,
+// the update time field must be part of the given fields to be written.
+// Objects not implementing manipulate.Timestampable are left untouched.
+func OptionTimestamps(enabled bool) Option {
+	return func(c *config) {
+		c.timestamps = enabled
+	}
+}
+
+// OptionFilterCacheSize enables caching of the compiled filters, holding
+// up to the given number of entries. When the cache is full, the least
+// recently used filters are evicted. This avoids compiling the same filters
+// again and again for frequent identical queries. Filters containing
+// relative times are never cached. If size is 0 (the default), there is
+// no cache.
+func OptionFilterCacheSize(size int) Option {
+	return func(c *config) {
+		c.filterCacheSize = size
+	}
+}
+
+// OptionSessionLeakDetection enables the detection of the sessions that
+// are garbage collected without being closed. When such a session is found,
+// a warning containing the stack where it was created is logged using the global
+// zap logger. The sessions returned by GetDatabase and GetMongoSession are
+// tracked as well. This has a cost and should only be used during development.
+func OptionSessionLeakDetection(enabled bool) Option {
+	return func(c *config) {
+		c.detectLeaks = enabled
+	}
+}
+
+// OptionUUIDIdentifiers tells the manipulator to generate UUIDs
+// instead of ObjectIds as identifiers of the created objects.
+// They are stored as strings in the _id field. The retrieval
+// of existing objects is not affected, so both kinds of identifiers
+// can coexist in the same collection.
+func OptionUUIDIdentifiers(enabled bool) Option {
+	return func(c *config) {
+		c.uuidIdentifiers = enabled
+	}
+}
+
+// OptionIdentifierGenerator sets the manipulate.IdentifierGenerator
+// used to generate the identifiers of the created objects. If the
+// generated identifier is a valid ObjectId, it will be stored as such,
+// otherwise it is stored as a string. It takes precedence
+// over OptionUUIDIdentifiers.
+func OptionIdentifierGenerator(generator manipulate.IdentifierGenerator) Option {
+	return func(c *config) {
+		c.idGenerator = generator
+	}
+}
+
+// OptionPreserveIdentifiers tells the manipulator to keep the identifier
+// of the objects passed to Create when it is already set, instead of
+// replacing it by a generated one. This is useful to import data with
+// known identifiers. If the identifier is a valid ObjectId, it will be
+// stored as such, otherwise it is stored as a string.
+func OptionPreserveIdentifiers(enabled bool) Option {
+	return func(c *config) {
+		c.preserveIdentifiers = enabled
+	}
+}
+
+// OptionValidation tells the manipulator to validate the objects
+// implementing elemental.Validatable before creating or updating them.
+// If the validation fails, a manipulate.ErrValidation containing the
+// detailed errors is returned and nothing is written.
+func OptionValidation(enabled bool) Option {
+	return func(c *config) {
+		c.validate = enabled
+	}
+}
+
+// OptionStrictCollections tells the manipulator to check that the collection
+// of the targeted identity exists before retrieving, counting, updating or
+// deleting objects, and to return a manipulate.ErrCannotExecuteQuery if it does not.
+// Without it, MongoDB silently returns nothing for missing collections, which
+// can hide mistakes like a typo in an identity name.
+// Existing collections are remembered so the check is only done once per collection.
+func OptionStrictCollections(enabled bool) Option {
+	return func(c *config) {
+		c.strictCollections = enabled
+	}
+}
+
+const (
+	opaqueKeyUpsert          = "manipmongo.upsert"
+	opaqueKeyCountEstimated  = "manipmongo.count.estimated"
+	opaqueKeyUpdateFields    = "manipmongo.update.fields"
+	opaqueKeyDatabase        = "manipmongo.database"
+	opaqueKeyDeduplicate     = "manipmongo.retrievemany.deduplicate"
+	opaqueKeyMaxTime         = "manipmongo.maxtime"
+	opaqueKeyDeleteBatch     = "manipmongo.deletemany.batch"
+	opaqueKeyCollation       = "manipmongo.collation"
+	opaqueKeyReturnUpdated   = "manipmongo.update.returnupdated"
+	opaqueKeyAfterValue      = "manipmongo.retrievemany.aftervalue"
+	opaqueKeyCountTotal      = "manipmongo.retrievemany.counttotal"
+	opaqueKeyAllowDiskUse    = "manipmongo.allowdiskuse"
+	opaqueKeyNoCursorTimeout = "manipmongo.nocursortimeout"
+	opaqueKeyAppendUnique    = "manipmongo.appendtoarray.unique"
+	opaqueKeyPartialRetrieve = "manipmongo.retrievemultiple.partial"
+)
+
+// The known parameters that can be passed to manipmongo using
+// manipulate.ContextOptionParameters. Their values must be parsable
+// booleans. Other parameters are ignored.
+const (
+	// ParameterAllowDiskUse allows the server to use temporary
+	// files for the aggregations exceeding the memory limit.
+	ParameterAllowDiskUse = "allowDiskUse"
+
+	// ParameterNoCursorTimeout prevents the server from closing
+	// the idle cursors after the default cursor timeout.
+	ParameterNoCursorTimeout = "noCursorTimeout"
+)
 
 type opaquer interface {
 	Opaque() map[string]interface{}
@@ -178,3 +350,183 @@ func ContextOptionUpsert(operations bson.M) manipulate.ContextOption {
 		c.(opaquer).Opaque()[opaqueKeyUpsert] = operations
 	}
 }
+
+// ContextOptionEstimatedCount tells the manipulator that an approximated
+// result is acceptable for a Count operation. When there is no filter to apply,
+// the count will be computed from the collection metadata instead of
+// running a full query, which is much faster on large collections. The
+// maximum execution time of the context still applies.
+// If there is a filter, this option has no effect and the count is exact.
+func ContextOptionEstimatedCount() manipulate.ContextOption {
+
+	return func(c manipulate.Context) {
+		c.(opaquer).Opaque()[opaqueKeyCountEstimated] = true
+	}
+}
+
+// ContextOptionUpdateFields tells the manipulator to only update the
+// given attributes of the object during an Update operation. The other
+// fields of the stored document are left untouched. If a given attribute
+// is not present in the encoded object (i.e. it is empty and marked
+// omitempty), it will be removed from the stored document.
+// The identifier can never be updated and is ignored.
+func ContextOptionUpdateFields(fields ...string) manipulate.ContextOption {
+
+	return func(c manipulate.Context) {
+		c.(opaquer).Opaque()[opaqueKeyUpdateFields] = fields
+	}
+}
+
+// ContextOptionDatabase tells the manipulator to run the operation
+// against the given database instead of the one the manipulator has
+// been created with. The underlying session is shared, so the database
+// must be reachable with the manipulator's credentials.
+func ContextOptionDatabase(db string) manipulate.ContextOption {
+
+	return func(c manipulate.Context) {
+		c.(opaquer).Opaque()[opaqueKeyDatabase] = db
+	}
+}
+
+// ContextOptionDeduplicate tells the manipulator to only return one
+// object per distinct value of the given key during a RetrieveMany operation.
+// The kept object is the first one according to the given pick ordering.
+// For instance, ContextOptionDeduplicate("name", "-createTime") returns the
+// latest object for each name. This is done server side using an aggregation.
+func ContextOptionDeduplicate(key string, pick ...string) manipulate.ContextOption {
+
+	if key == "" {
+		panic("deduplication key must not be empty")
+	}
+
+	return func(c manipulate.Context) {
+		c.(opaquer).Opaque()[opaqueKeyDeduplicate] = deduplication{key: key, pick: pick}
+	}
+}
+
+// ContextOptionMaxExecutionTime sets the maximum time the mongo server
+// can spend running the queries of Retrieve, RetrieveMany and Count operations.
+// When it is reached, the server aborts the query and frees its resources, even if
+// the client already gave up. It is capped by the deadline of the context, if any.
+func ContextOptionMaxExecutionTime(d time.Duration) manipulate.ContextOption {
+
+	return func(c manipulate.Context) {
+		c.(opaquer).Opaque()[opaqueKeyMaxTime] = d
+	}
+}
+
+// ContextOptionDeleteBatch tells the manipulator to remove the documents
+// matching the filter of a DeleteMany operation by batches of the given size,
+// waiting for the given pause between each batch, until no document matches.
+// This avoids holding locks and spiking the replication lag when removing
+// a large number of documents. The total number of removed documents is set
+// as the count of the context.
+func ContextOptionDeleteBatch(size int, pause time.Duration) manipulate.ContextOption {
+
+	if size <= 0 {
+		panic("delete batch size must be greater than 0")
+	}
+
+	return func(c manipulate.Context) {
+		c.(opaquer).Opaque()[opaqueKeyDeleteBatch] = deleteBatch{size: size, pause: pause}
+	}
+}
+
+// ContextOptionCollation sets the collation to use to compare strings
+// when filtering and sorting the documents of RetrieveMany and Count
+// operations. For instance, a collation with a locale and a strength of 2
+// makes the comparisons case insensitive. Other backends ignore this option.
+func ContextOptionCollation(collation mgo.Collation) manipulate.ContextOption {
+
+	return func(c manipulate.Context) {
+		c.(opaquer).Opaque()[opaqueKeyCollation] = collation
+	}
+}
+
+// ContextOptionReturnUpdated tells the manipulator to populate the object
+// given to an Update operation with the document as it is stored in database
+// once updated, including the fields that are not part of the update.
+// This uses a findAndModify command instead of a regular update.
+func ContextOptionReturnUpdated() manipulate.ContextOption {
+
+	return func(c manipulate.Context) {
+		c.(opaquer).Opaque()[opaqueKeyReturnUpdated] = true
+	}
+}
+
+// ContextOptionAfterValue tells the manipulator to only return the documents
+// that come after the given value of the ordering field, and after the given
+// identifier for the documents having the same value, during a RetrieveMany
+// operation. It gives a constant cost pagination where Page needs to skip all
+// the previous documents: to fetch the next page, pass the value of the
+// ordering field and the identifier of the last object of the current page.
+//
+// The order of the context must have at most one field, and the documents
+// are also ordered by _id to break ties. There should be an index on the
+// ordering field followed by _id. If the given identifier is empty,
+// only the value is used. It cannot be used with ContextOptionAfter.
+func ContextOptionAfterValue(value interface{}, id string) manipulate.ContextOption {
+
+	return func(c manipulate.Context) {
+		c.(opaquer).Opaque()[opaqueKeyAfterValue] = afterValue{value: value, id: id}
+	}
+}
+
+// ContextOptionCountTotal tells the manipulator to count all the documents
+// matching the filter of a RetrieveMany operation, regardless of the pagination,
+// using an additional query in the same session. The count is set in the
+// context, and manipulate.PaginationFromContext can be used to get the
+// pagination metadata.
+func ContextOptionCountTotal() manipulate.ContextOption {
+
+	return func(c manipulate.Context) {
+		c.(opaquer).Opaque()[opaqueKeyCountTotal] = true
+	}
+}
+
+// ContextOptionAllowDiskUse sets if the server can use temporary files
+// when sorting the results of a RetrieveMany operation or running its
+// deduplication pipeline exceeds the memory limit of the server. This is
+// useful for occasional large queries that cannot use an index to sort.
+// It takes precedence over the ParameterAllowDiskUse parameter.
+func ContextOptionAllowDiskUse(allow bool) manipulate.ContextOption {
+
+	return func(c manipulate.Context) {
+		c.(opaquer).Opaque()[opaqueKeyAllowDiskUse] = allow
+	}
+}
+
+// ContextOptionNoCursorTimeout sets if the server must keep the cursor of a
+// RetrieveMany operation open when it is idle for longer than the default
+// cursor timeout of 10 minutes. This is useful for slow consumers, like
+// manipulate.Iter with large blocks. Beware that such a cursor is only closed
+// when it is exhausted or explicitly killed: an interrupted iteration leaks it
+// on the server. It takes precedence over the ParameterNoCursorTimeout parameter.
+func ContextOptionNoCursorTimeout(enabled bool) manipulate.ContextOption {
+
+	return func(c manipulate.Context) {
+		c.(opaquer).Opaque()[opaqueKeyNoCursorTimeout] = enabled
+	}
+}
+
+// ContextOptionAppendUnique tells AppendToArray to only append
+// the values that are not already in the array, using $addToSet
+// instead of $push.
+func ContextOptionAppendUnique() manipulate.ContextOption {
+
+	return func(c manipulate.Context) {
+		c.(opaquer).Opaque()[opaqueKeyAppendUnique] = true
+	}
+}
+
+// ContextOptionPartialRetrieve tells RetrieveMultiple to return a
+// manipulate.ErrPartialRetrieve holding an ErrObjectNotFound for each
+// object that cannot be found, instead of a single ErrObjectNotFound,
+// as manipulate.RetrieveConcurrently does. The objects that are found
+// are populated in both cases.
+func ContextOptionPartialRetrieve() manipulate.ContextOption {
+
+	return func(c manipulate.Context) {
+		c.(opaquer).Opaque()[opaqueKeyPartialRetrieve] = true
+	}
+}
@@ -17,6 +17,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/globalsign/mgo"
 	"github.com/globalsign/mgo/bson"
 	. "github.com/smartystreets/goconvey/convey"
 	"go.aporeto.io/elemental"
@@ -132,12 +133,79 @@ func Test_Options(t *testing.T) {
 		So(c.attributeEncrypter, ShouldEqual, enc)
 	})
 
+	Convey("Calling OptionAttributeEncoder should work", t, func() {
+		enc := &fakeAttributeEncoder{}
+		c := newConfig()
+		OptionAttributeEncoder(elemental.MakeIdentity("a", "a"), enc)(c)
+		So(c.attributeEncoders[elemental.MakeIdentity("a", "a")], ShouldEqual, enc)
+	})
+
+	Convey("Calling OptionDefaultOrder should work", t, func() {
+		c := newConfig()
+		OptionDefaultOrder(elemental.MakeIdentity("a", "a"), "-date", "name")(c)
+		So(c.defaultOrders, ShouldResemble, map[elemental.Identity][]string{elemental.MakeIdentity("a", "a"): {"-date", "name"}})
+	})
+
+	Convey("Calling OptionFieldMapping should work", t, func() {
+		c := newConfig()
+		OptionFieldMapping(elemental.MakeIdentity("a", "a"), map[string]string{"Name": "n"})(c)
+		So(c.fieldMappings, ShouldResemble, map[elemental.Identity]map[string]string{elemental.MakeIdentity("a", "a"): {"name": "n"}})
+	})
+
 	Convey("Calling OptionExplain should work", t, func() {
 		m := map[elemental.Identity]map[elemental.Operation]struct{}{}
 		c := newConfig()
 		OptionExplain(m)(c)
 		So(c.explain, ShouldEqual, m)
 	})
+
+	Convey("Calling OptionTimestamps should work", t, func() {
+		c := newConfig()
+		OptionTimestamps(true)(c)
+		So(c.timestamps, ShouldBeTrue)
+	})
+
+	Convey("Calling OptionFilterCacheSize should work", t, func() {
+		c := newConfig()
+		OptionFilterCacheSize(42)(c)
+		So(c.filterCacheSize, ShouldEqual, 42)
+	})
+
+	Convey("Calling OptionSessionLeakDetection should work", t, func() {
+		c := newConfig()
+		OptionSessionLeakDetection(true)(c)
+		So(c.detectLeaks, ShouldBeTrue)
+	})
+
+	Convey("Calling OptionUUIDIdentifiers should work", t, func() {
+		c := newConfig()
+		OptionUUIDIdentifiers(true)(c)
+		So(c.uuidIdentifiers, ShouldBeTrue)
+	})
+
+	Convey("Calling OptionIdentifierGenerator should work", t, func() {
+		c := newConfig()
+		OptionIdentifierGenerator(func(elemental.Identifiable) string { return "id" })(c)
+		So(c.idGenerator(nil), ShouldEqual, "id")
+	})
+
+	Convey("Calling OptionPreserveIdentifiers should work", t, func() {
+		c := newConfig()
+		OptionPreserveIdentifiers(true)(c)
+		So(c.preserveIdentifiers, ShouldBeTrue)
+	})
+
+	Convey("Calling OptionValidation should work", t, func() {
+		c := newConfig()
+		OptionValidation(true)(c)
+		So(c.validate, ShouldBeTrue)
+	})
+
+	Convey("Calling OptionStrictCollections should work", t, func() {
+		c := newConfig()
+		OptionStrictCollections(true)(c)
+		So(c.strictCollections, ShouldBeTrue)
+	})
 }
 
 func Test_ContextOptions(t *testing.T) {
@@ -151,6 +219,98 @@ func Test_ContextOptions(t *testing.T) {
 		So(mctx.(opaquer).Opaque()[opaqueKeyUpsert], ShouldEqual, b)
 	})
 
+	Convey("Calling ContextOptionEstimatedCount should work", t, func() {
+		mctx := manipulate.NewContext(context.Background())
+		ContextOptionEstimatedCount()(mctx)
+		So(mctx.(opaquer).Opaque()[opaqueKeyCountEstimated], ShouldEqual, true)
+	})
+
+	Convey("Calling ContextOptionUpdateFields should work", t, func() {
+		mctx := manipulate.NewContext(context.Background())
+		ContextOptionUpdateFields("a", "b")(mctx)
+		So(mctx.(opaquer).Opaque()[opaqueKeyUpdateFields], ShouldResemble, []string{"a", "b"})
+	})
+
+	Convey("Calling ContextOptionDatabase should work", t, func() {
+		mctx := manipulate.NewContext(context.Background())
+		ContextOptionDatabase("otherdb")(mctx)
+		So(mctx.(opaquer).Opaque()[opaqueKeyDatabase], ShouldEqual, "otherdb")
+	})
+
+	Convey("Calling ContextOptionDeduplicate should work", t, func() {
+		mctx := manipulate.NewContext(context.Background())
+		ContextOptionDeduplicate("name", "-date")(mctx)
+		So(mctx.(opaquer).Opaque()[opaqueKeyDeduplicate], ShouldResemble, deduplication{key: "name", pick: []string{"-date"}})
+	})
+
+	Convey("Calling ContextOptionDeduplicate with an empty key should panic", t, func() {
+		So(func() { ContextOptionDeduplicate("") }, ShouldPanicWith, "deduplication key must not be empty")
+	})
+
+	Convey("Calling ContextOptionMaxExecutionTime should work", t, func() {
+		mctx := manipulate.NewContext(context.Background())
+		ContextOptionMaxExecutionTime(time.Second)(mctx)
+		So(mctx.(opaquer).Opaque()[opaqueKeyMaxTime], ShouldEqual, time.Second)
+	})
+
+	Convey("Calling ContextOptionDeleteBatch should work", t, func() {
+		mctx := manipulate.NewContext(context.Background())
+		ContextOptionDeleteBatch(1000, time.Second)(mctx)
+		So(mctx.(opaquer).Opaque()[opaqueKeyDeleteBatch], ShouldResemble, deleteBatch{size: 1000, pause: time.Second})
+	})
+
+	Convey("Calling ContextOptionDeleteBatch with an invalid size should panic", t, func() {
+		So(func() { ContextOptionDeleteBatch(0, 0) }, ShouldPanicWith, "delete batch size must be greater than 0")
+	})
+
+	Convey("Calling ContextOptionCollation should work", t, func() {
+		mctx := manipulate.NewContext(context.Background())
+		ContextOptionCollation(mgo.Collation{Locale: "en", Strength: 2})(mctx)
+		So(mctx.(opaquer).Opaque()[opaqueKeyCollation], ShouldResemble, mgo.Collation{Locale: "en", Strength: 2})
+	})
+
+	Convey("Calling ContextOptionReturnUpdated should work", t, func() {
+		mctx := manipulate.NewContext(context.Background())
+		ContextOptionReturnUpdated()(mctx)
+		So(mctx.(opaquer).Opaque()[opaqueKeyReturnUpdated], ShouldEqual, true)
+	})
+
+	Convey("Calling ContextOptionAfterValue should work", t, func() {
+		mctx := manipulate.NewContext(context.Background())
+		ContextOptionAfterValue("a", "id")(mctx)
+		So(mctx.(opaquer).Opaque()[opaqueKeyAfterValue], ShouldResemble, afterValue{value: "a", id: "id"})
+	})
+
+	Convey("Calling ContextOptionCountTotal should work", t, func() {
+		mctx := manipulate.NewContext(context.Background())
+		ContextOptionCountTotal()(mctx)
+		So(mctx.(opaquer).Opaque()[opaqueKeyCountTotal], ShouldEqual, true)
+	})
+
+	Convey("Calling ContextOptionAllowDiskUse should work", t, func() {
+		mctx := manipulate.NewContext(context.Background())
+		ContextOptionAllowDiskUse(true)(mctx)
+		So(mctx.(opaquer).Opaque()[opaqueKeyAllowDiskUse], ShouldEqual, true)
+	})
+
+	Convey("Calling ContextOptionNoCursorTimeout should work", t, func() {
+		mctx := manipulate.NewContext(context.Background())
+		ContextOptionNoCursorTimeout(true)(mctx)
+		So(mctx.(opaquer).Opaque()[opaqueKeyNoCursorTimeout], ShouldEqual, true)
+	})
+
+	Convey("Calling ContextOptionAppendUnique should work", t, func() {
+		mctx := manipulate.NewContext(context.Background())
+		ContextOptionAppendUnique()(mctx)
+		So(mctx.(opaquer).Opaque()[opaqueKeyAppendUnique], ShouldEqual, true)
+	})
+
+	Convey("Calling ContextOptionPartialRetrieve should work", t, func() {
+		mctx := manipulate.NewContext(context.Background())
+		ContextOptionPartialRetrieve()(mctx)
+		So(mctx.(opaquer).Opaque()[opaqueKeyPartialRetrieve], ShouldEqual, true)
+	})
+
 	Convey("Calling ContextOptionUpsert with $set should panic", t, func() {
 		b := bson.M{"$set": true}
 		So(func() { ContextOptionUpsert(b)(nil) }, ShouldPanicWith, "cannot use $set in upsert operations")
@@ -0,0 +1,135 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manipmongo
+
+import (
+	"context"
+	"time"
+
+	"go.aporeto.io/elemental"
+	"go.aporeto.io/manipulate"
+)
+
+// The fields where the queue records the state of the queued documents.
+const (
+	queueStatusFieldName    = "_queuestatus"
+	queueClaimedAtFieldName = "_queueclaimedat"
+)
+
+const (
+	queueStatusPending    = "pending"
+	queueStatusProcessing = "processing"
+)
+
+// A Queue uses the documents of a collection as the items of a job queue,
+// providing at-least-once processing semantics.
+//
+// An item is enqueued by creating it, and claimed atomically by Dequeue. Once
+// processed, it must be removed with Complete, or made available again with
+// Requeue. If an item is neither completed nor requeued before the visibility
+// timeout expires, for instance because its consumer crashed, it can be claimed
+// again. The items are claimed in the order of their identifiers, so the
+// identifiers must be ordered by creation time, like the default ObjectIds.
+//
+// The state of the items is stored in additional fields of the documents,
+// that are not part of the model.
+type Queue struct {
+	manipulator manipulate.Manipulator
+	timeout     time.Duration
+}
+
+// NewQueue returns a new Queue using the given mongo manipulator.
+// The given visibility timeout is the time after which a claimed
+// item that has not been completed can be claimed again.
+func NewQueue(manipulator manipulate.Manipulator, timeout time.Duration) *Queue {
+
+	if _, ok := manipulator.(*mongoManipulator); !ok {
+		panic("you can only pass a mongo manipulator to NewQueue")
+	}
+
+	if timeout <= 0 {
+		panic("timeout must be greater than 0")
+	}
+
+	return &Queue{
+		manipulator: manipulator,
+		timeout:     timeout,
+	}
+}
+
+// Enqueue adds the given object to the queue.
+func (q *Queue) Enqueue(mctx manipulate.Context, object elemental.Identifiable) error {
+	return q.manipulator.Create(mctx, object)
+}
+
+// Dequeue claims the oldest available item matching the given filter,
+// that can be nil, and populates the given object with it. The identity of
+// the given object decides the queue to use. If no item is available, an
+// ErrObjectNotFound is returned.
+func (q *Queue) Dequeue(mctx manipulate.Context, filter *elemental.Filter, object elemental.Identifiable) error {
+
+	available := elemental.NewFilterComposer().Or(
+		elemental.NewFilterComposer().WithKey(queueStatusFieldName).NotExists().Done(),
+		elemental.NewFilterComposer().WithKey(queueStatusFieldName).Equals(queueStatusPending).Done(),
+		elemental.NewFilterComposer().
+			WithKey(queueStatusFieldName).Equals(queueStatusProcessing).
+			WithKey(queueClaimedAtFieldName).LesserThan(-q.timeout).
+			Done(),
+	).Done()
+
+	if filter != nil {
+		available = elemental.NewFilterComposer().And(filter, available).Done()
+	}
+
+	if mctx == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), defaultGlobalContextTimeout)
+		defer cancel()
+		mctx = manipulate.NewContext(ctx)
+	}
+
+	object.SetIdentifier("")
+
+	return UpdateAndRetrieve(
+		q.manipulator,
+		mctx.Derive(manipulate.ContextOptionFilter(available), manipulate.ContextOptionOrder("_id")),
+		object,
+		map[string]interface{}{
+			queueStatusFieldName:    queueStatusProcessing,
+			queueClaimedAtFieldName: time.Now(),
+		},
+	)
+}
+
+// Complete removes the given claimed item from the queue.
+func (q *Queue) Complete(mctx manipulate.Context, object elemental.Identifiable) error {
+	return q.manipulator.Delete(mctx, object)
+}
+
+// Requeue makes the given claimed item available again.
+func (q *Queue) Requeue(mctx manipulate.Context, object elemental.Identifiable) error {
+
+	if mctx == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), defaultGlobalContextTimeout)
+		defer cancel()
+		mctx = manipulate.NewContext(ctx)
+	}
+
+	return UpdateAndRetrieve(
+		q.manipulator,
+		mctx.Derive(manipulate.ContextOptionFilter(nil)),
+		object,
+		map[string]interface{}{
+			queueStatusFieldName:    queueStatusPending,
+			queueClaimedAtFieldName: nil,
+		},
+	)
+}
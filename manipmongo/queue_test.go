@@ -0,0 +1,55 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manipmongo
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.aporeto.io/manipulate/maniptest"
+)
+
+func TestNewQueue(t *testing.T) {
+
+	Convey("Given I a test manipulator", t, func() {
+
+		m := maniptest.NewTestManipulator()
+
+		Convey("When I call NewQueue", func() {
+			Convey("Then it should panic", func() {
+				So(func() { _ = NewQueue(m, time.Second) }, ShouldPanicWith, "you can only pass a mongo manipulator to NewQueue")
+			})
+		})
+	})
+
+	Convey("Given I have a mongo manipulator", t, func() {
+
+		m := &mongoManipulator{}
+
+		Convey("When I call NewQueue with an invalid timeout", func() {
+			Convey("Then it should panic", func() {
+				So(func() { _ = NewQueue(m, 0) }, ShouldPanicWith, "timeout must be greater than 0")
+			})
+		})
+
+		Convey("When I call NewQueue with a valid timeout", func() {
+
+			q := NewQueue(m, time.Minute)
+
+			Convey("Then the queue should be correct", func() {
+				So(q.manipulator, ShouldEqual, m)
+				So(q.timeout, ShouldEqual, time.Minute)
+			})
+		})
+	})
+}
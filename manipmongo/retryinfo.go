@@ -14,6 +14,7 @@ package manipmongo
 import (
 	"go.aporeto.io/elemental"
 	"go.aporeto.io/manipulate"
+	"go.aporeto.io/manipulate/internal/stats"
 )
 
 // A RetryInfo contains information about a retry,
@@ -26,6 +27,7 @@ type RetryInfo struct {
 	mctx manipulate.Context
 
 	defaultRetryFunc manipulate.RetryFunc
+	stats            *stats.Counters
 }
 
 // Try returns the try number.
@@ -0,0 +1,176 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manipmongo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"go.aporeto.io/elemental"
+	"go.aporeto.io/manipulate"
+)
+
+const (
+	tailAwaitTimeout = time.Second
+	tailRestartDelay = time.Second
+)
+
+// TailFunc opens a tailable cursor on the capped collection storing the
+// objects of the given identity and calls tailFunc for every document
+// inserted in it, in insertion order. The object passed to tailFunc
+// is created using the given elemental.ModelManager.
+//
+// The filter of the given manipulate.Context is applied on the documents.
+// If the cursor dies (for instance because the collection was empty or
+// because the cursor fell behind the capped collection), it is
+// transparently reopened after the last document that has been seen.
+//
+// TailFunc only returns when the context of the given manipulate.Context
+// is canceled, in which case it returns nil, when tailFunc returns an error, or
+// when the query fails for any other reason.
+// The collection must have been created with CreateCappedCollection.
+func TailFunc(
+	manipulator manipulate.Manipulator,
+	mctx manipulate.Context,
+	manager elemental.ModelManager,
+	identity elemental.Identity,
+	tailFunc func(elemental.Identifiable) error,
+) error {
+
+	m, ok := manipulator.(*mongoManipulator)
+	if !ok {
+		panic("you can only pass a mongo manipulator to TailFunc")
+	}
+
+	if manager == nil {
+		panic("manager must not be nil")
+	}
+
+	if tailFunc == nil {
+		panic("tailFunc must not be nil")
+	}
+
+	if mctx == nil {
+		mctx = manipulate.NewContext(context.Background())
+	}
+
+	ctx := mctx.Context()
+
+	c, close := m.makeSession(mctx, identity)
+	defer close()
+
+	filter := bson.D{}
+	if f := mctx.Filter(); f != nil {
+		filter = m.compileFilter(identity, f)
+	}
+
+	var lastID interface{}
+
+	for {
+
+		query := filter
+		if lastID != nil {
+			query = bson.D{{Name: "$and", Value: []bson.D{{{Name: "_id", Value: bson.M{"$gt": lastID}}}, filter}}}
+		}
+
+		iter := c.Find(query).Sort("$natural").Tail(tailAwaitTimeout)
+
+		if err := m.tailIter(ctx, iter, manager, identity, &lastID, tailFunc); err != nil {
+			return err
+		}
+
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		// The cursor is dead. We wait a bit before
+		// reopening it to avoid spinning on an empty collection.
+		select {
+		case <-time.After(tailRestartDelay):
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// tailIter consumes the given tailable iterator until the cursor dies
+// or the context is canceled. It updates lastID with the identifier of
+// the last seen document.
+func (m *mongoManipulator) tailIter(
+	ctx context.Context,
+	iter *mgo.Iter,
+	manager elemental.ModelManager,
+	identity elemental.Identity,
+	lastID *interface{},
+	tailFunc func(elemental.Identifiable) error,
+) error {
+
+	defer iter.Close() // nolint: errcheck
+
+	for {
+
+		raw := bson.Raw{}
+
+		if !iter.Next(&raw) {
+
+			if err := iter.Err(); err != nil && err != mgo.ErrCursor {
+				return HandleQueryError(err)
+			}
+
+			// If we only hit the await timeout, the cursor is still
+			// alive and we can keep waiting for new documents.
+			if iter.Timeout() && ctx.Err() == nil {
+				continue
+			}
+
+			return nil
+		}
+
+		doc := struct {
+			ID interface{} `bson:"_id"`
+		}{}
+		if err := raw.Unmarshal(&doc); err != nil {
+			return manipulate.NewErrCannotExecuteQuery(fmt.Sprintf("tail: unable to decode document: %s", err))
+		}
+		*lastID = doc.ID
+
+		obj := manager.Identifiable(identity)
+		if err := raw.Unmarshal(obj); err != nil {
+			return manipulate.NewErrCannotExecuteQuery(fmt.Sprintf("tail: unable to decode object: %s", err))
+		}
+
+		if encoder := m.attributeEncoder(identity); encoder != nil {
+			if err := encoder.DecodeAttributes(obj); err != nil {
+				return manipulate.NewErrCannotBuildQuery(fmt.Sprintf("tail: unable to decode attributes: %s", err))
+			}
+		}
+
+		if m.attributeEncrypter != nil {
+			if a, ok := obj.(elemental.AttributeEncryptable); ok {
+				if err := a.DecryptAttributes(m.attributeEncrypter); err != nil {
+					return manipulate.NewErrCannotBuildQuery(fmt.Sprintf("tail: unable to decrypt attributes: %s", err))
+				}
+			}
+		}
+
+		if err := tailFunc(obj); err != nil {
+			return fmt.Errorf("tail function returned an error: %s", err)
+		}
+
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+}
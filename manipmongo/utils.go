@@ -16,10 +16,15 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"reflect"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/globalsign/mgo"
 	"github.com/globalsign/mgo/bson"
+	"github.com/mitchellh/copystructure"
 	"go.aporeto.io/elemental"
 	"go.aporeto.io/manipulate"
 	"go.aporeto.io/manipulate/internal/objectid"
@@ -96,6 +101,48 @@ func prepareNextFilter(collection *mgo.Collection, orderingField string, next st
 	}, nil
 }
 
+// makeAfterValueFilter returns the filter matching the documents that come after
+// the given afterValue according to the given mgo ordering field. When an identifier
+// is given, the documents with the same value are matched if their _id comes after it.
+func makeAfterValueFilter(orderingField string, after afterValue) bson.D {
+
+	comp := "$gt"
+	if strings.HasPrefix(orderingField, "-") {
+		orderingField = strings.TrimPrefix(orderingField, "-")
+		comp = "$lt"
+	}
+
+	if orderingField == "" {
+		orderingField = "_id"
+	}
+
+	filter := bson.D{{Name: orderingField, Value: bson.D{{Name: comp, Value: after.value}}}}
+
+	if after.id == "" || orderingField == "_id" {
+		return filter
+	}
+
+	var id interface{}
+	if oid, ok := objectid.Parse(after.id); ok {
+		id = oid
+	} else {
+		id = after.id
+	}
+
+	return bson.D{
+		{
+			Name: "$or",
+			Value: []bson.D{
+				filter,
+				{
+					{Name: orderingField, Value: after.value},
+					{Name: "_id", Value: bson.D{{Name: comp, Value: id}}},
+				},
+			},
+		},
+	}
+}
+
 // HandleQueryError handles the provided upstream error returned by Mongo by returning a corresponding manipulate error type.
 func HandleQueryError(err error) error {
 
@@ -223,6 +270,428 @@ func makeFieldsSelector(fields []string) bson.M {
 	return sels
 }
 
+// stampActor returns the given document as a bson.M
+// with the actor field set to the given actor.
+func stampActor(doc interface{}, actor string) (bson.M, error) {
+
+	m, ok := doc.(bson.M)
+	if !ok {
+
+		data, err := bson.Marshal(doc)
+		if err != nil {
+			return nil, err
+		}
+
+		m = bson.M{}
+		if err := bson.Unmarshal(data, &m); err != nil {
+			return nil, err
+		}
+	}
+
+	m[actorFieldName] = actor
+
+	return m, nil
+}
+
+// updateOperators are the update operators accepted by makeRawUpdate.
+var updateOperators = map[string]struct{}{
+	"$set":         {},
+	"$unset":       {},
+	"$setOnInsert": {},
+	"$inc":         {},
+	"$mul":         {},
+	"$min":         {},
+	"$max":         {},
+	"$rename":      {},
+	"$currentDate": {},
+	"$push":        {},
+	"$addToSet":    {},
+	"$pull":        {},
+	"$pullAll":     {},
+	"$pop":         {},
+	"$bit":         {},
+}
+
+// makeRawUpdate checks that the given update document only contains
+// update operators applied to at least one field, and returns a copy
+// of it that records the given actor, if any.
+func makeRawUpdate(update map[string]interface{}, actor string) (bson.M, error) {
+
+	if len(update) == 0 {
+		return nil, fmt.Errorf("empty update document")
+	}
+
+	out := make(bson.M, len(update)+1)
+
+	for op, fields := range update {
+
+		if _, ok := updateOperators[op]; !ok {
+			return nil, fmt.Errorf("invalid update operator '%s'", op)
+		}
+
+		var n int
+		switch f := fields.(type) {
+		case bson.M:
+			n = len(f)
+		case map[string]interface{}:
+			n = len(f)
+		case bson.D:
+			n = len(f)
+		default:
+			return nil, fmt.Errorf("fields of update operator '%s' must be a document", op)
+		}
+
+		if n == 0 {
+			return nil, fmt.Errorf("no field given to update operator '%s'", op)
+		}
+
+		out[op] = fields
+	}
+
+	if actor == "" {
+		return out, nil
+	}
+
+	set := bson.M{}
+	switch f := out["$set"].(type) {
+	case bson.M:
+		for k, v := range f {
+			set[k] = v
+		}
+	case map[string]interface{}:
+		for k, v := range f {
+			set[k] = v
+		}
+	case bson.D:
+		for _, e := range f {
+			set[e.Name] = e.Value
+		}
+	}
+	set[actorFieldName] = actor
+	out["$set"] = set
+
+	return out, nil
+}
+
+// creationTimeFieldsCache holds the creation time fields by object type.
+var creationTimeFieldsCache sync.Map
+
+// creationTimeFields returns the names of the fields of the documents of the
+// type of the given object that hold their creation time. They are found once
+// per type by comparing the documents of a new object of this type marshaled
+// with two different creation times. The given object is left untouched.
+func creationTimeFields(object manipulate.Timestampable) ([]string, error) {
+
+	typ := reflect.TypeOf(object)
+
+	if fields, ok := creationTimeFieldsCache.Load(typ); ok {
+		return fields.([]string), nil
+	}
+
+	if typ.Kind() != reflect.Ptr {
+		return nil, fmt.Errorf("%s is not a pointer", typ)
+	}
+
+	probe := reflect.New(typ.Elem()).Interface().(manipulate.Timestampable)
+
+	docs := [2]bson.M{}
+	for i, t := range []time.Time{{}, time.Now()} {
+
+		probe.SetCreatedAt(t)
+
+		data, err := bson.Marshal(probe)
+		if err != nil {
+			return nil, err
+		}
+
+		docs[i] = bson.M{}
+		if err := bson.Unmarshal(data, &docs[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	var fields []string
+	for k, v := range docs[1] {
+		if !reflect.DeepEqual(docs[0][k], v) {
+			fields = append(fields, k)
+		}
+	}
+
+	creationTimeFieldsCache.Store(typ, fields)
+
+	return fields, nil
+}
+
+func makePartialUpdate(object interface{}, fields []string) (bson.M, error) {
+
+	data, err := bson.Marshal(object)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := bson.M{}
+	if err := bson.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	set := bson.M{}
+	unset := bson.M{}
+
+	for _, f := range fields {
+
+		f = strings.ToLower(f)
+
+		if f == "" || f == "id" || f == "_id" {
+			continue
+		}
+
+		if v, ok := doc[f]; ok {
+			set[f] = v
+		} else {
+			unset[f] = 1
+		}
+	}
+
+	update := bson.M{}
+
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	if len(update) == 0 {
+		return nil, fmt.Errorf("no field to update")
+	}
+
+	return update, nil
+}
+
+// resetDefaultForZeroValues backports the default values of the
+// attributes of the given object that are empty. If fields is not empty,
+// only the given fields are considered as the other ones have not
+// been retrieved from the database.
+func resetDefaultForZeroValues(obj elemental.AttributeSpecifiable, fields []string) {
+
+	if len(fields) == 0 {
+		elemental.ResetDefaultForZeroValues(obj)
+		return
+	}
+
+	selected := make(map[string]struct{}, len(fields))
+	for _, f := range fields {
+		selected[strings.ToLower(strings.TrimPrefix(f, "-"))] = struct{}{}
+	}
+
+	v := reflect.Indirect(reflect.ValueOf(obj))
+	if v.Kind() != reflect.Struct {
+		return
+	}
+
+	for name, spec := range obj.AttributeSpecifications() {
+
+		if spec.DefaultValue == nil {
+			continue
+		}
+
+		if _, ok := selected[strings.ToLower(name)]; !ok {
+			continue
+		}
+
+		field := v.FieldByName(spec.ConvertedName)
+		if !field.IsValid() || !field.CanSet() || !field.IsZero() {
+			continue
+		}
+
+		dv, err := copystructure.Copy(spec.DefaultValue)
+		if err != nil {
+			continue
+		}
+
+		if rv := reflect.ValueOf(dv); rv.Type().ConvertibleTo(field.Type()) {
+			field.Set(rv.Convert(field.Type()))
+		}
+	}
+}
+
+// identifierString returns the string representation
+// of the given _id value.
+func identifierString(id interface{}) string {
+
+	switch tid := id.(type) {
+	case bson.ObjectId:
+		return tid.Hex()
+	case string:
+		return tid
+	default:
+		return fmt.Sprintf("%v", tid)
+	}
+}
+
+// deduplication holds the parameters of ContextOptionDeduplicate.
+type deduplication struct {
+	key  string
+	pick []string
+}
+
+// deleteBatch holds the parameters of ContextOptionDeleteBatch.
+type deleteBatch struct {
+	size  int
+	pause time.Duration
+}
+
+// validatePagination returns an error if the pagination
+// parameters of the given context are invalid.
+func validatePagination(mctx manipulate.Context) error {
+
+	if mctx.PageSize() < 0 {
+		return manipulate.NewErrCannotBuildQuery("invalid pagination: page size must not be negative")
+	}
+
+	if mctx.Page() < 0 {
+		return manipulate.NewErrCannotBuildQuery("invalid pagination: page must not be negative")
+	}
+
+	if mctx.Page() > 0 && mctx.PageSize() == 0 {
+		return manipulate.NewErrCannotBuildQuery("invalid pagination: page requires a page size")
+	}
+
+	if mctx.Limit() < 0 {
+		return manipulate.NewErrCannotBuildQuery("invalid pagination: limit must not be negative")
+	}
+
+	return nil
+}
+
+// replaceIdentifiables replaces the content of the given
+// dest, that must be a pointer to a slice, by the given objects.
+func replaceIdentifiables(dest elemental.Identifiables, objects elemental.IdentifiablesList) {
+
+	out := reflect.ValueOf(dest).Elem()
+	out.Set(reflect.MakeSlice(out.Type(), 0, len(objects)))
+
+	for _, o := range objects {
+		out.Set(reflect.Append(out, reflect.ValueOf(o)))
+	}
+}
+
+// afterValue holds the parameters of ContextOptionAfterValue.
+type afterValue struct {
+	value interface{}
+	id    string
+}
+
+// makeSortStage converts the given mgo ordering into a $sort document.
+func makeSortStage(order []string) bson.D {
+
+	sort := make(bson.D, 0, len(order))
+
+	for _, o := range order {
+		if strings.HasPrefix(o, "-") {
+			sort = append(sort, bson.DocElem{Name: strings.TrimPrefix(o, "-"), Value: -1})
+		} else {
+			sort = append(sort, bson.DocElem{Name: o, Value: 1})
+		}
+	}
+
+	return sort
+}
+
+// makeDeduplicatePipeline returns the aggregation pipeline that returns one document
+// per distinct value of the deduplication key among the ones matching the given filter.
+// The given order, skip, limit and fields selector are applied on the deduplicated documents.
+func makeDeduplicatePipeline(filter bson.D, dedup deduplication, order []string, skip int, limit int, sels bson.M) []bson.M {
+
+	pipeline := []bson.M{{"$match": filter}}
+
+	if pick := applyOrdering(dedup.pick); len(pick) > 0 {
+		pipeline = append(pipeline, bson.M{"$sort": makeSortStage(pick)})
+	}
+
+	pipeline = append(
+		pipeline,
+		bson.M{"$group": bson.M{"_id": "$" + applyOrdering([]string{dedup.key})[0], "doc": bson.M{"$first": "$$ROOT"}}},
+		bson.M{"$replaceRoot": bson.M{"newRoot": "$doc"}},
+	)
+
+	if len(order) > 0 {
+		pipeline = append(pipeline, bson.M{"$sort": makeSortStage(order)})
+	}
+
+	if skip > 0 {
+		pipeline = append(pipeline, bson.M{"$skip": skip})
+	}
+
+	if limit > 0 {
+		pipeline = append(pipeline, bson.M{"$limit": limit})
+	}
+
+	if sels != nil {
+		pipeline = append(pipeline, bson.M{"$project": sels})
+	}
+
+	return pipeline
+}
+
+// makeCollation returns the collation set in the given context, if any.
+func makeCollation(mctx manipulate.Context) *mgo.Collation {
+
+	collation, ok := mctx.(opaquer).Opaque()[opaqueKeyCollation].(mgo.Collation)
+	if !ok {
+		return nil
+	}
+
+	return &collation
+}
+
+// maxExecutionTime returns the maximum time the server can spend
+// running a query for the given context. It is the time left before
+// the deadline of the context, or the default global timeout, capped by
+// the duration set using ContextOptionMaxExecutionTime.
+func maxExecutionTime(mctx manipulate.Context) time.Duration {
+
+	max := defaultGlobalContextTimeout
+	if d, ok := mctx.Context().Deadline(); ok {
+		max = time.Until(d)
+	}
+
+	if d, ok := mctx.(opaquer).Opaque()[opaqueKeyMaxTime].(time.Duration); ok && d > 0 && d < max {
+		max = d
+	}
+
+	return max
+}
+
+// validateObject validates the given object if it implements
+// elemental.Validatable and returns a manipulate.ErrValidation
+// containing the detailed errors if it is not valid.
+func validateObject(object elemental.Identifiable) error {
+
+	v, ok := object.(elemental.Validatable)
+	if !ok {
+		return nil
+	}
+
+	err := v.Validate()
+	if err == nil {
+		return nil
+	}
+
+	var details []error
+	if errs, ok := err.(elemental.Errors); ok {
+		details = make([]error, len(errs))
+		for i, e := range errs {
+			details[i] = e
+		}
+	} else {
+		details = []error{err}
+	}
+
+	return manipulate.NewErrValidation(err.Error(), details...)
+}
+
 func convertReadConsistency(c manipulate.ReadConsistency) mgo.Mode {
 	switch c {
 	case manipulate.ReadConsistencyEventual:
@@ -251,8 +720,24 @@ func convertWriteConsistency(c manipulate.WriteConsistency) *mgo.Safe {
 	}
 }
 
+// An explainer can explain how the server runs a query.
+type explainer interface {
+	Explain(result interface{}) error
+}
+
+// commandExplainer explains a raw command.
+type commandExplainer struct {
+	db  *mgo.Database
+	cmd bson.D
+}
+
+// Explain is part of the implementation of the explainer interface.
+func (e commandExplainer) Explain(result interface{}) error {
+	return e.db.Run(bson.D{{Name: "explain", Value: e.cmd}}, result)
+}
+
 func explainIfNeeded(
-	query *mgo.Query,
+	query explainer,
 	filter bson.D,
 	identity elemental.Identity,
 	operation elemental.Operation,
@@ -279,7 +764,7 @@ func explainIfNeeded(
 	return nil
 }
 
-func explain(query *mgo.Query, operation elemental.Operation, identity elemental.Identity, filter bson.D) error {
+func explain(query explainer, operation elemental.Operation, identity elemental.Identity, filter bson.D) error {
 
 	r := bson.M{}
 	if err := query.Explain(&r); err != nil {
@@ -312,3 +797,162 @@ func explain(query *mgo.Query, operation elemental.Operation, identity elemental
 
 	return nil
 }
+
+// makeFindCommand returns the find command equivalent to a query with the
+// given parameters, using the given collation if any. It is only used to
+// pass the options that mgo does not support on queries.
+func makeFindCommand(collection string, filter bson.D, order []string, skip int, limit int, sels bson.M, maxTime time.Duration, collation *mgo.Collation) bson.D {
+
+	cmd := bson.D{
+		{Name: "find", Value: collection},
+		{Name: "filter", Value: filter},
+	}
+
+	if len(order) > 0 {
+		cmd = append(cmd, bson.DocElem{Name: "sort", Value: makeSortStage(order)})
+	}
+
+	if sels != nil {
+		cmd = append(cmd, bson.DocElem{Name: "projection", Value: sels})
+	}
+
+	if skip > 0 {
+		cmd = append(cmd, bson.DocElem{Name: "skip", Value: skip})
+	}
+
+	if limit > 0 {
+		cmd = append(cmd, bson.DocElem{Name: "limit", Value: limit})
+	}
+
+	if maxTime > 0 {
+		cmd = append(cmd, bson.DocElem{Name: "maxTimeMS", Value: int64(maxTime / time.Millisecond)})
+	}
+
+	if collation != nil {
+		cmd = append(cmd, bson.DocElem{Name: "collation", Value: *collation})
+	}
+
+	return cmd
+}
+
+// makeEstimatedCountCommand returns the count command without query, which
+// returns the number of documents from the metadata of the collection.
+func makeEstimatedCountCommand(collection string, maxTime time.Duration) bson.D {
+
+	return bson.D{
+		{Name: "count", Value: collection},
+		{Name: "maxTimeMS", Value: int64(maxTime / time.Millisecond)},
+	}
+}
+
+// runCountCommand runs the given count command and returns the count.
+func runCountCommand(c *mgo.Collection, cmd bson.D) (int, error) {
+
+	res := struct {
+		N int `bson:"n"`
+	}{}
+
+	if err := c.Database.Run(cmd, &res); err != nil {
+		return 0, err
+	}
+
+	return res.N, nil
+}
+
+// makeDeduplicateCountPipeline returns the aggregation pipeline that counts the
+// distinct values of the deduplication key among the documents matching the given filter.
+func makeDeduplicateCountPipeline(filter bson.D, dedup deduplication) []bson.M {
+
+	return []bson.M{
+		{"$match": filter},
+		{"$group": bson.M{"_id": "$" + applyOrdering([]string{dedup.key})[0]}},
+		{"$count": "n"},
+	}
+}
+
+// runCountPipeline runs the given pipeline, that must return a single
+// document with the count in the n field, and returns the count.
+func runCountPipeline(pipe *mgo.Pipe) (int, error) {
+
+	res := []struct {
+		N int `bson:"n"`
+	}{}
+
+	if err := pipe.All(&res); err != nil {
+		return 0, err
+	}
+
+	// An empty result means there is no matching document.
+	if len(res) == 0 {
+		return 0, nil
+	}
+
+	return res[0].N, nil
+}
+
+// runCursorCommand runs the given command, that must return a cursor,
+// and decodes all the documents it returns into the given destination.
+func runCursorCommand(c *mgo.Collection, cmd bson.D, dest interface{}) error {
+
+	// The cursor must be consumed on the server that created it.
+	session := c.Database.Session
+	if session.Mode() == mgo.Eventual {
+		session.SetMode(mgo.Monotonic, false)
+	}
+
+	res := struct {
+		Cursor struct {
+			FirstBatch []bson.Raw `bson:"firstBatch"`
+			ID         int64      `bson:"id"`
+		} `bson:"cursor"`
+	}{}
+
+	if err := c.Database.Run(cmd, &res); err != nil {
+		return err
+	}
+
+	return c.NewIter(session, res.Cursor.FirstBatch, res.Cursor.ID, nil).All(dest)
+}
+
+// queryHints holds the hints passed to manipmongo
+// through the parameters of a context.
+type queryHints struct {
+	allowDiskUse    bool
+	noCursorTimeout bool
+}
+
+// makeQueryHints returns the queryHints set in the parameters of
+// the given context. Invalid values are ignored. The options of the
+// context take precedence over the parameters.
+func makeQueryHints(mctx manipulate.Context) queryHints {
+
+	params := mctx.Parameters()
+
+	h := queryHints{}
+	h.allowDiskUse, _ = strconv.ParseBool(params.Get(ParameterAllowDiskUse))
+	h.noCursorTimeout, _ = strconv.ParseBool(params.Get(ParameterNoCursorTimeout))
+
+	if allow, ok := mctx.(opaquer).Opaque()[opaqueKeyAllowDiskUse].(bool); ok {
+		h.allowDiskUse = allow
+	}
+
+	if enabled, ok := mctx.(opaquer).Opaque()[opaqueKeyNoCursorTimeout].(bool); ok {
+		h.noCursorTimeout = enabled
+	}
+
+	return h
+}
+
+// applyToFindCommand returns the given find command with the hints.
+func (h queryHints) applyToFindCommand(cmd bson.D) bson.D {
+
+	if h.allowDiskUse {
+		cmd = append(cmd, bson.DocElem{Name: "allowDiskUse", Value: true})
+	}
+
+	if h.noCursorTimeout {
+		cmd = append(cmd, bson.DocElem{Name: "noCursorTimeout", Value: true})
+	}
+
+	return cmd
+}
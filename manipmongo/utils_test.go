@@ -12,11 +12,14 @@
 package manipmongo
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net"
+	"net/url"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/globalsign/mgo"
 	"github.com/globalsign/mgo/bson"
@@ -273,73 +276,751 @@ func Test_makeFieldsSelector(t *testing.T) {
 	}
 }
 
+func Test_stampActor(t *testing.T) {
+
+	type object struct {
+		Name string `bson:"name"`
+	}
+
+	type args struct {
+		doc   interface{}
+		actor string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    bson.M
+		wantErr bool
+	}{
+		{
+			"struct",
+			args{
+				&object{Name: "hello"},
+				"bob",
+			},
+			bson.M{"name": "hello", "_actor": "bob"},
+			false,
+		},
+		{
+			"bson.M",
+			args{
+				bson.M{"name": "hello"},
+				"bob",
+			},
+			bson.M{"name": "hello", "_actor": "bob"},
+			false,
+		},
+		{
+			"not marshalable",
+			args{
+				"not a document",
+				"bob",
+			},
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := stampActor(tt.args.doc, tt.args.actor)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("stampActor() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("stampActor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_makeRawUpdate(t *testing.T) {
+
+	type args struct {
+		update map[string]interface{}
+		actor  string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    bson.M
+		wantErr bool
+	}{
+		{
+			"mixed operators",
+			args{
+				map[string]interface{}{
+					"$set":  bson.M{"name": "hello"},
+					"$inc":  map[string]interface{}{"count": 1},
+					"$push": bson.D{{Name: "tags", Value: "a"}},
+				},
+				"",
+			},
+			bson.M{
+				"$set":  bson.M{"name": "hello"},
+				"$inc":  map[string]interface{}{"count": 1},
+				"$push": bson.D{{Name: "tags", Value: "a"}},
+			},
+			false,
+		},
+		{
+			"with actor",
+			args{
+				map[string]interface{}{
+					"$inc": bson.M{"count": 1},
+				},
+				"bob",
+			},
+			bson.M{
+				"$inc": bson.M{"count": 1},
+				"$set": bson.M{"_actor": "bob"},
+			},
+			false,
+		},
+		{
+			"with actor and $set",
+			args{
+				map[string]interface{}{
+					"$set": bson.D{{Name: "name", Value: "hello"}},
+				},
+				"bob",
+			},
+			bson.M{
+				"$set": bson.M{"name": "hello", "_actor": "bob"},
+			},
+			false,
+		},
+		{
+			"empty",
+			args{
+				map[string]interface{}{},
+				"",
+			},
+			nil,
+			true,
+		},
+		{
+			"not an operator",
+			args{
+				map[string]interface{}{"name": "hello"},
+				"",
+			},
+			nil,
+			true,
+		},
+		{
+			"operator without document",
+			args{
+				map[string]interface{}{"$set": "hello"},
+				"",
+			},
+			nil,
+			true,
+		},
+		{
+			"operator without field",
+			args{
+				map[string]interface{}{"$set": bson.M{}},
+				"",
+			},
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := makeRawUpdate(tt.args.update, tt.args.actor)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("makeRawUpdate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("makeRawUpdate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+type timestampedObject struct {
+	Name      string    `bson:"name"`
+	CreatedAt time.Time `bson:"createtime"`
+	UpdatedAt time.Time `bson:"updatetime"`
+}
+
+func (o *timestampedObject) SetCreatedAt(t time.Time) { o.CreatedAt = t }
+func (o *timestampedObject) SetUpdatedAt(t time.Time) { o.UpdatedAt = t }
+
+func Test_creationTimeFields(t *testing.T) {
+
+	created := time.Now().Add(-time.Hour)
+	o := &timestampedObject{Name: "hello", CreatedAt: created, UpdatedAt: time.Now()}
+
+	for i := 0; i < 2; i++ {
+
+		got, err := creationTimeFields(o)
+		if err != nil {
+			t.Fatalf("creationTimeFields() error = %v", err)
+		}
+
+		if want := []string{"createtime"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("creationTimeFields() = %v, want %v", got, want)
+		}
+	}
+
+	if !o.CreatedAt.Equal(created) {
+		t.Errorf("creationTimeFields() changed CreatedAt to %v, want %v", o.CreatedAt, created)
+	}
+
+	if _, ok := creationTimeFieldsCache.Load(reflect.TypeOf(o)); !ok {
+		t.Errorf("creationTimeFields() did not cache the fields")
+	}
+}
+
+func Test_makePartialUpdate(t *testing.T) {
+
+	type object struct {
+		ID    string `bson:"_id"`
+		Name  string `bson:"name"`
+		Desc  string `bson:"desc,omitempty"`
+		Count int    `bson:"count"`
+	}
+
+	type args struct {
+		object interface{}
+		fields []string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    bson.M
+		wantErr bool
+	}{
+		{
+			"simple",
+			args{
+				&object{ID: "x", Name: "hello", Desc: "desc", Count: 2},
+				[]string{"Name", "count"},
+			},
+			bson.M{
+				"$set": bson.M{"name": "hello", "count": 2},
+			},
+			false,
+		},
+		{
+			"omitted field",
+			args{
+				&object{ID: "x", Name: "hello"},
+				[]string{"Name", "desc"},
+			},
+			bson.M{
+				"$set":   bson.M{"name": "hello"},
+				"$unset": bson.M{"desc": 1},
+			},
+			false,
+		},
+		{
+			"identifier",
+			args{
+				&object{ID: "x", Name: "hello"},
+				[]string{"ID", "name"},
+			},
+			bson.M{
+				"$set": bson.M{"name": "hello"},
+			},
+			false,
+		},
+		{
+			"nothing to update",
+			args{
+				&object{ID: "x", Name: "hello"},
+				[]string{"ID", ""},
+			},
+			nil,
+			true,
+		},
+		{
+			"not marshalable",
+			args{
+				"not a document",
+				[]string{"name"},
+			},
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := makePartialUpdate(tt.args.object, tt.args.fields)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("makePartialUpdate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("makePartialUpdate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func Test_applyOrdering(t *testing.T) {
 	type args struct {
 		order []string
 	}
 	tests := []struct {
 		name string
-		args args
-		want []string
+		args args
+		want []string
+	}{
+		{
+			"simple",
+			args{
+				[]string{"NAME", "toto", ""},
+			},
+			[]string{"name", "toto"},
+		},
+
+		{
+			"ID",
+			args{
+				[]string{"ID"},
+			},
+			[]string{"_id"},
+		},
+		{
+			"-ID",
+			args{
+				[]string{"-ID"},
+			},
+			[]string{"-_id"},
+		},
+
+		{
+			"id",
+			args{
+				[]string{"id"},
+			},
+			[]string{"_id"},
+		},
+		{
+			"-id",
+			args{
+				[]string{"-id"},
+			},
+			[]string{"-_id"},
+		},
+
+		{
+			"_id",
+			args{
+				[]string{"_id"},
+			},
+			[]string{"_id"},
+		},
+
+		{
+			"only empty",
+			args{
+				[]string{"", ""},
+			},
+			[]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := applyOrdering(tt.args.order); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("applyOrdering() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+type defaultedObject struct {
+	Name   string
+	Status string
+	Tags   []string
+}
+
+func (o *defaultedObject) SpecificationForAttribute(name string) elemental.AttributeSpecification {
+	return o.AttributeSpecifications()[name]
+}
+
+func (o *defaultedObject) AttributeSpecifications() map[string]elemental.AttributeSpecification {
+	return map[string]elemental.AttributeSpecification{
+		"name":   {Name: "name", ConvertedName: "Name"},
+		"status": {Name: "status", ConvertedName: "Status", DefaultValue: "Active"},
+		"tags":   {Name: "tags", ConvertedName: "Tags", DefaultValue: []string{"default"}},
+	}
+}
+
+func (o *defaultedObject) ValueForAttribute(name string) interface{} { return nil }
+
+func Test_resetDefaultForZeroValues(t *testing.T) {
+
+	type args struct {
+		obj    *defaultedObject
+		fields []string
+	}
+	tests := []struct {
+		name string
+		args args
+		want *defaultedObject
+	}{
+		{
+			"selected fields",
+			args{
+				&defaultedObject{},
+				[]string{"Name", "tags"},
+			},
+			&defaultedObject{Tags: []string{"default"}},
+		},
+		{
+			"prefixed fields",
+			args{
+				&defaultedObject{},
+				[]string{"-status"},
+			},
+			&defaultedObject{Status: "Active"},
+		},
+		{
+			"non zero field",
+			args{
+				&defaultedObject{Status: "Inactive"},
+				[]string{"status"},
+			},
+			&defaultedObject{Status: "Inactive"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetDefaultForZeroValues(tt.args.obj, tt.args.fields)
+			if !reflect.DeepEqual(tt.args.obj, tt.want) {
+				t.Errorf("resetDefaultForZeroValues() = %v, want %v", tt.args.obj, tt.want)
+			}
+		})
+	}
+}
+
+func Test_identifierString(t *testing.T) {
+
+	oid := bson.NewObjectId()
+
+	tests := []struct {
+		name string
+		id   interface{}
+		want string
+	}{
+		{
+			"object id",
+			oid,
+			oid.Hex(),
+		},
+		{
+			"string",
+			"d5e4c2a4-6f5e-4d2a-9c1e-3b0f3f0a8a11",
+			"d5e4c2a4-6f5e-4d2a-9c1e-3b0f3f0a8a11",
+		},
+		{
+			"other",
+			42,
+			"42",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := identifierString(tt.id); got != tt.want {
+				t.Errorf("identifierString() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_makeDeduplicatePipeline(t *testing.T) {
+
+	type args struct {
+		filter bson.D
+		dedup  deduplication
+		order  []string
+		skip   int
+		limit  int
+		sels   bson.M
+	}
+	tests := []struct {
+		name string
+		args args
+		want []bson.M
+	}{
+		{
+			"simple",
+			args{
+				bson.D{{Name: "a", Value: 1}},
+				deduplication{key: "Name"},
+				nil,
+				0,
+				0,
+				nil,
+			},
+			[]bson.M{
+				{"$match": bson.D{{Name: "a", Value: 1}}},
+				{"$group": bson.M{"_id": "$name", "doc": bson.M{"$first": "$$ROOT"}}},
+				{"$replaceRoot": bson.M{"newRoot": "$doc"}},
+			},
+		},
+		{
+			"complete",
+			args{
+				bson.D{},
+				deduplication{key: "name", pick: []string{"-createTime"}},
+				[]string{"name", "-_id"},
+				10,
+				5,
+				bson.M{"name": 1},
+			},
+			[]bson.M{
+				{"$match": bson.D{}},
+				{"$sort": bson.D{{Name: "createtime", Value: -1}}},
+				{"$group": bson.M{"_id": "$name", "doc": bson.M{"$first": "$$ROOT"}}},
+				{"$replaceRoot": bson.M{"newRoot": "$doc"}},
+				{"$sort": bson.D{{Name: "name", Value: 1}, {Name: "_id", Value: -1}}},
+				{"$skip": 10},
+				{"$limit": 5},
+				{"$project": bson.M{"name": 1}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := makeDeduplicatePipeline(tt.args.filter, tt.args.dedup, tt.args.order, tt.args.skip, tt.args.limit, tt.args.sels); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("makeDeduplicatePipeline() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_makeFindCommand(t *testing.T) {
+
+	collation := mgo.Collation{Locale: "en", Strength: 2}
+
+	type args struct {
+		collection string
+		filter     bson.D
+		order      []string
+		skip       int
+		limit      int
+		sels       bson.M
+		maxTime    time.Duration
+		collation  *mgo.Collation
+	}
+	tests := []struct {
+		name string
+		args args
+		want bson.D
+	}{
+		{
+			"simple",
+			args{
+				"things",
+				bson.D{{Name: "a", Value: 1}},
+				nil,
+				0,
+				0,
+				nil,
+				0,
+				&collation,
+			},
+			bson.D{
+				{Name: "find", Value: "things"},
+				{Name: "filter", Value: bson.D{{Name: "a", Value: 1}}},
+				{Name: "collation", Value: collation},
+			},
+		},
+		{
+			"without collation",
+			args{
+				"things",
+				bson.D{{Name: "a", Value: 1}},
+				[]string{"name"},
+				0,
+				0,
+				nil,
+				0,
+				nil,
+			},
+			bson.D{
+				{Name: "find", Value: "things"},
+				{Name: "filter", Value: bson.D{{Name: "a", Value: 1}}},
+				{Name: "sort", Value: bson.D{{Name: "name", Value: 1}}},
+			},
+		},
+		{
+			"complete",
+			args{
+				"things",
+				bson.D{},
+				[]string{"name", "-_id"},
+				10,
+				5,
+				bson.M{"name": 1},
+				2 * time.Second,
+				&collation,
+			},
+			bson.D{
+				{Name: "find", Value: "things"},
+				{Name: "filter", Value: bson.D{}},
+				{Name: "sort", Value: bson.D{{Name: "name", Value: 1}, {Name: "_id", Value: -1}}},
+				{Name: "projection", Value: bson.M{"name": 1}},
+				{Name: "skip", Value: 10},
+				{Name: "limit", Value: 5},
+				{Name: "maxTimeMS", Value: int64(2000)},
+				{Name: "collation", Value: collation},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := makeFindCommand(tt.args.collection, tt.args.filter, tt.args.order, tt.args.skip, tt.args.limit, tt.args.sels, tt.args.maxTime, tt.args.collation); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("makeFindCommand() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_makeCollation(t *testing.T) {
+
+	tests := []struct {
+		name string
+		mctx manipulate.Context
+		want *mgo.Collation
 	}{
 		{
-			"simple",
-			args{
-				[]string{"NAME", "toto", ""},
-			},
-			[]string{"name", "toto"},
+			"no collation",
+			manipulate.NewContext(context.Background()),
+			nil,
+		},
+		{
+			"collation",
+			manipulate.NewContext(context.Background(), ContextOptionCollation(mgo.Collation{Locale: "en", Strength: 2})),
+			&mgo.Collation{Locale: "en", Strength: 2},
 		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := makeCollation(tt.mctx); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("makeCollation() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
 
+func Test_maxExecutionTime(t *testing.T) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tests := []struct {
+		name    string
+		mctx    manipulate.Context
+		wantMin time.Duration
+		wantMax time.Duration
+	}{
 		{
-			"ID",
-			args{
-				[]string{"ID"},
-			},
-			[]string{"_id"},
+			"default",
+			manipulate.NewContext(context.Background()),
+			defaultGlobalContextTimeout,
+			defaultGlobalContextTimeout,
 		},
 		{
-			"-ID",
-			args{
-				[]string{"-ID"},
-			},
-			[]string{"-_id"},
+			"max execution time",
+			manipulate.NewContext(context.Background(), ContextOptionMaxExecutionTime(time.Second)),
+			time.Second,
+			time.Second,
 		},
-
 		{
-			"id",
-			args{
-				[]string{"id"},
-			},
-			[]string{"_id"},
+			"deadline",
+			manipulate.NewContext(ctx),
+			9 * time.Second,
+			10 * time.Second,
 		},
 		{
-			"-id",
-			args{
-				[]string{"-id"},
-			},
-			[]string{"-_id"},
+			"max execution time greater than deadline",
+			manipulate.NewContext(ctx, ContextOptionMaxExecutionTime(time.Minute)),
+			9 * time.Second,
+			10 * time.Second,
 		},
-
 		{
-			"_id",
-			args{
-				[]string{"_id"},
-			},
-			[]string{"_id"},
+			"max execution time lesser than deadline",
+			manipulate.NewContext(ctx, ContextOptionMaxExecutionTime(time.Second)),
+			time.Second,
+			time.Second,
 		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := maxExecutionTime(tt.mctx); got < tt.wantMin || got > tt.wantMax {
+				t.Errorf("maxExecutionTime() = %v, want between %v and %v", got, tt.wantMin, tt.wantMax)
+			}
+		})
+	}
+}
+
+type validatableObject struct {
+	elemental.Identifiable
+	err error
+}
+
+func (o *validatableObject) Validate() error { return o.err }
+
+func Test_validateObject(t *testing.T) {
+
+	e1 := elemental.Error{Title: "invalid name"}
+	e2 := elemental.Error{Title: "invalid age"}
 
+	tests := []struct {
+		name        string
+		object      elemental.Identifiable
+		wantErr     bool
+		wantDetails []error
+	}{
 		{
-			"only empty",
-			args{
-				[]string{"", ""},
-			},
-			[]string{},
+			"not validatable",
+			struct{ elemental.Identifiable }{},
+			false,
+			nil,
+		},
+		{
+			"valid",
+			&validatableObject{},
+			false,
+			nil,
+		},
+		{
+			"invalid with elemental errors",
+			&validatableObject{err: elemental.Errors{e1, e2}},
+			true,
+			[]error{e1, e2},
+		},
+		{
+			"invalid with another error",
+			&validatableObject{err: io.EOF},
+			true,
+			[]error{io.EOF},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := applyOrdering(tt.args.order); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("applyOrdering() = %v, want %v", got, tt.want)
+			err := validateObject(tt.object)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateObject() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil {
+				return
+			}
+			if !manipulate.IsValidationError(err) {
+				t.Errorf("validateObject() error = %v, want a validation error", err)
+				return
+			}
+			if details := err.(manipulate.ErrValidation).Details(); !reflect.DeepEqual(details, tt.wantDetails) {
+				t.Errorf("validateObject() details = %v, want %v", details, tt.wantDetails)
 			}
 		})
 	}
@@ -681,3 +1362,306 @@ func Test_explainIfNeeded(t *testing.T) {
 		})
 	}
 }
+
+func Test_makeAfterValueFilter(t *testing.T) {
+
+	oid := bson.NewObjectId()
+
+	type args struct {
+		orderingField string
+		after         afterValue
+	}
+	tests := []struct {
+		name string
+		args args
+		want bson.D
+	}{
+		{
+			"no ordering",
+			args{
+				"",
+				afterValue{value: oid},
+			},
+			bson.D{{Name: "_id", Value: bson.D{{Name: "$gt", Value: oid}}}},
+		},
+		{
+			"value only",
+			args{
+				"name",
+				afterValue{value: "a"},
+			},
+			bson.D{{Name: "name", Value: bson.D{{Name: "$gt", Value: "a"}}}},
+		},
+		{
+			"value only descending",
+			args{
+				"-name",
+				afterValue{value: "a"},
+			},
+			bson.D{{Name: "name", Value: bson.D{{Name: "$lt", Value: "a"}}}},
+		},
+		{
+			"value and object id",
+			args{
+				"name",
+				afterValue{value: "a", id: oid.Hex()},
+			},
+			bson.D{
+				{
+					Name: "$or",
+					Value: []bson.D{
+						{{Name: "name", Value: bson.D{{Name: "$gt", Value: "a"}}}},
+						{{Name: "name", Value: "a"}, {Name: "_id", Value: bson.D{{Name: "$gt", Value: oid}}}},
+					},
+				},
+			},
+		},
+		{
+			"value and string id descending",
+			args{
+				"-name",
+				afterValue{value: "a", id: "xyz"},
+			},
+			bson.D{
+				{
+					Name: "$or",
+					Value: []bson.D{
+						{{Name: "name", Value: bson.D{{Name: "$lt", Value: "a"}}}},
+						{{Name: "name", Value: "a"}, {Name: "_id", Value: bson.D{{Name: "$lt", Value: "xyz"}}}},
+					},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := makeAfterValueFilter(tt.args.orderingField, tt.args.after); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("makeAfterValueFilter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_validatePagination(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		options []manipulate.ContextOption
+		wantErr string
+	}{
+		{
+			"no pagination",
+			nil,
+			"",
+		},
+		{
+			"valid page",
+			[]manipulate.ContextOption{manipulate.ContextOptionPage(2, 10)},
+			"",
+		},
+		{
+			"valid limit",
+			[]manipulate.ContextOption{manipulate.ContextOptionAfter("", 10)},
+			"",
+		},
+		{
+			"negative page size",
+			[]manipulate.ContextOption{manipulate.ContextOptionPage(1, -1)},
+			"Unable to build query: invalid pagination: page size must not be negative",
+		},
+		{
+			"negative page",
+			[]manipulate.ContextOption{manipulate.ContextOptionPage(-1, 10)},
+			"Unable to build query: invalid pagination: page must not be negative",
+		},
+		{
+			"page without page size",
+			[]manipulate.ContextOption{manipulate.ContextOptionPage(2, 0)},
+			"Unable to build query: invalid pagination: page requires a page size",
+		},
+		{
+			"negative limit",
+			[]manipulate.ContextOption{manipulate.ContextOptionAfter("", -1)},
+			"Unable to build query: invalid pagination: limit must not be negative",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validatePagination(manipulate.NewContext(context.Background(), tt.options...))
+			if (err == nil) != (tt.wantErr == "") || (err != nil && err.Error() != tt.wantErr) {
+				t.Errorf("validatePagination() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_makeDeduplicateCountPipeline(t *testing.T) {
+
+	type args struct {
+		filter bson.D
+		dedup  deduplication
+	}
+	tests := []struct {
+		name string
+		args args
+		want []bson.M
+	}{
+		{
+			"simple",
+			args{
+				bson.D{{Name: "a", Value: 1}},
+				deduplication{key: "Name"},
+			},
+			[]bson.M{
+				{"$match": bson.D{{Name: "a", Value: 1}}},
+				{"$group": bson.M{"_id": "$name"}},
+				{"$count": "n"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := makeDeduplicateCountPipeline(tt.args.filter, tt.args.dedup); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("makeDeduplicateCountPipeline() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_makeEstimatedCountCommand(t *testing.T) {
+
+	type args struct {
+		collection string
+		maxTime    time.Duration
+	}
+	tests := []struct {
+		name string
+		args args
+		want bson.D
+	}{
+		{
+			"simple",
+			args{
+				"things",
+				2 * time.Second,
+			},
+			bson.D{
+				{Name: "count", Value: "things"},
+				{Name: "maxTimeMS", Value: int64(2000)},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := makeEstimatedCountCommand(tt.args.collection, tt.args.maxTime); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("makeEstimatedCountCommand() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_makeQueryHints(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		options []manipulate.ContextOption
+		want    queryHints
+	}{
+		{
+			"no parameters",
+			nil,
+			queryHints{},
+		},
+		{
+			"all hints",
+			[]manipulate.ContextOption{
+				manipulate.ContextOptionParameters(url.Values{ParameterAllowDiskUse: {"true"}, ParameterNoCursorTimeout: {"1"}}),
+			},
+			queryHints{allowDiskUse: true, noCursorTimeout: true},
+		},
+		{
+			"disabled hints",
+			[]manipulate.ContextOption{
+				manipulate.ContextOptionParameters(url.Values{ParameterAllowDiskUse: {"false"}, ParameterNoCursorTimeout: {"0"}}),
+			},
+			queryHints{},
+		},
+		{
+			"invalid and unknown parameters",
+			[]manipulate.ContextOption{
+				manipulate.ContextOptionParameters(url.Values{ParameterAllowDiskUse: {"yes please"}, "hello": {"true"}}),
+			},
+			queryHints{},
+		},
+		{
+			"disk use allowed by option",
+			[]manipulate.ContextOption{
+				ContextOptionAllowDiskUse(true),
+			},
+			queryHints{allowDiskUse: true},
+		},
+		{
+			"disk use disallowed by option",
+			[]manipulate.ContextOption{
+				manipulate.ContextOptionParameters(url.Values{ParameterAllowDiskUse: {"true"}}),
+				ContextOptionAllowDiskUse(false),
+			},
+			queryHints{},
+		},
+		{
+			"no cursor timeout enabled by option",
+			[]manipulate.ContextOption{
+				ContextOptionNoCursorTimeout(true),
+			},
+			queryHints{noCursorTimeout: true},
+		},
+		{
+			"no cursor timeout disabled by option",
+			[]manipulate.ContextOption{
+				manipulate.ContextOptionParameters(url.Values{ParameterNoCursorTimeout: {"true"}}),
+				ContextOptionNoCursorTimeout(false),
+			},
+			queryHints{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := makeQueryHints(manipulate.NewContext(context.Background(), tt.options...)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("makeQueryHints() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_queryHints_applyToCommand(t *testing.T) {
+
+	base := bson.D{{Name: "find", Value: "things"}}
+
+	tests := []struct {
+		name     string
+		hints    queryHints
+		wantFind bson.D
+	}{
+		{
+			"no hints",
+			queryHints{},
+			base,
+		},
+		{
+			"all hints",
+			queryHints{allowDiskUse: true, noCursorTimeout: true},
+			bson.D{
+				{Name: "find", Value: "things"},
+				{Name: "allowDiskUse", Value: true},
+				{Name: "noCursorTimeout", Value: true},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.hints.applyToFindCommand(append(bson.D{}, base...)); !reflect.DeepEqual(got, tt.wantFind) {
+				t.Errorf("applyToFindCommand() = %v, want %v", got, tt.wantFind)
+			}
+		})
+	}
+}
@@ -0,0 +1,243 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package maniptest
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"go.aporeto.io/elemental"
+	testmodel "go.aporeto.io/elemental/test/model"
+	"go.aporeto.io/manipulate"
+)
+
+// missingID is an identifier that is valid for all
+// backends, but matches no object.
+const missingID = "5d85727b919e0c397a58e940"
+
+// RunConformance runs a suite of behavioral tests against the
+// manipulators returned by the given factory, to ensure all backends
+// behave the same way. The factory is called for every test and must
+// return a manipulator that is empty and able to store the objects
+// of the elemental test model with the list identity. The name
+// attribute must be filterable.
+//
+// A missing object must be reported with a manipulate.ErrObjectNotFound
+// or an elemental error with the 404 code. The pagination test is
+// skipped if the manipulator does not honor the page size.
+func RunConformance(t *testing.T, factory func() manipulate.Manipulator) {
+
+	t.Run("create and retrieve", func(t *testing.T) {
+
+		m := factory()
+
+		obj := createList(t, m, "a")
+		if obj.ID == "" {
+			t.Fatal("expected the created object to have an identifier")
+		}
+
+		got := &testmodel.List{ID: obj.ID}
+		if err := m.Retrieve(newContext(), got); err != nil {
+			t.Fatalf("unable to retrieve the object: %s", err)
+		}
+
+		if got.Name != "a" {
+			t.Errorf("expected the retrieved name to be 'a', got '%s'", got.Name)
+		}
+	})
+
+	t.Run("update", func(t *testing.T) {
+
+		m := factory()
+
+		obj := createList(t, m, "a")
+		obj.Description = "updated"
+		if err := m.Update(newContext(), obj); err != nil {
+			t.Fatalf("unable to update the object: %s", err)
+		}
+
+		got := &testmodel.List{ID: obj.ID}
+		if err := m.Retrieve(newContext(), got); err != nil {
+			t.Fatalf("unable to retrieve the object: %s", err)
+		}
+
+		if got.Description != "updated" {
+			t.Errorf("expected the retrieved description to be 'updated', got '%s'", got.Description)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+
+		m := factory()
+
+		obj := createList(t, m, "a")
+		if err := m.Delete(newContext(), obj); err != nil {
+			t.Fatalf("unable to delete the object: %s", err)
+		}
+
+		err := m.Retrieve(newContext(), &testmodel.List{ID: obj.ID})
+		if !isNotFound(err) {
+			t.Errorf("expected a not found error when retrieving a deleted object, got: %v", err)
+		}
+	})
+
+	t.Run("missing object", func(t *testing.T) {
+
+		m := factory()
+
+		if err := m.Retrieve(newContext(), &testmodel.List{ID: missingID}); !isNotFound(err) {
+			t.Errorf("expected a not found error from Retrieve, got: %v", err)
+		}
+
+		if err := m.Update(newContext(), &testmodel.List{ID: missingID}); !isNotFound(err) {
+			t.Errorf("expected a not found error from Update, got: %v", err)
+		}
+
+		if err := m.Delete(newContext(), &testmodel.List{ID: missingID}); !isNotFound(err) {
+			t.Errorf("expected a not found error from Delete, got: %v", err)
+		}
+	})
+
+	t.Run("retrieve many and count", func(t *testing.T) {
+
+		m := factory()
+
+		for _, name := range []string{"a", "b", "c"} {
+			createList(t, m, name)
+		}
+
+		dest := testmodel.ListsList{}
+		if err := m.RetrieveMany(newContext(), &dest); err != nil {
+			t.Fatalf("unable to retrieve the objects: %s", err)
+		}
+
+		if len(dest) != 3 {
+			t.Errorf("expected 3 objects, got %d", len(dest))
+		}
+
+		n, err := m.Count(newContext(), testmodel.ListIdentity)
+		if err != nil {
+			t.Fatalf("unable to count the objects: %s", err)
+		}
+
+		if n != 3 {
+			t.Errorf("expected a count of 3, got %d", n)
+		}
+	})
+
+	t.Run("filtering", func(t *testing.T) {
+
+		m := factory()
+
+		for _, name := range []string{"a", "b", "b"} {
+			createList(t, m, name)
+		}
+
+		filter := manipulate.ContextOptionFilter(
+			elemental.NewFilterComposer().WithKey("name").Equals("b").Done(),
+		)
+
+		dest := testmodel.ListsList{}
+		if err := m.RetrieveMany(newContext(filter), &dest); err != nil {
+			t.Fatalf("unable to retrieve the objects: %s", err)
+		}
+
+		if len(dest) != 2 {
+			t.Fatalf("expected 2 objects, got %d", len(dest))
+		}
+
+		for _, o := range dest {
+			if o.Name != "b" {
+				t.Errorf("expected only objects named 'b', got '%s'", o.Name)
+			}
+		}
+
+		n, err := m.Count(newContext(filter), testmodel.ListIdentity)
+		if err != nil {
+			t.Fatalf("unable to count the objects: %s", err)
+		}
+
+		if n != 2 {
+			t.Errorf("expected a count of 2, got %d", n)
+		}
+	})
+
+	t.Run("pagination", func(t *testing.T) {
+
+		m := factory()
+
+		for _, name := range []string{"a", "b", "c", "d", "e"} {
+			createList(t, m, name)
+		}
+
+		seen := map[string]struct{}{}
+
+		for page := 1; page <= 4; page++ {
+
+			dest := testmodel.ListsList{}
+			if err := m.RetrieveMany(newContext(manipulate.ContextOptionPage(page, 2)), &dest); err != nil {
+				t.Fatalf("unable to retrieve page %d: %s", page, err)
+			}
+
+			if len(dest) > 2 {
+				t.Skip("the manipulator does not honor the page size")
+			}
+
+			for _, o := range dest {
+				if _, ok := seen[o.ID]; ok {
+					t.Errorf("object %s returned in more than one page", o.ID)
+				}
+				seen[o.ID] = struct{}{}
+			}
+		}
+
+		if len(seen) != 5 {
+			t.Errorf("expected the pages to contain 5 objects, got %d", len(seen))
+		}
+	})
+}
+
+func newContext(options ...manipulate.ContextOption) manipulate.Context {
+	return manipulate.NewContext(context.Background(), options...)
+}
+
+func createList(t *testing.T, m manipulate.Manipulator, name string) *testmodel.List {
+
+	t.Helper()
+
+	obj := testmodel.NewList()
+	obj.Name = name
+
+	if err := m.Create(newContext(), obj); err != nil {
+		t.Fatalf("unable to create the object: %s", err)
+	}
+
+	return obj
+}
+
+// isNotFound returns true if the given error reports a missing object.
+func isNotFound(err error) bool {
+
+	if manipulate.IsObjectNotFoundError(err) {
+		return true
+	}
+
+	switch e := err.(type) {
+	case elemental.Error:
+		return e.Code == http.StatusNotFound
+	case elemental.Errors:
+		return len(e) > 0 && e.Code() == http.StatusNotFound
+	}
+
+	return false
+}
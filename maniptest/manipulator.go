@@ -52,6 +52,8 @@ type testManipulator struct {
 	currentTest *testing.T
 }
 
+var _ TestManipulator = (*testManipulator)(nil)
+
 // NewTestManipulator returns a new TestManipulator.
 func NewTestManipulator() TestManipulator {
 	return &testManipulator{
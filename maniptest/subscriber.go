@@ -45,6 +45,8 @@ type testSubscriber struct {
 	currentTest *testing.T
 }
 
+var _ TestSubscriber = (*testSubscriber)(nil)
+
 // NewTestSubscriber returns a new TestSubscriber.
 func NewTestSubscriber() TestSubscriber {
 	return &testSubscriber{
@@ -38,6 +38,8 @@ type testTokenManager struct {
 	currentTest *testing.T
 }
 
+var _ TestTokenManager = (*testTokenManager)(nil)
+
 // NewTestTokenManager returns a new TestTokenManager.
 func NewTestTokenManager() TestTokenManager {
 	return &testTokenManager{
@@ -13,6 +13,7 @@ package manipulate
 
 import (
 	"context"
+	"time"
 
 	"go.aporeto.io/elemental"
 )
@@ -50,6 +51,11 @@ type Manipulator interface {
 type TransactionalManipulator interface {
 
 	// Commit commits the given TransactionID.
+	// None of the provided implementations perform network
+	// operations during Commit: writes are either sent when
+	// the operation is called, or applied in memory. If you need to
+	// bound the time spent committing pending transactions, use
+	// the Shutdown method of a ClosableManipulator.
 	Commit(id TransactionID) error
 
 	// Abort aborts the give TransactionID. It returns true if
@@ -59,6 +65,22 @@ type TransactionalManipulator interface {
 	Manipulator
 }
 
+// An IdentifierGenerator returns the identifier to
+// assign to the given object when it is created.
+type IdentifierGenerator func(object elemental.Identifiable) string
+
+// A Timestampable is an object that holds the time of
+// its creation and of its last update. Manipulators supporting
+// it can set these automatically during write operations.
+type Timestampable interface {
+
+	// SetCreatedAt sets the creation time.
+	SetCreatedAt(time.Time)
+
+	// SetUpdatedAt sets the last update time.
+	SetUpdatedAt(time.Time)
+}
+
 // A FlushableManipulator is a manipulator that can flush its
 // content to somewhere, like a file.
 type FlushableManipulator interface {
@@ -67,6 +89,28 @@ type FlushableManipulator interface {
 	Flush(ctx context.Context) error
 }
 
+// A ClosableManipulator is a manipulator that holds resources
+// that must be released when it is not needed anymore.
+type ClosableManipulator interface {
+
+	// Shutdown commits or aborts, according to the value of commit,
+	// all the transactions that are still in flight, then releases
+	// the resources held by the manipulator. The manipulator cannot
+	// be used anymore after this call. If the given context is
+	// canceled before all transactions are processed, the remaining
+	// ones are aborted and the context error is returned.
+	Shutdown(ctx context.Context, commit bool) error
+}
+
+// An ExistenceChecker is a manipulator that can tell if objects
+// exist without counting all of them.
+type ExistenceChecker interface {
+
+	// Exists returns true if at least one object of the given identity
+	// matches the filter of the given Context.
+	Exists(mctx Context, identity elemental.Identity) (bool, error)
+}
+
 // A BufferedManipulator is a Manipulator with a local cache
 type BufferedManipulator interface {
 	FlushableManipulator
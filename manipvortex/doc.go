@@ -11,4 +11,8 @@
 
 // Package manipvortex contains a Manipulator that can be used
 // as cache in front of another Manipulator.
+//
+// The errors that cannot be returned to the caller (like failures
+// of background commits) are logged using the global zap logger.
+// You can redirect them to your own logger using zap.ReplaceGlobals.
 package manipvortex // import "go.aporeto.io/manipulate/manipvortex"
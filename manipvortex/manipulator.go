@@ -19,6 +19,7 @@ import (
 
 	"go.aporeto.io/elemental"
 	"go.aporeto.io/manipulate"
+	"go.aporeto.io/manipulate/internal/stats"
 	"go.uber.org/zap"
 )
 
@@ -46,10 +47,16 @@ type vortexManipulator struct {
 	upstreamReconciler      Reconciler
 	downstreamReconciler    Reconciler
 	disableUpstreamCommit   bool
+	stats                   *stats.Counters
 
 	sync.RWMutex
 }
 
+var (
+	_ manipulate.BufferedManipulator = (*vortexManipulator)(nil)
+	_ manipulate.StatsProvider       = (*vortexManipulator)(nil)
+)
+
 // New will create a new cache. Caller must provide a valid
 // backend manipulator and susbscriber. If the manipulator is nil, it will be assumed
 // that the cache is standalone (ie there is no backend to synchronize with).
@@ -97,6 +104,7 @@ func New(
 		defaultQueueDuration:    cfg.defaultQueueDuration,
 		subscribers:             []*vortexSubscriber{},
 		commitIdentityEvent:     map[string]struct{}{},
+		stats:                   stats.New(),
 	}
 
 	if m.enableLog {
@@ -173,7 +181,9 @@ func (m *vortexManipulator) Flush(ctx context.Context) error {
 	return nil
 }
 
-func (m *vortexManipulator) RetrieveMany(mctx manipulate.Context, dest elemental.Identifiables) error {
+func (m *vortexManipulator) RetrieveMany(mctx manipulate.Context, dest elemental.Identifiables) (err error) {
+
+	defer func() { m.stats.Operation(elemental.OperationRetrieveMany, err) }()
 
 	m.RLock()
 	defer m.RUnlock()
@@ -196,11 +206,14 @@ func (m *vortexManipulator) RetrieveMany(mctx manipulate.Context, dest elemental
 
 	if !m.shouldProcess(mctx, dest.Identity()) {
 		if m.upstreamManipulator != nil {
+			m.stats.Cache(false)
 			return m.upstreamManipulator.RetrieveMany(mctx, dest)
 		}
 		return nil
 	}
 
+	m.stats.Cache(true)
+
 	if cfg := m.processors[dest.Identity().Name]; cfg != nil && cfg.RetrieveManyHook != nil {
 		commit, err := cfg.RetrieveManyHook(m.downstreamManipulator, mctx, dest)
 		if !commit {
@@ -211,7 +224,9 @@ func (m *vortexManipulator) RetrieveMany(mctx manipulate.Context, dest elemental
 	return m.downstreamManipulator.RetrieveMany(mctx, dest)
 }
 
-func (m *vortexManipulator) Retrieve(mctx manipulate.Context, object elemental.Identifiable) error {
+func (m *vortexManipulator) Retrieve(mctx manipulate.Context, object elemental.Identifiable) (err error) {
+
+	defer func() { m.stats.Operation(elemental.OperationRetrieve, err) }()
 
 	m.RLock()
 	defer m.RUnlock()
@@ -234,6 +249,7 @@ func (m *vortexManipulator) Retrieve(mctx manipulate.Context, object elemental.I
 	// We only deal with CRUDs.
 	if !m.shouldProcess(mctx, object.Identity()) {
 		if m.upstreamManipulator != nil {
+			m.stats.Cache(false)
 			return m.upstreamManipulator.Retrieve(mctx, object)
 		}
 		return nil
@@ -247,6 +263,8 @@ func (m *vortexManipulator) Retrieve(mctx manipulate.Context, object elemental.I
 			return err
 		}
 
+		m.stats.Cache(false)
+
 		if err := m.upstreamManipulator.Retrieve(mctx, object); err != nil {
 			return err
 		}
@@ -255,12 +273,18 @@ func (m *vortexManipulator) Retrieve(mctx manipulate.Context, object elemental.I
 		if err := m.downstreamManipulator.Create(mctx, object); err != nil {
 			return fmt.Errorf("unable to update local cache from backend: %s", err)
 		}
+
+		return nil
 	}
 
+	m.stats.Cache(true)
+
 	return nil
 }
 
-func (m *vortexManipulator) Create(mctx manipulate.Context, object elemental.Identifiable) error {
+func (m *vortexManipulator) Create(mctx manipulate.Context, object elemental.Identifiable) (err error) {
+
+	defer func() { m.stats.Operation(elemental.OperationCreate, err) }()
 
 	m.RLock()
 	defer m.RUnlock()
@@ -268,7 +292,9 @@ func (m *vortexManipulator) Create(mctx manipulate.Context, object elemental.Ide
 	return m.coreCRUDOperation(elemental.OperationCreate, mctx, object)
 }
 
-func (m *vortexManipulator) Update(mctx manipulate.Context, object elemental.Identifiable) error {
+func (m *vortexManipulator) Update(mctx manipulate.Context, object elemental.Identifiable) (err error) {
+
+	defer func() { m.stats.Operation(elemental.OperationUpdate, err) }()
 
 	m.RLock()
 	defer m.RUnlock()
@@ -276,7 +302,9 @@ func (m *vortexManipulator) Update(mctx manipulate.Context, object elemental.Ide
 	return m.coreCRUDOperation(elemental.OperationUpdate, mctx, object)
 }
 
-func (m *vortexManipulator) Delete(mctx manipulate.Context, object elemental.Identifiable) error {
+func (m *vortexManipulator) Delete(mctx manipulate.Context, object elemental.Identifiable) (err error) {
+
+	defer func() { m.stats.Operation(elemental.OperationDelete, err) }()
 
 	m.RLock()
 	defer m.RUnlock()
@@ -284,7 +312,9 @@ func (m *vortexManipulator) Delete(mctx manipulate.Context, object elemental.Ide
 	return m.coreCRUDOperation(elemental.OperationDelete, mctx, object)
 }
 
-func (m *vortexManipulator) DeleteMany(mctx manipulate.Context, identity elemental.Identity) error {
+func (m *vortexManipulator) DeleteMany(mctx manipulate.Context, identity elemental.Identity) (err error) {
+
+	defer func() { m.stats.Operation(elemental.OperationDelete, err) }()
 
 	m.RLock()
 	defer m.RUnlock()
@@ -300,7 +330,9 @@ func (m *vortexManipulator) DeleteMany(mctx manipulate.Context, identity element
 	return m.upstreamManipulator.DeleteMany(mctx, identity)
 }
 
-func (m *vortexManipulator) Count(mctx manipulate.Context, identity elemental.Identity) (int, error) {
+func (m *vortexManipulator) Count(mctx manipulate.Context, identity elemental.Identity) (n int, err error) {
+
+	defer func() { m.stats.Operation(elemental.OperationInfo, err) }()
 
 	m.RLock()
 	defer m.RUnlock()
@@ -316,6 +348,13 @@ func (m *vortexManipulator) Count(mctx manipulate.Context, identity elemental.Id
 	return m.downstreamManipulator.Count(mctx, identity)
 }
 
+// Stats is part of the implementation of the StatsProvider interface.
+// The reads served by the local cache are counted as cache hits, and
+// the ones forwarded to the upstream manipulator as cache misses.
+func (m *vortexManipulator) Stats() manipulate.Stats {
+	return m.stats.Snapshot()
+}
+
 func (m *vortexManipulator) hasBackendSubscriber() bool {
 
 	m.RLock()
@@ -202,6 +202,46 @@ func Test_Count(t *testing.T) {
 
 }
 
+func Test_Stats(t *testing.T) {
+
+	t.Parallel()
+	Convey("Given a new memdb vortex with a backend", t, func() {
+		m := maniptest.NewTestManipulator()
+		d, err := newDatastore()
+		So(err, ShouldBeNil)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		v, err := New(
+			ctx,
+			d,
+			newIdentityProcessor(manipulate.ReadConsistencyDefault, manipulate.WriteConsistencyDefault),
+			testmodel.Manager(),
+			OptionUpstreamManipulator(m),
+		)
+		So(err, ShouldBeNil)
+
+		Convey("When I retrieve objects from the cache and the backend", func() {
+
+			So(v.RetrieveMany(nil, &testmodel.ListsList{}), ShouldBeNil)
+			So(v.RetrieveMany(nil, &testmodel.TasksList{}), ShouldBeNil)
+			_, _ = v.Count(nil, testmodel.ListIdentity)
+
+			Convey("Then the stats should be correct", func() {
+				st := v.(manipulate.StatsProvider).Stats()
+				So(st.Operations, ShouldResemble, map[elemental.Operation]int64{
+					elemental.OperationRetrieveMany: 2,
+					elemental.OperationInfo:         1,
+				})
+				So(st.Errors, ShouldEqual, 0)
+				So(st.CacheHits, ShouldEqual, 1)
+				So(st.CacheMisses, ShouldEqual, 1)
+			})
+		})
+	})
+}
+
 func Test_run(t *testing.T) {
 	t.Parallel()
 
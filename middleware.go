@@ -0,0 +1,133 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manipulate
+
+import "go.aporeto.io/elemental"
+
+// A MiddlewareHandler handles an operation of a middleware manipulator.
+//
+// The data depends on the operation:
+//   - elemental.OperationRetrieve, elemental.OperationCreate, elemental.OperationUpdate
+//     and elemental.OperationDelete: the elemental.Identifiable.
+//   - elemental.OperationRetrieveMany: the destination elemental.Identifiables.
+//   - elemental.OperationDelete (from DeleteMany): a DeleteManyRequest.
+//   - elemental.OperationInfo (from Count): a *int receiving the count.
+//
+// The given Context may be nil.
+type MiddlewareHandler func(mctx Context, operation elemental.Operation, identity elemental.Identity, data interface{}) error
+
+// A DeleteManyRequest is the data given to the MiddlewareHandler
+// for a DeleteMany operation.
+type DeleteManyRequest struct {
+	Identity elemental.Identity
+}
+
+// A Middleware wraps a MiddlewareHandler. It can run code before and after
+// calling the next handler, modify the given data, or short-circuit the
+// operation by returning an error without calling the next handler.
+type Middleware func(next MiddlewareHandler) MiddlewareHandler
+
+type middlewareManipulator struct {
+	transactionForwarder
+
+	manipulator Manipulator
+	handler     MiddlewareHandler
+}
+
+// NewMiddlewareManipulator returns a TransactionalManipulator that runs every
+// operation through the given middlewares before delegating it to the given
+// manipulator. The first middleware is the outermost one.
+// Commit and Abort do not go through the middlewares. They are passed
+// as is to the manipulator if it supports transactions.
+func NewMiddlewareManipulator(manipulator Manipulator, middlewares ...Middleware) TransactionalManipulator {
+
+	if manipulator == nil {
+		panic("manipulator must not be nil")
+	}
+
+	m := &middlewareManipulator{
+		transactionForwarder: transactionForwarder{m: manipulator},
+		manipulator:          manipulator,
+	}
+
+	m.handler = m.dispatch
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		m.handler = middlewares[i](m.handler)
+	}
+
+	return m
+}
+
+func (m *middlewareManipulator) RetrieveMany(mctx Context, dest elemental.Identifiables) error {
+	return m.handler(mctx, elemental.OperationRetrieveMany, dest.Identity(), dest)
+}
+
+func (m *middlewareManipulator) Retrieve(mctx Context, object elemental.Identifiable) error {
+	return m.handler(mctx, elemental.OperationRetrieve, object.Identity(), object)
+}
+
+func (m *middlewareManipulator) Create(mctx Context, object elemental.Identifiable) error {
+	return m.handler(mctx, elemental.OperationCreate, object.Identity(), object)
+}
+
+func (m *middlewareManipulator) Update(mctx Context, object elemental.Identifiable) error {
+	return m.handler(mctx, elemental.OperationUpdate, object.Identity(), object)
+}
+
+func (m *middlewareManipulator) Delete(mctx Context, object elemental.Identifiable) error {
+	return m.handler(mctx, elemental.OperationDelete, object.Identity(), object)
+}
+
+func (m *middlewareManipulator) DeleteMany(mctx Context, identity elemental.Identity) error {
+	return m.handler(mctx, elemental.OperationDelete, identity, DeleteManyRequest{Identity: identity})
+}
+
+func (m *middlewareManipulator) Count(mctx Context, identity elemental.Identity) (int, error) {
+
+	var n int
+	err := m.handler(mctx, elemental.OperationInfo, identity, &n)
+
+	return n, err
+}
+
+// dispatch is the final handler calling the wrapped manipulator.
+func (m *middlewareManipulator) dispatch(mctx Context, operation elemental.Operation, identity elemental.Identity, data interface{}) (err error) {
+
+	switch operation {
+
+	case elemental.OperationRetrieveMany:
+		return m.manipulator.RetrieveMany(mctx, data.(elemental.Identifiables))
+
+	case elemental.OperationRetrieve:
+		return m.manipulator.Retrieve(mctx, data.(elemental.Identifiable))
+
+	case elemental.OperationCreate:
+		return m.manipulator.Create(mctx, data.(elemental.Identifiable))
+
+	case elemental.OperationUpdate:
+		return m.manipulator.Update(mctx, data.(elemental.Identifiable))
+
+	case elemental.OperationDelete:
+		if req, ok := data.(DeleteManyRequest); ok {
+			return m.manipulator.DeleteMany(mctx, req.Identity)
+		}
+		return m.manipulator.Delete(mctx, data.(elemental.Identifiable))
+
+	case elemental.OperationInfo:
+		n := data.(*int)
+		*n, err = m.manipulator.Count(mctx, identity)
+		return err
+
+	default:
+		return NewErrNotImplemented("unsupported operation " + string(operation))
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manipulate
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.aporeto.io/elemental"
+	testmodel "go.aporeto.io/elemental/test/model"
+)
+
+func TestNewMiddlewareManipulator(t *testing.T) {
+
+	Convey("Given I call NewMiddlewareManipulator with a nil manipulator", t, func() {
+		So(func() { NewMiddlewareManipulator(nil) }, ShouldPanicWith, "manipulator must not be nil")
+	})
+
+	Convey("Given I have a middleware manipulator with no middleware", t, func() {
+
+		r := &recordingManipulator{}
+		m := NewMiddlewareManipulator(r)
+
+		Convey("When I call all the methods", func() {
+
+			_ = m.RetrieveMany(nil, testmodel.ListsList{})
+			_ = m.Retrieve(nil, testmodel.NewList())
+			_ = m.Create(nil, testmodel.NewList())
+			_ = m.Update(nil, testmodel.NewList())
+			_ = m.Delete(nil, testmodel.NewList())
+			_ = m.DeleteMany(nil, testmodel.ListIdentity)
+			_, _ = m.Count(nil, testmodel.ListIdentity)
+			_ = m.Commit("x")
+			_ = m.Abort("x")
+
+			Convey("Then they should have been forwarded", func() {
+				So(r.calls, ShouldResemble, []string{"retrievemany", "retrieve", "create", "update", "delete", "deletemany", "count", "commit", "abort"})
+			})
+		})
+	})
+
+	Convey("Given I have a middleware manipulator with some middlewares", t, func() {
+
+		var trace []string
+
+		mw := func(name string) Middleware {
+			return func(next MiddlewareHandler) MiddlewareHandler {
+				return func(mctx Context, operation elemental.Operation, identity elemental.Identity, data interface{}) error {
+					trace = append(trace, fmt.Sprintf("%s:pre:%s:%s", name, operation, identity.Name))
+					err := next(mctx, operation, identity, data)
+					trace = append(trace, fmt.Sprintf("%s:post:%s", name, operation))
+					return err
+				}
+			}
+		}
+
+		r := &recordingManipulator{}
+		m := NewMiddlewareManipulator(r, mw("a"), mw("b"))
+
+		Convey("When I call Create", func() {
+
+			err := m.Create(nil, testmodel.NewList())
+
+			Convey("Then the middlewares should have been called in order", func() {
+				So(err, ShouldBeNil)
+				So(trace, ShouldResemble, []string{"a:pre:create:list", "b:pre:create:list", "b:post:create", "a:post:create"})
+				So(r.calls, ShouldResemble, []string{"create"})
+			})
+		})
+	})
+
+	Convey("Given I have a middleware manipulator with a middleware that short-circuits", t, func() {
+
+		r := &recordingManipulator{}
+		m := NewMiddlewareManipulator(r, func(next MiddlewareHandler) MiddlewareHandler {
+			return func(mctx Context, operation elemental.Operation, identity elemental.Identity, data interface{}) error {
+				if operation == elemental.OperationDelete {
+					return fmt.Errorf("nope")
+				}
+				return next(mctx, operation, identity, data)
+			}
+		})
+
+		Convey("When I call Delete", func() {
+
+			err := m.Delete(nil, testmodel.NewList())
+
+			Convey("Then the operation should have been blocked", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldEqual, "nope")
+				So(r.calls, ShouldBeNil)
+			})
+		})
+
+		Convey("When I call Update", func() {
+
+			err := m.Update(nil, testmodel.NewList())
+
+			Convey("Then the operation should have been forwarded", func() {
+				So(err, ShouldBeNil)
+				So(r.calls, ShouldResemble, []string{"update"})
+			})
+		})
+	})
+
+	Convey("Given I have a middleware manipulator with a middleware that records the data", t, func() {
+
+		var data []interface{}
+
+		r := &recordingManipulator{}
+		m := NewMiddlewareManipulator(r, func(next MiddlewareHandler) MiddlewareHandler {
+			return func(mctx Context, operation elemental.Operation, identity elemental.Identity, d interface{}) error {
+				data = append(data, d)
+				return next(mctx, operation, identity, d)
+			}
+		})
+
+		Convey("When I call DeleteMany", func() {
+
+			err := m.DeleteMany(nil, testmodel.ListIdentity)
+
+			Convey("Then the middleware should have received a DeleteManyRequest", func() {
+				So(err, ShouldBeNil)
+				So(data, ShouldResemble, []interface{}{DeleteManyRequest{Identity: testmodel.ListIdentity}})
+				So(r.calls, ShouldResemble, []string{"deletemany"})
+			})
+		})
+	})
+
+	Convey("Given I have a middleware manipulator with a middleware that mutates the object", t, func() {
+
+		m := NewMiddlewareManipulator(&recordingManipulator{}, func(next MiddlewareHandler) MiddlewareHandler {
+			return func(mctx Context, operation elemental.Operation, identity elemental.Identity, data interface{}) error {
+				if o, ok := data.(*testmodel.List); ok {
+					o.Name = "mutated"
+				}
+				return next(mctx, operation, identity, data)
+			}
+		})
+
+		Convey("When I call Create", func() {
+
+			o := testmodel.NewList()
+			err := m.Create(nil, o)
+
+			Convey("Then the object should have been mutated", func() {
+				So(err, ShouldBeNil)
+				So(o.Name, ShouldEqual, "mutated")
+			})
+		})
+	})
+
+	Convey("Given I have a middleware manipulator wrapping a non transactional manipulator", t, func() {
+
+		m := NewMiddlewareManipulator(&testManipulator{})
+
+		Convey("Then Commit should do nothing", func() {
+			So(m.Commit("x"), ShouldBeNil)
+		})
+
+		Convey("Then Abort should return false", func() {
+			So(m.Abort("x"), ShouldBeFalse)
+		})
+	})
+}
@@ -0,0 +1,107 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manipulate
+
+import (
+	"context"
+
+	"go.aporeto.io/elemental"
+)
+
+// A namespacedManipulator injects a namespace
+// in all the contexts it receives.
+type namespacedManipulator struct {
+	transactionForwarder
+
+	manipulator Manipulator
+	namespace   string
+}
+
+// NewNamespacedManipulator returns a TransactionalManipulator that wraps
+// the given manipulator and sets the given namespace in the Context of
+// every operation, overriding the one that may already be set.
+// The given Context is never modified, except for the values that are part of
+// the response, like Count, Next or Messages, that are reported back.
+// If the given Context is nil, a new one is created using context.Background().
+//
+// How the namespace is used depends on the wrapped manipulator.
+// A transaction is not bound to a namespace, so committing or aborting
+// one is left to the wrapped manipulator, when it handles transactions.
+func NewNamespacedManipulator(manipulator Manipulator, namespace string) TransactionalManipulator {
+
+	if manipulator == nil {
+		panic("manipulator must not be nil")
+	}
+
+	if namespace == "" {
+		panic("namespace must not be empty")
+	}
+
+	return &namespacedManipulator{
+		transactionForwarder: transactionForwarder{m: manipulator},
+		manipulator:          manipulator,
+		namespace:            namespace,
+	}
+}
+
+func (m *namespacedManipulator) RetrieveMany(mctx Context, dest elemental.Identifiables) error {
+	return m.do(mctx, func(mctx Context) error { return m.manipulator.RetrieveMany(mctx, dest) })
+}
+
+func (m *namespacedManipulator) Retrieve(mctx Context, object elemental.Identifiable) error {
+	return m.do(mctx, func(mctx Context) error { return m.manipulator.Retrieve(mctx, object) })
+}
+
+func (m *namespacedManipulator) Create(mctx Context, object elemental.Identifiable) error {
+	return m.do(mctx, func(mctx Context) error { return m.manipulator.Create(mctx, object) })
+}
+
+func (m *namespacedManipulator) Update(mctx Context, object elemental.Identifiable) error {
+	return m.do(mctx, func(mctx Context) error { return m.manipulator.Update(mctx, object) })
+}
+
+func (m *namespacedManipulator) Delete(mctx Context, object elemental.Identifiable) error {
+	return m.do(mctx, func(mctx Context) error { return m.manipulator.Delete(mctx, object) })
+}
+
+func (m *namespacedManipulator) DeleteMany(mctx Context, identity elemental.Identity) error {
+	return m.do(mctx, func(mctx Context) error { return m.manipulator.DeleteMany(mctx, identity) })
+}
+
+func (m *namespacedManipulator) Count(mctx Context, identity elemental.Identity) (int, error) {
+
+	var n int
+	err := m.do(mctx, func(mctx Context) (err error) {
+		n, err = m.manipulator.Count(mctx, identity)
+		return err
+	})
+
+	return n, err
+}
+
+func (m *namespacedManipulator) do(mctx Context, operation func(Context) error) error {
+
+	if mctx == nil {
+		return operation(NewContext(context.Background(), ContextOptionNamespace(m.namespace)))
+	}
+
+	dctx := mctx.Derive(ContextOptionNamespace(m.namespace))
+	err := operation(dctx)
+
+	mctx.SetCount(dctx.Count())
+	mctx.SetMessages(dctx.Messages())
+	if next := dctx.Next(); next != "" {
+		mctx.SetNext(next)
+	}
+
+	return err
+}
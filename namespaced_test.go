@@ -0,0 +1,116 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manipulate
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.aporeto.io/elemental"
+	testmodel "go.aporeto.io/elemental/test/model"
+)
+
+// A namespaceRecorder records the namespace of the contexts it receives.
+type namespaceRecorder struct {
+	recordingManipulator
+	namespaces []string
+}
+
+func (m *namespaceRecorder) RetrieveMany(mctx Context, dest elemental.Identifiables) error {
+	m.namespaces = append(m.namespaces, mctx.Namespace())
+	mctx.SetCount(42)
+	mctx.SetNext("next")
+	mctx.SetMessages([]string{"hello"})
+	return nil
+}
+
+func (m *namespaceRecorder) Retrieve(mctx Context, object elemental.Identifiable) error {
+	m.namespaces = append(m.namespaces, mctx.Namespace())
+	return nil
+}
+
+func TestNewNamespacedManipulator(t *testing.T) {
+
+	Convey("Given I call NewNamespacedManipulator with a nil manipulator", t, func() {
+		So(func() { NewNamespacedManipulator(nil, "/a") }, ShouldPanicWith, "manipulator must not be nil")
+	})
+
+	Convey("Given I call NewNamespacedManipulator with an empty namespace", t, func() {
+		So(func() { NewNamespacedManipulator(&recordingManipulator{}, "") }, ShouldPanicWith, "namespace must not be empty")
+	})
+
+	Convey("Given I have a namespaced manipulator", t, func() {
+
+		r := &namespaceRecorder{}
+		m := NewNamespacedManipulator(r, "/a")
+
+		Convey("When I call Retrieve with no context", func() {
+
+			err := m.Retrieve(nil, testmodel.NewList())
+
+			Convey("Then the namespace should have been set", func() {
+				So(err, ShouldBeNil)
+				So(r.namespaces, ShouldResemble, []string{"/a"})
+			})
+		})
+
+		Convey("When I call RetrieveMany with a context with another namespace", func() {
+
+			mctx := NewContext(context.Background(), ContextOptionNamespace("/b"))
+			err := m.RetrieveMany(mctx, testmodel.ListsList{})
+
+			Convey("Then the namespace should have been overridden", func() {
+				So(err, ShouldBeNil)
+				So(r.namespaces, ShouldResemble, []string{"/a"})
+			})
+
+			Convey("Then the given context should be untouched", func() {
+				So(mctx.Namespace(), ShouldEqual, "/b")
+			})
+
+			Convey("Then the response values should have been reported", func() {
+				So(mctx.Count(), ShouldEqual, 42)
+				So(mctx.Next(), ShouldEqual, "next")
+				So(mctx.Messages(), ShouldResemble, []string{"hello"})
+			})
+		})
+
+		Convey("When I call the other methods", func() {
+
+			_ = m.Create(nil, testmodel.NewList())
+			_ = m.Update(nil, testmodel.NewList())
+			_ = m.Delete(nil, testmodel.NewList())
+			_ = m.DeleteMany(nil, testmodel.ListIdentity)
+			_, _ = m.Count(nil, testmodel.ListIdentity)
+			_ = m.Commit("x")
+			_ = m.Abort("x")
+
+			Convey("Then they should have been forwarded", func() {
+				So(r.calls, ShouldResemble, []string{"create", "update", "delete", "deletemany", "count", "commit", "abort"})
+			})
+		})
+	})
+
+	Convey("Given I have a namespaced manipulator wrapping a non transactional manipulator", t, func() {
+
+		m := NewNamespacedManipulator(&testManipulator{}, "/a")
+
+		Convey("Then Commit should do nothing", func() {
+			So(m.Commit("x"), ShouldBeNil)
+		})
+
+		Convey("Then Abort should return false", func() {
+			So(m.Abort("x"), ShouldBeFalse)
+		})
+	})
+}
@@ -14,6 +14,7 @@ package manipulate
 import (
 	"net/url"
 
+	opentracing "github.com/opentracing/opentracing-go"
 	"go.aporeto.io/elemental"
 )
 
@@ -79,6 +80,16 @@ func ContextOptionTracking(identifier, typ string) ContextOption {
 	}
 }
 
+// ContextOptionSpanContext sets the opentracing SpanContext to use
+// as parent when starting new spans. This is useful to continue a trace
+// extracted from an inbound request when there is no live span
+// in the underlying context.Context.
+func ContextOptionSpanContext(sc opentracing.SpanContext) ContextOption {
+	return func(c Context) {
+		c.(*mcontext).spanContext = sc
+	}
+}
+
 // ContextOptionOrder sets the ordering option of the context.
 func ContextOptionOrder(orders ...string) ContextOption {
 	return func(c Context) {
@@ -100,6 +111,33 @@ func ContextOptionFinalizer(f FinalizerFunc) ContextOption {
 	}
 }
 
+// ContextOptionUpdateFinalizer sets the update finalizer option of the context.
+// The finalizer is called with the object before it is updated. If it returns
+// an error, the update is aborted and the error is returned.
+func ContextOptionUpdateFinalizer(f FinalizerFunc) ContextOption {
+	return func(c Context) {
+		c.(*mcontext).updateFinalizer = f
+	}
+}
+
+// ContextOptionDeleteFinalizer sets the delete finalizer option of the context.
+// The finalizer is called with the object before it is deleted. If it returns
+// an error, the deletion is aborted and the error is returned.
+func ContextOptionDeleteFinalizer(f FinalizerFunc) ContextOption {
+	return func(c Context) {
+		c.(*mcontext).deleteFinalizer = f
+	}
+}
+
+// ContextOptionPostReadHook sets the post read hook option of the context.
+// The hook is called with every object returned by Retrieve and RetrieveMany,
+// after the default values have been reset and the attributes decoded.
+func ContextOptionPostReadHook(f PostReadHookFunc) ContextOption {
+	return func(c Context) {
+		c.(*mcontext).postReadHook = f
+	}
+}
+
 // ContextOptionTransactionID sets the parameters option of the context.
 func ContextOptionTransactionID(tid TransactionID) ContextOption {
 	return func(c Context) {
@@ -107,6 +145,17 @@ func ContextOptionTransactionID(tid TransactionID) ContextOption {
 	}
 }
 
+// ContextOptionTransactionLabel sets a human readable label describing
+// the transaction. It has no effect on the operation itself but will be
+// reported in traces, and in the commit errors of the manipulators keeping
+// track of their transactions, like manipmemory, to help understanding what
+// a transaction is doing.
+func ContextOptionTransactionLabel(label string) ContextOption {
+	return func(c Context) {
+		c.(*mcontext).transactionLabel = label
+	}
+}
+
 // ContextOptionParent sets the parent option of the context.
 func ContextOptionParent(i elemental.Identifiable) ContextOption {
 	return func(c Context) {
@@ -129,6 +178,8 @@ func ContextOptionWriteConsistency(consistency WriteConsistency) ContextOption {
 }
 
 // ContextOptionReadConsistency sets the desired read consistency of the request.
+// It overrides the default read consistency of the manipulator for this request
+// only. Use ReadConsistencyStrong to read your own writes from the primary.
 func ContextOptionReadConsistency(consistency ReadConsistency) ContextOption {
 	return func(c Context) {
 		c.(*mcontext).readConsistency = consistency
@@ -158,6 +209,36 @@ func ContextOptionClientIP(clientIP string) ContextOption {
 	}
 }
 
+// ContextOptionActor sets the identity of the caller performing
+// the operation. Manipulators supporting it will record it alongside
+// the data they write, or forward it to the backend, for audit purposes.
+func ContextOptionActor(actor string) ContextOption {
+	return func(c Context) {
+		c.(*mcontext).actor = actor
+	}
+}
+
+// ContextOptionNotFoundAsEmpty tells the manipulator to not return
+// an ErrObjectNotFound when a Retrieve operation does not find the
+// object. Instead, it returns no error and the object is left untouched.
+// This is useful when a missing object is a normal result.
+func ContextOptionNotFoundAsEmpty() ContextOption {
+	return func(c Context) {
+		c.(*mcontext).notFoundAsEmpty = true
+	}
+}
+
+// ContextOptionPreserveZeroValues tells the manipulator to not backport
+// the default values of the attributes on the zero values of the objects
+// it returns, so callers can see the values actually stored. Only
+// manipmongo and maniphttp backport the default values, the other
+// backends ignore this option.
+func ContextOptionPreserveZeroValues() ContextOption {
+	return func(c Context) {
+		c.(*mcontext).preserveZeroValues = true
+	}
+}
+
 // ContextOptionRetryFunc sets the retry function.
 // This function will be called on every communication error, and will be passed
 // the try number and the error. If it itself return an error, retrying will stop and
@@ -16,6 +16,7 @@ import (
 	"net/url"
 	"testing"
 
+	opentracing "github.com/opentracing/opentracing-go"
 	. "github.com/smartystreets/goconvey/convey"
 	"go.aporeto.io/elemental"
 	testmodel "go.aporeto.io/elemental/test/model"
@@ -68,6 +69,12 @@ func TestManipulate_ContextOption(t *testing.T) {
 		So(mctx.ExternalTrackingType(), ShouldEqual, "b")
 	})
 
+	Convey("Calling ContextOptionSpanContext should work", t, func() {
+		sc := opentracing.NoopTracer{}.StartSpan("test").Context()
+		ContextOptionSpanContext(sc)(mctx.(*mcontext))
+		So(mctx.SpanContext(), ShouldResemble, sc)
+	})
+
 	Convey("Calling ContextOptionOrder should work", t, func() {
 		ContextOptionOrder("a", "b")(mctx.(*mcontext))
 		So(mctx.Order(), ShouldResemble, []string{"a", "b"})
@@ -84,12 +91,35 @@ func TestManipulate_ContextOption(t *testing.T) {
 		So(mctx.Finalizer(), ShouldEqual, f)
 	})
 
+	Convey("Calling ContextOptionUpdateFinalizer should work", t, func() {
+		f := func(elemental.Identifiable) error { return nil }
+		ContextOptionUpdateFinalizer(f)(mctx.(*mcontext))
+		So(mctx.UpdateFinalizer(), ShouldEqual, f)
+	})
+
+	Convey("Calling ContextOptionDeleteFinalizer should work", t, func() {
+		f := func(elemental.Identifiable) error { return nil }
+		ContextOptionDeleteFinalizer(f)(mctx.(*mcontext))
+		So(mctx.DeleteFinalizer(), ShouldEqual, f)
+	})
+
+	Convey("Calling ContextOptionPostReadHook should work", t, func() {
+		f := func(elemental.Identifiable) (bool, error) { return true, nil }
+		ContextOptionPostReadHook(f)(mctx.(*mcontext))
+		So(mctx.PostReadHook(), ShouldEqual, f)
+	})
+
 	Convey("Calling ContextOptionFinalizer should work", t, func() {
 		tid := NewTransactionID()
 		ContextOptionTransactionID(tid)(mctx.(*mcontext))
 		So(mctx.TransactionID(), ShouldEqual, tid)
 	})
 
+	Convey("Calling ContextOptionTransactionLabel should work", t, func() {
+		ContextOptionTransactionLabel("label")(mctx.(*mcontext))
+		So(mctx.TransactionLabel(), ShouldEqual, "label")
+	})
+
 	Convey("Calling ContextOptionParent should work", t, func() {
 		i := testmodel.NewList()
 		ContextOptionParent(i)(mctx.(*mcontext))
@@ -130,6 +160,21 @@ func TestManipulate_ContextOption(t *testing.T) {
 		So(mctx.ClientIP(), ShouldEqual, "10.1.1.1")
 	})
 
+	Convey("Calling ContextOptionActor should work", t, func() {
+		ContextOptionActor("bob")(mctx.(*mcontext))
+		So(mctx.Actor(), ShouldEqual, "bob")
+	})
+
+	Convey("Calling ContextOptionNotFoundAsEmpty should work", t, func() {
+		ContextOptionNotFoundAsEmpty()(mctx.(*mcontext))
+		So(mctx.NotFoundAsEmpty(), ShouldBeTrue)
+	})
+
+	Convey("Calling ContextOptionPreserveZeroValues should work", t, func() {
+		ContextOptionPreserveZeroValues()(mctx.(*mcontext))
+		So(mctx.PreserveZeroValues(), ShouldBeTrue)
+	})
+
 	Convey("Calling ContextOptionRetryFunc should work", t, func() {
 		f := func(RetryInfo) error { return nil }
 		ContextOptionRetryFunc(f)(mctx.(*mcontext))
@@ -0,0 +1,58 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manipulate
+
+// Pagination holds the pagination metadata of a RetrieveMany operation.
+type Pagination struct {
+
+	// TotalCount is the total number of objects matching the query.
+	TotalCount int
+
+	// TotalPages is the number of pages of the size
+	// of the context needed to get all the objects.
+	TotalPages int
+
+	// HasNext is true if there are objects after the current page.
+	HasNext bool
+}
+
+// PaginationFromContext returns the Pagination of the given context, once
+// it has been used for a RetrieveMany operation that populated the total count,
+// like maniphttp does, or manipmongo with its ContextOptionCountTotal.
+// When the context uses lazy pagination, HasNext is true if the manipulator
+// returned a next marker.
+func PaginationFromContext(mctx Context) Pagination {
+
+	p := Pagination{
+		TotalCount: mctx.Count(),
+	}
+
+	size := mctx.PageSize()
+	if size <= 0 {
+		size = mctx.Limit()
+	}
+
+	switch {
+	case size > 0:
+		p.TotalPages = (p.TotalCount + size - 1) / size
+	case p.TotalCount > 0:
+		p.TotalPages = 1
+	}
+
+	if mctx.Next() != "" {
+		p.HasNext = true
+	} else if page := mctx.Page(); page > 0 && mctx.PageSize() > 0 {
+		p.HasNext = page*mctx.PageSize() < p.TotalCount
+	}
+
+	return p
+}
@@ -0,0 +1,72 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manipulate
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPaginationFromContext(t *testing.T) {
+
+	Convey("Given I have a context without pagination", t, func() {
+
+		mctx := NewContext(context.Background())
+		mctx.SetCount(12)
+
+		Convey("Then the pagination should be correct", func() {
+			So(PaginationFromContext(mctx), ShouldResemble, Pagination{TotalCount: 12, TotalPages: 1})
+		})
+	})
+
+	Convey("Given I have a context on the first page", t, func() {
+
+		mctx := NewContext(context.Background(), ContextOptionPage(1, 5))
+		mctx.SetCount(12)
+
+		Convey("Then the pagination should be correct", func() {
+			So(PaginationFromContext(mctx), ShouldResemble, Pagination{TotalCount: 12, TotalPages: 3, HasNext: true})
+		})
+	})
+
+	Convey("Given I have a context on the last page", t, func() {
+
+		mctx := NewContext(context.Background(), ContextOptionPage(3, 5))
+		mctx.SetCount(12)
+
+		Convey("Then the pagination should be correct", func() {
+			So(PaginationFromContext(mctx), ShouldResemble, Pagination{TotalCount: 12, TotalPages: 3})
+		})
+	})
+
+	Convey("Given I have a context using lazy pagination", t, func() {
+
+		mctx := NewContext(context.Background(), ContextOptionAfter("", 10))
+		mctx.SetCount(20)
+		mctx.SetNext("next")
+
+		Convey("Then the pagination should be correct", func() {
+			So(PaginationFromContext(mctx), ShouldResemble, Pagination{TotalCount: 20, TotalPages: 2, HasNext: true})
+		})
+	})
+
+	Convey("Given I have a context with no result", t, func() {
+
+		mctx := NewContext(context.Background(), ContextOptionPage(1, 5))
+
+		Convey("Then the pagination should be correct", func() {
+			So(PaginationFromContext(mctx), ShouldResemble, Pagination{})
+		})
+	})
+}
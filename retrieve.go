@@ -0,0 +1,78 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manipulate
+
+import (
+	"sync"
+
+	"go.aporeto.io/elemental"
+)
+
+const retrieveDefaultConcurrency = 10
+
+// RetrieveConcurrently retrieves the given objects using the given manipulator,
+// running at most concurrency Retrieve operations at the same time. The objects
+// can be of different identities.
+//
+// Each Retrieve operation uses a context derived from the given one. All
+// objects are retrieved even if some of them fail. In that case, an
+// ErrPartialRetrieve containing the error of each failed object is returned,
+// keyed by the name of its identity and its identifier, like "list/xyz".
+//
+// If the given concurrency is <= 0, then it will use the default that is 10.
+// Keep in mind that each concurrent operation may use its own connection
+// to the backend.
+func RetrieveConcurrently(manipulator Manipulator, mctx Context, concurrency int, objects ...elemental.Identifiable) error {
+
+	if manipulator == nil {
+		panic("manipulator must not be nil")
+	}
+
+	if concurrency <= 0 {
+		concurrency = retrieveDefaultConcurrency
+	}
+
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	errs := map[string]error{}
+	sem := make(chan struct{}, concurrency)
+
+	for _, o := range objects {
+
+		var smctx Context
+		if mctx != nil {
+			smctx = mctx.Derive()
+		}
+
+		sem <- struct{}{}
+		wg.Add(1)
+
+		go func(o elemental.Identifiable) {
+
+			defer func() { <-sem; wg.Done() }()
+
+			if err := manipulator.Retrieve(smctx, o); err != nil {
+				lock.Lock()
+				errs[o.Identity().Name+"/"+o.Identifier()] = err
+				lock.Unlock()
+			}
+		}(o)
+	}
+
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return NewErrPartialRetrieve(errs)
+	}
+
+	return nil
+}
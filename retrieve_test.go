@@ -0,0 +1,134 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manipulate
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.aporeto.io/elemental"
+	testmodel "go.aporeto.io/elemental/test/model"
+)
+
+// A concurrentManipulator retrieves lists by setting their
+// name to their ID, and fails for the configured IDs.
+// It records the maximum number of concurrent Retrieve.
+type concurrentManipulator struct {
+	recordingManipulator
+	failures map[string]error
+	current  int64
+	max      int64
+}
+
+func (m *concurrentManipulator) Retrieve(mctx Context, object elemental.Identifiable) error {
+
+	c := atomic.AddInt64(&m.current, 1)
+	defer atomic.AddInt64(&m.current, -1)
+
+	for {
+		max := atomic.LoadInt64(&m.max)
+		if c <= max || atomic.CompareAndSwapInt64(&m.max, max, c) {
+			break
+		}
+	}
+
+	time.Sleep(10 * time.Millisecond)
+
+	if err, ok := m.failures[object.Identity().Name+"/"+object.Identifier()]; ok {
+		return err
+	}
+
+	switch o := object.(type) {
+	case *testmodel.List:
+		o.Name = o.ID
+	case *testmodel.Task:
+		o.Name = o.ID
+	}
+
+	return nil
+}
+
+func TestRetrieveConcurrently(t *testing.T) {
+
+	Convey("Given I call RetrieveConcurrently with a nil manipulator", t, func() {
+		So(func() { _ = RetrieveConcurrently(nil, nil, 1) }, ShouldPanicWith, "manipulator must not be nil")
+	})
+
+	Convey("Given I have a manipulator and some objects", t, func() {
+
+		m := &concurrentManipulator{}
+
+		objects := []elemental.Identifiable{}
+		for _, id := range []string{"a", "b", "c", "d", "e"} {
+			objects = append(objects, &testmodel.List{ID: id})
+		}
+
+		Convey("When I call RetrieveConcurrently with a concurrency of 2", func() {
+
+			err := RetrieveConcurrently(m, NewContext(context.Background()), 2, objects...)
+
+			Convey("Then err should be nil", func() {
+				So(err, ShouldBeNil)
+			})
+
+			Convey("Then all objects should have been retrieved", func() {
+				for _, o := range objects {
+					So(o.(*testmodel.List).Name, ShouldEqual, o.Identifier())
+				}
+			})
+
+			Convey("Then the concurrency should have been respected", func() {
+				So(atomic.LoadInt64(&m.max), ShouldEqual, 2)
+			})
+		})
+
+		Convey("When I call RetrieveConcurrently and some objects fail", func() {
+
+			m.failures = map[string]error{
+				"list/b": NewErrObjectNotFound("b"),
+				"list/d": NewErrCannotCommunicate("d"),
+			}
+
+			err := RetrieveConcurrently(m, nil, 0, objects...)
+
+			Convey("Then err should be a partial retrieve error", func() {
+				So(IsPartialRetrieveError(err), ShouldBeTrue)
+				So(err.(ErrPartialRetrieve).Errors(), ShouldResemble, m.failures)
+				So(err.Error(), ShouldEqual, "Partial retrieve: list/b: Object not found: b, list/d: Cannot communicate: d")
+			})
+
+			Convey("Then the other objects should have been retrieved", func() {
+				So(objects[0].(*testmodel.List).Name, ShouldEqual, "a")
+				So(objects[1].(*testmodel.List).Name, ShouldEqual, "")
+				So(objects[2].(*testmodel.List).Name, ShouldEqual, "c")
+			})
+		})
+
+		Convey("When I call RetrieveConcurrently with objects of different identities having the same ID", func() {
+
+			m.failures = map[string]error{
+				"list/a": NewErrObjectNotFound("list a"),
+				"task/a": NewErrObjectNotFound("task a"),
+			}
+
+			err := RetrieveConcurrently(m, nil, 0, &testmodel.List{ID: "a"}, &testmodel.Task{ID: "a"})
+
+			Convey("Then both errors should be reported", func() {
+				So(IsPartialRetrieveError(err), ShouldBeTrue)
+				So(err.(ErrPartialRetrieve).Errors(), ShouldResemble, m.failures)
+			})
+		})
+	})
+}
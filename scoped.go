@@ -0,0 +1,120 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manipulate
+
+import (
+	"context"
+
+	"go.aporeto.io/elemental"
+)
+
+// A ScopeFunc is the type of function that returns the filter that
+// must be part of every query on the given identity, or nil if there is none.
+type ScopeFunc func(identity elemental.Identity) *elemental.Filter
+
+// A scopedManipulator injects a mandatory filter
+// in the contexts of the reads and deletions it receives.
+type scopedManipulator struct {
+	transactionForwarder
+
+	manipulator Manipulator
+	scope       ScopeFunc
+}
+
+// NewScopedManipulator returns a TransactionalManipulator that wraps the
+// given manipulator and combines, using an And, the filter returned by the
+// given ScopeFunc with the filter of the Context of every RetrieveMany,
+// Retrieve, Delete, DeleteMany and Count operation. Create and Update
+// are forwarded as is.
+// The given Context is never modified, except for the values that are part of
+// the response, like Count, Next or Messages, that are reported back.
+// If the given Context is nil, a new one is created using context.Background().
+//
+// How the filter is used depends on the wrapped manipulator. For instance,
+// manipmongo and manipmemory only retrieve or delete a single object if it
+// matches the filter, but a manipulator ignoring it would not be restricted.
+// The scope does not apply to Commit and Abort, which are handled by the
+// wrapped manipulator if it is a TransactionalManipulator.
+func NewScopedManipulator(manipulator Manipulator, scope ScopeFunc) TransactionalManipulator {
+
+	if manipulator == nil {
+		panic("manipulator must not be nil")
+	}
+
+	if scope == nil {
+		panic("scope must not be nil")
+	}
+
+	return &scopedManipulator{
+		transactionForwarder: transactionForwarder{m: manipulator},
+		manipulator:          manipulator,
+		scope:                scope,
+	}
+}
+
+func (m *scopedManipulator) RetrieveMany(mctx Context, dest elemental.Identifiables) error {
+	return m.do(mctx, dest.Identity(), func(mctx Context) error { return m.manipulator.RetrieveMany(mctx, dest) })
+}
+
+func (m *scopedManipulator) Retrieve(mctx Context, object elemental.Identifiable) error {
+	return m.do(mctx, object.Identity(), func(mctx Context) error { return m.manipulator.Retrieve(mctx, object) })
+}
+
+func (m *scopedManipulator) Create(mctx Context, object elemental.Identifiable) error {
+	return m.manipulator.Create(mctx, object)
+}
+
+func (m *scopedManipulator) Update(mctx Context, object elemental.Identifiable) error {
+	return m.manipulator.Update(mctx, object)
+}
+
+func (m *scopedManipulator) Delete(mctx Context, object elemental.Identifiable) error {
+	return m.do(mctx, object.Identity(), func(mctx Context) error { return m.manipulator.Delete(mctx, object) })
+}
+
+func (m *scopedManipulator) DeleteMany(mctx Context, identity elemental.Identity) error {
+	return m.do(mctx, identity, func(mctx Context) error { return m.manipulator.DeleteMany(mctx, identity) })
+}
+
+func (m *scopedManipulator) Count(mctx Context, identity elemental.Identity) (int, error) {
+
+	var n int
+	err := m.do(mctx, identity, func(mctx Context) (err error) {
+		n, err = m.manipulator.Count(mctx, identity)
+		return err
+	})
+
+	return n, err
+}
+
+func (m *scopedManipulator) do(mctx Context, identity elemental.Identity, operation func(Context) error) error {
+
+	scope := m.scope(identity)
+	if scope == nil {
+		return operation(mctx)
+	}
+
+	if mctx == nil {
+		return operation(NewContext(context.Background(), ContextOptionFilter(scope)))
+	}
+
+	dctx := mctx.Derive(ContextOptionFilter(AndFilters(mctx.Filter(), scope)))
+	err := operation(dctx)
+
+	mctx.SetCount(dctx.Count())
+	mctx.SetMessages(dctx.Messages())
+	if next := dctx.Next(); next != "" {
+		mctx.SetNext(next)
+	}
+
+	return err
+}
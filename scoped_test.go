@@ -0,0 +1,116 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manipulate
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.aporeto.io/elemental"
+	testmodel "go.aporeto.io/elemental/test/model"
+)
+
+// A filterRecorder records the filter of the contexts it receives.
+type filterRecorder struct {
+	recordingManipulator
+	filters []*elemental.Filter
+}
+
+func (m *filterRecorder) RetrieveMany(mctx Context, dest elemental.Identifiables) error {
+	m.filters = append(m.filters, mctx.Filter())
+	mctx.SetCount(42)
+	return nil
+}
+
+func (m *filterRecorder) Create(mctx Context, object elemental.Identifiable) error {
+	if mctx != nil {
+		m.filters = append(m.filters, mctx.Filter())
+	}
+	return nil
+}
+
+func TestNewScopedManipulator(t *testing.T) {
+
+	tenant := elemental.NewFilterComposer().WithKey("tenant").Equals("a").Done()
+	scope := func(identity elemental.Identity) *elemental.Filter {
+		if identity.IsEqual(testmodel.ListIdentity) {
+			return tenant
+		}
+		return nil
+	}
+
+	Convey("Given I call NewScopedManipulator with a nil manipulator", t, func() {
+		So(func() { NewScopedManipulator(nil, scope) }, ShouldPanicWith, "manipulator must not be nil")
+	})
+
+	Convey("Given I call NewScopedManipulator with a nil scope", t, func() {
+		So(func() { NewScopedManipulator(&recordingManipulator{}, nil) }, ShouldPanicWith, "scope must not be nil")
+	})
+
+	Convey("Given I have a scoped manipulator", t, func() {
+
+		r := &filterRecorder{}
+		m := NewScopedManipulator(r, scope)
+
+		Convey("When I call RetrieveMany with no context", func() {
+
+			err := m.RetrieveMany(nil, testmodel.ListsList{})
+
+			Convey("Then the scope should have been set", func() {
+				So(err, ShouldBeNil)
+				So(r.filters, ShouldResemble, []*elemental.Filter{tenant})
+			})
+		})
+
+		Convey("When I call RetrieveMany with a context with a filter", func() {
+
+			f := elemental.NewFilterComposer().WithKey("name").Equals("x").Done()
+			mctx := NewContext(context.Background(), ContextOptionFilter(f))
+			err := m.RetrieveMany(mctx, testmodel.ListsList{})
+
+			Convey("Then the filters should have been combined", func() {
+				So(err, ShouldBeNil)
+				So(r.filters, ShouldResemble, []*elemental.Filter{elemental.NewFilterComposer().And(f, tenant).Done()})
+			})
+
+			Convey("Then the given context should be untouched", func() {
+				So(mctx.Filter(), ShouldEqual, f)
+			})
+
+			Convey("Then the count should have been reported", func() {
+				So(mctx.Count(), ShouldEqual, 42)
+			})
+		})
+
+		Convey("When I call RetrieveMany on an identity without scope", func() {
+
+			mctx := NewContext(context.Background())
+			err := m.RetrieveMany(mctx, testmodel.TasksList{})
+
+			Convey("Then the context should have been forwarded as is", func() {
+				So(err, ShouldBeNil)
+				So(r.filters, ShouldResemble, []*elemental.Filter{nil})
+			})
+		})
+
+		Convey("When I call Create", func() {
+
+			err := m.Create(NewContext(context.Background()), testmodel.NewList())
+
+			Convey("Then the scope should not have been set", func() {
+				So(err, ShouldBeNil)
+				So(r.filters, ShouldResemble, []*elemental.Filter{nil})
+			})
+		})
+	})
+}
@@ -0,0 +1,101 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manipulate
+
+import (
+	"time"
+
+	"go.aporeto.io/elemental"
+)
+
+// A SlowOperationFunc is the type of function that is called
+// when an operation took longer than expected.
+type SlowOperationFunc func(operation elemental.Operation, identity elemental.Identity, duration time.Duration)
+
+// A slowLogManipulator reports the operations
+// that are slower than a threshold.
+type slowLogManipulator struct {
+	transactionForwarder
+
+	manipulator Manipulator
+	threshold   time.Duration
+	callback    SlowOperationFunc
+}
+
+// NewSlowLogManipulator returns a TransactionalManipulator that wraps the
+// given manipulator and calls the given callback, once the operation returned,
+// for every operation that took longer than the given threshold, whether it
+// succeeded or not. The callback is called synchronously, so it should not block.
+// DeleteMany is reported as elemental.OperationDelete, and Count as
+// elemental.OperationInfo.
+//
+// Commit and Abort are not timed. They only reach the wrapped
+// manipulator if it supports transactions.
+func NewSlowLogManipulator(manipulator Manipulator, threshold time.Duration, callback SlowOperationFunc) TransactionalManipulator {
+
+	if manipulator == nil {
+		panic("manipulator must not be nil")
+	}
+
+	if callback == nil {
+		panic("callback must not be nil")
+	}
+
+	return &slowLogManipulator{
+		transactionForwarder: transactionForwarder{m: manipulator},
+		manipulator:          manipulator,
+		threshold:            threshold,
+		callback:             callback,
+	}
+}
+
+func (m *slowLogManipulator) RetrieveMany(mctx Context, dest elemental.Identifiables) error {
+	defer m.time(elemental.OperationRetrieveMany, dest.Identity(), time.Now())
+	return m.manipulator.RetrieveMany(mctx, dest)
+}
+
+func (m *slowLogManipulator) Retrieve(mctx Context, object elemental.Identifiable) error {
+	defer m.time(elemental.OperationRetrieve, object.Identity(), time.Now())
+	return m.manipulator.Retrieve(mctx, object)
+}
+
+func (m *slowLogManipulator) Create(mctx Context, object elemental.Identifiable) error {
+	defer m.time(elemental.OperationCreate, object.Identity(), time.Now())
+	return m.manipulator.Create(mctx, object)
+}
+
+func (m *slowLogManipulator) Update(mctx Context, object elemental.Identifiable) error {
+	defer m.time(elemental.OperationUpdate, object.Identity(), time.Now())
+	return m.manipulator.Update(mctx, object)
+}
+
+func (m *slowLogManipulator) Delete(mctx Context, object elemental.Identifiable) error {
+	defer m.time(elemental.OperationDelete, object.Identity(), time.Now())
+	return m.manipulator.Delete(mctx, object)
+}
+
+func (m *slowLogManipulator) DeleteMany(mctx Context, identity elemental.Identity) error {
+	defer m.time(elemental.OperationDelete, identity, time.Now())
+	return m.manipulator.DeleteMany(mctx, identity)
+}
+
+func (m *slowLogManipulator) Count(mctx Context, identity elemental.Identity) (int, error) {
+	defer m.time(elemental.OperationInfo, identity, time.Now())
+	return m.manipulator.Count(mctx, identity)
+}
+
+func (m *slowLogManipulator) time(operation elemental.Operation, identity elemental.Identity, start time.Time) {
+
+	if d := time.Since(start); d > m.threshold {
+		m.callback(operation, identity, d)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manipulate
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.aporeto.io/elemental"
+	testmodel "go.aporeto.io/elemental/test/model"
+)
+
+func TestNewSlowLogManipulator(t *testing.T) {
+
+	cb := func(elemental.Operation, elemental.Identity, time.Duration) {}
+
+	Convey("Given I call NewSlowLogManipulator with a nil manipulator", t, func() {
+		So(func() { NewSlowLogManipulator(nil, time.Second, cb) }, ShouldPanicWith, "manipulator must not be nil")
+	})
+
+	Convey("Given I call NewSlowLogManipulator with a nil callback", t, func() {
+		So(func() { NewSlowLogManipulator(&recordingManipulator{}, time.Second, nil) }, ShouldPanicWith, "callback must not be nil")
+	})
+
+	Convey("Given I have a slow log manipulator", t, func() {
+
+		var operations []elemental.Operation
+		var identities []elemental.Identity
+		var durations []time.Duration
+
+		record := func(operation elemental.Operation, identity elemental.Identity, duration time.Duration) {
+			operations = append(operations, operation)
+			identities = append(identities, identity)
+			durations = append(durations, duration)
+		}
+
+		Convey("When an operation is faster than the threshold", func() {
+
+			r := &recordingManipulator{}
+			m := NewSlowLogManipulator(r, time.Minute, record)
+			err := m.Create(nil, testmodel.NewList())
+
+			Convey("Then the call should have been forwarded", func() {
+				So(err, ShouldBeNil)
+				So(r.calls, ShouldResemble, []string{"create"})
+			})
+
+			Convey("Then the callback should not have been called", func() {
+				So(operations, ShouldBeEmpty)
+			})
+		})
+
+		Convey("When an operation is slower than the threshold", func() {
+
+			r := &slowManipulator{delay: 5 * time.Millisecond}
+			m := NewSlowLogManipulator(r, time.Millisecond, record)
+			err := m.RetrieveMany(NewContext(context.Background()), testmodel.ListsList{})
+
+			Convey("Then err should be nil", func() {
+				So(err, ShouldBeNil)
+			})
+
+			Convey("Then the callback should have been called", func() {
+				So(operations, ShouldResemble, []elemental.Operation{elemental.OperationRetrieveMany})
+				So(identities, ShouldResemble, []elemental.Identity{testmodel.ListIdentity})
+				So(durations[0], ShouldBeGreaterThanOrEqualTo, 5*time.Millisecond)
+			})
+		})
+	})
+}
@@ -0,0 +1,77 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manipulate
+
+import "go.aporeto.io/elemental"
+
+// A splitManipulator dispatches read operations to a reader
+// and write operations to a writer.
+type splitManipulator struct {
+	transactionForwarder
+
+	reader Manipulator
+	writer Manipulator
+}
+
+// NewSplitManipulator returns a TransactionalManipulator that sends
+// read operations (Retrieve, RetrieveMany and Count) to the given reader,
+// and write operations (Create, Update, Delete and DeleteMany) to the
+// given writer. Commit and Abort are sent to the writer if it is a
+// TransactionalManipulator. Otherwise, Commit does nothing and Abort
+// returns false.
+//
+// This is useful to compose manipulators reading from a replica and
+// writing to a primary.
+func NewSplitManipulator(reader Manipulator, writer Manipulator) TransactionalManipulator {
+
+	if reader == nil {
+		panic("reader must not be nil")
+	}
+
+	if writer == nil {
+		panic("writer must not be nil")
+	}
+
+	return &splitManipulator{
+		transactionForwarder: transactionForwarder{m: writer},
+		reader:               reader,
+		writer:               writer,
+	}
+}
+
+func (m *splitManipulator) RetrieveMany(mctx Context, dest elemental.Identifiables) error {
+	return m.reader.RetrieveMany(mctx, dest)
+}
+
+func (m *splitManipulator) Retrieve(mctx Context, object elemental.Identifiable) error {
+	return m.reader.Retrieve(mctx, object)
+}
+
+func (m *splitManipulator) Count(mctx Context, identity elemental.Identity) (int, error) {
+	return m.reader.Count(mctx, identity)
+}
+
+func (m *splitManipulator) Create(mctx Context, object elemental.Identifiable) error {
+	return m.writer.Create(mctx, object)
+}
+
+func (m *splitManipulator) Update(mctx Context, object elemental.Identifiable) error {
+	return m.writer.Update(mctx, object)
+}
+
+func (m *splitManipulator) Delete(mctx Context, object elemental.Identifiable) error {
+	return m.writer.Delete(mctx, object)
+}
+
+func (m *splitManipulator) DeleteMany(mctx Context, identity elemental.Identity) error {
+	return m.writer.DeleteMany(mctx, identity)
+}
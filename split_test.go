@@ -0,0 +1,124 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manipulate
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.aporeto.io/elemental"
+	testmodel "go.aporeto.io/elemental/test/model"
+)
+
+// A recordingManipulator records the operations it receives
+// and returns the configured error.
+type recordingManipulator struct {
+	calls []string
+	err   error
+}
+
+func (m *recordingManipulator) RetrieveMany(mctx Context, dest elemental.Identifiables) error {
+	m.calls = append(m.calls, "retrievemany")
+	return m.err
+}
+
+func (m *recordingManipulator) Retrieve(mctx Context, object elemental.Identifiable) error {
+	m.calls = append(m.calls, "retrieve")
+	return m.err
+}
+
+func (m *recordingManipulator) Create(mctx Context, object elemental.Identifiable) error {
+	m.calls = append(m.calls, "create")
+	return m.err
+}
+
+func (m *recordingManipulator) Update(mctx Context, object elemental.Identifiable) error {
+	m.calls = append(m.calls, "update")
+	return m.err
+}
+
+func (m *recordingManipulator) Delete(mctx Context, object elemental.Identifiable) error {
+	m.calls = append(m.calls, "delete")
+	return m.err
+}
+
+func (m *recordingManipulator) DeleteMany(mctx Context, identity elemental.Identity) error {
+	m.calls = append(m.calls, "deletemany")
+	return m.err
+}
+
+func (m *recordingManipulator) Count(mctx Context, identity elemental.Identity) (int, error) {
+	m.calls = append(m.calls, "count")
+	return 0, m.err
+}
+
+func (m *recordingManipulator) Commit(id TransactionID) error {
+	m.calls = append(m.calls, "commit")
+	return m.err
+}
+
+func (m *recordingManipulator) Abort(id TransactionID) bool {
+	m.calls = append(m.calls, "abort")
+	return true
+}
+
+func TestNewSplitManipulator(t *testing.T) {
+
+	Convey("Given I call NewSplitManipulator with a nil reader", t, func() {
+		So(func() { NewSplitManipulator(nil, &recordingManipulator{}) }, ShouldPanicWith, "reader must not be nil")
+	})
+
+	Convey("Given I call NewSplitManipulator with a nil writer", t, func() {
+		So(func() { NewSplitManipulator(&recordingManipulator{}, nil) }, ShouldPanicWith, "writer must not be nil")
+	})
+
+	Convey("Given I have a split manipulator", t, func() {
+
+		r := &recordingManipulator{}
+		w := &recordingManipulator{}
+		m := NewSplitManipulator(r, w)
+
+		Convey("When I call all the methods", func() {
+
+			_ = m.RetrieveMany(nil, testmodel.ListsList{})
+			_ = m.Retrieve(nil, testmodel.NewList())
+			_, _ = m.Count(nil, testmodel.ListIdentity)
+			_ = m.Create(nil, testmodel.NewList())
+			_ = m.Update(nil, testmodel.NewList())
+			_ = m.Delete(nil, testmodel.NewList())
+			_ = m.DeleteMany(nil, testmodel.ListIdentity)
+			_ = m.Commit("x")
+			_ = m.Abort("x")
+
+			Convey("Then the reads should have been sent to the reader", func() {
+				So(r.calls, ShouldResemble, []string{"retrievemany", "retrieve", "count"})
+			})
+
+			Convey("Then the writes should have been sent to the writer", func() {
+				So(w.calls, ShouldResemble, []string{"create", "update", "delete", "deletemany", "commit", "abort"})
+			})
+		})
+	})
+
+	Convey("Given I have a split manipulator with a non transactional writer", t, func() {
+
+		m := NewSplitManipulator(&recordingManipulator{}, &testManipulator{})
+
+		Convey("Then Commit should do nothing", func() {
+			So(m.Commit("x"), ShouldBeNil)
+		})
+
+		Convey("Then Abort should return false", func() {
+			So(m.Abort("x"), ShouldBeFalse)
+		})
+	})
+}
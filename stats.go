@@ -0,0 +1,41 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package manipulate
+
+import "go.aporeto.io/elemental"
+
+// Stats holds the counters of a manipulator since its creation.
+type Stats struct {
+
+	// Operations is the number of operations run, by type.
+	Operations map[elemental.Operation]int64
+
+	// Errors is the number of operations that returned an error.
+	Errors int64
+
+	// Retries is the number of times an operation has been retried.
+	Retries int64
+
+	// CacheHits is the number of read operations served
+	// from a cache, for the manipulators having one.
+	CacheHits int64
+
+	// CacheMisses is the number of read operations forwarded
+	// to a backend, for the manipulators having a cache.
+	CacheMisses int64
+}
+
+// A StatsProvider is a manipulator that can report its Stats.
+// It is a lightweight alternative to a metrics decorator that does
+// not require any monitoring dependency.
+type StatsProvider interface {
+	Stats() Stats
+}
@@ -0,0 +1,133 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manipulate
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.aporeto.io/elemental"
+)
+
+// A timeoutManipulator bounds the duration of
+// every operation it forwards.
+type timeoutManipulator struct {
+	transactionForwarder
+
+	manipulator Manipulator
+	timeout     time.Duration
+}
+
+// NewTimeoutManipulator returns a TransactionalManipulator that wraps the
+// given manipulator and bounds the duration of every operation to the given
+// timeout. The operation receives a derived Context whose context.Context
+// expires after the timeout. If the operation does not return in time, an
+// ErrCannotExecuteQuery is returned without waiting for the wrapped manipulator,
+// even if it does not support contexts.
+//
+// As the operation may still be running in the background after a timeout,
+// the objects given to it must not be reused until it returns. The values that
+// are part of the response, like Count, Next or Messages, are only reported
+// back in the given Context if the operation completed in time.
+//
+// Commit and Abort take no Context, so they run without deadline on the
+// wrapped manipulator, provided it is a TransactionalManipulator.
+func NewTimeoutManipulator(manipulator Manipulator, timeout time.Duration) TransactionalManipulator {
+
+	if manipulator == nil {
+		panic("manipulator must not be nil")
+	}
+
+	if timeout <= 0 {
+		panic("timeout must be greater than 0")
+	}
+
+	return &timeoutManipulator{
+		transactionForwarder: transactionForwarder{m: manipulator},
+		manipulator:          manipulator,
+		timeout:              timeout,
+	}
+}
+
+func (m *timeoutManipulator) RetrieveMany(mctx Context, dest elemental.Identifiables) error {
+	return m.do(mctx, func(mctx Context) error { return m.manipulator.RetrieveMany(mctx, dest) })
+}
+
+func (m *timeoutManipulator) Retrieve(mctx Context, object elemental.Identifiable) error {
+	return m.do(mctx, func(mctx Context) error { return m.manipulator.Retrieve(mctx, object) })
+}
+
+func (m *timeoutManipulator) Create(mctx Context, object elemental.Identifiable) error {
+	return m.do(mctx, func(mctx Context) error { return m.manipulator.Create(mctx, object) })
+}
+
+func (m *timeoutManipulator) Update(mctx Context, object elemental.Identifiable) error {
+	return m.do(mctx, func(mctx Context) error { return m.manipulator.Update(mctx, object) })
+}
+
+func (m *timeoutManipulator) Delete(mctx Context, object elemental.Identifiable) error {
+	return m.do(mctx, func(mctx Context) error { return m.manipulator.Delete(mctx, object) })
+}
+
+func (m *timeoutManipulator) DeleteMany(mctx Context, identity elemental.Identity) error {
+	return m.do(mctx, func(mctx Context) error { return m.manipulator.DeleteMany(mctx, identity) })
+}
+
+func (m *timeoutManipulator) Count(mctx Context, identity elemental.Identity) (int, error) {
+
+	var n int
+	err := m.do(mctx, func(mctx Context) (err error) {
+		n, err = m.manipulator.Count(mctx, identity)
+		return err
+	})
+
+	return n, err
+}
+
+func (m *timeoutManipulator) do(mctx Context, operation func(Context) error) error {
+
+	if mctx == nil {
+		mctx = NewContext(context.Background())
+	}
+
+	ctx, cancel := context.WithTimeout(mctx.Context(), m.timeout)
+	defer cancel()
+
+	dctx := mctx.Derive(contextOptionContext(ctx))
+
+	done := make(chan error, 1)
+	go func() { done <- operation(dctx) }()
+
+	select {
+
+	case err := <-done:
+
+		mctx.SetCount(dctx.Count())
+		mctx.SetMessages(dctx.Messages())
+		if next := dctx.Next(); next != "" {
+			mctx.SetNext(next)
+		}
+
+		return err
+
+	case <-ctx.Done():
+		return NewErrCannotExecuteQuery(fmt.Sprintf("operation did not complete in %s: %s", m.timeout, ctx.Err()))
+	}
+}
+
+// contextOptionContext sets the context.Context of the Context.
+func contextOptionContext(ctx context.Context) ContextOption {
+	return func(c Context) {
+		c.(*mcontext).ctx = ctx
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manipulate
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.aporeto.io/elemental"
+	testmodel "go.aporeto.io/elemental/test/model"
+)
+
+// A slowManipulator blocks for the given delay, ignoring the context.
+type slowManipulator struct {
+	recordingManipulator
+	delay    time.Duration
+	deadline bool
+}
+
+func (m *slowManipulator) RetrieveMany(mctx Context, dest elemental.Identifiables) error {
+	_, m.deadline = mctx.Context().Deadline()
+	time.Sleep(m.delay)
+	mctx.SetCount(42)
+	mctx.SetNext("next")
+	return nil
+}
+
+func TestNewTimeoutManipulator(t *testing.T) {
+
+	Convey("Given I call NewTimeoutManipulator with a nil manipulator", t, func() {
+		So(func() { NewTimeoutManipulator(nil, time.Second) }, ShouldPanicWith, "manipulator must not be nil")
+	})
+
+	Convey("Given I call NewTimeoutManipulator with an invalid timeout", t, func() {
+		So(func() { NewTimeoutManipulator(&recordingManipulator{}, 0) }, ShouldPanicWith, "timeout must be greater than 0")
+	})
+
+	Convey("Given I have a timeout manipulator wrapping a fast manipulator", t, func() {
+
+		r := &slowManipulator{}
+		m := NewTimeoutManipulator(r, time.Second)
+
+		Convey("When I call RetrieveMany", func() {
+
+			mctx := NewContext(context.Background())
+			err := m.RetrieveMany(mctx, testmodel.ListsList{})
+
+			Convey("Then err should be nil", func() {
+				So(err, ShouldBeNil)
+			})
+
+			Convey("Then the wrapped manipulator should have received a deadline", func() {
+				So(r.deadline, ShouldBeTrue)
+			})
+
+			Convey("Then the response values should be reported", func() {
+				So(mctx.Count(), ShouldEqual, 42)
+				So(mctx.Next(), ShouldEqual, "next")
+			})
+
+			Convey("Then the given context should have no deadline", func() {
+				_, ok := mctx.Context().Deadline()
+				So(ok, ShouldBeFalse)
+			})
+		})
+
+		Convey("When I call Delete with no context", func() {
+
+			err := m.Delete(nil, testmodel.NewList())
+
+			Convey("Then the call should have been forwarded", func() {
+				So(err, ShouldBeNil)
+				So(r.calls, ShouldResemble, []string{"delete"})
+			})
+		})
+	})
+
+	Convey("Given I have a timeout manipulator wrapping a slow manipulator", t, func() {
+
+		m := NewTimeoutManipulator(&slowManipulator{delay: time.Second}, 10*time.Millisecond)
+
+		Convey("When I call RetrieveMany", func() {
+
+			mctx := NewContext(context.Background())
+			err := m.RetrieveMany(mctx, testmodel.ListsList{})
+
+			Convey("Then err should be correct", func() {
+				So(IsCannotExecuteQueryError(err), ShouldBeTrue)
+				So(err.Error(), ShouldEqual, "Unable to execute query: operation did not complete in 10ms: context deadline exceeded")
+			})
+
+			Convey("Then the response values should not be reported", func() {
+				So(mctx.Count(), ShouldEqual, 0)
+			})
+		})
+	})
+}
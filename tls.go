@@ -0,0 +1,65 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package manipulate
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+)
+
+// A TLSOption configures the tls.Config returned by NewTLSConfig.
+type TLSOption func(*tls.Config)
+
+// TLSOptionRootCAs sets the certificate pool used to verify the
+// certificates of the servers. By default, the system pool is used.
+func TLSOptionRootCAs(pool *x509.CertPool) TLSOption {
+	return func(c *tls.Config) {
+		c.RootCAs = pool
+	}
+}
+
+// TLSOptionCertificates sets the client certificates to present to the servers.
+func TLSOptionCertificates(certs ...tls.Certificate) TLSOption {
+	return func(c *tls.Config) {
+		c.Certificates = certs
+	}
+}
+
+// TLSOptionServerName sets the name used to verify the certificates
+// of the servers, instead of the host they are reached with.
+func TLSOptionServerName(name string) TLSOption {
+	return func(c *tls.Config) {
+		c.ServerName = name
+	}
+}
+
+// TLSOptionInsecureSkipVerify disables the verification of the certificates
+// of the servers. This must only be used for testing.
+func TLSOptionInsecureSkipVerify(skip bool) TLSOption {
+	return func(c *tls.Config) {
+		c.InsecureSkipVerify = skip // #nosec
+	}
+}
+
+// NewTLSConfig returns a new tls.Config configured with the given
+// options. The same configuration can be given to all the backends,
+// using manipmongo.OptionTLS or maniphttp.OptionTLSConfig, so the
+// services using several backends can configure TLS once.
+func NewTLSConfig(options ...TLSOption) *tls.Config {
+
+	c := &tls.Config{}
+
+	for _, o := range options {
+		o(c)
+	}
+
+	return c
+}
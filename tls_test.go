@@ -0,0 +1,51 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package manipulate
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestNewTLSConfig(t *testing.T) {
+
+	Convey("Given I call NewTLSConfig without options", t, func() {
+
+		c := NewTLSConfig()
+
+		Convey("Then the tls config should be empty", func() {
+			So(c, ShouldResemble, &tls.Config{})
+		})
+	})
+
+	Convey("Given I call NewTLSConfig with all options", t, func() {
+
+		pool := x509.NewCertPool()
+		cert := tls.Certificate{Certificate: [][]byte{[]byte("cert")}}
+
+		c := NewTLSConfig(
+			TLSOptionRootCAs(pool),
+			TLSOptionCertificates(cert),
+			TLSOptionServerName("server"),
+			TLSOptionInsecureSkipVerify(true),
+		)
+
+		Convey("Then the tls config should be correct", func() {
+			So(c.RootCAs, ShouldEqual, pool)
+			So(c.Certificates, ShouldResemble, []tls.Certificate{cert})
+			So(c.ServerName, ShouldEqual, "server")
+			So(c.InsecureSkipVerify, ShouldBeTrue)
+		})
+	})
+}
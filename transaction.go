@@ -21,3 +21,29 @@ func NewTransactionID() TransactionID {
 
 	return TransactionID(uuid.Must(uuid.NewV4()).String())
 }
+
+// A transactionForwarder implements the transaction methods of the
+// TransactionalManipulator interface for the manipulators wrapping another
+// one. They are forwarded to the wrapped manipulator if it handles
+// transactions. Otherwise, Commit does nothing and Abort returns false.
+type transactionForwarder struct {
+	m Manipulator
+}
+
+func (f transactionForwarder) Commit(id TransactionID) error {
+
+	if tm, ok := f.m.(TransactionalManipulator); ok {
+		return tm.Commit(id)
+	}
+
+	return nil
+}
+
+func (f transactionForwarder) Abort(id TransactionID) bool {
+
+	if tm, ok := f.m.(TransactionalManipulator); ok {
+		return tm.Abort(id)
+	}
+
+	return false
+}
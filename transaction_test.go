@@ -32,3 +32,37 @@ func TestTransaction_NewTransactionID(t *testing.T) {
 		})
 	})
 }
+
+func TestTransaction_transactionForwarder(t *testing.T) {
+
+	Convey("Given I have a transactionForwarder for a transactional manipulator", t, func() {
+
+		r := &recordingManipulator{}
+		f := transactionForwarder{m: r}
+
+		Convey("When I call Commit and Abort", func() {
+
+			err := f.Commit("x")
+			ok := f.Abort("x")
+
+			Convey("Then they should have been forwarded", func() {
+				So(err, ShouldBeNil)
+				So(ok, ShouldBeTrue)
+				So(r.calls, ShouldResemble, []string{"commit", "abort"})
+			})
+		})
+	})
+
+	Convey("Given I have a transactionForwarder for a non transactional manipulator", t, func() {
+
+		f := transactionForwarder{m: &testManipulator{}}
+
+		Convey("Then Commit should do nothing", func() {
+			So(f.Commit("x"), ShouldBeNil)
+		})
+
+		Convey("Then Abort should return false", func() {
+			So(f.Abort("x"), ShouldBeFalse)
+		})
+	})
+}